@@ -4,6 +4,7 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-chi/chi v3.3.3+incompatible
 	github.com/go-chi/jwtauth v3.3.0+incompatible
+	github.com/gorilla/websocket v1.2.0
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/kwo/exodus v1.0.0
 	github.com/lib/pq v1.0.0
@@ -14,6 +15,7 @@ require (
 	github.com/urfave/cli v1.20.1-0.20180821064027-934abfb2f102
 	golang.org/x/crypto v0.0.0-20181030102418-4d3f4d9ffa16
 	golang.org/x/net v0.0.0-20180926154720-4dfa2610cdf3 // indirect
+	golang.org/x/sys v0.7.0 // indirect
 	google.golang.org/appengine v1.3.0 // indirect
 	gopkg.in/gorp.v1 v1.7.2 // indirect
 )
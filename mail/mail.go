@@ -0,0 +1,61 @@
+// Package mail delivers mail and notifications sent by the services.
+// Only development implementations exist: mail is either logged or appended to a file.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// LogMailer writes every message to the log instead of sending it.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (z *LogMailer) Send(ctx context.Context, msg model.MailMessage) error {
+	logger := logging.New(ctx, "mail", "LogMailer")
+	logger.Info().Str("to", msg.To).Str("subject", msg.Subject).Str("body", msg.Body).Msg("mail")
+	return nil
+}
+
+// FileMailer appends every message to a file in mbox format.
+type FileMailer struct {
+	lock     sync.Mutex
+	filename string
+}
+
+func NewFileMailer(filename string) *FileMailer {
+	return &FileMailer{
+		filename: filename,
+	}
+}
+
+func (z *FileMailer) Send(ctx context.Context, msg model.MailMessage) error {
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	f, errOpen := os.OpenFile(z.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if errOpen != nil {
+		return errOpen
+	}
+
+	now := time.Now()
+	_, errWrite := fmt.Fprintf(f, "From wallawire %s\nDate: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		now.Format(time.ANSIC), now.Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if errWrite != nil {
+		f.Close()
+		return errWrite
+	}
+
+	return f.Close()
+
+}
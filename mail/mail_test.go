@@ -0,0 +1,87 @@
+package mail_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wallawire/mail"
+	"wallawire/model"
+)
+
+func TestFileMailer(t *testing.T) {
+
+	dir, errDir := ioutil.TempDir("", "mail")
+	if errDir != nil {
+		t.Fatal(errDir)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "mbox")
+	mailer := mail.NewFileMailer(filename)
+
+	messages := []model.MailMessage{
+		{To: "one@example.com", Subject: "First", Body: "first body"},
+		{To: "two@example.com", Subject: "Second", Body: "second body"},
+	}
+	for _, msg := range messages {
+		if err := mailer.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Bad send error: %s", err)
+		}
+	}
+
+	data, errRead := ioutil.ReadFile(filename)
+	if errRead != nil {
+		t.Fatal(errRead)
+	}
+	mbox := string(data)
+
+	if got, want := strings.Count(mbox, "From wallawire "), len(messages); got != want {
+		t.Errorf("Bad message count: %d, expected %d", got, want)
+	}
+	for _, expected := range []string{"To: one@example.com\n", "Subject: First\n", "\n\nfirst body\n", "To: two@example.com\n", "\n\nsecond body\n"} {
+		if !strings.Contains(mbox, expected) {
+			t.Errorf("Missing %q in %s", expected, mbox)
+		}
+	}
+
+}
+
+func TestLogMailer(t *testing.T) {
+	if err := mail.NewLogMailer().Send(context.Background(), model.MailMessage{To: "one@example.com"}); err != nil {
+		t.Errorf("Bad send error: %s", err)
+	}
+}
+
+type MailerMock struct {
+	Sent []model.MailMessage
+}
+
+func (z *MailerMock) Send(ctx context.Context, msg model.MailMessage) error {
+	z.Sent = append(z.Sent, msg)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+
+	mailer := &MailerMock{}
+	notifier := mail.NewNotifier(mailer)
+
+	if err := notifier.Notify(context.Background(), model.User{Username: "nomail"}, "Subject", "Body"); err != mail.ErrNoAddress {
+		t.Errorf("Bad notify error: %v, expected %v", err, mail.ErrNoAddress)
+	}
+
+	if err := notifier.Notify(context.Background(), model.User{Username: "demouser", Email: "demouser@example.com"}, "Subject", "Body"); err != nil {
+		t.Fatalf("Bad notify error: %s", err)
+	}
+
+	expected := []model.MailMessage{{To: "demouser@example.com", Subject: "Subject", Body: "Body"}}
+	if !reflect.DeepEqual(mailer.Sent, expected) {
+		t.Errorf("Bad sent: %v, expected %v", mailer.Sent, expected)
+	}
+
+}
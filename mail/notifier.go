@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"errors"
+
+	"wallawire/model"
+)
+
+var (
+	ErrNoAddress = errors.New("user has no email address")
+)
+
+type Mailer interface {
+	Send(context.Context, model.MailMessage) error
+}
+
+// Notifier notifies users by mail at their email address.
+type Notifier struct {
+	mailer Mailer
+}
+
+func NewNotifier(mailer Mailer) *Notifier {
+	return &Notifier{
+		mailer: mailer,
+	}
+}
+
+func (z *Notifier) Notify(ctx context.Context, user model.User, subject, body string) error {
+	if len(user.Email) == 0 {
+		return ErrNoAddress
+	}
+	return z.mailer.Send(ctx, model.MailMessage{
+		To:      user.Email,
+		Subject: subject,
+		Body:    body,
+	})
+}
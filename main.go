@@ -3,12 +3,16 @@ package main
 //go:generate go run tools/webui/generate.go
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,26 +21,36 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
 
 	"wallawire/idgen"
 	"wallawire/logging"
+	"wallawire/mail"
 	"wallawire/model"
 	"wallawire/repository"
 	"wallawire/schema"
 	"wallawire/services"
 	"wallawire/services/push"
 	"wallawire/web"
+	"wallawire/web/admin"
+	"wallawire/web/apitoken"
 	"wallawire/web/auth"
+	"wallawire/web/csp"
+	"wallawire/web/middleware"
 	"wallawire/web/router"
+	"wallawire/web/session"
 	"wallawire/web/sse"
 	"wallawire/web/static"
 	"wallawire/web/status"
 	"wallawire/web/user"
+	"wallawire/web/ws"
 )
 
 const (
-	ServiceName         = "wallawire"
-	dbConnectionTimeout = time.Minute * 2
+	ServiceName          = "wallawire"
+	dbConnectionTimeout  = time.Minute * 2
+	oidcDiscoveryTimeout = time.Second * 30
+	inboxRetryAfter      = time.Second * 30
 )
 
 var (
@@ -84,6 +98,38 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "audit-export",
+			Usage:  "export the security audit log as JSON Lines, most recent first",
+			Action: exportAudit,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "postgres-url",
+					EnvVar: "WALLAWIRE_POSTGRES_URL",
+					Usage:  "URL with which to connect to postgres",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "file to write to, standard output if not given",
+				},
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "export events at or after this time (RFC 3339)",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "export events before this time (RFC 3339)",
+				},
+				cli.StringFlag{
+					Name:  "actor",
+					Usage: "export events of this actor user ID",
+				},
+				cli.StringFlag{
+					Name:  "action",
+					Usage: "export events of this action",
+				},
+			},
+		},
 	}
 
 	app.Flags = []cli.Flag{
@@ -120,7 +166,17 @@ func main() {
 		cli.StringFlag{
 			Name:   "token-password",
 			EnvVar: "WALLAWIRE_TOKEN_PASSWORD",
-			Usage:  "password to sign JWT tokens",
+			Usage:  "password to sign JWT tokens (HS256), only verifies tokens if token-key is given",
+		},
+		cli.StringFlag{
+			Name:   "token-key",
+			EnvVar: "WALLAWIRE_TOKEN_KEY",
+			Usage:  "PEM private key file to sign JWT tokens, RSA (RS256) or Ed25519 (EdDSA)",
+		},
+		cli.StringSliceFlag{
+			Name:   "token-verify-key",
+			EnvVar: "WALLAWIRE_TOKEN_VERIFY_KEYS",
+			Usage:  "PEM public key file of a previous signing key whose tokens are still accepted, can be repeated",
 		},
 		cli.StringFlag{
 			Name:   "postgres-url",
@@ -137,6 +193,191 @@ func main() {
 			EnvVar: "WALLAWIRE_LOG_PRETTY",
 			Usage:  "pretty print log messages to console",
 		},
+		cli.BoolFlag{
+			Name:   "registration",
+			EnvVar: "WALLAWIRE_REGISTRATION",
+			Usage:  "allow self-service registration with email verification",
+		},
+		cli.StringFlag{
+			Name:   "public-url",
+			EnvVar: "WALLAWIRE_PUBLIC_URL",
+			Usage:  "public base URL of the server used in links sent by mail, defaults to https://server-addr",
+		},
+		cli.StringFlag{
+			Name:   "mail-file",
+			EnvVar: "WALLAWIRE_MAIL_FILE",
+			Usage:  "append outgoing mail to the given file instead of logging it",
+		},
+		cli.IntFlag{
+			Name:   "password-min-length",
+			EnvVar: "WALLAWIRE_PASSWORD_MIN_LENGTH",
+			Value:  model.DefaultPasswordPolicy.MinLength,
+			Usage:  "minimum number of characters of new passwords",
+		},
+		cli.IntFlag{
+			Name:   "password-min-classes",
+			EnvVar: "WALLAWIRE_PASSWORD_MIN_CLASSES",
+			Usage:  "number of character classes required in new passwords: lowercase, uppercase, digits and symbols",
+		},
+		cli.StringFlag{
+			Name:   "password-banned-file",
+			EnvVar: "WALLAWIRE_PASSWORD_BANNED_FILE",
+			Usage:  "file with passwords that cannot be used, one per line",
+		},
+		cli.IntFlag{
+			Name:   "password-history",
+			EnvVar: "WALLAWIRE_PASSWORD_HISTORY",
+			Usage:  "number of previous passwords that cannot be reused",
+		},
+		cli.DurationFlag{
+			Name:   "password-max-age",
+			EnvVar: "WALLAWIRE_PASSWORD_MAX_AGE",
+			Usage:  "if not zero, refuse login with older passwords until they are reset",
+		},
+		cli.StringFlag{
+			Name:   "password-hash",
+			EnvVar: "WALLAWIRE_PASSWORD_HASH",
+			Value:  model.DefaultPasswordHashing.Algorithm,
+			Usage:  "algorithm of new password hashes: argon2id or bcrypt, older hashes are replaced at login",
+		},
+		cli.IntFlag{
+			Name:   "password-bcrypt-cost",
+			EnvVar: "WALLAWIRE_PASSWORD_BCRYPT_COST",
+			Value:  model.DefaultPasswordHashing.BcryptCost,
+			Usage:  "cost of bcrypt password hashes",
+		},
+		cli.StringFlag{
+			Name:   "oidc-issuer",
+			EnvVar: "WALLAWIRE_OIDC_ISSUER",
+			Usage:  "if not empty, allow login at the given OpenID Connect provider",
+		},
+		cli.StringFlag{
+			Name:   "oidc-client-id",
+			EnvVar: "WALLAWIRE_OIDC_CLIENT_ID",
+			Usage:  "client ID registered at the OpenID Connect provider",
+		},
+		cli.StringFlag{
+			Name:   "oidc-client-secret",
+			EnvVar: "WALLAWIRE_OIDC_CLIENT_SECRET",
+			Usage:  "client secret registered at the OpenID Connect provider, empty for a public client",
+		},
+		cli.StringFlag{
+			Name:   "oidc-scopes",
+			EnvVar: "WALLAWIRE_OIDC_SCOPES",
+			Value:  "email profile",
+			Usage:  "scopes requested in addition to openid, separated by spaces",
+		},
+		cli.BoolFlag{
+			Name:   "oidc-provisioning",
+			EnvVar: "WALLAWIRE_OIDC_PROVISIONING",
+			Usage:  "create users with the user role at their first OpenID Connect login",
+		},
+		cli.StringFlag{
+			Name:   "ratelimit-login",
+			EnvVar: "WALLAWIRE_RATELIMIT_LOGIN",
+			Value:  "5/1m",
+			Usage:  "login attempts allowed per IP address as requests/duration",
+		},
+		cli.StringFlag{
+			Name:   "ratelimit-account",
+			EnvVar: "WALLAWIRE_RATELIMIT_ACCOUNT",
+			Value:  "5/10m",
+			Usage:  "password reset and registration requests allowed per IP address as requests/duration",
+		},
+		cli.StringFlag{
+			Name:   "ratelimit-change",
+			EnvVar: "WALLAWIRE_RATELIMIT_CHANGE",
+			Value:  "10/1m",
+			Usage:  "changes of username, password and profile allowed per user as requests/duration",
+		},
+		cli.IntFlag{
+			Name:   "inbox-max-connections",
+			EnvVar: "WALLAWIRE_INBOX_MAX_CONNECTIONS",
+			Value:  5,
+			Usage:  "open notification streams allowed per user",
+		},
+		cli.IntFlag{
+			Name:   "push-queue-size",
+			EnvVar: "WALLAWIRE_PUSH_QUEUE_SIZE",
+			Value:  push.DefaultOptions.QueueSize,
+			Usage:  "messages queued per notification stream",
+		},
+		cli.StringFlag{
+			Name:   "push-overflow",
+			EnvVar: "WALLAWIRE_PUSH_OVERFLOW",
+			Value:  push.DefaultOptions.Policy,
+			Usage:  "what to do when a stream queue is full: drop-oldest, drop-newest or evict",
+		},
+		cli.IntFlag{
+			Name:   "push-max-dropped",
+			EnvVar: "WALLAWIRE_PUSH_MAX_DROPPED",
+			Value:  push.DefaultOptions.MaxDropped,
+			Usage:  "disconnect a stream that has dropped this many messages in a row, 0 never",
+		},
+		cli.IntFlag{
+			Name:   "push-replay-size",
+			EnvVar: "WALLAWIRE_PUSH_REPLAY_SIZE",
+			Value:  100,
+			Usage:  "last messages kept per user to replay to reconnecting streams, 0 disables replay",
+		},
+		cli.BoolFlag{
+			Name:   "push-replay-persist",
+			EnvVar: "WALLAWIRE_PUSH_REPLAY_PERSIST",
+			Usage:  "keep the messages to replay in the database instead of memory, so that they survive a restart",
+		},
+		cli.StringFlag{
+			Name:   "push-broker",
+			EnvVar: "WALLAWIRE_PUSH_BROKER",
+			Value:  "memory",
+			Usage:  "fan-out of push messages: memory for a single instance, database to reach the clients of all instances by polling a table, postgres with LISTEN/NOTIFY (not supported by CockroachDB)",
+		},
+		cli.DurationFlag{
+			Name:   "push-broker-interval",
+			EnvVar: "WALLAWIRE_PUSH_BROKER_INTERVAL",
+			Value:  time.Second,
+			Usage:  "how often the instances poll the messages published by the others, with the database broker",
+		},
+		cli.StringFlag{
+			Name:   "push-broker-channel",
+			EnvVar: "WALLAWIRE_PUSH_BROKER_CHANNEL",
+			Value:  "wallawire_push",
+			Usage:  "channel the instances publish the push messages to, with the postgres broker",
+		},
+		cli.StringFlag{
+			Name:   "security-csp",
+			EnvVar: "WALLAWIRE_SECURITY_CSP",
+			Value:  middleware.DefaultSecurityOptions.ContentSecurityPolicy,
+			Usage:  "Content-Security-Policy, {nonce} is replaced by a new nonce per response, empty to omit the header",
+		},
+		cli.StringFlag{
+			Name:   "security-frame-ancestors",
+			EnvVar: "WALLAWIRE_SECURITY_FRAME_ANCESTORS",
+			Value:  middleware.DefaultSecurityOptions.FrameAncestors,
+			Usage:  "sources allowed to embed the pages in frames, added to the Content-Security-Policy",
+		},
+		cli.BoolTFlag{
+			Name:   "security-csp-report",
+			EnvVar: "WALLAWIRE_SECURITY_CSP_REPORT",
+			Usage:  "ask browsers to report Content-Security-Policy violations, which are logged",
+		},
+		cli.DurationFlag{
+			Name:   "security-hsts-max-age",
+			EnvVar: "WALLAWIRE_SECURITY_HSTS_MAX_AGE",
+			Value:  middleware.DefaultSecurityOptions.HSTSMaxAge,
+			Usage:  "max-age of Strict-Transport-Security sent over HTTPS, zero to omit the header",
+		},
+		cli.StringFlag{
+			Name:   "security-referrer-policy",
+			EnvVar: "WALLAWIRE_SECURITY_REFERRER_POLICY",
+			Value:  middleware.DefaultSecurityOptions.ReferrerPolicy,
+			Usage:  "Referrer-Policy, empty to omit the header",
+		},
+		cli.StringFlag{
+			Name:   "security-permissions-policy",
+			EnvVar: "WALLAWIRE_SECURITY_PERMISSIONS_POLICY",
+			Value:  middleware.DefaultSecurityOptions.PermissionsPolicy,
+			Usage:  "Permissions-Policy, empty to omit the header",
+		},
 		cli.StringFlag{
 			Name:   "ui-local-path",
 			EnvVar: "WALLAWIRE_UI_LOCAL_PATH",
@@ -217,6 +458,77 @@ func migrate(c *cli.Context) error {
 
 }
 
+func exportAudit(c *cli.Context) error {
+
+	logger := logging.New(nil, "main", "exportAudit")
+
+	filter := model.AuditFilter{
+		ActorID: c.String("actor"),
+		Action:  c.String("action"),
+	}
+	for _, name := range []string{"from", "to"} {
+		value := c.String(name)
+		if len(value) == 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("bad %s: %s", name, err)
+		}
+		if name == "from" {
+			filter.From = &t
+		} else {
+			filter.To = &t
+		}
+	}
+
+	out := os.Stdout
+	if filename := c.String("output"); len(filename) != 0 {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				logger.Warn().Err(err).Msg("cannot close output")
+			}
+		}()
+		out = f
+	}
+
+	dbx, errConnect := connectDatabase(c, logger)
+	if errConnect != nil {
+		return errConnect
+	}
+	defer func() {
+		if err := dbx.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close database")
+		}
+	}()
+
+	auditService := services.NewAuditService(repository.NewDatabase(dbx), repository.New(idgen.NewUUIDGenerator()), idgen.NewIdGenerator())
+
+	w := bufio.NewWriter(out)
+	encoder := json.NewEncoder(w) // one event per line
+	count := 0
+	err := auditService.ExportAuditEvents(context.Background(), filter, func(event model.AuditEvent) error {
+		count++
+		return encoder.Encode(event)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("export failed")
+		return err
+	}
+
+	logger.Info().Int("events", count).Msg("done")
+
+	return nil
+
+}
+
 func start(c *cli.Context) error {
 
 	if c.GlobalBool("help") {
@@ -248,7 +560,21 @@ func start(c *cli.Context) error {
 	repo := repository.New(repoid)
 
 	// push messaging
-	pushMessenger := instantiatePushMessenger()
+	pushMessenger, errPush := instantiatePushMessenger(c, sqlDB, repo)
+	if errPush != nil {
+		return errPush
+	}
+	pushBroker, errBroker := instantiatePushBroker(c, db, sqlDB, repo)
+	if errBroker != nil {
+		logger.Error().Err(errBroker).Msg("cannot start push broker")
+		return errBroker
+	}
+	if pushBroker != nil {
+		pushMessenger.SetBroker(pushBroker, idgen.NewIdGenerator().NewID())
+	}
+	pushMessenger.RegisterTopic(model.TopicAudit, func(token model.SessionToken, topic string) bool {
+		return token.HasPermission(model.PermissionReadAudit)
+	})
 	heartbeatService := instantiateHeartbeatService(pushMessenger, stat)
 	pushMessenger.AddOnClientConnectTrigger(func(userID, sessionID string) {
 		heartbeatService.SendHeartbeat(time.Now().Truncate(time.Second), userID, sessionID)
@@ -268,12 +594,38 @@ func start(c *cli.Context) error {
 		assetStore = ls
 	}
 
+	passwordPolicy, errPolicy := loadPasswordPolicy(c)
+	if errPolicy != nil {
+		return errPolicy
+	}
+	passwordHashing, errHashing := loadPasswordHashing(c)
+	if errHashing != nil {
+		return errHashing
+	}
+
 	// services
 	idgenService := idgen.NewIdGenerator()
-	userService := services.NewUserService(sqlDB, repo, idgenService)
+	userService := services.NewUserService(sqlDB, repo, repo, pushMessenger, idgenService)
+	mailer := instantiateMailer(c)
+	userService.SetMailer(mailer, publicURL(c))
+	userService.SetNotifier(mail.NewNotifier(mailer))
+	userService.SetRegistrationEnabled(c.GlobalBool("registration"))
+	userService.SetPasswordPolicy(passwordPolicy)
+	userService.SetPasswordHashing(passwordHashing)
+	userService.SetProvisioningEnabled(c.GlobalBool("oidc-provisioning"))
+	auditService := services.NewAuditService(sqlDB, repo, idgenService)
+	auditService.SetPublisher(pushMessenger)
+	userService.SetAuditor(auditService)
+	sessionService := services.NewSessionService(sqlDB, repo, repo, pushMessenger)
+	sessionService.SetAuditor(auditService)
+	apiTokenService := services.NewAPITokenService(sqlDB, repo, repo, idgenService)
+	adminService := services.NewAdminService(sqlDB, repo, repo, pushMessenger, repoid)
+	adminService.SetPasswordPolicy(passwordPolicy)
+	adminService.SetPasswordHashing(passwordHashing)
+	adminService.SetAuditor(auditService)
 
 	// router
-	routerHandler, errRouter := instantiateRouter(c, userService, idgenService, assetStore, pushMessenger, stat)
+	routerHandler, errRouter := instantiateRouter(c, userService, sessionService, apiTokenService, adminService, auditService, idgenService, assetStore, pushMessenger, stat)
 	if errRouter != nil {
 		return errRouter
 	}
@@ -304,6 +656,11 @@ func start(c *cli.Context) error {
 
 	logger.Info().Msg("stopping...")
 	webService.Stop(60 * time.Second)
+	if pushBroker != nil {
+		if err := pushBroker.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close push broker.")
+		}
+	}
 	if err := db.Close(); err != nil {
 		logger.Warn().Err(err).Msg("cannot close database.")
 	}
@@ -315,26 +672,250 @@ func start(c *cli.Context) error {
 
 }
 
-func instantiatePushMessenger() *push.PushMessenger {
-	return push.New()
+func loadPasswordPolicy(c *cli.Context) (model.PasswordPolicy, error) {
+
+	policy := model.PasswordPolicy{
+		MinLength:  c.GlobalInt("password-min-length"),
+		MinClasses: c.GlobalInt("password-min-classes"),
+		History:    c.GlobalInt("password-history"),
+		MaxAge:     c.GlobalDuration("password-max-age"),
+	}
+
+	if filename := c.GlobalString("password-banned-file"); len(filename) != 0 {
+		banned, err := services.LoadBannedPasswords(filename)
+		if err != nil {
+			return policy, fmt.Errorf("cannot load banned passwords %s: %s", filename, err)
+		}
+		policy.Banned = banned
+	}
+
+	return policy, nil
+
+}
+
+func loadPasswordHashing(c *cli.Context) (model.PasswordHashing, error) {
+
+	hashing := model.DefaultPasswordHashing
+	hashing.Algorithm = c.GlobalString("password-hash")
+	hashing.BcryptCost = c.GlobalInt("password-bcrypt-cost")
+
+	switch hashing.Algorithm {
+	case model.PasswordHashArgon2id:
+	case model.PasswordHashBcrypt:
+		if hashing.BcryptCost < bcrypt.MinCost || hashing.BcryptCost > bcrypt.MaxCost {
+			return hashing, fmt.Errorf("password-bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return hashing, fmt.Errorf("unknown password-hash %s", hashing.Algorithm)
+	}
+
+	return hashing, nil
+
+}
+
+func loadSecurityOptions(c *cli.Context) middleware.SecurityOptions {
+
+	opts := middleware.SecurityOptions{
+		ContentSecurityPolicy: c.GlobalString("security-csp"),
+		FrameAncestors:        c.GlobalString("security-frame-ancestors"),
+		HSTSMaxAge:            c.GlobalDuration("security-hsts-max-age"),
+		ReferrerPolicy:        c.GlobalString("security-referrer-policy"),
+		PermissionsPolicy:     c.GlobalString("security-permissions-policy"),
+	}
+	if c.GlobalBoolT("security-csp-report") {
+		opts.ReportURI = router.CSPReportPath
+	}
+
+	return opts
+
+}
+
+func instantiateMailer(c *cli.Context) services.Mailer {
+	if filename := c.GlobalString("mail-file"); len(filename) != 0 {
+		return mail.NewFileMailer(filename)
+	}
+	return mail.NewLogMailer()
+}
+
+func publicURL(c *cli.Context) string {
+	if u := c.GlobalString("public-url"); len(u) != 0 {
+		return u
+	}
+	return "https://" + c.GlobalString("server-addr")
+}
+
+// instantiateOIDCProvider returns nil if no OpenID Connect provider is configured.
+func instantiateOIDCProvider(c *cli.Context) (*auth.OIDCProvider, error) {
+
+	issuer := c.GlobalString("oidc-issuer")
+	if len(issuer) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+
+	provider, err := auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     c.GlobalString("oidc-client-id"),
+		ClientSecret: c.GlobalString("oidc-client-secret"),
+		RedirectURL:  strings.TrimSuffix(publicURL(c), "/") + "/api/oidc/callback",
+		Scopes:       strings.Fields(c.GlobalString("oidc-scopes")),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure OpenID Connect provider %s: %s", issuer, err)
+	}
+
+	return provider, nil
+
+}
+
+func instantiatePushMessenger(c *cli.Context, db model.Database, repo *repository.Repository) (*push.PushMessenger, error) {
+
+	options := push.Options{
+		QueueSize:  c.GlobalInt("push-queue-size"),
+		Policy:     c.GlobalString("push-overflow"),
+		MaxDropped: c.GlobalInt("push-max-dropped"),
+	}
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("bad push options: %s", err)
+	}
+	messenger := push.New(options)
+
+	replaySize := c.GlobalInt("push-replay-size")
+	if replaySize <= 0 {
+		return messenger, nil
+	}
+	var store push.ReplayStore = push.NewMemoryReplayStore(replaySize)
+	if c.GlobalBool("push-replay-persist") {
+		store = push.NewDatabaseReplayStore(db, repo, replaySize)
+	}
+	if err := messenger.SetReplayStore(store); err != nil {
+		return nil, fmt.Errorf("cannot set push replay store: %s", err)
+	}
+
+	return messenger, nil
+
+}
+
+// closableBroker connects the push messengers of all instances until closed.
+type closableBroker interface {
+	push.Broker
+	Close() error
+}
+
+// instantiatePushBroker returns the broker connecting the push messengers of all instances, nil for a single instance.
+func instantiatePushBroker(c *cli.Context, db *sqlx.DB, sqlDB model.Database, repo *repository.Repository) (closableBroker, error) {
+	switch broker := c.GlobalString("push-broker"); broker {
+	case "memory":
+		return nil, nil
+	case "database":
+		interval := c.GlobalDuration("push-broker-interval")
+		if interval <= 0 {
+			return nil, fmt.Errorf("bad push broker interval %s", interval)
+		}
+		return push.NewDatabaseBroker(sqlDB, repo, interval), nil
+	case "postgres":
+		pgBroker, err := repository.NewPushBroker(db, c.String("postgres-url"), c.GlobalString("push-broker-channel"))
+		if err != nil {
+			return nil, err
+		}
+		return pgBroker, nil
+	default:
+		return nil, fmt.Errorf("unknown push broker %s", broker)
+	}
 }
 
 func instantiateHeartbeatService(messageBus *push.PushMessenger, status *model.Status) *push.HeartbeatService {
 	return push.NewHeartbeatService(messageBus, status)
 }
 
-func instantiateRouter(c *cli.Context, userService *services.UserService, idg *idgen.IdGenerator, assetStore static.AssetStore, pushMessenger *push.PushMessenger, stat *model.Status) (http.Handler, error) {
+func instantiateRouter(c *cli.Context, userService *services.UserService, sessionService *services.SessionService, apiTokenService *services.APITokenService, adminService *services.AdminService, auditService *services.AuditService, idg *idgen.IdGenerator, assetStore static.AssetStore, pushMessenger *push.PushMessenger, stat *model.Status) (http.Handler, error) {
 
-	tokenPassword := c.String("token-password")
-	loginHandler := auth.Login(userService, tokenPassword)
-	logoutHandler := auth.Logout()
-	whoami := auth.Whoami()
-	changepassword := user.ChangePassword(userService, tokenPassword)
-	changeusername := user.ChangeUsername(userService, tokenPassword)
-	changeprofile := user.ChangeProfile(userService, tokenPassword)
+	tokenKeys, errKeys := loadTokenKeys(c)
+	if errKeys != nil {
+		return nil, errKeys
+	}
 
-	authenticator := auth.NewAuthenticator(tokenPassword)
-	authorizerUsers := auth.NewAuthorizer(model.RoleNameUser)
+	loginHandler := auth.Login(userService, tokenKeys)
+	loginOTPHandler := auth.LoginOTP(userService, tokenKeys)
+	logoutHandler := auth.Logout(sessionService, tokenKeys)
+	registerHandler := auth.Register(userService)
+	registerVerifyHandler := auth.VerifyEmail(userService)
+	passwordForgotHandler := auth.ForgotPassword(userService)
+	passwordResetHandler := auth.ResetPassword(userService)
+	whoami := auth.Whoami()
+	var oidcLoginHandler, oidcCallbackHandler http.HandlerFunc
+	if oidcProvider, errOIDC := instantiateOIDCProvider(c); errOIDC != nil {
+		return nil, errOIDC
+	} else if oidcProvider != nil {
+		oidcLoginHandler = auth.LoginOIDC(oidcProvider, tokenKeys)
+		oidcCallbackHandler = auth.CallbackOIDC(oidcProvider, userService, tokenKeys)
+	}
+	jwks := auth.JWKS(tokenKeys)
+	changepassword := user.ChangePassword(userService, tokenKeys)
+	changeusername := user.ChangeUsername(userService, tokenKeys)
+	changeprofile := user.ChangeProfile(userService, tokenKeys)
+	sessions := session.List(sessionService)
+	sessionsDelete := session.TerminateOthers(sessionService)
+	sessionDelete := session.Terminate(sessionService)
+	apiTokens := apitoken.List(apiTokenService)
+	apiTokenCreate := apitoken.Create(apiTokenService)
+	apiTokenRevoke := apitoken.Revoke(apiTokenService)
+	totpEnroll := user.EnrollTOTP(userService)
+	totpConfirm := user.ConfirmTOTP(userService)
+	totpDisable := user.DisableTOTP(userService)
+	adminUsers := admin.ListUsers(adminService)
+	adminUserCreate := admin.CreateUser(adminService)
+	adminUserDelete := admin.DeleteUser(adminService)
+	adminUserDisable := admin.DisableUser(adminService)
+	adminUserEnable := admin.EnableUser(adminService)
+	adminUserPassword := admin.ResetPassword(adminService)
+	adminUserUnlock := admin.UnlockUser(adminService)
+	adminUserRoles := admin.ListUserRoles(adminService)
+	adminUserGrant := admin.GrantRole(adminService)
+	adminUserRevoke := admin.RevokeRole(adminService)
+	adminRoles := admin.ListRoles(adminService)
+	adminRoleCreate := admin.CreateRole(adminService)
+	adminRoleDelete := admin.DeleteRole(adminService)
+	adminRolePerms := admin.SetRolePermissions(adminService)
+	adminAudit := admin.ListAuditEvents(auditService)
+	adminImpersonate := auth.Impersonate(adminService, tokenKeys)
+	adminPush := admin.PushStats(pushMessenger)
+
+	authenticator := auth.NewAPITokenAuthenticator(apiTokenService, auth.NewAuthenticator(tokenKeys, sessionService))
+	sessionRequired := auth.NewSessionRequired()
+	notImpersonating := auth.NewImpersonationForbidden()
+	authorizerUsers := auth.RequirePermission(model.PermissionAccount)
+	authorizerAdmin := auth.RequirePermission(model.PermissionManageUsers)
+	authorizerRoles := auth.RequirePermission(model.PermissionManageRoles)
+	authorizerAudit := auth.RequirePermission(model.PermissionReadAudit)
+	authorizerImpersonate := auth.RequirePermission(model.PermissionImpersonate)
+
+	securityHeaders := middleware.SecurityHeaders(loadSecurityOptions(c))
+
+	rateLimitLogin, errLimitLogin := middleware.ParseRateLimit(c.GlobalString("ratelimit-login"))
+	if errLimitLogin != nil {
+		return nil, fmt.Errorf("bad ratelimit-login: %s", errLimitLogin)
+	}
+	rateLimitAccount, errLimitAccount := middleware.ParseRateLimit(c.GlobalString("ratelimit-account"))
+	if errLimitAccount != nil {
+		return nil, fmt.Errorf("bad ratelimit-account: %s", errLimitAccount)
+	}
+	rateLimitChange, errLimitChange := middleware.ParseRateLimit(c.GlobalString("ratelimit-change"))
+	if errLimitChange != nil {
+		return nil, fmt.Errorf("bad ratelimit-change: %s", errLimitChange)
+	}
+	inboxMaxConnections := c.GlobalInt("inbox-max-connections")
+	if inboxMaxConnections < 1 {
+		return nil, errors.New("inbox-max-connections must be at least 1")
+	}
+	rateLimitStore := middleware.NewMemoryRateLimitStore()
+	rateLimiterLogin := middleware.RateLimiter("login", rateLimitLogin, rateLimitStore, middleware.KeyByIP)
+	rateLimiterAccount := middleware.RateLimiter("account", rateLimitAccount, rateLimitStore, middleware.KeyByIP)
+	rateLimiterChange := middleware.RateLimiter("change", rateLimitChange, rateLimitStore, middleware.KeyByUser)
+	inboxConnections := middleware.ConnectionLimiter("inbox", inboxMaxConnections, inboxRetryAfter, middleware.NewMemoryConnectionStore(), middleware.KeyByUser)
+	cspReport := csp.Report()
 
 	staticHandler := static.Handler(assetStore)
 
@@ -344,23 +925,109 @@ func instantiateRouter(c *cli.Context, userService *services.UserService, idg *i
 	}
 
 	sseHandler := sse.Handler(pushMessenger)
+	sseTopics := sse.Topics(pushMessenger)
+	wsHandler := ws.Handler(pushMessenger)
 
 	return router.Router(router.Options{
-		Authenticator:   authenticator,
-		AuthorizerUsers: authorizerUsers,
-		ChangePassword:  changepassword,
-		ChangeUsername:  changeusername,
-		ChangeProfile:   changeprofile,
-		IdGenerator:     idg,
-		Login:           loginHandler,
-		Logout:          logoutHandler,
-		Notifier:        sseHandler,
-		Static:          staticHandler,
-		Status:          statusHandler,
-		Whoami:          whoami,
+		AdminAudit:            adminAudit,
+		AdminPush:             adminPush,
+		AdminRoles:            adminRoles,
+		AdminRoleCreate:       adminRoleCreate,
+		AdminRoleDelete:       adminRoleDelete,
+		AdminRolePerms:        adminRolePerms,
+		AdminUsers:            adminUsers,
+		AdminUserCreate:       adminUserCreate,
+		AdminUserDelete:       adminUserDelete,
+		AdminUserDisable:      adminUserDisable,
+		AdminUserEnable:       adminUserEnable,
+		AdminUserPassword:     adminUserPassword,
+		AdminUserUnlock:       adminUserUnlock,
+		AdminUserRoles:        adminUserRoles,
+		AdminUserGrant:        adminUserGrant,
+		AdminImpersonate:      adminImpersonate,
+		AdminUserRevoke:       adminUserRevoke,
+		APITokens:             apiTokens,
+		APITokenCreate:        apiTokenCreate,
+		APITokenRevoke:        apiTokenRevoke,
+		Authenticator:         authenticator,
+		AuthorizerAdmin:       authorizerAdmin,
+		AuthorizerAudit:       authorizerAudit,
+		AuthorizerImpersonate: authorizerImpersonate,
+		AuthorizerRoles:       authorizerRoles,
+		AuthorizerUsers:       authorizerUsers,
+		ChangePassword:        changepassword,
+		ChangeUsername:        changeusername,
+		ChangeProfile:         changeprofile,
+		CSPReport:             cspReport,
+		IdGenerator:           idg,
+		InboxConnections:      inboxConnections,
+		JWKS:                  jwks,
+		Login:                 loginHandler,
+		LoginOTP:              loginOTPHandler,
+		Logout:                logoutHandler,
+		Notifier:              sseHandler,
+		NotifierTopics:        sseTopics,
+		NotifierWebSocket:     wsHandler,
+		OIDCLogin:             oidcLoginHandler,
+		OIDCCallback:          oidcCallbackHandler,
+		PasswordForgot:        passwordForgotHandler,
+		RateLimitAccount:      rateLimiterAccount,
+		RateLimitChange:       rateLimiterChange,
+		RateLimitLogin:        rateLimiterLogin,
+		PasswordReset:         passwordResetHandler,
+		Register:              registerHandler,
+		RegisterVerify:        registerVerifyHandler,
+		Sessions:              sessions,
+		SessionsDelete:        sessionsDelete,
+		SessionDelete:         sessionDelete,
+		SecurityHeaders:       securityHeaders,
+		SessionRequired:       sessionRequired,
+		NotImpersonating:      notImpersonating,
+		Static:                staticHandler,
+		Status:                statusHandler,
+		TOTPEnroll:            totpEnroll,
+		TOTPConfirm:           totpConfirm,
+		TOTPDisable:           totpDisable,
+		Whoami:                whoami,
 	})
 }
 
+// loadTokenKeys returns the keys to sign and verify JWT tokens.
+// With a token key, the token password and the token verify keys are used for verification only,
+// so that tokens issued before a key rotation remain valid.
+func loadTokenKeys(c *cli.Context) (*auth.Keys, error) {
+
+	tokenPassword := c.String("token-password")
+	tokenKeyFile := c.String("token-key")
+
+	if len(tokenKeyFile) == 0 {
+		if len(tokenPassword) == 0 {
+			return nil, errors.New("token-key or token-password required")
+		}
+		return auth.NewHMACKeys(tokenPassword), nil
+	}
+
+	signingKey, errSigningKey := auth.LoadSigningKey(tokenKeyFile)
+	if errSigningKey != nil {
+		return nil, fmt.Errorf("cannot load token key %s: %s", tokenKeyFile, errSigningKey)
+	}
+
+	var verificationKeys []*auth.Key
+	if len(tokenPassword) != 0 {
+		verificationKeys = append(verificationKeys, auth.NewHMACKey(tokenPassword))
+	}
+	for _, filename := range c.StringSlice("token-verify-key") {
+		key, err := auth.LoadVerificationKey(filename)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load token verify key %s: %s", filename, err)
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	return auth.NewKeys(signingKey, verificationKeys...)
+
+}
+
 func instantiateWebService(c *cli.Context, handler http.Handler) (*web.Service, error) {
 	serverAddr := c.String("server-addr")
 	serverCertFile := c.String("server-cert")
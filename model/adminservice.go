@@ -0,0 +1,180 @@
+package model
+
+import (
+	"time"
+)
+
+// UserInfo describes a user as shown in the admin console, without credentials.
+type UserInfo struct {
+	ID       string    `json:"id"`
+	Disabled bool      `json:"disabled"`
+	Username string    `json:"username"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+func ToUserInfo(u User) UserInfo {
+	return UserInfo{
+		ID:       u.ID,
+		Disabled: u.Disabled,
+		Username: u.Username,
+		Name:     u.Name,
+		Email:    u.Email,
+		Created:  u.Created,
+		Updated:  u.Updated,
+	}
+}
+
+type ListUsersRequest struct {
+	Offset   int
+	Limit    int
+	Username string
+	Disabled *bool
+}
+
+type ListUsersResponse struct {
+	Code    int
+	Message string
+	Users   []UserInfo
+	Total   int
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Disabled bool   `json:"disabled"`
+}
+
+type CreateUserResponse struct {
+	Code    int
+	Message string
+	Rule    string
+	User    *UserInfo
+}
+
+type SetUserDisabledRequest struct {
+	UserID   string `json:"-"`
+	Disabled bool   `json:"-"`
+}
+
+type SetUserDisabledResponse struct {
+	Code    int
+	Message string
+}
+
+type UnlockUserRequest struct {
+	UserID string `json:"-"`
+}
+
+type UnlockUserResponse struct {
+	Code    int
+	Message string
+}
+
+type DeleteUserRequest struct {
+	UserID string `json:"-"`
+}
+
+type DeleteUserResponse struct {
+	Code    int
+	Message string
+}
+
+type ResetPasswordRequest struct {
+	UserID   string `json:"-"`
+	Password string `json:"password"`
+}
+
+type ResetPasswordResponse struct {
+	Code    int
+	Message string
+	Rule    string
+}
+
+type ListRolesRequest struct{}
+
+type ListRolesResponse struct {
+	Code    int
+	Message string
+	Roles   []Role
+}
+
+type CreateRoleRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateRoleResponse struct {
+	Code    int
+	Message string
+	Role    *Role
+}
+
+type DeleteRoleRequest struct {
+	RoleID string `json:"-"`
+}
+
+type DeleteRoleResponse struct {
+	Code    int
+	Message string
+}
+
+// SetRolePermissionsRequest replaces the permissions of a role.
+type SetRolePermissionsRequest struct {
+	RoleID      string   `json:"-"`
+	Permissions []string `json:"permissions"`
+}
+
+type SetRolePermissionsResponse struct {
+	Code    int
+	Message string
+}
+
+type ListUserRolesRequest struct {
+	UserID string `json:"-"`
+}
+
+type ListUserRolesResponse struct {
+	Code    int
+	Message string
+	Roles   []UserRole
+}
+
+// GrantRoleRequest grants a role to a user.
+// The grant is unbounded if ValidFrom and ValidTo are not given.
+type GrantRoleRequest struct {
+	UserID    string     `json:"-"`
+	RoleID    string     `json:"-"`
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+	ValidTo   *time.Time `json:"validTo,omitempty"`
+}
+
+type GrantRoleResponse struct {
+	Code    int
+	Message string
+}
+
+type RevokeRoleRequest struct {
+	UserID string `json:"-"`
+	RoleID string `json:"-"`
+}
+
+type RevokeRoleResponse struct {
+	Code    int
+	Message string
+}
+
+// ImpersonateRequest issues a session for a user to the administrator in the request context.
+type ImpersonateRequest struct {
+	UserID    string `json:"-"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
+}
+
+type ImpersonateResponse struct {
+	Code         int
+	Message      string
+	SessionToken *SessionToken
+}
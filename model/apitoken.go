@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	APITokenPrefix      = "wwt_"
+	APITokenMaxLifetime = time.Hour * 24 * 365
+)
+
+// APIToken is a personal token with which scripts authenticate as its user.
+// Scopes name the roles the token may use, the token never has roles its user has lost.
+// Only the hash of the token, shown once at creation, is stored.
+type APIToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"-"`
+	Name      string     `json:"name"`
+	TokenHash string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	Created   time.Time  `json:"created"`
+	Expires   time.Time  `json:"expires"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+	Revoked   *time.Time `json:"revoked,omitempty"`
+}
+
+// IsActive tests if the token is neither revoked nor expired at the given time.
+func (z *APIToken) IsActive(t time.Time) bool {
+	return z != nil && z.Revoked == nil && t.Before(z.Expires)
+}
+
+// ScopedRoles returns the roles that are both granted to the user and in the scopes of the token.
+func (z *APIToken) ScopedRoles(roles []UserRole) []UserRole {
+	var scoped []UserRole
+	for _, role := range roles {
+		for _, scope := range z.Scopes {
+			if role.Name == scope {
+				scoped = append(scoped, role)
+				break
+			}
+		}
+	}
+	return scoped
+}
+
+// IsAPIToken tests if a bearer token is an API token rather than a session JWT.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
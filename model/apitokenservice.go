@@ -0,0 +1,36 @@
+package model
+
+type CreateAPITokenRequest struct {
+	UserID        string   `json:"-"`
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// CreateAPITokenResponse carries the new token, which cannot be retrieved again.
+type CreateAPITokenResponse struct {
+	Code     int
+	Message  string
+	Token    string
+	APIToken *APIToken
+}
+
+type ListAPITokensRequest struct {
+	UserID string `json:"-"`
+}
+
+type ListAPITokensResponse struct {
+	Code      int
+	Message   string
+	APITokens []APIToken
+}
+
+type RevokeAPITokenRequest struct {
+	UserID     string `json:"-"`
+	APITokenID string `json:"-"`
+}
+
+type RevokeAPITokenResponse struct {
+	Code    int
+	Message string
+}
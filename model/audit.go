@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	AuditLogin            = "login"
+	AuditLoginOTP         = "login.otp"
+	AuditLoginExternal    = "login.external"
+	AuditLogout           = "logout"
+	AuditTerminateSession = "session.terminate"
+	AuditImpersonate      = "impersonate"
+	AuditChangePassword   = "password.change"
+	AuditChangeUsername   = "username.change"
+	AuditChangeProfile    = "profile.change"
+	AuditResetPassword    = "password.reset"
+	AuditForgotPassword   = "password.forgot"
+	AuditDisableUser      = "user.disable"
+	AuditEnableUser       = "user.enable"
+	AuditDeleteUser       = "user.delete"
+
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records a security relevant action.
+// The actor is the user performing the action, ActorName the username given at login if the user is not known.
+// The target is the user affected by the action, which is the actor for self-service actions.
+type AuditEvent struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	Action        string    `json:"action"`
+	Outcome       string    `json:"outcome"`
+	ActorID       string    `json:"actorID,omitempty"`
+	ActorName     string    `json:"actorName,omitempty"`
+	TargetID      string    `json:"targetID,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	IPAddress     string    `json:"ipAddress,omitempty"`
+	UserAgent     string    `json:"userAgent,omitempty"`
+	CorrelationID string    `json:"correlationID,omitempty"`
+}
+
+// AuditFilter selects a page of audit events, most recent first.
+// Empty fields are ignored, From is inclusive and To exclusive.
+// BeforeCreated and BeforeID select the events listed after the event with the given created time and ID,
+// which pages through the events without skipping or repeating any while new events are added.
+type AuditFilter struct {
+	ActorID       string
+	TargetID      string
+	Action        string
+	Outcome       string
+	From          *time.Time
+	To            *time.Time
+	BeforeCreated *time.Time
+	BeforeID      string
+	Offset        int
+	Limit         int
+}
+
+type ListAuditEventsRequest struct {
+	Filter AuditFilter
+}
+
+type ListAuditEventsResponse struct {
+	Code    int
+	Message string
+	Events  []AuditEvent
+	Total   int
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	LoginChallengeTimeout     = time.Minute * 5
+	LoginChallengeMaxFailures = 3
+)
+
+// LoginChallenge is the pending second step of a login requiring a one-time password.
+// It is single-use, removed by a successful login or after LoginChallengeMaxFailures wrong codes.
+// A user has at most one, a new password login replaces it.
+type LoginChallenge struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"userID"`
+	Expires  time.Time `json:"expires"`
+	Failures int       `json:"failures"`
+}
+
+// IsValid tests if the challenge has been issued to the user and has not expired at the given time.
+func (z *LoginChallenge) IsValid(userID string, t time.Time) bool {
+	return z != nil && z.UserID == userID && t.Before(z.Expires)
+}
@@ -5,10 +5,27 @@ import (
 )
 
 const (
+	ClientKey        = "client"
 	CorrelationIDKey = "correlationID"
+	CSPNonceKey      = "cspNonce"
 	UserKey          = "user"
 )
 
+// Client describes the remote end of a request.
+type Client struct {
+	IPAddress string
+	UserAgent string
+}
+
+func ClientFromContext(ctx context.Context) Client {
+	if value := ctx.Value(ClientKey); value != nil {
+		if client, ok := value.(Client); ok {
+			return client
+		}
+	}
+	return Client{}
+}
+
 func CorrelationIDFromContext(ctx context.Context) string {
 	if value := ctx.Value(CorrelationIDKey); value != nil {
 		if uuid, ok := value.(string); ok {
@@ -18,6 +35,16 @@ func CorrelationIDFromContext(ctx context.Context) string {
 	return ""
 }
 
+// CSPNonceFromContext returns the nonce allowed by the Content-Security-Policy of the response, if any.
+func CSPNonceFromContext(ctx context.Context) string {
+	if value := ctx.Value(CSPNonceKey); value != nil {
+		if nonce, ok := value.(string); ok {
+			return nonce
+		}
+	}
+	return ""
+}
+
 func TokenFromContext(ctx context.Context) SessionToken {
 	if value := ctx.Value(UserKey); value != nil {
 		if user, ok := value.(SessionToken); ok {
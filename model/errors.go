@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"time"
 )
 
 type NotFoundError struct {
@@ -24,8 +25,10 @@ func IsNotFoundError(err error) bool {
 	return false
 }
 
+// ValidationError rejects invalid input. Rule optionally names the rule that failed.
 type ValidationError struct {
 	error
+	Rule string
 }
 
 func (z *ValidationError) Invalid() {}
@@ -34,6 +37,18 @@ func NewValidationError(msg string) *ValidationError {
 	return &ValidationError{error: errors.New(msg)}
 }
 
+func NewRuleValidationError(rule, msg string) *ValidationError {
+	return &ValidationError{error: errors.New(msg), Rule: rule}
+}
+
+// ValidationRule returns the rule of a validation error, if any.
+func ValidationRule(err error) string {
+	if v, ok := err.(*ValidationError); ok {
+		return v.Rule
+	}
+	return ""
+}
+
 func IsValidationError(err error) bool {
 	type Invalid interface {
 		Invalid()
@@ -43,3 +58,46 @@ func IsValidationError(err error) bool {
 	}
 	return false
 }
+
+// LockedError refuses an action until RetryAfter has passed.
+type LockedError struct {
+	error
+	RetryAfter time.Duration
+}
+
+func (z *LockedError) Locked() {}
+
+func NewLockedError(msg string, retryAfter time.Duration) *LockedError {
+	return &LockedError{error: errors.New(msg), RetryAfter: retryAfter}
+}
+
+func IsLockedError(err error) bool {
+	type Locked interface {
+		Locked()
+	}
+	if _, ok := err.(Locked); ok {
+		return true
+	}
+	return false
+}
+
+// ForbiddenError refuses an action the user is not authorized for.
+type ForbiddenError struct {
+	error
+}
+
+func (z *ForbiddenError) Forbidden() {}
+
+func NewForbiddenError(msg string) *ForbiddenError {
+	return &ForbiddenError{error: errors.New(msg)}
+}
+
+func IsForbiddenError(err error) bool {
+	type Forbidden interface {
+		Forbidden()
+	}
+	if _, ok := err.(Forbidden); ok {
+		return true
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package model_test
 
 import (
 	"testing"
+	"time"
 
 	"wallawire/model"
 )
@@ -17,6 +18,19 @@ func TestValidationError(t *testing.T) {
 		t.Error("Unexpected notfound error")
 	}
 
+	if got := model.ValidationRule(x); got != "" {
+		t.Errorf("Unexpected rule %s", got)
+	}
+
+	y := model.NewRuleValidationError("length", "too long")
+	if !model.IsValidationError(y) {
+		t.Error("Expected validation error")
+	}
+
+	if got, want := model.ValidationRule(y), "length"; got != want {
+		t.Errorf("Bad rule %s, expected %s", got, want)
+	}
+
 }
 
 func TestNotFoundError(t *testing.T) {
@@ -33,3 +47,35 @@ func TestNotFoundError(t *testing.T) {
 
 
 }
+
+func TestLockedError(t *testing.T) {
+
+	x := model.NewLockedError("locked", time.Minute)
+
+	if !model.IsLockedError(x) {
+		t.Error("Expected locked error")
+	}
+
+	if model.IsValidationError(x) {
+		t.Error("Unexpected validation error")
+	}
+
+	if got, want := x.RetryAfter, time.Minute; got != want {
+		t.Errorf("Bad retry after: %s, expected %s", got, want)
+	}
+
+}
+
+func TestForbiddenError(t *testing.T) {
+
+	x := model.NewForbiddenError("forbidden")
+
+	if !model.IsForbiddenError(x) {
+		t.Error("Expected forbidden error")
+	}
+
+	if model.IsNotFoundError(x) {
+		t.Error("Unexpected notfound error")
+	}
+
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// UserIdentity links an account at an external identity provider, identified by issuer and subject, to a user.
+type UserIdentity struct {
+	Issuer  string    `json:"issuer"`
+	Subject string    `json:"subject"`
+	UserID  string    `json:"userID"`
+	Email   string    `json:"email,omitempty"`
+	Created time.Time `json:"created"`
+}
@@ -1,7 +1,70 @@
 package model
 
+import (
+	"time"
+)
+
+const (
+	// PushTypeLockout is sent to the sessions of a user whose account has been locked by failed logins.
+	PushTypeLockout = "lockout"
+	// PushTypePasswordChanged is sent to the sessions of a user whose password has been reset, right before they are ended.
+	PushTypePasswordChanged = "password-changed"
+	// PushTypeDisconnect is the last message to a client disconnected by the server, the data holds the reason.
+	PushTypeDisconnect = "disconnect"
+	// PushTypeRead is sent to the connected sessions of a user that has read the message with the ID in the data.
+	PushTypeRead = "read"
+	// PushTypeAudit is published to TopicAudit for every audit event recorded, the data holds the event.
+	PushTypeAudit = "audit"
+)
+
+const (
+	// TopicAudit is subscribed by the sessions allowed to read the audit log.
+	TopicAudit = "audit"
+)
+
+// PushDataMaxLength is the longest data of a push message, which the push tables of the database can store.
+const PushDataMaxLength = 4096
+
 type PushMessage struct {
-	ID   string `json:"id,omitempty"`
-	Type string `json:"type,omitempty"`
-	Data string `json:"data"`
+	ID        string `json:"id,omitempty"` // the sequence number of a message that can be replayed
+	Type      string `json:"type,omitempty"`
+	Data      string `json:"data"`
+	Transient bool   `json:"-"` // for the clients of this instance only, neither replayed nor published to other instances
+	Live      bool   `json:"-"` // for the clients connected to any instance, published but not replayed
+}
+
+const (
+	// BrokerControlDisconnect asks all instances to close the inbox of the user session.
+	BrokerControlDisconnect = "disconnect"
+)
+
+// BrokerMessage is a push message published to all instances, or a control instruction without message.
+// Origin is the instance that has sent it, together with the serial number it has given the message it identifies the message.
+// A message to a topic has neither UserID nor SessionID.
+type BrokerMessage struct {
+	Origin    string      `json:"origin"`
+	Serial    uint64      `json:"serial"`
+	UserID    string      `json:"userID,omitempty"`
+	SessionID string      `json:"sessionID,omitempty"`
+	Topic     string      `json:"topic,omitempty"`
+	Control   string      `json:"control,omitempty"`
+	Message   PushMessage `json:"message"`
+}
+
+// ReplayMessage is a push message kept to be replayed to clients that reconnect after missing it.
+// An empty UserID addresses all users, an empty SessionID all sessions of the user.
+type ReplayMessage struct {
+	Seq       uint64
+	UserID    string
+	SessionID string
+	Created   time.Time
+	Message   PushMessage
+}
+
+// PushStats counts the push deliveries since start.
+type PushStats struct {
+	Clients   int    `json:"clients"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Evicted   uint64 `json:"evicted"`
 }
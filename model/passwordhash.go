@@ -0,0 +1,190 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms.
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrPasswordHashAlgorithm = errors.New("unknown password hash algorithm")
+)
+
+// PasswordHashing selects the algorithm and cost of new password hashes.
+//
+// Hashes are self-describing:
+// Argon2id hashes use the PHC string format, $argon2id$v=19$m=65536,t=3,p=4$salt$key,
+// bcrypt hashes use the modular crypt format, $2a$10$....
+// Hex-encoded bcrypt hashes written by earlier versions are still accepted.
+type PasswordHashing struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+}
+
+// DefaultPasswordHashing uses Argon2id with the second recommended parameters of RFC 9106.
+var DefaultPasswordHashing = PasswordHashing{
+	Algorithm:     PasswordHashArgon2id,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+}
+
+type argon2Params struct {
+	Version int
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	Salt    []byte
+	Key     []byte
+}
+
+// Hash returns a new hash of the password.
+func (z PasswordHashing) Hash(password string) (string, error) {
+
+	switch z.Algorithm {
+
+	case PasswordHashArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, z.Argon2Time, z.Argon2Memory, z.Argon2Threads, argon2KeyLength)
+		return formatArgon2(argon2Params{
+			Version: argon2.Version,
+			Memory:  z.Argon2Memory,
+			Time:    z.Argon2Time,
+			Threads: z.Argon2Threads,
+			Salt:    salt,
+			Key:     key,
+		}), nil
+
+	case PasswordHashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), z.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+
+	}
+
+	return "", ErrPasswordHashAlgorithm
+
+}
+
+// NeedsRehash tests if a hash uses another algorithm or cost than configured.
+func (z PasswordHashing) NeedsRehash(passwordHash string) bool {
+
+	passwordHash = strings.TrimSpace(passwordHash)
+
+	switch z.Algorithm {
+
+	case PasswordHashArgon2id:
+		if !strings.HasPrefix(passwordHash, "$argon2id$") {
+			return true
+		}
+		p, err := parseArgon2(passwordHash)
+		return err != nil || p.Version != argon2.Version || p.Memory != z.Argon2Memory || p.Time != z.Argon2Time || p.Threads != z.Argon2Threads
+
+	case PasswordHashBcrypt:
+		if !strings.HasPrefix(passwordHash, "$2") {
+			return true // legacy hex encoding
+		}
+		cost, err := bcrypt.Cost([]byte(passwordHash))
+		return err != nil || cost != z.BcryptCost
+
+	}
+
+	return false
+
+}
+
+// MatchPasswordHash checks if the given password matches a password hash of any supported format.
+func MatchPasswordHash(passwordHash, password string) bool {
+
+	passwordHash = strings.TrimSpace(passwordHash)
+
+	switch {
+
+	case strings.HasPrefix(passwordHash, "$argon2id$"):
+		p, err := parseArgon2(passwordHash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(password), p.Salt, p.Time, p.Memory, p.Threads, uint32(len(p.Key)))
+		return subtle.ConstantTimeCompare(key, p.Key) == 1
+
+	case strings.HasPrefix(passwordHash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+
+	}
+
+	// hex-encoded bcrypt
+	hashedPassword, err := hex.DecodeString(passwordHash)
+	if err != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(password)) == nil
+
+}
+
+func formatArgon2(p argon2Params) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		p.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(p.Salt),
+		base64.RawStdEncoding.EncodeToString(p.Key))
+}
+
+func parseArgon2(passwordHash string) (*argon2Params, error) {
+
+	parts := strings.Split(passwordHash, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashArgon2id {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	p := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &p.Version); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return nil, err
+	}
+
+	salt, errSalt := base64.RawStdEncoding.DecodeString(parts[4])
+	if errSalt != nil {
+		return nil, errSalt
+	}
+	key, errKey := base64.RawStdEncoding.DecodeString(parts[5])
+	if errKey != nil {
+		return nil, errKey
+	}
+	if len(key) == 0 || p.Time == 0 || p.Threads == 0 {
+		return nil, errors.New("invalid argon2id hash")
+	}
+	p.Salt = salt
+	p.Key = key
+
+	return p, nil
+
+}
@@ -0,0 +1,137 @@
+package model_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"wallawire/model"
+)
+
+func TestPasswordHashing(b *testing.T) {
+
+	argon2id := model.PasswordHashing{
+		Algorithm:     model.PasswordHashArgon2id,
+		Argon2Time:    1,
+		Argon2Memory:  1024,
+		Argon2Threads: 1,
+	}
+	bcrypt4 := model.PasswordHashing{
+		Algorithm:  model.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost,
+	}
+	bcrypt5 := model.PasswordHashing{
+		Algorithm:  model.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost + 1,
+	}
+
+	legacy, errLegacy := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if errLegacy != nil {
+		b.Fatal(errLegacy)
+	}
+
+	hash := func(hashing model.PasswordHashing) string {
+		h, err := hashing.Hash("password")
+		if err != nil {
+			b.Fatal(err)
+		}
+		return h
+	}
+
+	testCases := []struct {
+		Alias          string
+		Hash           string
+		Hashing        model.PasswordHashing
+		ExpectedPrefix string
+		ExpectedRehash bool
+	}{
+		{
+			Alias:          "argon2id",
+			Hash:           hash(argon2id),
+			Hashing:        argon2id,
+			ExpectedPrefix: "$argon2id$v=19$m=1024,t=1,p=1$",
+		},
+		{
+			Alias:          "bcrypt",
+			Hash:           hash(bcrypt4),
+			Hashing:        bcrypt4,
+			ExpectedPrefix: "$2a$04$",
+		},
+		{
+			Alias:          "bcrypt to argon2id",
+			Hash:           hash(bcrypt4),
+			Hashing:        argon2id,
+			ExpectedPrefix: "$2a$04$",
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "bcrypt cost",
+			Hash:           hash(bcrypt4),
+			Hashing:        bcrypt5,
+			ExpectedPrefix: "$2a$04$",
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "argon2id parameters",
+			Hash:           hash(argon2id),
+			Hashing:        model.DefaultPasswordHashing,
+			ExpectedPrefix: "$argon2id$",
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "legacy hex bcrypt",
+			Hash:           hex.EncodeToString(legacy),
+			Hashing:        bcrypt4,
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "padded",
+			Hash:           hash(bcrypt4) + strings.Repeat(" ", 60),
+			Hashing:        bcrypt4,
+			ExpectedPrefix: "$2a$04$",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			if !strings.HasPrefix(tCase.Hash, tCase.ExpectedPrefix) {
+				t.Errorf("Bad hash: %s, expected prefix %s", tCase.Hash, tCase.ExpectedPrefix)
+			}
+
+			if !model.MatchPasswordHash(tCase.Hash, "password") {
+				t.Error("Bad match: false, expected true")
+			}
+
+			if model.MatchPasswordHash(tCase.Hash, "bogus") {
+				t.Error("Bad match with wrong password: true, expected false")
+			}
+
+			if got, want := tCase.Hashing.NeedsRehash(tCase.Hash), tCase.ExpectedRehash; got != want {
+				t.Errorf("Bad rehash: %t, expected %t", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestPasswordHashingInvalid(t *testing.T) {
+
+	if _, err := (model.PasswordHashing{Algorithm: "md5"}).Hash("password"); err != model.ErrPasswordHashAlgorithm {
+		t.Errorf("Bad error: %v, expected %v", err, model.ErrPasswordHashAlgorithm)
+	}
+
+	for _, h := range []string{"", "$argon2id$v=19$m=1024,t=1,p=1$salt", "$argon2id$v=19$bogus$c2FsdA$a2V5", "not hex"} {
+		if model.MatchPasswordHash(h, "password") {
+			t.Errorf("Bad match for %q: true, expected false", h)
+		}
+	}
+
+}
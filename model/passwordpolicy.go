@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules of the password policy, set on the ValidationError of a rejected password.
+const (
+	PasswordRuleMinLength = "minLength"
+	PasswordRuleMaxLength = "maxLength"
+	PasswordRuleClasses   = "classes"
+	PasswordRuleBanned    = "banned"
+	PasswordRuleHistory   = "history"
+	PasswordRuleExpired   = "expired"
+)
+
+const (
+	// PasswordMaxLength limits the work of hashing a password.
+	// With bcrypt only the first 72 bytes are used.
+	PasswordMaxLength = 256
+)
+
+// PasswordPolicy defines the rules for new passwords.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters, not counting leading and trailing space.
+	MinLength int
+	// MinClasses is the number of character classes required: lowercase, uppercase, digits and symbols.
+	MinClasses int
+	// Banned passwords, in lowercase, are rejected ignoring case.
+	Banned map[string]bool
+	// History is the number of previous passwords that cannot be reused.
+	History int
+	// MaxAge, if not zero, is the time after which a password must be changed.
+	MaxAge time.Duration
+}
+
+// DefaultPasswordPolicy only requires a minimum length.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+}
+
+// PasswordHistory is a password previously set for a user.
+type PasswordHistory struct {
+	UserID       string    `json:"userID"`
+	PasswordHash string    `json:"-"`
+	Created      time.Time `json:"created"`
+}
+
+// Check tests the password against the rules which do not depend on the user.
+// The returned error is a ValidationError with the failed rule.
+func (z *PasswordPolicy) Check(password string) error {
+
+	if utf8.RuneCountInString(strings.TrimSpace(password)) < z.MinLength {
+		return NewRuleValidationError(PasswordRuleMinLength, fmt.Sprintf("password must have at least %d characters", z.MinLength))
+	}
+	if len(password) > PasswordMaxLength {
+		return NewRuleValidationError(PasswordRuleMaxLength, fmt.Sprintf("password must not be longer than %d bytes", PasswordMaxLength))
+	}
+	if countPasswordClasses(password) < z.MinClasses {
+		return NewRuleValidationError(PasswordRuleClasses, fmt.Sprintf("password must use %d of lowercase, uppercase, digits and symbols", z.MinClasses))
+	}
+	if z.Banned[strings.ToLower(password)] {
+		return NewRuleValidationError(PasswordRuleBanned, "password is too common")
+	}
+
+	return nil
+
+}
+
+// CheckHistory tests that the password is not one of the last History passwords, newest first.
+func (z *PasswordPolicy) CheckHistory(password string, history []PasswordHistory) error {
+	for i, h := range history {
+		if i >= z.History {
+			break
+		}
+		if MatchPasswordHash(h.PasswordHash, password) {
+			return NewRuleValidationError(PasswordRuleHistory, fmt.Sprintf("password must differ from the last %d passwords", z.History))
+		}
+	}
+	return nil
+}
+
+// IsExpired tests if a password set at the given time must be changed.
+func (z *PasswordPolicy) IsExpired(changed, t time.Time) bool {
+	return z.MaxAge > 0 && !t.Before(changed.Add(z.MaxAge))
+}
+
+func countPasswordClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
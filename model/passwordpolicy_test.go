@@ -0,0 +1,127 @@
+package model_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+)
+
+func TestPasswordPolicy(b *testing.T) {
+
+	policy := model.PasswordPolicy{
+		MinLength:  10,
+		MinClasses: 3,
+		Banned:     map[string]bool{"password123!": true},
+	}
+
+	testCases := []struct {
+		Alias        string
+		Password     string
+		ExpectedRule string
+	}{
+		{
+			Alias:    "success",
+			Password: "Correct-Horse",
+		},
+		{
+			Alias:        "too short",
+			Password:     "Short-1",
+			ExpectedRule: model.PasswordRuleMinLength,
+		},
+		{
+			Alias:        "too short with spaces",
+			Password:     "  Short-12  ",
+			ExpectedRule: model.PasswordRuleMinLength,
+		},
+		{
+			Alias:        "too long",
+			Password:     "Aa1" + strings.Repeat("x", model.PasswordMaxLength),
+			ExpectedRule: model.PasswordRuleMaxLength,
+		},
+		{
+			Alias:        "not enough classes",
+			Password:     "correcthorse1",
+			ExpectedRule: model.PasswordRuleClasses,
+		},
+		{
+			Alias:        "banned ignoring case",
+			Password:     "PASSWORD123!",
+			ExpectedRule: model.PasswordRuleBanned,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			err := policy.Check(tCase.Password)
+
+			if tCase.ExpectedRule == "" {
+				if err != nil {
+					t.Errorf("Bad error: %s, expected none", err)
+				}
+				return
+			}
+
+			if !model.IsValidationError(err) {
+				t.Fatalf("Bad error: %v, expected validation error", err)
+			}
+			if got, want := model.ValidationRule(err), tCase.ExpectedRule; got != want {
+				t.Errorf("Bad rule: %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+
+	policy := model.PasswordPolicy{
+		History: 2,
+	}
+
+	var history []model.PasswordHistory
+	for _, password := range []string{"newest", "older", "oldest"} {
+		u := model.User{}
+		if err := u.SetPassword(password); err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, model.PasswordHistory{UserID: "id", PasswordHash: u.PasswordHash})
+	}
+
+	if got, want := model.ValidationRule(policy.CheckHistory("older", history)), model.PasswordRuleHistory; got != want {
+		t.Errorf("Bad rule: %s, expected %s", got, want)
+	}
+	if err := policy.CheckHistory("oldest", history); err != nil {
+		t.Errorf("Bad error: %s, expected none", err)
+	}
+	if err := policy.CheckHistory("other", history); err != nil {
+		t.Errorf("Bad error: %s, expected none", err)
+	}
+
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+
+	now := time.Now()
+
+	policy := model.PasswordPolicy{}
+	if policy.IsExpired(now.Add(-time.Hour*24*365), now) {
+		t.Error("Bad expired without max age: true, expected false")
+	}
+
+	policy.MaxAge = time.Hour
+	if policy.IsExpired(now.Add(-time.Minute), now) {
+		t.Error("Bad expired: true, expected false")
+	}
+	if !policy.IsExpired(now.Add(-time.Hour), now) {
+		t.Error("Bad expired: false, expected true")
+	}
+
+}
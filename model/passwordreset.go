@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	PasswordResetTimeout = time.Hour
+)
+
+// PasswordReset is a single-use token to set a forgotten password.
+// Only the hash of the token sent to the user is stored.
+type PasswordReset struct {
+	TokenHash string     `json:"-"`
+	UserID    string     `json:"userID"`
+	Created   time.Time  `json:"created"`
+	Expires   time.Time  `json:"expires"`
+	Used      *time.Time `json:"used,omitempty"`
+}
+
+// IsValid tests if the token is neither used nor expired at the given time.
+func (z *PasswordReset) IsValid(t time.Time) bool {
+	return z != nil && z.Used == nil && t.Before(z.Expires)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"sort"
+)
+
+const (
+	// PermissionAccount allows to use the application as a signed in user
+	PermissionAccount = "account"
+	// PermissionManageUsers allows to create, disable and delete users and to grant them roles
+	PermissionManageUsers = "users.manage"
+	// PermissionManageRoles allows to create and delete roles and to change their permissions
+	PermissionManageRoles = "roles.manage"
+	// PermissionImpersonate allows to sign in as another user
+	PermissionImpersonate = "users.impersonate"
+	// PermissionReadAudit allows to query the security audit log
+	PermissionReadAudit = "audit.read"
+	// see 12_role_permissions.sql, 13_audit_events.sql, 14_impersonation.sql
+)
+
+// Permissions lists the permissions that can be assigned to roles.
+var Permissions = []string{
+	PermissionAccount,
+	PermissionImpersonate,
+	PermissionManageRoles,
+	PermissionManageUsers,
+	PermissionReadAudit,
+}
+
+// IsValidPermission tests if the permission is one of Permissions.
+func IsValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns the sorted union of the permissions of the given roles.
+func RolePermissions(roles []UserRole) []string {
+	seen := make(map[string]bool)
+	var permissions []string
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
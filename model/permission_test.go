@@ -0,0 +1,38 @@
+package model_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"wallawire/model"
+)
+
+func TestToSessionTokenPermissions(t *testing.T) {
+
+	now := time.Now()
+	u := &model.User{ID: "id", Username: "demouser"}
+	roles := []model.UserRole{
+		{Name: model.RoleNameUser, Permissions: []string{model.PermissionAccount}},
+		{Name: model.RoleNameAdmin, Permissions: []string{model.PermissionManageUsers, model.PermissionManageRoles}},
+		{Name: "staff", Permissions: []string{model.PermissionAccount}},
+	}
+
+	token := model.ToSessionToken("S123", u, roles, now, now.Add(time.Hour))
+
+	expected := []string{model.PermissionAccount, model.PermissionManageRoles, model.PermissionManageUsers}
+	if got, want := token.Permissions, expected; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad permissions %v, expected %v", got, want)
+	}
+	if !token.HasPermission(model.PermissionManageUsers) {
+		t.Errorf("bad permission %s false, expected true", model.PermissionManageUsers)
+	}
+	if token.HasPermission("bogus") {
+		t.Error("bad permission bogus true, expected false")
+	}
+
+	if token := model.ToSessionToken("S123", u, nil, now, now.Add(time.Hour)); token.Permissions != nil {
+		t.Errorf("bad permissions %v, expected none", token.Permissions)
+	}
+
+}
@@ -12,9 +12,21 @@ const (
 	// see 02_up.sql
 )
 
+type Role struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// IsBuiltin reports if the role is one of the roles required by the application itself.
+func (z Role) IsBuiltin() bool {
+	return z.ID == RoleIDAdmin || z.ID == RoleIDUser
+}
+
 type UserRole struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	ValidFrom *time.Time `json:"validFrom,omitempty"`
-	ValidTo   *time.Time `json:"validTo,omitempty"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions,omitempty"`
+	ValidFrom   *time.Time `json:"validFrom,omitempty"`
+	ValidTo     *time.Time `json:"validTo,omitempty"`
 }
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// Session is the server-side record of a login, keyed by the SessionID carried in the JWT.
+type Session struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userID"`
+	Issued    time.Time  `json:"issued"`
+	LastSeen  time.Time  `json:"lastSeen"`
+	Expires   time.Time  `json:"expires"`
+	Revoked   *time.Time `json:"revoked,omitempty"`
+	UserAgent string     `json:"userAgent"`
+	IPAddress string     `json:"ipAddress"`
+	// ImpersonatorID is the user acting as the session user, if any.
+	ImpersonatorID string `json:"impersonatorID,omitempty"`
+}
+
+// IsActive tests if the session is neither revoked nor expired at the given time.
+func (z *Session) IsActive(t time.Time) bool {
+	if z.Revoked != nil {
+		return false
+	}
+	return t.Before(z.Expires)
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+// SessionInfo describes a login session as shown to its owner.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Device    string    `json:"device"`
+	UserAgent string    `json:"userAgent"`
+	IPAddress string    `json:"ipAddress"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Expires   time.Time `json:"expires"`
+	Current   bool      `json:"current"`
+	Connected bool      `json:"connected"`
+}
+
+type ListSessionsRequest struct {
+	UserID    string `json:"-"`
+	SessionID string `json:"-"`
+}
+
+type ListSessionsResponse struct {
+	Code     int
+	Message  string
+	Sessions []SessionInfo
+}
+
+type TerminateSessionRequest struct {
+	UserID           string `json:"-"`
+	SessionID        string `json:"-"`
+	CurrentSessionID string `json:"-"`
+}
+
+type TerminateSessionResponse struct {
+	Code    int
+	Message string
+}
+
+type TerminateOtherSessionsRequest struct {
+	UserID    string `json:"-"`
+	SessionID string `json:"-"`
+}
+
+type TerminateOtherSessionsResponse struct {
+	Code    int
+	Message string
+	Count   int
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	ThrottleUsername  = "username"
+	ThrottleIPAddress = "ip"
+)
+
+// ThrottlePolicy defines when failed logins lead to a lockout.
+// Every failure after the Threshold doubles the lockout, up to MaxLockout.
+type ThrottlePolicy struct {
+	Threshold  int           // failures before the first lockout
+	Lockout    time.Duration // duration of the first lockout
+	MaxLockout time.Duration
+	Reset      time.Duration // failures are forgotten after this period without a failure
+}
+
+var (
+	UsernameThrottlePolicy = ThrottlePolicy{
+		Threshold:  5,
+		Lockout:    time.Minute,
+		MaxLockout: time.Hour,
+		Reset:      time.Hour * 24,
+	}
+	// IPAddressThrottlePolicy is more lenient as several users may share an address.
+	IPAddressThrottlePolicy = ThrottlePolicy{
+		Threshold:  20,
+		Lockout:    time.Minute,
+		MaxLockout: time.Hour,
+		Reset:      time.Hour * 24,
+	}
+)
+
+// LoginThrottle counts the failed logins for a username or an IP address.
+type LoginThrottle struct {
+	Scope       string     `json:"scope"`
+	Subject     string     `json:"subject"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"lastFailure"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// RetryAfter returns the remaining lockout time or zero if not locked.
+func (z *LoginThrottle) RetryAfter(t time.Time) time.Duration {
+	if z == nil || z.LockedUntil == nil || !t.Before(*z.LockedUntil) {
+		return 0
+	}
+	return z.LockedUntil.Sub(t)
+}
+
+// AddFailure records a failed login at the given time and returns true if it leads to a lockout.
+func (z *LoginThrottle) AddFailure(policy ThrottlePolicy, t time.Time) bool {
+
+	if t.Sub(z.LastFailure) > policy.Reset {
+		z.Failures = 0
+	}
+	z.Failures++
+	z.LastFailure = t
+	z.LockedUntil = nil
+
+	if z.Failures < policy.Threshold {
+		return false
+	}
+
+	lockout := policy.MaxLockout
+	if n := uint(z.Failures - policy.Threshold); n < 32 {
+		if d := policy.Lockout << n; d < lockout {
+			lockout = d
+		}
+	}
+	lockedUntil := t.Add(lockout)
+	z.LockedUntil = &lockedUntil
+
+	return true
+
+}
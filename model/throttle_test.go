@@ -0,0 +1,52 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"wallawire/model"
+)
+
+func TestLoginThrottle(t *testing.T) {
+
+	policy := model.ThrottlePolicy{
+		Threshold:  3,
+		Lockout:    time.Minute,
+		MaxLockout: time.Minute * 5,
+		Reset:      time.Hour,
+	}
+	now := time.Now().Truncate(time.Second)
+	throttle := &model.LoginThrottle{
+		Scope:   model.ThrottleUsername,
+		Subject: "demouser",
+	}
+
+	expectedLockouts := []time.Duration{0, 0, time.Minute, time.Minute * 2, time.Minute * 4, time.Minute * 5, time.Minute * 5}
+	for i, expected := range expectedLockouts {
+		locked := throttle.AddFailure(policy, now)
+		if got, want := locked, expected != 0; got != want {
+			t.Errorf("Bad locked %d: %t, expected %t", i, got, want)
+		}
+		if got, want := throttle.RetryAfter(now), expected; got != want {
+			t.Errorf("Bad retry after %d: %s, expected %s", i, got, want)
+		}
+		if got, want := throttle.RetryAfter(now.Add(expected)), time.Duration(0); got != want {
+			t.Errorf("Bad retry after lockout %d: %s, expected %s", i, got, want)
+		}
+	}
+
+	// forgotten after reset period
+	later := now.Add(policy.Reset + time.Second)
+	if throttle.AddFailure(policy, later) {
+		t.Error("Bad locked after reset: true, expected false")
+	}
+	if got, want := throttle.Failures, 1; got != want {
+		t.Errorf("Bad failures after reset: %d, expected %d", got, want)
+	}
+
+	var none *model.LoginThrottle
+	if got, want := none.RetryAfter(now), time.Duration(0); got != want {
+		t.Errorf("Bad retry after without throttle: %s, expected %s", got, want)
+	}
+
+}
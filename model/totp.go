@@ -0,0 +1,37 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// UserTOTP holds the time-based one-time password secret of a user.
+// Two-factor authentication is only enabled once the enrollment has been confirmed with a first code.
+type UserTOTP struct {
+	UserID      string     `json:"userID"`
+	Secret      string     `json:"-"`
+	LastCounter int64      `json:"-"`
+	Created     time.Time  `json:"created"`
+	Confirmed   *time.Time `json:"confirmed,omitempty"`
+}
+
+// IsEnabled tests if the enrollment has been confirmed.
+func (z *UserTOTP) IsEnabled() bool {
+	return z != nil && z.Confirmed != nil
+}
+
+// NormalizeRecoveryCode strips separators and case from a recovery code as typed by a user.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	return code
+}
+
+// HashRecoveryCode returns the hash of a recovery code as stored in the database.
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
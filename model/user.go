@@ -1,14 +1,15 @@
 package model
 
 import (
-	"encoding/hex"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	LoginTimeout = time.Hour * 24
+	// SessionLifetime limits how long a session can be kept alive by renewing its token
+	SessionLifetime = time.Hour * 24 * 30
+	// ImpersonationTimeout limits sessions issued to an administrator acting as another user, they are not renewed
+	ImpersonationTimeout = time.Hour
 )
 
 // User defines a system user
@@ -17,42 +18,59 @@ type User struct {
 	Disabled     bool      `json:"disabled"`
 	Username     string    `json:"username"`
 	Name         string    `json:"name"`
+	Email        string    `json:"email,omitempty"`
 	PasswordHash string    `json:"passwordHash"`
 	Created      time.Time `json:"created"`
 	Updated      time.Time `json:"updated"`
 }
 
+// UserFilter selects a page of users.
+// Username matches any part of the username, ignoring case; Disabled is ignored if nil.
+type UserFilter struct {
+	Username string
+	Disabled *bool
+	Offset   int
+	Limit    int
+}
+
 // MatchPassword checks if the given password matches the user password.
 func (z *User) MatchPassword(password string) bool {
-	hashedPassword, err := hex.DecodeString(z.PasswordHash)
-	if err != nil {
-		return false
-	}
-	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(password)) == nil
+	return MatchPasswordHash(z.PasswordHash, password)
 }
 
-// SetPassword updates the password hashes.
+// SetPassword updates the password hash using DefaultPasswordHashing.
 func (z *User) SetPassword(password string) error {
+	return z.SetPasswordWith(DefaultPasswordHashing, password)
+}
 
-	bhash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// SetPasswordWith updates the password hash using the given hashing.
+func (z *User) SetPasswordWith(hashing PasswordHashing, password string) error {
+
+	hash, err := hashing.Hash(password)
 	if err != nil {
 		return err
 	}
-	z.PasswordHash = hex.EncodeToString(bhash)
+	z.PasswordHash = hash
 
 	return nil
 
 }
 
-// SessionToken is constructed from the JWT claims and stored in the request context
+// SessionToken is constructed from the JWT claims and stored in the request context.
+// APITokenID is set instead of SessionID when the request is authenticated by an API token.
+// Permissions are those of the roles at the time the token was issued.
 type SessionToken struct {
-	SessionID string    `json:"sessionID"`
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Name      string    `json:"name"`
-	Roles     []string  `json:"roles"`
-	Issued    time.Time `json:"-"`
-	Expires   time.Time `json:"-"`
+	SessionID   string   `json:"sessionID"`
+	APITokenID  string   `json:"apiTokenID,omitempty"`
+	ID          string   `json:"id"`
+	Username    string   `json:"username"`
+	Name        string   `json:"name"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	// Impersonator is the ID of the user acting as this user, if any.
+	Impersonator string    `json:"impersonator,omitempty"`
+	Issued       time.Time `json:"-"`
+	Expires      time.Time `json:"-"`
 }
 
 // HasRole tests if the user has been assigned the given role
@@ -67,6 +85,16 @@ func (z *SessionToken) HasRole(role string) bool {
 	return false
 }
 
+// HasPermission tests if one of the roles of the user grants the given permission
+func (z *SessionToken) HasPermission(permission string) bool {
+	for _, value := range z.Permissions {
+		if value == permission {
+			return true
+		}
+	}
+	return false
+}
+
 func ToSessionToken(sessionID string, u *User, roles []UserRole, issued, expires time.Time) *SessionToken {
 
 	if u == nil {
@@ -79,13 +107,14 @@ func ToSessionToken(sessionID string, u *User, roles []UserRole, issued, expires
 	}
 
 	return &SessionToken{
-		SessionID: sessionID,
-		ID:        u.ID,
-		Username:  u.Username,
-		Name:      u.Name,
-		Roles:     rolenames,
-		Issued:    issued,
-		Expires:   expires,
+		SessionID:   sessionID,
+		ID:          u.ID,
+		Username:    u.Username,
+		Name:        u.Name,
+		Roles:       rolenames,
+		Permissions: RolePermissions(roles),
+		Issued:      issued,
+		Expires:     expires,
 	}
 
 }
@@ -1,5 +1,9 @@
 package model
 
+import (
+	"time"
+)
+
 type ChangePasswordRequest struct {
 	UserID      string `json:"-"`
 	Password    string `json:"oldpassword"`
@@ -9,6 +13,7 @@ type ChangePasswordRequest struct {
 type ChangePasswordResponse struct {
 	Code         int
 	Message      string
+	Rule         string
 	SessionToken *SessionToken
 }
 
@@ -36,12 +41,123 @@ type ChangeUsernameResponse struct {
 }
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
+// LoginResponse carries the SessionToken of a successful login.
+// If the user has two-factor authentication enabled, OTPRequired is set instead
+// and the login must be completed with a LoginOTPRequest for UserID and the single-use ChallengeID.
+// RetryAfter is set if the login has been refused because of too many failed logins.
 type LoginResponse struct {
 	Code         int
 	Message      string
 	SessionToken *SessionToken
+	OTPRequired  bool
+	UserID       string
+	ChallengeID  string
+	RetryAfter   time.Duration
+}
+
+// ExternalLoginRequest carries the verified claims of an identity provider.
+// Name, PreferredUsername and a verified Email are used when a new user is provisioned.
+type ExternalLoginRequest struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	UserAgent         string
+	IPAddress         string
+}
+
+type LoginOTPRequest struct {
+	UserID      string `json:"-"`
+	ChallengeID string `json:"-"`
+	Challenge   string `json:"challenge"`
+	Code        string `json:"code"`
+	UserAgent   string `json:"-"`
+	IPAddress   string `json:"-"`
+}
+
+type EnrollTOTPRequest struct {
+	UserID   string `json:"-"`
+	Password string `json:"password"`
+}
+
+type EnrollTOTPResponse struct {
+	Code    int
+	Message string
+	Secret  string
+	URI     string
+}
+
+type ConfirmTOTPRequest struct {
+	UserID string `json:"-"`
+	Code   string `json:"code"`
+}
+
+type ConfirmTOTPResponse struct {
+	Code          int
+	Message       string
+	RecoveryCodes []string
+}
+
+type DisableTOTPRequest struct {
+	UserID   string `json:"-"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type DisableTOTPResponse struct {
+	Code    int
+	Message string
+}
+
+// RegisterRequest creates a disabled user which is enabled once the email address has been verified.
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RegisterResponse struct {
+	Code    int
+	Message string
+	Rule    string
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type VerifyEmailResponse struct {
+	Code    int
+	Message string
+}
+
+// ForgotPasswordRequest sends a password reset token to the user.
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+type ForgotPasswordResponse struct {
+	Code    int
+	Message string
+}
+
+// ResetForgottenPasswordRequest sets a new password with the token sent for a ForgotPasswordRequest.
+type ResetForgottenPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ResetForgottenPasswordResponse struct {
+	Code    int
+	Message string
+	Rule    string
 }
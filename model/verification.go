@@ -0,0 +1,32 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	VerificationTimeout = time.Hour * 24
+)
+
+// UserVerification is a pending email verification of a registered user.
+// Only the hash of the token sent by mail is stored.
+type UserVerification struct {
+	TokenHash string    `json:"-"`
+	UserID    string    `json:"userID"`
+	Email     string    `json:"email"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// IsExpired tests if the verification is no longer valid at the given time.
+func (z *UserVerification) IsExpired(t time.Time) bool {
+	return !t.Before(z.Expires)
+}
+
+// HashToken returns the hash of a token sent by mail as stored in the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbAPIToken struct {
+	ID        sql.NullString `db:"id"`
+	UserID    sql.NullString `db:"user_id"`
+	Name      sql.NullString `db:"name"`
+	TokenHash sql.NullString `db:"token_hash"`
+	Scopes    sql.NullString `db:"scopes"`
+	Created   sql.NullInt64  `db:"created"`
+	Expires   sql.NullInt64  `db:"expires"`
+	LastUsed  sql.NullInt64  `db:"last_used"`
+	Revoked   sql.NullInt64  `db:"revoked"`
+}
+
+// GetAPIToken returns the API token with the given hash, nil if not found.
+func (z *Repository) GetAPIToken(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.APIToken, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetAPIToken")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, user_id, name, token_hash, scopes, created, expires, last_used, revoked
+	FROM api_tokens
+	WHERE token_hash = :tokenHash
+	`
+	params := map[string]interface{}{
+		"tokenHash": tokenHash,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var apiToken *model.APIToken
+
+	if rs.Next() {
+		a := dbAPIToken{}
+		if err := rs.StructScan(&a); err != nil {
+			return nil, err
+		}
+		apiToken = convertToAPIToken(a)
+	}
+
+	return apiToken, nil
+
+}
+
+// GetUserAPITokens returns all API tokens of a user, most recently created first.
+func (z *Repository) GetUserAPITokens(ctx context.Context, tx model.ReadOnlyTransaction, userID string) ([]model.APIToken, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetUserAPITokens")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, user_id, name, token_hash, scopes, created, expires, last_used, revoked
+	FROM api_tokens
+	WHERE user_id = :userID
+	ORDER BY created DESC
+	`
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	apiTokens := make([]model.APIToken, 0)
+	for rs.Next() {
+		var a dbAPIToken
+		if err := rs.StructScan(&a); err != nil {
+			return nil, err
+		}
+		apiTokens = append(apiTokens, *convertToAPIToken(a))
+	}
+
+	return apiTokens, nil
+
+}
+
+// SetAPIToken adds an API token or updates its last-used time.
+func (z *Repository) SetAPIToken(ctx context.Context, tx model.WriteOnlyTransaction, apiToken model.APIToken) error {
+
+	logger := logging.New(ctx, componentRepo, "SetAPIToken")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created, expires, last_used, revoked)
+	VALUES (:id, :userID, :name, :tokenHash, :scopes, :created, :expires, :lastUsed, :revoked)
+	ON CONFLICT (id) DO UPDATE SET
+	last_used = :lastUsed
+	`
+	params := apiTokenToParams(apiToken)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// RevokeAPIToken marks the API token of the given user as revoked at the given time, if not already revoked.
+// It returns false if there is no such active token.
+func (z *Repository) RevokeAPIToken(ctx context.Context, tx model.WriteOnlyTransaction, userID, apiTokenID string, t time.Time) (bool, error) {
+
+	logger := logging.New(ctx, componentRepo, "RevokeAPIToken")
+	logger.Debug().Msg("invoked")
+
+	query := "UPDATE api_tokens SET revoked = :revoked WHERE id = :id AND user_id = :userID AND revoked IS NULL"
+	params := map[string]interface{}{
+		"id":      apiTokenID,
+		"userID":  userID,
+		"revoked": toNullTimeInteger(&t),
+	}
+	rs, errExec := tx.Exec(query, params)
+	if errExec != nil {
+		return false, errExec
+	}
+	count, errCount := rs.RowsAffected()
+	if errCount != nil {
+		return false, errCount
+	}
+	return count == 1, nil
+
+}
+
+func (z *Repository) deleteUserAPITokens(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM api_tokens WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToAPIToken(a dbAPIToken) *model.APIToken {
+	var scopes []string
+	if len(a.Scopes.String) != 0 {
+		scopes = strings.Split(a.Scopes.String, ",")
+	}
+	return &model.APIToken{
+		ID:        a.ID.String,
+		UserID:    a.UserID.String,
+		Name:      a.Name.String,
+		TokenHash: a.TokenHash.String,
+		Scopes:    scopes,
+		Created:   toTime(a.Created),
+		Expires:   toTime(a.Expires),
+		LastUsed:  toTimePointer(toTime(a.LastUsed)),
+		Revoked:   toTimePointer(toTime(a.Revoked)),
+	}
+}
+
+func apiTokenToParams(a model.APIToken) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        toNullString(a.ID),
+		"userID":    toNullString(a.UserID),
+		"name":      toNullString(a.Name),
+		"tokenHash": toNullString(a.TokenHash),
+		"scopes":    strings.Join(a.Scopes, ","),
+		"created":   toNullTimeInteger(&a.Created),
+		"expires":   toNullTimeInteger(&a.Expires),
+		"lastUsed":  toNullTimeInteger(a.LastUsed),
+		"revoked":   toNullTimeInteger(a.Revoked),
+	}
+}
@@ -0,0 +1,86 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestAPIToken(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	apiToken := model.APIToken{
+		ID:        "bm5pa3d3ge5ul2tbj6t0",
+		UserID:    userIDFakeuser,
+		Name:      "backup script",
+		TokenHash: model.HashToken("wwt_token"),
+		Scopes:    []string{"staff", "reporter"},
+		Created:   now.UTC(),
+		Expires:   now3d.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetAPIToken(ctx, tx, apiToken); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		a, errGet := us.GetAPIToken(ctx, tx, apiToken.TokenHash)
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(a, &apiToken) {
+			t.Errorf("Bad API token: %v, expected %v", a, apiToken)
+		}
+
+		// Touch
+		lastUsed := now1h.UTC()
+		apiToken.LastUsed = &lastUsed
+		if err := us.SetAPIToken(ctx, tx, apiToken); err != nil {
+			t.Fatalf("Bad update error: %s", err)
+		}
+
+		// Revoke
+		if ok, err := us.RevokeAPIToken(ctx, tx, userIDGuest, apiToken.ID, now2h); err != nil || ok {
+			t.Fatalf("Bad revoke of other user: %t %v, expected false", ok, err)
+		}
+		if ok, err := us.RevokeAPIToken(ctx, tx, userIDFakeuser, apiToken.ID, now2h); err != nil || !ok {
+			t.Fatalf("Bad revoke: %t %v, expected true", ok, err)
+		}
+		if ok, err := us.RevokeAPIToken(ctx, tx, userIDFakeuser, apiToken.ID, now2h); err != nil || ok {
+			t.Fatalf("Bad revoke of revoked token: %t %v, expected false", ok, err)
+		}
+
+		// List
+		apiTokens, errList := us.GetUserAPITokens(ctx, tx, userIDFakeuser)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := len(apiTokens), 1; got != want {
+			t.Fatalf("Bad API token count: %d, expected %d", got, want)
+		}
+		revoked := now2h.UTC()
+		apiToken.Revoked = &revoked
+		if !reflect.DeepEqual(apiTokens[0], apiToken) {
+			t.Errorf("Bad API token: %v, expected %v", apiTokens[0], apiToken)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbAuditEvent struct {
+	ID            sql.NullString `db:"id"`
+	Created       sql.NullInt64  `db:"created"`
+	Action        sql.NullString `db:"action"`
+	Outcome       sql.NullString `db:"outcome"`
+	ActorID       sql.NullString `db:"actor_id"`
+	ActorName     sql.NullString `db:"actor_name"`
+	TargetID      sql.NullString `db:"target_id"`
+	Message       sql.NullString `db:"message"`
+	IPAddress     sql.NullString `db:"ip_address"`
+	UserAgent     sql.NullString `db:"user_agent"`
+	CorrelationID sql.NullString `db:"correlation_id"`
+}
+
+func (z *Repository) AddAuditEvent(ctx context.Context, tx model.WriteOnlyTransaction, event model.AuditEvent) error {
+
+	logger := logging.New(ctx, componentRepo, "AddAuditEvent")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO audit_events (id, created, action, outcome, actor_id, actor_name, target_id, message, ip_address, user_agent, correlation_id)
+	VALUES (:id, :created, :action, :outcome, :actorID, :actorName, :targetID, :message, :ipAddress, :userAgent, :correlationID)
+	`
+	params := map[string]interface{}{
+		"id":            toNullString(event.ID),
+		"created":       toNullTimeInteger(&event.Created),
+		"action":        toNullString(event.Action),
+		"outcome":       toNullString(event.Outcome),
+		"actorID":       toNullString(event.ActorID),
+		"actorName":     toNullString(event.ActorName),
+		"targetID":      toNullString(event.TargetID),
+		"message":       toNullString(event.Message),
+		"ipAddress":     toNullString(event.IPAddress),
+		"userAgent":     toNullString(event.UserAgent),
+		"correlationID": toNullString(event.CorrelationID),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+// ListAuditEvents returns a page of audit events matching the filter, most recent first, and the total number of matches.
+func (z *Repository) ListAuditEvents(ctx context.Context, tx model.ReadOnlyTransaction, filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+
+	logger := logging.New(ctx, componentRepo, "ListAuditEvents")
+	logger.Debug().Msg("invoked")
+
+	where := "WHERE 1 = 1 "
+	params := map[string]interface{}{
+		"offset": filter.Offset,
+		"limit":  filter.Limit,
+	}
+
+	if filter.ActorID != "" {
+		where += "AND actor_id = :actorID "
+		params["actorID"] = filter.ActorID
+	}
+
+	if filter.TargetID != "" {
+		where += "AND target_id = :targetID "
+		params["targetID"] = filter.TargetID
+	}
+
+	if filter.Action != "" {
+		where += "AND action = :action "
+		params["action"] = filter.Action
+	}
+
+	if filter.Outcome != "" {
+		where += "AND outcome = :outcome "
+		params["outcome"] = filter.Outcome
+	}
+
+	if filter.From != nil {
+		where += "AND created >= :from "
+		params["from"] = filter.From.Unix()
+	}
+
+	if filter.To != nil {
+		where += "AND created < :to "
+		params["to"] = filter.To.Unix()
+	}
+
+	if filter.BeforeCreated != nil {
+		where += "AND (created < :beforeCreated OR (created = :beforeCreated AND id < :beforeID)) "
+		params["beforeCreated"] = filter.BeforeCreated.Unix()
+		params["beforeID"] = filter.BeforeID
+	}
+
+	total, errCount := z.countAuditEvents(ctx, tx, where, params)
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+
+	query := `
+	SELECT id, created, action, outcome, actor_id, actor_name, target_id, message, ip_address, user_agent, correlation_id
+	FROM audit_events
+	` + where + `
+	ORDER BY created DESC, id DESC
+	LIMIT :limit OFFSET :offset
+	`
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, 0, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	events := make([]model.AuditEvent, 0)
+	for rs.Next() {
+		e := dbAuditEvent{}
+		if err := rs.StructScan(&e); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, convertToAuditEvent(e))
+	}
+
+	return events, total, nil
+
+}
+
+func (z *Repository) countAuditEvents(ctx context.Context, tx model.ReadOnlyTransaction, where string, params map[string]interface{}) (int, error) {
+
+	logger := logging.New(ctx, componentRepo, "countAuditEvents")
+
+	rs, errQuery := tx.Query("SELECT COUNT(*) FROM audit_events "+where, params)
+	if errQuery != nil {
+		return 0, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var total int
+	if rs.Next() {
+		if err := rs.Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+
+}
+
+func convertToAuditEvent(e dbAuditEvent) model.AuditEvent {
+	return model.AuditEvent{
+		ID:            e.ID.String,
+		Created:       toTime(e.Created),
+		Action:        e.Action.String,
+		Outcome:       e.Outcome.String,
+		ActorID:       e.ActorID.String,
+		ActorName:     e.ActorName.String,
+		TargetID:      e.TargetID.String,
+		Message:       e.Message.String,
+		IPAddress:     e.IPAddress.String,
+		UserAgent:     e.UserAgent.String,
+		CorrelationID: e.CorrelationID.String,
+	}
+}
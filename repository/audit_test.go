@@ -0,0 +1,119 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+const (
+	auditCorrelationID = "audit-test"
+)
+
+func init() {
+
+	tStatements := []string{
+		"DELETE FROM audit_events WHERE correlation_id = '" + auditCorrelationID + "'",
+	}
+
+	addTestStatements(nil, tStatements)
+
+}
+
+func TestAuditEvents(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	failure := model.AuditEvent{
+		ID:            "bm5pa3d3ge5ul2tbj6a0",
+		Created:       now.UTC(),
+		Action:        model.AuditLogin,
+		Outcome:       model.AuditFailure,
+		ActorName:     "fakeuser",
+		Message:       "bad password",
+		IPAddress:     "192.0.2.1",
+		UserAgent:     "test-agent",
+		CorrelationID: auditCorrelationID,
+	}
+	success := model.AuditEvent{
+		ID:            "bm5pa3d3ge5ul2tbj6a1",
+		Created:       now1h.UTC(),
+		Action:        model.AuditLogin,
+		Outcome:       model.AuditSuccess,
+		ActorID:       userIDFakeuser,
+		ActorName:     "fakeuser",
+		TargetID:      userIDFakeuser,
+		IPAddress:     "192.0.2.1",
+		CorrelationID: auditCorrelationID,
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		for _, event := range []model.AuditEvent{failure, success} {
+			if err := us.AddAuditEvent(ctx, tx, event); err != nil {
+				t.Fatalf("Bad add error: %s", err)
+			}
+		}
+
+		// most recent first
+		from := now.UTC()
+		events, total, errList := us.ListAuditEvents(ctx, tx, model.AuditFilter{Action: model.AuditLogin, From: &from, Limit: 10})
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := total, 2; got != want {
+			t.Errorf("Bad total: %d, expected %d", got, want)
+		}
+		if got, want := events, []model.AuditEvent{success, failure}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad events: %v, expected %v", got, want)
+		}
+
+		// filter and paging
+		to := now1h.UTC()
+		events, total, errList = us.ListAuditEvents(ctx, tx, model.AuditFilter{Outcome: model.AuditFailure, From: &from, To: &to, Limit: 10})
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := total, 1; got != want {
+			t.Errorf("Bad total: %d, expected %d", got, want)
+		}
+		if got, want := events, []model.AuditEvent{failure}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad events: %v, expected %v", got, want)
+		}
+
+		// keyset paging
+		events, _, errList = us.ListAuditEvents(ctx, tx, model.AuditFilter{Action: model.AuditLogin, From: &from, BeforeCreated: &success.Created, BeforeID: success.ID, Limit: 10})
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := events, []model.AuditEvent{failure}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad events: %v, expected %v", got, want)
+		}
+
+		events, total, errList = us.ListAuditEvents(ctx, tx, model.AuditFilter{ActorID: userIDFakeuser, From: &from, Offset: 1, Limit: 10})
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := total, 1; got != want {
+			t.Errorf("Bad total: %d, expected %d", got, want)
+		}
+		if got, want := len(events), 0; got != want {
+			t.Errorf("Bad event count: %d, expected %d", got, want)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	componentBroker      = "PushBroker"
+	notifyPayloadLimit   = 8000 // bytes, the limit of PostgreSQL
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPing         = time.Minute
+)
+
+// PushBroker publishes the push messages with PostgreSQL NOTIFY over the database connection
+// and receives them with LISTEN on a dedicated connection, reconnecting after failures.
+// Messages published while the listener is disconnected are not received.
+// CockroachDB does not support LISTEN/NOTIFY, the broker refuses to start with it.
+type PushBroker struct {
+	db          *sqlx.DB
+	listener    *pq.Listener
+	channel     string
+	lock        sync.RWMutex
+	subscribers []func(msg model.BrokerMessage)
+	done        chan struct{}
+}
+
+// NewPushBroker listens on the channel with a new connection to the database at url.
+func NewPushBroker(db *sqlx.DB, url, channel string) (*PushBroker, error) {
+
+	logger := logging.New(nil, componentBroker, "listener")
+
+	var version string
+	if err := db.Get(&version, "SELECT version()"); err != nil {
+		return nil, err
+	}
+	if strings.Contains(version, "CockroachDB") {
+		return nil, errors.New("CockroachDB does not support LISTEN/NOTIFY, use the database broker")
+	}
+
+	listener := pq.NewListener(url, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Warn().Err(err).Msg("disconnected")
+		case pq.ListenerEventReconnected:
+			logger.Info().Msg("reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Warn().Err(err).Msg("connection attempt failed")
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	z := &PushBroker{
+		db:       db,
+		listener: listener,
+		channel:  channel,
+		done:     make(chan struct{}),
+	}
+	go z.run()
+
+	return z, nil
+
+}
+
+func (z *PushBroker) Publish(msg model.BrokerMessage) error {
+	data, errData := json.Marshal(msg)
+	if errData != nil {
+		return errData
+	}
+	if len(data) >= notifyPayloadLimit {
+		return errors.New("message too large to publish")
+	}
+	_, err := z.db.Exec("SELECT pg_notify($1, $2)", z.channel, string(data))
+	return err
+}
+
+func (z *PushBroker) Subscribe(fn func(msg model.BrokerMessage)) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.subscribers = append(z.subscribers, fn)
+}
+
+// Close stops listening and closes the dedicated connection.
+func (z *PushBroker) Close() error {
+	err := z.listener.Close()
+	<-z.done
+	return err
+}
+
+func (z *PushBroker) run() {
+
+	logger := logging.New(nil, componentBroker, "run")
+	logger.Debug().Str("channel", z.channel).Msg("starting...")
+
+	ticker := time.NewTicker(listenerPing)
+
+Loop:
+	for {
+		select {
+		case n, ok := <-z.listener.Notify:
+			if !ok {
+				break Loop
+			}
+			if n == nil {
+				continue // reconnected
+			}
+			var msg model.BrokerMessage
+			if err := json.Unmarshal([]byte(n.Extra), &msg); err != nil {
+				logger.Warn().Err(err).Msg("cannot decode message")
+				continue
+			}
+			z.lock.RLock()
+			subscribers := z.subscribers
+			z.lock.RUnlock()
+			for _, fn := range subscribers {
+				fn(msg)
+			}
+		case <-ticker.C:
+			// detects a broken connection early, the listener reconnects itself
+			go z.listener.Ping()
+		}
+	}
+
+	ticker.Stop()
+	logger.Debug().Msg("exiting")
+	close(z.done)
+
+}
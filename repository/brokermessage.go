@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbBrokerMessage struct {
+	Origin    sql.NullString `db:"origin"`
+	Serial    sql.NullInt64  `db:"serial"`
+	Created   sql.NullInt64  `db:"created"`
+	UserID    sql.NullString `db:"user_id"`
+	SessionID sql.NullString `db:"session_id"`
+	Topic     sql.NullString `db:"topic"`
+	Control   sql.NullString `db:"control"`
+	ID        sql.NullString `db:"id"`
+	Type      sql.NullString `db:"type"`
+	Data      sql.NullString `db:"data"`
+}
+
+// AddBrokerMessage publishes the message to the instances polling the broker messages.
+func (z *Repository) AddBrokerMessage(ctx context.Context, tx model.WriteOnlyTransaction, msg model.BrokerMessage, created time.Time) error {
+
+	logger := logging.New(ctx, componentRepo, "AddBrokerMessage")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO push_broker_messages (origin, serial, created, user_id, session_id, topic, control, id, type, data)
+	VALUES (:origin, :serial, :created, :userID, :sessionID, :topic, :control, :id, :type, :data)
+	`
+	params := map[string]interface{}{
+		"origin":    msg.Origin,
+		"serial":    int64(msg.Serial),
+		"created":   toNullTimeInteger(&created),
+		"userID":    msg.UserID,
+		"sessionID": msg.SessionID,
+		"topic":     msg.Topic,
+		"control":   toNullString(msg.Control),
+		"id":        toNullString(msg.Message.ID),
+		"type":      toNullString(msg.Message.Type),
+		"data":      toNullString(msg.Message.Data),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+// ListBrokerMessages returns the messages published since the given time, oldest first.
+func (z *Repository) ListBrokerMessages(ctx context.Context, tx model.ReadOnlyTransaction, since time.Time) ([]model.BrokerMessage, error) {
+
+	logger := logging.New(ctx, componentRepo, "ListBrokerMessages")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT origin, serial, created, user_id, session_id, topic, control, id, type, data
+	FROM push_broker_messages
+	WHERE created >= :since
+	ORDER BY created, origin, serial
+	`
+	params := map[string]interface{}{
+		"since": toNullTimeInteger(&since),
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var messages []model.BrokerMessage
+	for rs.Next() {
+		m := dbBrokerMessage{}
+		if err := rs.StructScan(&m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, convertToBrokerMessage(m))
+	}
+
+	return messages, nil
+
+}
+
+// DeleteBrokerMessages removes the messages published before the given time.
+func (z *Repository) DeleteBrokerMessages(ctx context.Context, tx model.WriteOnlyTransaction, before time.Time) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteBrokerMessages")
+	logger.Debug().Msg("invoked")
+
+	query := "DELETE FROM push_broker_messages WHERE created < :before"
+	params := map[string]interface{}{
+		"before": toNullTimeInteger(&before),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToBrokerMessage(m dbBrokerMessage) model.BrokerMessage {
+	return model.BrokerMessage{
+		Origin:    m.Origin.String,
+		Serial:    uint64(m.Serial.Int64),
+		UserID:    m.UserID.String,
+		SessionID: m.SessionID.String,
+		Topic:     m.Topic.String,
+		Control:   m.Control.String,
+		Message: model.PushMessage{
+			ID:   m.ID.String,
+			Type: m.Type.String,
+			Data: m.Data.String,
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+const (
+	brokerOriginTest = "repository-test"
+)
+
+func init() {
+
+	tStatements := []string{
+		"DELETE FROM push_broker_messages WHERE origin = '" + brokerOriginTest + "'",
+	}
+
+	addTestStatements(nil, tStatements)
+
+}
+
+func TestBrokerMessages(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	m1 := model.BrokerMessage{
+		Origin:  brokerOriginTest,
+		Serial:  1,
+		UserID:  userIDFakeuser,
+		Message: model.PushMessage{ID: "42", Type: "test", Data: "user"},
+	}
+	m2 := model.BrokerMessage{
+		Origin:  brokerOriginTest,
+		Serial:  2,
+		Topic:   "news",
+		Message: model.PushMessage{Type: "test", Data: "topic"},
+	}
+	m3 := model.BrokerMessage{
+		Origin:    brokerOriginTest,
+		Serial:    3,
+		UserID:    userIDFakeuser,
+		SessionID: "S1",
+		Control:   model.BrokerControlDisconnect,
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		if err := us.AddBrokerMessage(ctx, tx, m1, now1h); err != nil {
+			t.Fatalf("Bad add error: %s", err)
+		}
+		if err := us.AddBrokerMessage(ctx, tx, m2, now2h); err != nil {
+			t.Fatalf("Bad add error: %s", err)
+		}
+		if err := us.AddBrokerMessage(ctx, tx, m3, now2h); err != nil {
+			t.Fatalf("Bad add error: %s", err)
+		}
+
+		messages, errList := us.ListBrokerMessages(ctx, tx, now1h)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := messages, []model.BrokerMessage{m1, m2, m3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad messages: %v, expected %v", got, want)
+		}
+
+		// messages published before are deleted
+		if err := us.DeleteBrokerMessages(ctx, tx, now2h); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		messages, errList = us.ListBrokerMessages(ctx, tx, now1h)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := messages, []model.BrokerMessage{m2, m3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad messages: %v, expected %v", got, want)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbLoginChallenge struct {
+	ID       sql.NullString `db:"id"`
+	UserID   sql.NullString `db:"user_id"`
+	Expires  sql.NullInt64  `db:"expires"`
+	Failures sql.NullInt64  `db:"failures"`
+}
+
+func (z *Repository) GetLoginChallenge(ctx context.Context, tx model.ReadOnlyTransaction, challengeID string) (*model.LoginChallenge, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetLoginChallenge")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, user_id, expires, failures
+	FROM login_challenges
+	WHERE id = :challengeID
+	`
+	params := map[string]interface{}{
+		"challengeID": challengeID,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var challenge *model.LoginChallenge
+
+	if rs.Next() {
+		c := dbLoginChallenge{}
+		if err := rs.StructScan(&c); err != nil {
+			return nil, err
+		}
+		challenge = convertToLoginChallenge(c)
+	}
+
+	return challenge, nil
+
+}
+
+// SetLoginChallenge will add the challenge or update its failures, replacing any other challenge of the user.
+func (z *Repository) SetLoginChallenge(ctx context.Context, tx model.WriteOnlyTransaction, challenge model.LoginChallenge) error {
+
+	logger := logging.New(ctx, componentRepo, "SetLoginChallenge")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO login_challenges (id, user_id, expires, failures)
+	VALUES (:id, :userID, :expires, :failures)
+	ON CONFLICT (user_id) DO UPDATE SET
+	id = :id,
+	expires = :expires,
+	failures = :failures
+	`
+	params := loginChallengeToParams(challenge)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// DeleteLoginChallenge consumes a challenge.
+func (z *Repository) DeleteLoginChallenge(ctx context.Context, tx model.WriteOnlyTransaction, challengeID string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteLoginChallenge")
+	logger.Debug().Msg("invoked")
+
+	query := "DELETE FROM login_challenges WHERE id = :challengeID"
+	params := map[string]interface{}{
+		"challengeID": challengeID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func (z *Repository) deleteLoginChallenges(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM login_challenges WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToLoginChallenge(c dbLoginChallenge) *model.LoginChallenge {
+	return &model.LoginChallenge{
+		ID:       c.ID.String,
+		UserID:   c.UserID.String,
+		Expires:  toTime(c.Expires),
+		Failures: int(c.Failures.Int64),
+	}
+}
+
+func loginChallengeToParams(c model.LoginChallenge) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       toNullString(c.ID),
+		"userID":   toNullString(c.UserID),
+		"expires":  toNullTimeInteger(&c.Expires),
+		"failures": c.Failures,
+	}
+}
@@ -0,0 +1,84 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestLoginChallenge(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	challenge := model.LoginChallenge{
+		ID:      "C1",
+		UserID:  userIDGuest,
+		Expires: now1h.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetLoginChallenge(ctx, tx, challenge); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		c, errGet := us.GetLoginChallenge(ctx, tx, "C1")
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(c, &challenge) {
+			t.Errorf("Bad challenge: %v, expected %v", c, challenge)
+		}
+
+		// Update failures
+		challenge.Failures = 1
+		if err := us.SetLoginChallenge(ctx, tx, challenge); err != nil {
+			t.Fatalf("Bad update error: %s", err)
+		}
+		c, errGet = us.GetLoginChallenge(ctx, tx, "C1")
+		if errGet != nil {
+			t.Fatalf("Bad re-get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(c, &challenge) {
+			t.Errorf("Bad challenge: %v, expected %v", c, challenge)
+		}
+
+		// Replace
+		replacement := model.LoginChallenge{
+			ID:      "C2",
+			UserID:  userIDGuest,
+			Expires: now2h.UTC(),
+		}
+		if err := us.SetLoginChallenge(ctx, tx, replacement); err != nil {
+			t.Fatalf("Bad replace error: %s", err)
+		}
+		if c, _ := us.GetLoginChallenge(ctx, tx, "C1"); c != nil {
+			t.Errorf("Bad replaced challenge: %v, expected nil", c)
+		}
+
+		// Delete
+		if err := us.DeleteLoginChallenge(ctx, tx, "C2"); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		if c, _ := us.GetLoginChallenge(ctx, tx, "C2"); c != nil {
+			t.Errorf("Bad deleted challenge: %v, expected nil", c)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
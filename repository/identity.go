@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbUserIdentity struct {
+	Issuer  sql.NullString `db:"issuer"`
+	Subject sql.NullString `db:"subject"`
+	UserID  sql.NullString `db:"user_id"`
+	Email   sql.NullString `db:"email"`
+	Created sql.NullInt64  `db:"created"`
+}
+
+// GetUserIdentity returns the identity of the given issuer and subject, nil if not linked to a user.
+func (z *Repository) GetUserIdentity(ctx context.Context, tx model.ReadOnlyTransaction, issuer, subject string) (*model.UserIdentity, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetUserIdentity")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT issuer, subject, user_id, email, created
+	FROM user_identities
+	WHERE issuer = :issuer AND subject = :subject
+	`
+	params := map[string]interface{}{
+		"issuer":  issuer,
+		"subject": subject,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var identity *model.UserIdentity
+
+	if rs.Next() {
+		i := dbUserIdentity{}
+		if err := rs.StructScan(&i); err != nil {
+			return nil, err
+		}
+		identity = convertToUserIdentity(i)
+	}
+
+	return identity, nil
+
+}
+
+// SetUserIdentity links an external identity to a user.
+func (z *Repository) SetUserIdentity(ctx context.Context, tx model.WriteOnlyTransaction, identity model.UserIdentity) error {
+
+	logger := logging.New(ctx, componentRepo, "SetUserIdentity")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO user_identities (issuer, subject, user_id, email, created)
+	VALUES (:issuer, :subject, :userID, :email, :created)
+	ON CONFLICT (issuer, subject) DO UPDATE SET
+	user_id = :userID,
+	email = :email
+	`
+	params := userIdentityToParams(identity)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+func (z *Repository) deleteUserIdentities(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM user_identities WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToUserIdentity(i dbUserIdentity) *model.UserIdentity {
+	return &model.UserIdentity{
+		Issuer:  i.Issuer.String,
+		Subject: i.Subject.String,
+		UserID:  i.UserID.String,
+		Email:   i.Email.String,
+		Created: toTime(i.Created),
+	}
+}
+
+func userIdentityToParams(i model.UserIdentity) map[string]interface{} {
+	return map[string]interface{}{
+		"issuer":  toNullString(i.Issuer),
+		"subject": toNullString(i.Subject),
+		"userID":  toNullString(i.UserID),
+		"email":   toNullString(i.Email),
+		"created": toNullTimeInteger(&i.Created),
+	}
+}
@@ -0,0 +1,64 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestUserIdentity(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	identity := model.UserIdentity{
+		Issuer:  "https://idp.example.com",
+		Subject: "248289761001",
+		UserID:  userIDGuest,
+		Email:   "guest@example.com",
+		Created: now.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetUserIdentity(ctx, tx, identity); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		i, errGet := us.GetUserIdentity(ctx, tx, identity.Issuer, identity.Subject)
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if i == nil {
+			t.Fatal("Bad identity: nil")
+		}
+		if got, want := *i, identity; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad identity: %v, expected %v", got, want)
+		}
+
+		// Unknown subject of the same issuer
+		unknown, errUnknown := us.GetUserIdentity(ctx, tx, identity.Issuer, "bogus")
+		if errUnknown != nil {
+			t.Fatalf("Bad get error: %s", errUnknown)
+		}
+		if unknown != nil {
+			t.Errorf("Bad identity: %v, expected nil", unknown)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbPasswordHistory struct {
+	UserID       sql.NullString `db:"user_id"`
+	PasswordHash sql.NullString `db:"password_hash"`
+	Created      sql.NullInt64  `db:"created"`
+}
+
+// GetPasswordHistory returns the last passwords of a user, newest first.
+func (z *Repository) GetPasswordHistory(ctx context.Context, tx model.ReadOnlyTransaction, userID string, limit int) ([]model.PasswordHistory, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetPasswordHistory")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT user_id, password_hash, created
+	FROM password_history
+	WHERE user_id = :userID
+	ORDER BY created DESC
+	LIMIT :limit
+	`
+	params := map[string]interface{}{
+		"userID": userID,
+		"limit":  limit,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var history []model.PasswordHistory
+
+	for rs.Next() {
+		h := dbPasswordHistory{}
+		if err := rs.StructScan(&h); err != nil {
+			return nil, err
+		}
+		history = append(history, convertToPasswordHistory(h))
+	}
+
+	return history, nil
+
+}
+
+// AddPasswordHistory records a password set for a user.
+func (z *Repository) AddPasswordHistory(ctx context.Context, tx model.WriteOnlyTransaction, h model.PasswordHistory) error {
+
+	logger := logging.New(ctx, componentRepo, "AddPasswordHistory")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO password_history (user_id, password_hash, created)
+	VALUES (:userID, :passwordHash, :created)
+	`
+	params := map[string]interface{}{
+		"userID":       toNullString(h.UserID),
+		"passwordHash": toNullString(h.PasswordHash),
+		"created":      toNullTimeInteger(&h.Created),
+	}
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+func (z *Repository) deletePasswordHistory(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM password_history WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToPasswordHistory(h dbPasswordHistory) model.PasswordHistory {
+	return model.PasswordHistory{
+		UserID:       h.UserID.String,
+		PasswordHash: h.PasswordHash.String,
+		Created:      toTime(h.Created),
+	}
+}
@@ -0,0 +1,66 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestPasswordHistory(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	older := model.PasswordHistory{
+		UserID:       userIDGuest,
+		PasswordHash: "older",
+		Created:      now1h.UTC(),
+	}
+	newer := model.PasswordHistory{
+		UserID:       userIDGuest,
+		PasswordHash: "newer",
+		Created:      now2h.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Add
+		for _, h := range []model.PasswordHistory{older, newer} {
+			if err := us.AddPasswordHistory(ctx, tx, h); err != nil {
+				t.Fatalf("Bad add error: %s", err)
+			}
+		}
+
+		// Get, newest first
+		history, errGet := us.GetPasswordHistory(ctx, tx, userIDGuest, 2)
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if got, want := history, []model.PasswordHistory{newer, older}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad history: %v, expected %v", got, want)
+		}
+
+		// Limit
+		latest, errLatest := us.GetPasswordHistory(ctx, tx, userIDGuest, 1)
+		if errLatest != nil {
+			t.Fatalf("Bad get error: %s", errLatest)
+		}
+		if got, want := latest, []model.PasswordHistory{newer}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad history: %v, expected %v", got, want)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbPasswordReset struct {
+	TokenHash sql.NullString `db:"token_hash"`
+	UserID    sql.NullString `db:"user_id"`
+	Created   sql.NullInt64  `db:"created"`
+	Expires   sql.NullInt64  `db:"expires"`
+	Used      sql.NullInt64  `db:"used"`
+}
+
+func (z *Repository) GetPasswordReset(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.PasswordReset, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetPasswordReset")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT token_hash, user_id, created, expires, used
+	FROM password_resets
+	WHERE token_hash = :tokenHash
+	`
+	params := map[string]interface{}{
+		"tokenHash": tokenHash,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var reset *model.PasswordReset
+
+	if rs.Next() {
+		r := dbPasswordReset{}
+		if err := rs.StructScan(&r); err != nil {
+			return nil, err
+		}
+		reset = convertToPasswordReset(r)
+	}
+
+	return reset, nil
+
+}
+
+// SetPasswordReset adds a password reset token.
+func (z *Repository) SetPasswordReset(ctx context.Context, tx model.WriteOnlyTransaction, reset model.PasswordReset) error {
+
+	logger := logging.New(ctx, componentRepo, "SetPasswordReset")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO password_resets (token_hash, user_id, created, expires, used)
+	VALUES (:tokenHash, :userID, :created, :expires, :used)
+	`
+	params := passwordResetToParams(reset)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// UsePasswordResets marks all unused password reset tokens of a user as used at the given time.
+func (z *Repository) UsePasswordResets(ctx context.Context, tx model.WriteOnlyTransaction, userID string, t time.Time) error {
+
+	logger := logging.New(ctx, componentRepo, "UsePasswordResets")
+	logger.Debug().Msg("invoked")
+
+	query := "UPDATE password_resets SET used = :used WHERE user_id = :userID AND used IS NULL"
+	params := map[string]interface{}{
+		"userID": userID,
+		"used":   toNullTimeInteger(&t),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func (z *Repository) deletePasswordResets(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM password_resets WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToPasswordReset(r dbPasswordReset) *model.PasswordReset {
+	return &model.PasswordReset{
+		TokenHash: r.TokenHash.String,
+		UserID:    r.UserID.String,
+		Created:   toTime(r.Created),
+		Expires:   toTime(r.Expires),
+		Used:      toTimePointer(toTime(r.Used)),
+	}
+}
+
+func passwordResetToParams(r model.PasswordReset) map[string]interface{} {
+	return map[string]interface{}{
+		"tokenHash": toNullString(r.TokenHash),
+		"userID":    toNullString(r.UserID),
+		"created":   toNullTimeInteger(&r.Created),
+		"expires":   toNullTimeInteger(&r.Expires),
+		"used":      toNullTimeInteger(r.Used),
+	}
+}
@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestPasswordReset(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	used := now1h.UTC()
+	reset := model.PasswordReset{
+		TokenHash: model.HashToken("token"),
+		UserID:    userIDGuest,
+		Created:   now.UTC(),
+		Expires:   now2h.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetPasswordReset(ctx, tx, reset); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		r, errGet := us.GetPasswordReset(ctx, tx, model.HashToken("token"))
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(r, &reset) {
+			t.Errorf("Bad reset: %v, expected %v", r, reset)
+		}
+		if !r.IsValid(now1h) {
+			t.Error("Bad valid: false, expected true")
+		}
+
+		// Use
+		if err := us.UsePasswordResets(ctx, tx, userIDGuest, used); err != nil {
+			t.Fatalf("Bad use error: %s", err)
+		}
+		r2, errReGet := us.GetPasswordReset(ctx, tx, model.HashToken("token"))
+		if errReGet != nil {
+			t.Fatalf("Bad re-get error: %s", errReGet)
+		}
+		reset.Used = &used
+		if !reflect.DeepEqual(r2, &reset) {
+			t.Errorf("Bad reset: %v, expected %v", r2, reset)
+		}
+		if r2.IsValid(now1h) {
+			t.Error("Bad valid: true, expected false")
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
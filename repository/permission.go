@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbRolePermission struct {
+	RoleID     string `db:"role_id"`
+	Permission string `db:"permission"`
+}
+
+// GetRolePermissions returns the permissions of a role ordered by name.
+func (z *Repository) GetRolePermissions(ctx context.Context, tx model.ReadOnlyTransaction, roleID string) ([]string, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetRolePermissions")
+	logger.Debug().Msg("invoked")
+
+	permissions, err := z.getRolePermissions(ctx, tx, "WHERE role_id = :roleID", map[string]interface{}{"roleID": roleID})
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions[roleID], nil
+
+}
+
+// SetRolePermissions replaces the permissions of a role.
+func (z *Repository) SetRolePermissions(ctx context.Context, tx model.WriteOnlyTransaction, roleID string, permissions []string) error {
+
+	logger := logging.New(ctx, componentRepo, "SetRolePermissions")
+	logger.Debug().Msg("invoked")
+
+	if err := z.deleteRolePermissions(tx, roleID); err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		params := map[string]interface{}{
+			"roleID":     roleID,
+			"permission": permission,
+		}
+		if _, err := tx.Exec("INSERT INTO role_permissions (role_id, permission) VALUES (:roleID, :permission)", params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// getRolePermissions returns the permissions of the roles selected by the where clause, mapped by role id.
+func (z *Repository) getRolePermissions(ctx context.Context, tx model.ReadOnlyTransaction, where string, params map[string]interface{}) (map[string][]string, error) {
+
+	logger := logging.New(ctx, componentRepo, "getRolePermissions")
+
+	query := `
+	SELECT role_id, permission
+	FROM role_permissions
+	` + where + `
+	ORDER BY permission
+	`
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	permissions := make(map[string][]string)
+	for rs.Next() {
+		var p dbRolePermission
+		if err := rs.StructScan(&p); err != nil {
+			return nil, err
+		}
+		permissions[p.RoleID] = append(permissions[p.RoleID], p.Permission)
+	}
+
+	return permissions, nil
+
+}
+
+func (z *Repository) deleteRolePermissions(tx model.WriteOnlyTransaction, roleID string) error {
+	_, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = :roleID", map[string]interface{}{"roleID": roleID})
+	return err
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/rs/zerolog"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbPushMessage struct {
+	Seq       sql.NullInt64  `db:"seq"`
+	UserID    sql.NullString `db:"user_id"`
+	SessionID sql.NullString `db:"session_id"`
+	Created   sql.NullInt64  `db:"created"`
+	Type      sql.NullString `db:"type"`
+	Data      sql.NullString `db:"data"`
+}
+
+// AddPushMessage stores a push message under the next sequence number, which it returns,
+// and removes the messages of the same recipient beyond the newest keep.
+// The sequence number of msg is ignored, so that the instances sharing the database never collide.
+func (z *Repository) AddPushMessage(ctx context.Context, tx model.Transaction, msg model.ReplayMessage, keep int) (uint64, error) {
+
+	logger := logging.New(ctx, componentRepo, "AddPushMessage")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO push_messages (seq, user_id, session_id, created, type, data)
+	VALUES (nextval('push_messages_seq'), :userID, :sessionID, :created, :type, :data)
+	RETURNING seq
+	`
+	params := map[string]interface{}{
+		"userID":    msg.UserID,
+		"sessionID": msg.SessionID,
+		"created":   toNullTimeInteger(&msg.Created),
+		"type":      toNullString(msg.Message.Type),
+		"data":      toNullString(msg.Message.Data),
+	}
+	seq, errInsert := queryPushMessageSeq(logger, tx, query, params) // closes its rows before the next statement
+	if errInsert != nil {
+		return 0, errInsert
+	}
+
+	query = `
+	DELETE FROM push_messages
+	WHERE user_id = :userID
+	AND seq <= (SELECT seq FROM push_messages WHERE user_id = :userID ORDER BY seq DESC LIMIT 1 OFFSET :keep)
+	`
+	params = map[string]interface{}{
+		"userID": msg.UserID,
+		"keep":   keep,
+	}
+	if _, err := tx.Exec(query, params); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+
+}
+
+func queryPushMessageSeq(logger *zerolog.Logger, tx model.ReadOnlyTransaction, query string, params map[string]interface{}) (uint64, error) {
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return 0, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var seq sql.NullInt64
+	if rs.Next() {
+		if err := rs.Scan(&seq); err != nil {
+			return 0, err
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return 0, err
+	}
+
+	return uint64(seq.Int64), nil
+
+}
+
+// ListPushMessages returns the messages to the user session, including those to all users, after seq, oldest first.
+func (z *Repository) ListPushMessages(ctx context.Context, tx model.ReadOnlyTransaction, userID, sessionID string, seq uint64) ([]model.ReplayMessage, error) {
+
+	logger := logging.New(ctx, componentRepo, "ListPushMessages")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT seq, user_id, session_id, created, type, data
+	FROM push_messages
+	WHERE user_id IN (:userID, '')
+	AND session_id IN (:sessionID, '')
+	AND seq > :seq
+	ORDER BY seq
+	`
+	params := map[string]interface{}{
+		"userID":    userID,
+		"sessionID": sessionID,
+		"seq":       int64(seq),
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var messages []model.ReplayMessage
+	for rs.Next() {
+		m := dbPushMessage{}
+		if err := rs.StructScan(&m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, convertToReplayMessage(m))
+	}
+
+	return messages, nil
+
+}
+
+// GetLastPushMessageSeq returns the last sequence number taken by any instance.
+func (z *Repository) GetLastPushMessageSeq(ctx context.Context, tx model.ReadOnlyTransaction) (uint64, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetLastPushMessageSeq")
+	logger.Debug().Msg("invoked")
+
+	return queryPushMessageSeq(logger, tx, "SELECT last_value FROM push_messages_seq", map[string]interface{}{})
+
+}
+
+func convertToReplayMessage(m dbPushMessage) model.ReplayMessage {
+	seq := uint64(m.Seq.Int64)
+	return model.ReplayMessage{
+		Seq:       seq,
+		UserID:    m.UserID.String,
+		SessionID: m.SessionID.String,
+		Created:   toTime(m.Created),
+		Message: model.PushMessage{
+			ID:   strconv.FormatUint(seq, 10),
+			Type: m.Type.String,
+			Data: m.Data.String,
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func init() {
+
+	tStatements := []string{
+		"DELETE FROM push_messages WHERE user_id = '" + userIDFakeuser + "'",
+	}
+
+	addTestStatements(nil, tStatements)
+
+}
+
+func TestPushMessages(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	newMessage := func(userID, sessionID, data string) model.ReplayMessage {
+		return model.ReplayMessage{
+			Seq:       1, // assigned by the database
+			UserID:    userID,
+			SessionID: sessionID,
+			Created:   now.UTC(),
+			Message: model.PushMessage{
+				Type: "test",
+				Data: data,
+			},
+		}
+	}
+
+	m1 := newMessage(userIDFakeuser, "", "user")
+	m2 := newMessage(userIDFakeuser, "S1", "session 1")
+	m3 := newMessage(userIDFakeuser, "S2", "session 2")
+	m4 := newMessage(userIDFakeuser, "", "user again")
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		add := func(msg *model.ReplayMessage, keep int) {
+			seq, err := us.AddPushMessage(ctx, tx, *msg, keep)
+			if err != nil {
+				t.Fatalf("Bad add error: %s", err)
+			}
+			msg.Seq = seq
+			msg.Message.ID = strconv.FormatUint(seq, 10)
+		}
+
+		for _, msg := range []*model.ReplayMessage{&m1, &m2, &m3} {
+			add(msg, 10)
+		}
+		if m1.Seq <= 1 || m2.Seq <= m1.Seq || m3.Seq <= m2.Seq {
+			t.Errorf("Bad sequence: %d, %d, %d", m1.Seq, m2.Seq, m3.Seq)
+		}
+
+		messages, errList := us.ListPushMessages(ctx, tx, userIDFakeuser, "S1", m1.Seq-1)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := messages, []model.ReplayMessage{m1, m2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad messages: %v, expected %v", got, want)
+		}
+
+		messages, errList = us.ListPushMessages(ctx, tx, userIDFakeuser, "S2", m1.Seq)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := messages, []model.ReplayMessage{m3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad messages: %v, expected %v", got, want)
+		}
+
+		// keep the newest two
+		add(&m4, 2)
+		messages, errList = us.ListPushMessages(ctx, tx, userIDFakeuser, "S2", m1.Seq-1)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		if got, want := messages, []model.ReplayMessage{m3, m4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad messages: %v, expected %v", got, want)
+		}
+
+		seq, errSeq := us.GetLastPushMessageSeq(ctx, tx)
+		if errSeq != nil {
+			t.Fatalf("Bad last seq error: %s", errSeq)
+		}
+		if seq < m4.Seq {
+			t.Errorf("Bad last seq: %d, expected at least %d", seq, m4.Seq)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
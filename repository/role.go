@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbRole struct {
+	ID   sql.NullString `db:"id"`
+	Name sql.NullString `db:"name"`
+}
+
+// GetRoles returns all roles ordered by name.
+func (z *Repository) GetRoles(ctx context.Context, tx model.ReadOnlyTransaction) ([]model.Role, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetRoles")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, name
+	FROM roles
+	ORDER BY name
+	`
+
+	rs, errQuery := tx.Query(query, map[string]interface{}{})
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	roles := make([]model.Role, 0)
+	for rs.Next() {
+		var role dbRole
+		if err := rs.StructScan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, *convertToRoleInfo(role))
+	}
+
+	permissions, errPermissions := z.getRolePermissions(ctx, tx, "", map[string]interface{}{})
+	if errPermissions != nil {
+		return nil, errPermissions
+	}
+	for i := range roles {
+		roles[i].Permissions = permissions[roles[i].ID]
+	}
+
+	return roles, nil
+
+}
+
+func (z *Repository) GetRole(ctx context.Context, tx model.ReadOnlyTransaction, roleID string) (*model.Role, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetRole")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, name
+	FROM roles
+	WHERE id = :id
+	`
+	params := map[string]interface{}{
+		"id": roleID,
+	}
+
+	return z.getRole(ctx, tx, query, params)
+
+}
+
+// GetRoleByName returns the role with the given name, ignoring case.
+func (z *Repository) GetRoleByName(ctx context.Context, tx model.ReadOnlyTransaction, name string) (*model.Role, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetRoleByName")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, name
+	FROM roles
+	WHERE LOWER(name) = LOWER(:name)
+	`
+	params := map[string]interface{}{
+		"name": name,
+	}
+
+	return z.getRole(ctx, tx, query, params)
+
+}
+
+func (z *Repository) SetRole(ctx context.Context, tx model.WriteOnlyTransaction, role model.Role) error {
+
+	logger := logging.New(ctx, componentRepo, "SetRole")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO roles (id, name)
+	VALUES (:id, :name)
+	ON CONFLICT (id) DO UPDATE SET
+	name = :name
+	`
+	params := map[string]interface{}{
+		"id":   toNullString(role.ID),
+		"name": toNullString(role.Name),
+	}
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// DeleteRole removes a role together with all grants of that role.
+func (z *Repository) DeleteRole(ctx context.Context, tx model.WriteOnlyTransaction, roleID string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteRole")
+	logger.Debug().Msg("invoked")
+
+	if _, err := tx.Exec("DELETE FROM user_role WHERE role_id = :roleID", map[string]interface{}{"roleID": roleID}); err != nil {
+		return err
+	}
+
+	if err := z.deleteRolePermissions(tx, roleID); err != nil {
+		return err
+	}
+
+	rs, errExec := tx.Exec("DELETE FROM roles WHERE id = :roleID", map[string]interface{}{"roleID": roleID})
+	if errExec != nil {
+		return errExec
+	}
+	count, errCount := rs.RowsAffected()
+	if errCount != nil {
+		return errCount
+	}
+	if count == 0 {
+		return errors.New("no records deleted")
+	} else if count != 1 {
+		return errors.New("multiple records deleted")
+	}
+	return nil
+
+}
+
+// SetUserRole grants a role to a user, replacing the validity window of an existing grant.
+func (z *Repository) SetUserRole(ctx context.Context, tx model.WriteOnlyTransaction, userID string, role model.UserRole) error {
+
+	logger := logging.New(ctx, componentRepo, "SetUserRole")
+	logger.Debug().Msg("invoked")
+
+	return z.setUserRole(tx, userID, role)
+
+}
+
+func (z *Repository) DeleteUserRole(ctx context.Context, tx model.WriteOnlyTransaction, userID, roleID string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteUserRole")
+	logger.Debug().Msg("invoked")
+
+	return z.deleteUserRole(tx, userID, roleID)
+
+}
+
+func (z *Repository) getRole(ctx context.Context, tx model.ReadOnlyTransaction, query string, params map[string]interface{}) (*model.Role, error) {
+
+	logger := logging.New(ctx, componentRepo, "getRole")
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var role *model.Role
+
+	if rs.Next() {
+		r := dbRole{}
+		if err := rs.StructScan(&r); err != nil {
+			return nil, err
+		}
+		role = convertToRoleInfo(r)
+	}
+
+	return role, nil
+
+}
+
+func convertToRoleInfo(r dbRole) *model.Role {
+	return &model.Role{
+		ID:   r.ID.String,
+		Name: r.Name.String,
+	}
+}
@@ -0,0 +1,158 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+const (
+	roleIDAuditor = "2ff4dc0b-57c5-4b1b-a1a6-0aa3b0c0c2c5"
+)
+
+func init() {
+
+	tStatements := []string{
+		fmt.Sprintf("DELETE FROM user_role WHERE role_id = '%s'", roleIDAuditor),
+		fmt.Sprintf("DELETE FROM role_permissions WHERE role_id = '%s'", roleIDAuditor),
+		fmt.Sprintf("DELETE FROM roles WHERE id = '%s'", roleIDAuditor),
+	}
+
+	addTestStatements(nil, tStatements)
+
+}
+
+func TestRoles(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	role := model.Role{
+		ID:   roleIDAuditor,
+		Name: "auditor",
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetRole(ctx, tx, role); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		r, errGet := us.GetRole(ctx, tx, roleIDAuditor)
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if r == nil || !reflect.DeepEqual(*r, role) {
+			t.Errorf("Bad role: %v, expected %v", r, role)
+		}
+
+		// Get by name
+		rn, errGetName := us.GetRoleByName(ctx, tx, "Auditor")
+		if errGetName != nil {
+			t.Fatalf("Bad get by name error: %s", errGetName)
+		}
+		if rn == nil || !reflect.DeepEqual(*rn, role) {
+			t.Errorf("Bad role: %v, expected %v", rn, role)
+		}
+
+		// Permissions
+		permissions := []string{model.PermissionManageUsers, model.PermissionAccount}
+		if err := us.SetRolePermissions(ctx, tx, roleIDAuditor, permissions); err != nil {
+			t.Fatalf("Bad set permissions error: %s", err)
+		}
+		if err := us.SetRolePermissions(ctx, tx, roleIDAuditor, permissions); err != nil {
+			t.Fatalf("Bad replace permissions error: %s", err)
+		}
+		rp, errPermissions := us.GetRolePermissions(ctx, tx, roleIDAuditor)
+		if errPermissions != nil {
+			t.Fatalf("Bad get permissions error: %s", errPermissions)
+		}
+		role.Permissions = []string{model.PermissionAccount, model.PermissionManageUsers} // ordered
+		if got, want := rp, role.Permissions; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad permissions: %v, expected %v", got, want)
+		}
+
+		// List
+		roles, errList := us.GetRoles(ctx, tx)
+		if errList != nil {
+			t.Fatalf("Bad list error: %s", errList)
+		}
+		found := false
+		for _, x := range roles {
+			if reflect.DeepEqual(x, role) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Role %v not found in %v", role, roles)
+		}
+
+		// Grant, then revoke
+		grant := model.UserRole{
+			ID:      roleIDAuditor,
+			ValidTo: &now1h,
+		}
+		if err := us.SetUserRole(ctx, tx, userIDGuest, grant); err != nil {
+			t.Fatalf("Bad grant error: %s", err)
+		}
+		active, errActive := us.GetUserRoles(ctx, tx, userIDGuest, &now)
+		if errActive != nil {
+			t.Fatalf("Bad user roles error: %s", errActive)
+		}
+		if got, want := len(active), 1; got != want {
+			t.Fatalf("Bad active role count: %d, expected %d", got, want)
+		}
+		if got, want := active[0].Permissions, role.Permissions; !reflect.DeepEqual(got, want) {
+			t.Errorf("Bad user role permissions: %v, expected %v", got, want)
+		}
+		expired, errExpired := us.GetUserRoles(ctx, tx, userIDGuest, &now2h)
+		if errExpired != nil {
+			t.Fatalf("Bad user roles error: %s", errExpired)
+		}
+		if got, want := len(expired), 0; got != want {
+			t.Errorf("Bad expired role count: %d, expected %d", got, want)
+		}
+		if err := us.DeleteUserRole(ctx, tx, userIDGuest, roleIDAuditor); err != nil {
+			t.Fatalf("Bad revoke error: %s", err)
+		}
+
+		// Delete, including remaining grants
+		if err := us.SetUserRole(ctx, tx, userIDGuest, grant); err != nil {
+			t.Fatalf("Bad grant error: %s", err)
+		}
+		if err := us.DeleteRole(ctx, tx, roleIDAuditor); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		rd, errGetDeleted := us.GetRole(ctx, tx, roleIDAuditor)
+		if errGetDeleted != nil {
+			t.Fatalf("Bad get error: %s", errGetDeleted)
+		}
+		if rd != nil {
+			t.Errorf("Bad role: %v, expected nil", rd)
+		}
+		granted, errGranted := us.GetUserRoles(ctx, tx, userIDGuest, nil)
+		if errGranted != nil {
+			t.Fatalf("Bad user roles error: %s", errGranted)
+		}
+		if got, want := len(granted), 0; got != want {
+			t.Errorf("Bad granted role count: %d, expected %d", got, want)
+		}
+
+		return nil
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbSession struct {
+	ID           sql.NullString `db:"id"`
+	UserID       sql.NullString `db:"user_id"`
+	Issued       sql.NullInt64  `db:"issued"`
+	LastSeen     sql.NullInt64  `db:"last_seen"`
+	Expires      sql.NullInt64  `db:"expires"`
+	Revoked      sql.NullInt64  `db:"revoked"`
+	UserAgent    sql.NullString `db:"user_agent"`
+	IPAddress    sql.NullString `db:"ip_address"`
+	Impersonator sql.NullString `db:"impersonator_id"`
+}
+
+func (z *Repository) GetSession(ctx context.Context, tx model.ReadOnlyTransaction, sessionID string) (*model.Session, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetSession")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, user_id, issued, last_seen, expires, revoked, user_agent, ip_address, impersonator_id
+	FROM sessions
+	WHERE id = :id
+	`
+	params := map[string]interface{}{
+		"id": sessionID,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var session *model.Session
+
+	if rs.Next() {
+		s := dbSession{}
+		if err := rs.StructScan(&s); err != nil {
+			return nil, err
+		}
+		session = convertToSession(s)
+	}
+
+	return session, nil
+
+}
+
+// GetUserSessions returns all sessions for a user, most recently issued first.
+// Only sessions active at given time will be returned if parameter is non-nil.
+func (z *Repository) GetUserSessions(ctx context.Context, tx model.ReadOnlyTransaction, userID string, t *time.Time) ([]model.Session, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetUserSessions")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT id, user_id, issued, last_seen, expires, revoked, user_agent, ip_address, impersonator_id
+	FROM sessions
+	WHERE user_id = :userID
+	`
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+
+	if t != nil {
+		query += `AND revoked IS NULL AND expires > :t `
+		params["t"] = toNullTimeInteger(t)
+	}
+
+	query += "ORDER BY issued DESC"
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	sessions := make([]model.Session, 0)
+	for rs.Next() {
+		var s dbSession
+		if err := rs.StructScan(&s); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *convertToSession(s))
+	}
+
+	return sessions, nil
+
+}
+
+// SetSession will add or update a session.
+func (z *Repository) SetSession(ctx context.Context, tx model.WriteOnlyTransaction, session model.Session) error {
+
+	logger := logging.New(ctx, componentRepo, "SetSession")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO sessions (id, user_id, issued, last_seen, expires, revoked, user_agent, ip_address, impersonator_id)
+	VALUES (:id, :userID, :issued, :lastSeen, :expires, :revoked, :userAgent, :ipAddress, :impersonatorID)
+	ON CONFLICT (id) DO UPDATE SET
+	last_seen = :lastSeen,
+	expires = :expires,
+	revoked = :revoked
+	`
+	params := sessionToParams(session)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// RevokeSession marks the session as revoked at the given time, if not already revoked.
+func (z *Repository) RevokeSession(ctx context.Context, tx model.WriteOnlyTransaction, sessionID string, t time.Time) error {
+
+	logger := logging.New(ctx, componentRepo, "RevokeSession")
+	logger.Debug().Msg("invoked")
+
+	query := "UPDATE sessions SET revoked = :revoked WHERE id = :id AND revoked IS NULL"
+	params := map[string]interface{}{
+		"id":      sessionID,
+		"revoked": toNullTimeInteger(&t),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func (z *Repository) deleteUserSessions(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM sessions WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToSession(s dbSession) *model.Session {
+	return &model.Session{
+		ID:             s.ID.String,
+		UserID:         s.UserID.String,
+		Issued:         toTime(s.Issued),
+		LastSeen:       toTime(s.LastSeen),
+		Expires:        toTime(s.Expires),
+		Revoked:        toTimePointer(toTime(s.Revoked)),
+		UserAgent:      s.UserAgent.String,
+		IPAddress:      s.IPAddress.String,
+		ImpersonatorID: s.Impersonator.String,
+	}
+}
+
+func sessionToParams(session model.Session) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             toNullString(session.ID),
+		"userID":         toNullString(session.UserID),
+		"issued":         toNullTimeInteger(&session.Issued),
+		"lastSeen":       toNullTimeInteger(&session.LastSeen),
+		"expires":        toNullTimeInteger(&session.Expires),
+		"revoked":        toNullTimeInteger(session.Revoked),
+		"userAgent":      session.UserAgent,
+		"ipAddress":      session.IPAddress,
+		"impersonatorID": toNullString(session.ImpersonatorID),
+	}
+}
@@ -0,0 +1,236 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+const (
+	sessionIDFakeuser = "bm5p8ol3ge5ul2tbj6jg"
+)
+
+func TestSession(b *testing.T) {
+
+	testCases := []struct {
+		Alias   string
+		Session model.Session
+	}{
+		{
+			Alias: "success",
+			Session: model.Session{
+				ID:        "bm5pa3d3ge5ul2tbj6k0",
+				UserID:    userIDFakeuser,
+				Issued:    now.UTC(),
+				LastSeen:  now.UTC(),
+				Expires:   now3d.UTC(),
+				UserAgent: "curl/7.58.0",
+				IPAddress: "10.0.0.1",
+			},
+		},
+		{
+			Alias: "impersonated",
+			Session: model.Session{
+				ID:             "bm5pa3d3ge5ul2tbj6k1",
+				UserID:         userIDFakeuser,
+				Issued:         now.UTC(),
+				LastSeen:       now.UTC(),
+				Expires:        now1h.UTC(),
+				UserAgent:      "curl/7.58.0",
+				IPAddress:      "10.0.0.1",
+				ImpersonatorID: userIDGuest,
+			},
+		},
+	}
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			err := database.Run(func(tx model.Transaction) error {
+
+				ctx := context.Background()
+
+				// Set
+				if err := us.SetSession(ctx, tx, tc.Session); err != nil {
+					t.Fatalf("Bad set error: %s", err)
+				}
+
+				// Get
+				s, errGet := us.GetSession(ctx, tx, tc.Session.ID)
+				if errGet != nil {
+					t.Fatalf("Bad get error: %s", errGet)
+				}
+				if !reflect.DeepEqual(s, &tc.Session) {
+					t.Errorf("Bad session: %v, expected %v", s, tc.Session)
+				}
+
+				// Update
+				session2 := tc.Session
+				session2.LastSeen = now1h.UTC()
+				if err := us.SetSession(ctx, tx, session2); err != nil {
+					t.Fatalf("Bad update error: %s", err)
+				}
+
+				// Revoke
+				if err := us.RevokeSession(ctx, tx, tc.Session.ID, now2h); err != nil {
+					t.Fatalf("Bad revoke error: %s", err)
+				}
+
+				// ReGet
+				s2, errReGet := us.GetSession(ctx, tx, tc.Session.ID)
+				if errReGet != nil {
+					t.Fatalf("Bad re-get error: %s", errReGet)
+				}
+				if s2 == nil {
+					t.Fatal("nil session, expected non-nil")
+				}
+				compareTimes(t, &s2.LastSeen, &session2.LastSeen)
+				revoked := now2h.UTC()
+				compareTimes(t, s2.Revoked, &revoked)
+				if s2.IsActive(now) {
+					t.Error("Bad active: true, expected false")
+				}
+
+				return nil // always nil, so don't test database.Run return value
+
+			})
+
+			if err != nil {
+				t.Error(err)
+			}
+
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
+
+func TestGetSession(b *testing.T) {
+
+	testCases := []struct {
+		Alias           string
+		SessionID       string
+		ExpectedSession *model.Session
+	}{
+		{
+			Alias:     "active session",
+			SessionID: sessionIDFakeuser,
+			ExpectedSession: &model.Session{
+				ID:        sessionIDFakeuser,
+				UserID:    userIDFakeuser,
+				Issued:    now.UTC(),
+				LastSeen:  now.UTC(),
+				Expires:   now5d.UTC(),
+				UserAgent: "Mozilla/5.0",
+				IPAddress: "127.0.0.1",
+			},
+		},
+		{
+			Alias:           "unknown session",
+			SessionID:       "bm5pbd33ge5ul2tbj6kg",
+			ExpectedSession: nil,
+		},
+	}
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			err := database.Run(func(tx model.Transaction) error {
+
+				ctx := context.Background()
+
+				s, errGet := us.GetSession(ctx, tx, tc.SessionID)
+				if errGet != nil {
+					t.Fatalf("Bad get error: %s", errGet)
+				}
+				if !reflect.DeepEqual(s, tc.ExpectedSession) {
+					t.Errorf("Bad session: %v, expected %v", s, tc.ExpectedSession)
+				}
+
+				return nil // always nil, so don't test database.Run return value
+
+			})
+
+			if err != nil {
+				t.Error(err)
+			}
+
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
+
+func TestGetUserSessions(b *testing.T) {
+
+	testCases := []struct {
+		Alias              string
+		UserID             string
+		ExpectedSessionIDs []string
+	}{
+		{
+			Alias:              "active sessions",
+			UserID:             userIDFakeuser,
+			ExpectedSessionIDs: []string{sessionIDFakeuser},
+		},
+		{
+			Alias:              "unknown user",
+			UserID:             "cd3d9ac1-3c89-4a2c-a3c6-e8ba1a7b1d9e",
+			ExpectedSessionIDs: nil,
+		},
+	}
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			err := database.Run(func(tx model.Transaction) error {
+
+				ctx := context.Background()
+
+				sessions, errGet := us.GetUserSessions(ctx, tx, tc.UserID, &now)
+				if errGet != nil {
+					t.Fatalf("Bad get error: %s", errGet)
+				}
+				var sessionIDs []string
+				for _, s := range sessions {
+					sessionIDs = append(sessionIDs, s.ID)
+				}
+				if !reflect.DeepEqual(sessionIDs, tc.ExpectedSessionIDs) {
+					t.Errorf("Bad sessions: %v, expected %v", sessionIDs, tc.ExpectedSessionIDs)
+				}
+
+				return nil // always nil, so don't test database.Run return value
+
+			})
+
+			if err != nil {
+				t.Error(err)
+			}
+
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbLoginThrottle struct {
+	Scope       sql.NullString `db:"scope"`
+	Subject     sql.NullString `db:"subject"`
+	Failures    sql.NullInt64  `db:"failures"`
+	LastFailure sql.NullInt64  `db:"last_failure"`
+	LockedUntil sql.NullInt64  `db:"locked_until"`
+}
+
+func (z *Repository) GetLoginThrottle(ctx context.Context, tx model.ReadOnlyTransaction, scope, subject string) (*model.LoginThrottle, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetLoginThrottle")
+	logger.Debug().Str("scope", scope).Str("subject", subject).Msg("invoked")
+
+	query := `
+	SELECT scope, subject, failures, last_failure, locked_until
+	FROM login_throttles
+	WHERE scope = :scope AND subject = :subject
+	`
+	params := map[string]interface{}{
+		"scope":   scope,
+		"subject": subject,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var throttle *model.LoginThrottle
+
+	if rs.Next() {
+		t := dbLoginThrottle{}
+		if err := rs.StructScan(&t); err != nil {
+			return nil, err
+		}
+		throttle = convertToLoginThrottle(t)
+	}
+
+	return throttle, nil
+
+}
+
+// SetLoginThrottle will add or replace the failed login count of a username or IP address.
+func (z *Repository) SetLoginThrottle(ctx context.Context, tx model.WriteOnlyTransaction, throttle model.LoginThrottle) error {
+
+	logger := logging.New(ctx, componentRepo, "SetLoginThrottle")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO login_throttles (scope, subject, failures, last_failure, locked_until)
+	VALUES (:scope, :subject, :failures, :lastFailure, :lockedUntil)
+	ON CONFLICT (scope, subject) DO UPDATE SET
+	failures = :failures,
+	last_failure = :lastFailure,
+	locked_until = :lockedUntil
+	`
+	params := loginThrottleToParams(throttle)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// DeleteLoginThrottle forgets the failed logins of a username or IP address, lifting any lockout.
+func (z *Repository) DeleteLoginThrottle(ctx context.Context, tx model.WriteOnlyTransaction, scope, subject string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteLoginThrottle")
+	logger.Debug().Msg("invoked")
+
+	query := "DELETE FROM login_throttles WHERE scope = :scope AND subject = :subject"
+	params := map[string]interface{}{
+		"scope":   scope,
+		"subject": subject,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToLoginThrottle(t dbLoginThrottle) *model.LoginThrottle {
+	return &model.LoginThrottle{
+		Scope:       t.Scope.String,
+		Subject:     t.Subject.String,
+		Failures:    int(t.Failures.Int64),
+		LastFailure: toTime(t.LastFailure),
+		LockedUntil: toTimePointer(toTime(t.LockedUntil)),
+	}
+}
+
+func loginThrottleToParams(t model.LoginThrottle) map[string]interface{} {
+	return map[string]interface{}{
+		"scope":       toNullString(t.Scope),
+		"subject":     toNullString(t.Subject),
+		"failures":    t.Failures,
+		"lastFailure": toNullTimeInteger(&t.LastFailure),
+		"lockedUntil": toNullTimeInteger(t.LockedUntil),
+	}
+}
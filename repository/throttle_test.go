@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestLoginThrottle(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	lockedUntil := now1h.UTC()
+	throttle := model.LoginThrottle{
+		Scope:       model.ThrottleUsername,
+		Subject:     "guest",
+		Failures:    1,
+		LastFailure: now.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetLoginThrottle(ctx, tx, throttle); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		lt, errGet := us.GetLoginThrottle(ctx, tx, model.ThrottleUsername, "guest")
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(lt, &throttle) {
+			t.Errorf("Bad throttle: %v, expected %v", lt, throttle)
+		}
+
+		// other scope
+		lt2, errGet2 := us.GetLoginThrottle(ctx, tx, model.ThrottleIPAddress, "guest")
+		if errGet2 != nil {
+			t.Fatalf("Bad get error: %s", errGet2)
+		}
+		if lt2 != nil {
+			t.Errorf("Bad throttle: %v, expected nil", lt2)
+		}
+
+		// Lock
+		throttle.Failures = 5
+		throttle.LockedUntil = &lockedUntil
+		if err := us.SetLoginThrottle(ctx, tx, throttle); err != nil {
+			t.Fatalf("Bad update error: %s", err)
+		}
+		lt3, errReGet := us.GetLoginThrottle(ctx, tx, model.ThrottleUsername, "guest")
+		if errReGet != nil {
+			t.Fatalf("Bad re-get error: %s", errReGet)
+		}
+		if !reflect.DeepEqual(lt3, &throttle) {
+			t.Errorf("Bad throttle: %v, expected %v", lt3, throttle)
+		}
+
+		// Delete
+		if err := us.DeleteLoginThrottle(ctx, tx, model.ThrottleUsername, "guest"); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		lt4, errDeleted := us.GetLoginThrottle(ctx, tx, model.ThrottleUsername, "guest")
+		if errDeleted != nil {
+			t.Fatalf("Bad get after delete error: %s", errDeleted)
+		}
+		if lt4 != nil {
+			t.Errorf("Bad throttle after delete: %v, expected nil", lt4)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbUserTOTP struct {
+	UserID      sql.NullString `db:"user_id"`
+	Secret      sql.NullString `db:"secret"`
+	LastCounter sql.NullInt64  `db:"last_counter"`
+	Created     sql.NullInt64  `db:"created"`
+	Confirmed   sql.NullInt64  `db:"confirmed"`
+}
+
+func (z *Repository) GetUserTOTP(ctx context.Context, tx model.ReadOnlyTransaction, userID string) (*model.UserTOTP, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetUserTOTP")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT user_id, secret, last_counter, created, confirmed
+	FROM user_totp
+	WHERE user_id = :userID
+	`
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var userTOTP *model.UserTOTP
+
+	if rs.Next() {
+		t := dbUserTOTP{}
+		if err := rs.StructScan(&t); err != nil {
+			return nil, err
+		}
+		userTOTP = convertToUserTOTP(t)
+	}
+
+	return userTOTP, nil
+
+}
+
+// SetUserTOTP will add or replace the TOTP secret of a user.
+func (z *Repository) SetUserTOTP(ctx context.Context, tx model.WriteOnlyTransaction, userTOTP model.UserTOTP) error {
+
+	logger := logging.New(ctx, componentRepo, "SetUserTOTP")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO user_totp (user_id, secret, last_counter, created, confirmed)
+	VALUES (:userID, :secret, :lastCounter, :created, :confirmed)
+	ON CONFLICT (user_id) DO UPDATE SET
+	secret = :secret,
+	last_counter = :lastCounter,
+	created = :created,
+	confirmed = :confirmed
+	`
+	params := userTOTPToParams(userTOTP)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// DeleteUserTOTP removes the TOTP secret and all recovery codes of a user.
+func (z *Repository) DeleteUserTOTP(ctx context.Context, tx model.WriteOnlyTransaction, userID string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteUserTOTP")
+	logger.Debug().Msg("invoked")
+
+	return z.deleteUserTOTP(tx, userID)
+
+}
+
+// SetRecoveryCodes replaces all recovery codes of a user with the given hashes.
+func (z *Repository) SetRecoveryCodes(ctx context.Context, tx model.WriteOnlyTransaction, userID string, codeHashes []string) error {
+
+	logger := logging.New(ctx, componentRepo, "SetRecoveryCodes")
+	logger.Debug().Msg("invoked")
+
+	if err := z.deleteRecoveryCodes(tx, userID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO user_recovery_codes (user_id, code_hash, used) VALUES (:userID, :codeHash, NULL)"
+	for _, codeHash := range codeHashes {
+		params := map[string]interface{}{
+			"userID":   userID,
+			"codeHash": codeHash,
+		}
+		if _, err := tx.Exec(query, params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// UseRecoveryCode marks an unused recovery code as used at the given time.
+// It returns false if the code does not exist or has already been used.
+func (z *Repository) UseRecoveryCode(ctx context.Context, tx model.WriteOnlyTransaction, userID, codeHash string, t time.Time) (bool, error) {
+
+	logger := logging.New(ctx, componentRepo, "UseRecoveryCode")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	UPDATE user_recovery_codes SET used = :used
+	WHERE user_id = :userID AND code_hash = :codeHash AND used IS NULL
+	`
+	params := map[string]interface{}{
+		"userID":   userID,
+		"codeHash": codeHash,
+		"used":     toNullTimeInteger(&t),
+	}
+	rs, errExec := tx.Exec(query, params)
+	if errExec != nil {
+		return false, errExec
+	}
+	count, errCount := rs.RowsAffected()
+	if errCount != nil {
+		return false, errCount
+	}
+	return count == 1, nil
+
+}
+
+func (z *Repository) deleteUserTOTP(tx model.WriteOnlyTransaction, userID string) error {
+
+	if err := z.deleteRecoveryCodes(tx, userID); err != nil {
+		return err
+	}
+
+	query := "DELETE FROM user_totp WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func (z *Repository) deleteRecoveryCodes(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM user_recovery_codes WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToUserTOTP(t dbUserTOTP) *model.UserTOTP {
+	return &model.UserTOTP{
+		UserID:      t.UserID.String,
+		Secret:      t.Secret.String,
+		LastCounter: t.LastCounter.Int64,
+		Created:     toTime(t.Created),
+		Confirmed:   toTimePointer(toTime(t.Confirmed)),
+	}
+}
+
+func userTOTPToParams(t model.UserTOTP) map[string]interface{} {
+	return map[string]interface{}{
+		"userID":      toNullString(t.UserID),
+		"secret":      toNullString(t.Secret),
+		"lastCounter": t.LastCounter,
+		"created":     toNullTimeInteger(&t.Created),
+		"confirmed":   toNullTimeInteger(t.Confirmed),
+	}
+}
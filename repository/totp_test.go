@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestUserTOTP(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	confirmed := now1h.UTC()
+	userTOTP := model.UserTOTP{
+		UserID:      userIDGuest,
+		Secret:      "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		LastCounter: 0,
+		Created:     now.UTC(),
+	}
+	codeHashes := []string{
+		model.HashRecoveryCode("aaaaa-bbbbb"),
+		model.HashRecoveryCode("ccccc-ddddd"),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetUserTOTP(ctx, tx, userTOTP); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		ut, errGet := us.GetUserTOTP(ctx, tx, userIDGuest)
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(ut, &userTOTP) {
+			t.Errorf("Bad totp: %v, expected %v", ut, userTOTP)
+		}
+		if ut.IsEnabled() {
+			t.Error("Bad enabled: true, expected false")
+		}
+
+		// Confirm
+		userTOTP.Confirmed = &confirmed
+		userTOTP.LastCounter = 41152263
+		if err := us.SetUserTOTP(ctx, tx, userTOTP); err != nil {
+			t.Fatalf("Bad update error: %s", err)
+		}
+		ut2, errReGet := us.GetUserTOTP(ctx, tx, userIDGuest)
+		if errReGet != nil {
+			t.Fatalf("Bad re-get error: %s", errReGet)
+		}
+		if !reflect.DeepEqual(ut2, &userTOTP) {
+			t.Errorf("Bad totp: %v, expected %v", ut2, userTOTP)
+		}
+
+		// Recovery codes
+		if err := us.SetRecoveryCodes(ctx, tx, userIDGuest, codeHashes); err != nil {
+			t.Fatalf("Bad set recovery codes error: %s", err)
+		}
+		for i, expected := range []bool{true, false} {
+			ok, errUse := us.UseRecoveryCode(ctx, tx, userIDGuest, model.HashRecoveryCode("AAAAABBBBB"), now)
+			if errUse != nil {
+				t.Fatalf("Bad use recovery code error: %s", errUse)
+			}
+			if got, want := ok, expected; got != want {
+				t.Errorf("Bad recovery code use %d: %t, expected %t", i, got, want)
+			}
+		}
+
+		// Delete
+		if err := us.DeleteUserTOTP(ctx, tx, userIDGuest); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		ut3, errDeleted := us.GetUserTOTP(ctx, tx, userIDGuest)
+		if errDeleted != nil {
+			t.Fatalf("Bad get after delete error: %s", errDeleted)
+		}
+		if ut3 != nil {
+			t.Errorf("Bad totp after delete: %v, expected nil", ut3)
+		}
+		ok, errUse := us.UseRecoveryCode(ctx, tx, userIDGuest, codeHashes[1], now)
+		if errUse != nil {
+			t.Fatalf("Bad use recovery code error: %s", errUse)
+		}
+		if ok {
+			t.Error("Bad recovery code after delete: true, expected false")
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
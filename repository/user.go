@@ -11,14 +11,12 @@ import (
 	"wallawire/model"
 )
 
-// TODO: missing role management: List,Set,Delete Roles
-// TODO: missing user List function
-
 type dbUser struct {
 	UserID       sql.NullString `db:"id"`
 	Disabled     bool           `db:"disabled"`
 	Username     sql.NullString `db:"username"`
 	Name         sql.NullString `db:"name"`
+	Email        sql.NullString `db:"email"`
 	PasswordHash sql.NullString `db:"password_hash"`
 	Created      sql.NullInt64  `db:"created"`
 	Updated      sql.NullInt64  `db:"updated"`
@@ -37,7 +35,7 @@ func (z *Repository) GetUser(ctx context.Context, tx model.ReadOnlyTransaction,
 	logger.Debug().Msg("invoked")
 
 	query := `
-	SELECT id, disabled, username, name, password_hash, created, updated
+	SELECT id, disabled, username, name, email, password_hash, created, updated
     FROM users
     WHERE id = :id
 	`
@@ -70,13 +68,72 @@ func (z *Repository) GetUser(ctx context.Context, tx model.ReadOnlyTransaction,
 
 }
 
+// ListUsers returns a page of users matching the filter, ordered by username, and the total number of matches.
+func (z *Repository) ListUsers(ctx context.Context, tx model.ReadOnlyTransaction, filter model.UserFilter) ([]model.User, int, error) {
+
+	logger := logging.New(ctx, componentRepo, "ListUsers")
+	logger.Debug().Msg("invoked")
+
+	where := "WHERE 1 = 1 "
+	params := map[string]interface{}{
+		"offset": filter.Offset,
+		"limit":  filter.Limit,
+	}
+
+	if filter.Username != "" {
+		where += "AND LOWER(username) LIKE :username "
+		params["username"] = "%" + escapeLike(strings.ToLower(filter.Username)) + "%"
+	}
+
+	if filter.Disabled != nil {
+		where += "AND disabled = :disabled "
+		params["disabled"] = *filter.Disabled
+	}
+
+	total, errCount := z.countUsers(ctx, tx, where, params)
+	if errCount != nil {
+		return nil, 0, errCount
+	}
+
+	query := `
+	SELECT id, disabled, username, name, email, password_hash, created, updated
+	FROM users
+	` + where + `
+	ORDER BY username
+	LIMIT :limit OFFSET :offset
+	`
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, 0, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	users := make([]model.User, 0)
+	for rs.Next() {
+		u := dbUser{}
+		if err := rs.StructScan(&u); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *convertToUser(u))
+	}
+
+	return users, total, nil
+
+}
+
 func (z *Repository) GetActiveUserByUsername(ctx context.Context, tx model.ReadOnlyTransaction, username string) (*model.User, error) {
 
 	logger := logging.New(ctx, componentRepo, "GetActiveUserByUsername")
 	logger.Debug().Str("username", username).Msg("invoked")
 
 	query := `
-	SELECT id, disabled, username, name, password_hash, created, updated
+	SELECT id, disabled, username, name, email, password_hash, created, updated
     FROM users
     WHERE username = :username
 	`
@@ -155,12 +212,13 @@ func (z *Repository) SetUser(ctx context.Context, tx model.WriteOnlyTransaction,
 	logger.Debug().Msg("invoked")
 
 	query := `
-	INSERT INTO users (id, disabled, username, name, password_hash, created, updated)
-	VALUES (:id, :disabled, :username, :name, :passwordHash, EXTRACT('epoch', now()), EXTRACT('epoch', now()))
+	INSERT INTO users (id, disabled, username, name, email, password_hash, created, updated)
+	VALUES (:id, :disabled, :username, :name, :email, :passwordHash, EXTRACT('epoch', now()), EXTRACT('epoch', now()))
 	ON CONFLICT (id) DO UPDATE SET
 	disabled = :disabled,
 	username = :username,
 	name = :name,
+	email = :email,
 	password_hash = :passwordHash,
 	updated = EXTRACT('epoch', now())
 	`
@@ -181,6 +239,46 @@ func (z *Repository) DeleteUser(ctx context.Context, tx model.WriteOnlyTransacti
 		return errRoles
 	}
 
+	errSessions := z.deleteUserSessions(tx, userID)
+	if errSessions != nil {
+		return errSessions
+	}
+
+	errTOTP := z.deleteUserTOTP(tx, userID)
+	if errTOTP != nil {
+		return errTOTP
+	}
+
+	errVerifications := z.deleteUserVerifications(tx, userID)
+	if errVerifications != nil {
+		return errVerifications
+	}
+
+	errResets := z.deletePasswordResets(tx, userID)
+	if errResets != nil {
+		return errResets
+	}
+
+	errChallenges := z.deleteLoginChallenges(tx, userID)
+	if errChallenges != nil {
+		return errChallenges
+	}
+
+	errHistory := z.deletePasswordHistory(tx, userID)
+	if errHistory != nil {
+		return errHistory
+	}
+
+	errIdentities := z.deleteUserIdentities(tx, userID)
+	if errIdentities != nil {
+		return errIdentities
+	}
+
+	errAPITokens := z.deleteUserAPITokens(tx, userID)
+	if errAPITokens != nil {
+		return errAPITokens
+	}
+
 	errUser := z.deleteUser(tx, userID)
 	if errUser != nil {
 		return errUser
@@ -235,6 +333,14 @@ func (z *Repository) GetUserRoles(ctx context.Context, tx model.ReadOnlyTransact
 		roles = append(roles, convertToRole(role))
 	}
 
+	permissions, errPermissions := z.getRolePermissions(ctx, tx, "WHERE role_id IN (SELECT role_id FROM user_role WHERE user_id = :userID)", params)
+	if errPermissions != nil {
+		return nil, errPermissions
+	}
+	for i := range roles {
+		roles[i].Permissions = permissions[roles[i].ID]
+	}
+
 	return roles, nil
 
 }
@@ -268,6 +374,32 @@ func (z *Repository) SetUserRoles(ctx context.Context, tx model.Transaction, use
 
 }
 
+func (z *Repository) countUsers(ctx context.Context, tx model.ReadOnlyTransaction, where string, params map[string]interface{}) (int, error) {
+
+	logger := logging.New(ctx, componentRepo, "countUsers")
+
+	rs, errQuery := tx.Query("SELECT COUNT(*) FROM users "+where, params)
+	if errQuery != nil {
+		return 0, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var total int
+	if rs.Next() {
+		if err := rs.Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+
+}
+
 func (z *Repository) deleteUser(tx model.WriteOnlyTransaction, userID string) error {
 
 	query := "DELETE FROM users WHERE id = :userID"
@@ -351,12 +483,18 @@ func (z *Repository) findComplement(a, b []model.UserRole) []model.UserRole {
 
 }
 
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 func convertToUser(u dbUser) *model.User {
 	return &model.User{
 		ID:           u.UserID.String,
 		Disabled:     u.Disabled,
 		Username:     u.Username.String,
 		Name:         u.Name.String,
+		Email:        u.Email.String,
 		PasswordHash: u.PasswordHash.String,
 		Created:      toTime(u.Created),
 		Updated:      toTime(u.Updated),
@@ -378,6 +516,7 @@ func userToParams(user model.User) map[string]interface{} {
 		"disabled":     user.Disabled,
 		"username":     toNullString(user.Username),
 		"name":         toNullString(user.Name),
+		"email":        toNullString(user.Email),
 		"passwordHash": toNullString(user.PasswordHash),
 		// Note: no updated, created as those are handled automatically
 	}
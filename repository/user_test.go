@@ -34,9 +34,18 @@ func init() {
 		fmt.Sprintf("INSERT INTO user_role (user_id, role_id, valid_from, valid_to) VALUES ('%s', '%s', %d, NULL)", userIDFakeuser, roleIDReporter, now1h.Unix()),
 		fmt.Sprintf("INSERT INTO user_role (user_id, role_id, valid_from, valid_to) VALUES ('%s', '%s', NULL, %d)", userIDFakeuser, roleIDCopywriter, now1h.Unix()),
 		fmt.Sprintf("INSERT INTO user_role (user_id, role_id, valid_from, valid_to) VALUES ('%s', '%s', NULL, NULL)", userIDFakeuser, roleIDStaff),
+		fmt.Sprintf("INSERT INTO sessions (id, user_id, issued, last_seen, expires, revoked, user_agent, ip_address) VALUES ('%s', '%s', %d, %d, %d, NULL, 'Mozilla/5.0', '127.0.0.1')", sessionIDFakeuser, userIDFakeuser, now.Unix(), now.Unix(), now5d.Unix()),
 	}
 
 	tStatements := []string{
+		fmt.Sprintf("DELETE FROM user_recovery_codes WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM user_totp WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM user_verifications WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM password_resets WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM password_history WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM user_identities WHERE user_id = '%s'", userIDGuest),
+		fmt.Sprintf("DELETE FROM api_tokens WHERE user_id = '%s'", userIDFakeuser),
+		fmt.Sprintf("DELETE FROM sessions WHERE user_id = '%s'", userIDFakeuser),
 		fmt.Sprintf("DELETE FROM user_role WHERE user_id = '%s'", userIDGuest),
 		fmt.Sprintf("DELETE FROM user_role WHERE user_id = '%s'", userIDFakeuser),
 		fmt.Sprintf("DELETE FROM users WHERE id = '%s'", userIDGuest),
@@ -309,6 +318,97 @@ func TestGetActiveUserByUsername(b *testing.T) {
 
 }
 
+func TestListUsers(b *testing.T) {
+
+	yes := true
+	no := false
+
+	testCases := []struct {
+		Alias             string
+		Filter            model.UserFilter
+		ExpectedUsernames []string
+		ExpectedTotal     int
+	}{
+		{
+			Alias:             "username",
+			Filter:            model.UserFilter{Username: "fake", Limit: 10},
+			ExpectedUsernames: []string{"fakeuser"},
+			ExpectedTotal:     1,
+		},
+		{
+			Alias:             "username ignores case",
+			Filter:            model.UserFilter{Username: "GUEST", Limit: 10},
+			ExpectedUsernames: []string{"guestuser"},
+			ExpectedTotal:     1,
+		},
+		{
+			Alias:             "disabled",
+			Filter:            model.UserFilter{Username: "guest", Disabled: &yes, Limit: 10},
+			ExpectedUsernames: []string{"guestuser"},
+			ExpectedTotal:     1,
+		},
+		{
+			Alias:             "enabled",
+			Filter:            model.UserFilter{Username: "guest", Disabled: &no, Limit: 10},
+			ExpectedUsernames: []string{},
+			ExpectedTotal:     0,
+		},
+		{
+			Alias:             "wildcards escaped",
+			Filter:            model.UserFilter{Username: "fake%", Limit: 10},
+			ExpectedUsernames: []string{},
+			ExpectedTotal:     0,
+		},
+		{
+			Alias:             "offset past end",
+			Filter:            model.UserFilter{Username: "fake", Offset: 1, Limit: 10},
+			ExpectedUsernames: []string{},
+			ExpectedTotal:     1,
+		},
+	}
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			err := database.Run(func(tx model.Transaction) error {
+
+				ctx := context.Background()
+
+				users, total, errList := us.ListUsers(ctx, tx, tc.Filter)
+				if errList != nil {
+					t.Fatalf("Bad list error: %s", errList)
+				}
+				usernames := []string{}
+				for _, u := range users {
+					usernames = append(usernames, u.Username)
+				}
+				if !reflect.DeepEqual(usernames, tc.ExpectedUsernames) {
+					t.Errorf("Bad users: %v, expected %v", usernames, tc.ExpectedUsernames)
+				}
+				if got, want := total, tc.ExpectedTotal; got != want {
+					t.Errorf("Bad total: %d, expected %d", got, want)
+				}
+
+				return nil // always nil, so don't test database.Run return value
+
+			})
+
+			if err != nil {
+				t.Error(err)
+			}
+
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
+
 func TestIsUsernameAvailable(b *testing.T) {
 
 	testCases := []struct {
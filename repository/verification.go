@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type dbUserVerification struct {
+	TokenHash sql.NullString `db:"token_hash"`
+	UserID    sql.NullString `db:"user_id"`
+	Email     sql.NullString `db:"email"`
+	Created   sql.NullInt64  `db:"created"`
+	Expires   sql.NullInt64  `db:"expires"`
+}
+
+func (z *Repository) GetUserVerification(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.UserVerification, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetUserVerification")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT token_hash, user_id, email, created, expires
+	FROM user_verifications
+	WHERE token_hash = :tokenHash
+	`
+	params := map[string]interface{}{
+		"tokenHash": tokenHash,
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	var verification *model.UserVerification
+
+	if rs.Next() {
+		v := dbUserVerification{}
+		if err := rs.StructScan(&v); err != nil {
+			return nil, err
+		}
+		verification = convertToUserVerification(v)
+	}
+
+	return verification, nil
+
+}
+
+// SetUserVerification adds a pending email verification.
+func (z *Repository) SetUserVerification(ctx context.Context, tx model.WriteOnlyTransaction, verification model.UserVerification) error {
+
+	logger := logging.New(ctx, componentRepo, "SetUserVerification")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	INSERT INTO user_verifications (token_hash, user_id, email, created, expires)
+	VALUES (:tokenHash, :userID, :email, :created, :expires)
+	`
+	params := userVerificationToParams(verification)
+	if _, err := tx.Exec(query, params); err != nil {
+		return err
+	}
+	return nil
+
+}
+
+// DeleteUserVerifications removes all pending email verifications of a user.
+func (z *Repository) DeleteUserVerifications(ctx context.Context, tx model.WriteOnlyTransaction, userID string) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteUserVerifications")
+	logger.Debug().Msg("invoked")
+
+	return z.deleteUserVerifications(tx, userID)
+
+}
+
+// GetExpiredRegistrations returns the IDs of the disabled users whose email verifications have all expired at the given time.
+func (z *Repository) GetExpiredRegistrations(ctx context.Context, tx model.ReadOnlyTransaction, t time.Time) ([]string, error) {
+
+	logger := logging.New(ctx, componentRepo, "GetExpiredRegistrations")
+	logger.Debug().Msg("invoked")
+
+	query := `
+	SELECT DISTINCT v.user_id
+	FROM user_verifications v
+	INNER JOIN users u ON u.id = v.user_id
+	WHERE u.disabled = true
+	AND v.expires <= :t
+	AND NOT EXISTS (SELECT 1 FROM user_verifications w WHERE w.user_id = v.user_id AND w.expires > :t)
+	`
+	params := map[string]interface{}{
+		"t": toNullTimeInteger(&t),
+	}
+
+	rs, errQuery := tx.Query(query, params)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	defer func() {
+		if err := rs.Close(); err != nil {
+			logger.Warn().Err(err).Msg("cannot close resultset")
+		}
+	}()
+
+	userIDs := make([]string, 0)
+	for rs.Next() {
+		var userID string
+		if err := rs.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+
+}
+
+// DeleteExpiredUserVerifications removes the email verifications expired at the given time.
+func (z *Repository) DeleteExpiredUserVerifications(ctx context.Context, tx model.WriteOnlyTransaction, t time.Time) error {
+
+	logger := logging.New(ctx, componentRepo, "DeleteExpiredUserVerifications")
+	logger.Debug().Msg("invoked")
+
+	query := "DELETE FROM user_verifications WHERE expires <= :t"
+	params := map[string]interface{}{
+		"t": toNullTimeInteger(&t),
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func (z *Repository) deleteUserVerifications(tx model.WriteOnlyTransaction, userID string) error {
+
+	query := "DELETE FROM user_verifications WHERE user_id = :userID"
+	params := map[string]interface{}{
+		"userID": userID,
+	}
+	_, errExec := tx.Exec(query, params)
+	return errExec
+
+}
+
+func convertToUserVerification(v dbUserVerification) *model.UserVerification {
+	return &model.UserVerification{
+		TokenHash: v.TokenHash.String,
+		UserID:    v.UserID.String,
+		Email:     v.Email.String,
+		Created:   toTime(v.Created),
+		Expires:   toTime(v.Expires),
+	}
+}
+
+func userVerificationToParams(v model.UserVerification) map[string]interface{} {
+	return map[string]interface{}{
+		"tokenHash": toNullString(v.TokenHash),
+		"userID":    toNullString(v.UserID),
+		"email":     toNullString(v.Email),
+		"created":   toNullTimeInteger(&v.Created),
+		"expires":   toNullTimeInteger(&v.Expires),
+	}
+}
@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"wallawire/idgen"
+	"wallawire/model"
+	"wallawire/repository"
+)
+
+func TestUserVerification(t *testing.T) {
+
+	database := repository.NewDatabase(db)
+	us := repository.New(idgen.NewUUIDGenerator())
+
+	verification := model.UserVerification{
+		TokenHash: model.HashToken("token"),
+		UserID:    userIDGuest,
+		Email:     "guest@example.com",
+		Created:   now.UTC(),
+		Expires:   now1h.UTC(),
+	}
+
+	err := database.Run(func(tx model.Transaction) error {
+
+		ctx := context.Background()
+
+		// Set
+		if err := us.SetUserVerification(ctx, tx, verification); err != nil {
+			t.Fatalf("Bad set error: %s", err)
+		}
+
+		// Get
+		v, errGet := us.GetUserVerification(ctx, tx, model.HashToken("token"))
+		if errGet != nil {
+			t.Fatalf("Bad get error: %s", errGet)
+		}
+		if !reflect.DeepEqual(v, &verification) {
+			t.Errorf("Bad verification: %v, expected %v", v, verification)
+		}
+
+		// Expired
+		contains := func(ids []string, id string) bool {
+			for _, x := range ids {
+				if x == id {
+					return true
+				}
+			}
+			return false
+		}
+		pending, errPending := us.GetExpiredRegistrations(ctx, tx, now)
+		if errPending != nil {
+			t.Fatalf("Bad get expired error: %s", errPending)
+		}
+		if contains(pending, userIDGuest) {
+			t.Errorf("Bad expired registrations: %v, expected without %s", pending, userIDGuest)
+		}
+		expired, errExpired := us.GetExpiredRegistrations(ctx, tx, now1h)
+		if errExpired != nil {
+			t.Fatalf("Bad get expired error: %s", errExpired)
+		}
+		if !contains(expired, userIDGuest) {
+			t.Errorf("Bad expired registrations: %v, expected with %s", expired, userIDGuest)
+		}
+		if err := us.DeleteExpiredUserVerifications(ctx, tx, now); err != nil {
+			t.Fatalf("Bad delete expired error: %s", err)
+		}
+		if v, _ := us.GetUserVerification(ctx, tx, model.HashToken("token")); v == nil {
+			t.Error("Bad verification nil after deleting expired, expected non-nil")
+		}
+
+		// Delete
+		if err := us.DeleteUserVerifications(ctx, tx, userIDGuest); err != nil {
+			t.Fatalf("Bad delete error: %s", err)
+		}
+		v2, errDeleted := us.GetUserVerification(ctx, tx, model.HashToken("token"))
+		if errDeleted != nil {
+			t.Fatalf("Bad get after delete error: %s", errDeleted)
+		}
+		if v2 != nil {
+			t.Errorf("Bad verification after delete: %v, expected nil", v2)
+		}
+
+		return nil // always nil, so don't test database.Run return value
+
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+}
@@ -9,6 +9,21 @@ func TestAssetNames(t *testing.T) {
 	expectedAssetNames := []string{
 		"1_init.sql",
 		"2_data.sql",
+		"3_sessions.sql",
+		"4_totp.sql",
+		"5_login_throttles.sql",
+		"6_registration.sql",
+		"7_password_resets.sql",
+		"8_password_history.sql",
+		"9_password_hash.sql",
+		"10_user_identities.sql",
+		"11_api_tokens.sql",
+		"12_role_permissions.sql",
+		"13_audit_events.sql",
+		"14_impersonation.sql",
+		"15_push_messages.sql",
+		"16_login_challenges.sql",
+		"17_push_broker_messages.sql",
 	}
 
 	names, errNames := getAssetNames("")
@@ -13,6 +13,101 @@ import (
 )
 
 var assetMap = map[string]*File{
+	"/10_user_identities.sql": &File{
+		name:    "/10_user_identities.sql",
+		hash:    "653d960b231c687a490fd356cecb8c1b403982a86b1a3df5eece4e1d520ca748",
+		modTime: time.Unix(1792239482, 493796963),
+		payload: `
+H4sIAAAAAAACA3WQwU7DMBBE7/6KOTaiuVQql55MsgWL4FRbG7Un1DYWMqIFxYng83FCA6WAb17Nzsy+NMXF3j/Wm8bBvoqMSRqC
+kVcFQc2hSwNaqaVZog2ufvCVOzS+8S5gJAAfQutq4F5ydiN5NJlOk35H26IYR0Fot09u1/wvOLrCWpVjeIMATHNi0hl9xsdQXyXd
+mttv/DNOgy/7+a528ZIKShu6Jv5p1wkWrO4kr3FL62jW1x8PLRORzMSAQOmcVmcIfPVuYw31BaH7odS/2RwHnV96Qjh/eTuInMvF
+N+G/6c7EB1LnBVWZAQAA
+`,
+	},
+	"/11_api_tokens.sql": &File{
+		name:    "/11_api_tokens.sql",
+		hash:    "922096ac48f8ac8c8081e7798803c296a522b5e0e837f8430db658572807d1a8",
+		modTime: time.Unix(1792239803, 89796963),
+		payload: `
+H4sIAAAAAAACA32SX0+DMBTF3/sp7iPEkUxjfFliwuBuNMOCpTXbEyGjcUQHBFD38W2ZbGxG+9bm/M79c+o4cLMvXpusUyBr4nF0
+BYJw5yECXQCLBOCaJiKBrC7SrnpTZQsWAShyGM6Ly73A5dbDva1vBmEyDCHm9MnlG1jhZqKBj1Y16ZGSkvon+gxwXCBH5mHSi3Wd
+IrcNWmZ79X8tL0BvBVaIbCkCay50actQtg2PMO1N+t7TXdbuYOQwNpGMPks02nZb1aq9KHg7vdPAoDWibaP01vqBKBO4RH41kBGp
+Q100R6u/Re9Z26V65PwkMq+N+tQtj/2JPSNDRJT5uL6KqMgPbkxFH5LUG4SIXcT2E8FkaN3YOaMP4FdfJfF5FJ8/wK/wZ+Qb/MIQ
+XjMCAAA=
+`,
+	},
+	"/12_role_permissions.sql": &File{
+		name:    "/12_role_permissions.sql",
+		hash:    "db590bd3d85897b80a29c1827244c0593c24f6279e7dbba5b3cc8de826722f44",
+		modTime: time.Unix(1792242178, 495660682),
+		payload: `
+H4sIAAAAAAACA72STW+CQBCG7/yKuQGp26B8xxPVbUpK0azQ1FOzsIOSIhjA0P77QjXqwZ6adI6z++yzb2YIgbtdvql5ixDvpRmj
+XkQh8h4CCv4jhIsI6Ju/ilZQVwW+77He5U2TV2UDigTHZi6grzj253CqAQvjIABGHymj4Ywe+R7KhTrqwctD8Oqx2ZPHFMtQz+Bw
+Zcn8F4+t4ZmuQTmJRlegKqlTSSIE2i1CcsiLNi9Plg/EPXRb3g5nX9BhjcCLoupQQFuBqCDBrOqb13nwM29aFFK8XFEWgR9Gixuh
+b32kjxDEfURF5ombTVxtTEyOnBh6YhEnmyTETjLXFkLXHM2RRyDzNK0OZSv3Cf6i00wUlm6bRLMdixg45iRJE42MTV1PHd12TUMM
+ukODdXO/4yXf4H85fyZx5RwmdV61edWV0pwtlpdV+2XNptI3Wyi8Q6MCAAA=
+`,
+	},
+	"/13_audit_events.sql": &File{
+		name:    "/13_audit_events.sql",
+		hash:    "8aca2409fb2f23c57483e17219274dc8c15f7ce86dfe2431ce2461fc66cfb7ad",
+		modTime: time.Unix(1792243516, 335231772),
+		payload: `
+H4sIAAAAAAACA42TXW/aMBSG7/0rzh2g4QkGCZ3QLjJw12hpgkzY2qvITU6ZNYiRY9r138/O+MgCmpYrx+c87/l4ZUrh3VautTAI
+qx2hFEoFz0qjXJfwE9+qPuALlqYCtTcb+YJgfiDsK9SVO72BxmfUYBSZcRakDNLgc8QgvIU4SYE9hMt0CWJfSJMddLoEQBbQ/L4F
+fHYX8K4/7kHNxasoggUP7wP+CF/ZY98yuUbb5AkM45R9YfzPz5FxeSI3UpX/1nZ5dp5cbbGdN/R7F3pKZ6eOG3rnaCkOQq2oEXqN
+5gS3olusKrG+6OCD59dhuctEUWibdA12FmSWLs1VOFda40a4VbjyDZj0puRoVhjP2UPLLFn8CpxfrLZrdth6ErdcPNhhxf5bK3C7
+ulQ6LrgPZ02yWiwZT53LCWi1wWyHeiurys5jkfrGEefbnp0xWrEldDsDDwt/NPHoYHLj0zEOBX3KnwZ06I1G+c1o8tEbF50+dOo2
+3tuaRceVpI2nMFevJZmziNnBbnlyf9nD9zvGWaM+fPpLcErmPFmcX8OVlzAlvwERvt+KfQMAAA==
+`,
+	},
+	"/14_impersonation.sql": &File{
+		name:    "/14_impersonation.sql",
+		hash:    "74da0183cbaf85807f5a27c5baef7bc672cc886e8c60e1d7e709f1b4222a6f21",
+		modTime: time.Unix(1792243661, 701796963),
+		payload: `
+H4sIAAAAAAACA22QUWuDMBSF3/Mrzpsta4bFWjtkD26mTLC1aLLtrVjNusA0xaTs78+OgcJ8unC497uHj1LcNerclVZCXAilMNLi
+Q3f9NEbp1kAZc5U1rEbZoqwb1Spj+4N+p6ysas8oDeynxNXIjkQpZzl49JSyARHFMZ6zVOz2SLbYZxzsPSl4AdVcZGd0e6MdVQ0h
+kjgkRBwKlnMke56h01/y2G816g82+01UvcCQzvEapYIVmDmuL+u1F/jUDTZrupLLkp6qk0uXvudVGy948Fe1s4Bza2vuhwLSmfef
+6chHrL9bErOUcYZtnu3+V3l7YTkb1cDjFDeclhLn2WFkZdpISH4AlSy5d6IBAAA=
+`,
+	},
+	"/15_push_messages.sql": &File{
+		name:    "/15_push_messages.sql",
+		hash:    "ad831c13a13a83382e7153ae7c54c90c8651eefd987e9d5534e117c3cae244c8",
+		modTime: time.Unix(1792257432, 454880952),
+		payload: `
+H4sIAAAAAAAC/31TXW+bQBB851fsW6DFUipFkSo/EXxJUG1wAVfOk3WBs30q3FFuiet/nz2Caew2vQc+htmZvbllMoHPtdy1HAWs
+GmcygYobhKYze6iFMXwnDDSihc7QBTW0oqn48e2p0EqJAqXaQVFJodD4wBWIusFjX7CRJfCybEmIZHhV9ahxwpQFOYM8uJsziO4h
+TnJg6yjLs955Mzq7DoARv2BYd9FDFOenN1sVr+ZzWKbRIkif4Bt78qng5EzrR5CGj0Hq3t547wr8XtUYqZXlfUwqWkHB9Erkyx5Y
+emFtSXhsxAC/U7JfSo784svN9ddbz/GmzimDKJ6x9UUGsvy9pBgWQworG30SX0YzbNO3AVlBOjvcC5DKIFcFMZD/FD1EhE4QBKqr
+nyl/0NsePz/lbavrHrZtP3MjSFkTwNGiR1DihfoodFXJUlg3iUBWLdLJbtFOx/6Pw3knB4l73SHw0/gY1K0grU7h6CvbNzlAWdti
+qGXRamOnrBxHJmPfVywO/zs1G9rv1MnYnIU57R1feOVe/cW48iEMstxl6zwNQrovk/AR7tNkAUofXM+DT/Dlul8QZMPoeUPQ4z8z
+0wflzNJkedbZx1311HHu/8WbOq+0yPVIlAMAAA==
+`,
+	},
+	"/16_login_challenges.sql": &File{
+		name:    "/16_login_challenges.sql",
+		hash:    "e1b2bb2d30d272ace69f673f18a0ee5fba75b4ff0d37e8d0a1cc596fe752f5b0",
+		modTime: time.Unix(1792250893, 312862434),
+		payload: `
+H4sIAAAAAAACA3WPwQqCQBRF9/MVb5mUu2jTatJXDdloz5moVUhNNmAqmtTnpxVUUHd9DpfjutA/27RKLgZ0yTxCrhAUnwQIYgoy
+VIAbEasYsiK1+W5/SrLM5KmpoccA7AGeW3Py5px6o6HzkKQOAohILDltYYHbQQs3tal2naG18F/eG9ZSrDQC4RQJpYfxg29v7MHp
+bHMrbdXegpAKZ0hfdgccE5s1HfELYM6YMfcj1i+uOfMpjN6xf0LH7A6i12yPJQEAAA==
+`,
+	},
+	"/17_push_broker_messages.sql": &File{
+		name:    "/17_push_broker_messages.sql",
+		hash:    "23d0f6fa5de64b1e34ff4685adb9fce53cdd8be4e1e72c6389959a1335c458ba",
+		modTime: time.Unix(1792258992, 634880952),
+		payload: `
+H4sIAAAAAAAC/4WSwW7CMAyG73kKH6nWSpuEkCZOBTJWDQrKygQnlLYZjQhNlYQx3n5J2zGEYOTm+PMfx7+DAB52fKOoYbCoUBBA
+tdcF7JjWdMO0jVLBdcFyMBKoEMBLbWiZ2VR6BFMwyKmhKdUMUiW3TPlwKHhWAPti6niioZJCaB+2rDLwKRVQ0IVUxsGCoSHBYYIh
+CQcTDNELxLME8DJ6T97rdtaN9PrUVQcBSMU3vAR3PkIyfA1Jp9f1bOSK48Vk4ltIM8WpqKFBNI7iBNpzDmWK2e/n7toSeIzJFWhv
+pdY8v/ec1lyWjrsNGVnxDO40nsnSKCnOoaee5zJND5fltfCxYtczzqOLTPfxudGbk2gakhW84RV0mqH67dw85PXRrzlRPMLLC3N4
+/j23/gxqe6atO8N2nLP4hnntvJ12cLZ+I3ko0YjM5n978M8O9NEPukw2u7sCAAA=
+`,
+	},
 	"/1_init.sql": &File{
 		name:    "/1_init.sql",
 		hash:    "fcfd7757c98b10f404d5f20b6a95f2d17e29ee2c19e8cbfb36d4932d84577c25",
@@ -37,13 +132,103 @@ chBJ+cZGxbEpOSXQXvse9kuXzz3qT7D98Bts2nchPudUXIc9yUBViPqcw8t1cUpDcQpdd2nPabsL3W56
 sawV1AaBmzKnGGUh7meLVVUImfKhvcoqhJznQys2Q1N9Wi9nb9YTmU9tvZOFOLaXyXT6h4W0DiwZ68CAtg4NOkfkkNizcUyWsgsO
 mYkQwSBSxMAaNCETokMqQaMHBnClzUAEDGgoEjGwoZIyaHBUu5qS82A5sGVw/FtHt/21xORa9tb27Xafbo420qhxP/PLq/xbBaPe
 qzfhNhPz9nK8m1eLal2J++XTuxtNH99Wy+q7HvHw+DfBGCnllnjIzUD6v/i+BgAA//8rMoMEDgQAAA==
+`,
+	},
+	"/3_sessions.sql": &File{
+		name:    "/3_sessions.sql",
+		hash:    "9ad7a5b3aff27322c580ca659be81f5bf2b560a3c04a43dbbe73cca5fe376a9e",
+		modTime: time.Unix(1792234032, 541895756),
+		payload: `
+H4sIAAAAAAACA32RsW6DMBCGdz/FjUENS9VmyeTCpbVKTWTsKpkQkq3IaguII20evxAITaIqnr///vN3YQh3X37XFK0DU7NIIdcI
+mj8lCGIFMtWAG5HpDMgR+aokmDEAb+H03rmKXriaLR4COPLSJAmslXjjaguvuJ13/J5ckw8hY0Q8hSde4QoVygizI9u1eBv0SU+0
+d0ObkBqfUV0me+azoDYn58objDvUvnF0c07jvquPoWxkpt2LnSvb6a/3j4vgIunrvLC2m0//+2DBkp3kChnj5kqut4ds9Gu6Okjl
+me9R3nx00Y8Kz84WVz8li1W6/jvb1cmW7BfdSqME5wEAAA==
+`,
+	},
+	"/4_totp.sql": &File{
+		name:    "/4_totp.sql",
+		hash:    "98df6e25a85b5b6c641bd6cab478e63daa2e40bcb9d1f050dd775aa214bcffb4",
+		modTime: time.Unix(1792234544, 122693528),
+		payload: `
+H4sIAAAAAAACA4WRMW+DMBCFd/+KG0ENW9WFyYVLY5WSyIGqmRACp0FqcGQ7rfrvaweLJFVob7P13rv77qII7vbdu6qNgPJAEo60
+QCjoY4bA5pAvC8A3ti7WcNRCVUaaAwQEhlfXwqnKkqXgyznyMstgxdkL5Rt4xg1wnCPHPMEhRkPQteHMxmjRKGG89ZXyZEF58HAf
+jjFO9FFrUzXy2BuhgOUFPiG/6uVENscy+IEmRbLfdmo/yLyIhDH5l1uJRn4K9W3HaIX+tYERf2Sf5HX2alfrHdwkteJrAvd5ucjA
+d52dk8ITQHRxx1R+9STly9WZZ5ol/kPprh2TH7Cjz5wjAgAA
+`,
+	},
+	"/5_login_throttles.sql": &File{
+		name:    "/5_login_throttles.sql",
+		hash:    "242a6d05b4edf4362ea9c93de3b1aa474801ab89753bc77393fc1c31f03b83a1",
+		modTime: time.Unix(1792235997, 671783557),
+		payload: `
+H4sIAAAAAAACA32QPQ+CMBRF9/6KN9Iogw7GhKlK1UYEUquRiSBWRSs1UOLfV/yIaIx3fDn35ebYNrRO2a5IjITFGQ05JYKCIAOP
+AhuBHwigKzYXc1B6l+Wx2RfaGCVLsBBAmeqzhGeWhA8nhFudHoZ70V94XrumqvVBpuaL6vbxB7VNMlUVt8d1mC/omPJHpUmppDTx
+E/1D6fQoN3GVm0y9qPoecjYjPIIpjcC6j2+/1mGEHYTshg5XX3Lk8iB86/itwkFXGc0R90YBAAA=
+`,
+	},
+	"/6_registration.sql": &File{
+		name:    "/6_registration.sql",
+		hash:    "845315b7892c91c77ffd66489df12ed9bc34d02a02149c0f666643f9f963829c",
+		modTime: time.Unix(1792236183, 238205334),
+		payload: `
+H4sIAAAAAAACA3WRsU7DMBCGdz/FjYkgC4IumUx8BYvUqRy7aqcqagy1oEmVBOjjE5vQpqi90fr/T+fvoghudvatKToDek9oqlCC
+oo8pwmdrmhYoY5BkqZ4J4FMQmQJc8lzlYHaF/YAFlckzlcHdwySMCUkkUoUD4DzvcOsv09hXuyk6W1ctBASgq99Ntd4W7RY8aHIf
+ghvXFDpNYS75jMoVvODqts97jC1dRGvO4G+OeYlTlCgSzIcfBLYMXfN3YT/jrY9Nl9k0pjfh6VwofOplnNE957C3jWmvZshIBBcM
+l/9E2PKg+8UWYxXuATJxUdLwY0eNRtdi9XdFmMzmJ9tXTccXDuurp8uOrxqTH06j1BsXAgAA
+`,
+	},
+	"/7_password_resets.sql": &File{
+		name:    "/7_password_resets.sql",
+		hash:    "02ee0da72ddfdee37b214330fc5af7f22a395089eb3d114d71a6aeb53a3d234f",
+		modTime: time.Unix(1792236393, 58113702),
+		payload: `
+H4sIAAAAAAACA3VQPW+DMBDd/StuDGrZqiyZKByNFWrQYUvJhFCwEitKQDZV8vNrrFRUSXvLDe9D7704hpezOdh21KAGlhImEkEm
+7wUCz0GUEnDLa1nD0Dp37W3XWO306GDBAMb+pC/NsXVHSNcJLZZvUZAIVRRQEf9MaAcb3L167pfTtjEd+FOKZ9OfuYQ5EooU68Dz
+7qaLJtXeah8tqLiQ+IE0qyZc3wbjA/2Le7cgnnEWrdhPTy4y3D70NN2tulel0FT5QFCK5wHujSa/+NeMWX+9sIzKap7x7wlX7BvB
+MjL9fgEAAA==
+`,
+	},
+	"/8_password_history.sql": &File{
+		name:    "/8_password_history.sql",
+		hash:    "3e665d69354b1964f50606d8ba14de6ef08e53b55549af869f3e39aa3fed606b",
+		modTime: time.Unix(1792236779, 864624391),
+		payload: `
+H4sIAAAAAAACA32RzW6DMBCE736KPYIapKbXnCgsjSVqImOk3CIrWAGpAWQb0b59Ify1VVKfVvLOzLe7ngdP1/KipVWQNSTg6AsE
+4b/GCDQClgjAI01FCo00pqt1fipKY2v9BQ4BaI3SpzKH8WUZDcdq0LEsjoFjhBxZgOmt14BT5u6mV6520hQQ7H3ubF+e3UU59Jy1
+6rlmd8oEviH/4U7cHZmRKQvx+Ae5zD8PU8x+hM56BkjYnWGmSTZz6GDteWALBedWa1XZRWTAWKnt7W/S97JCVpeetStUBR/SWGib
+fDAilKXIxYCf/Jf7ayErBkkxxkDAnZY5IOLJ+7jdkXk5aFh3FQl5clgP+uCYO/INIs8VcAkCAAA=
+`,
+	},
+	"/9_password_hash.sql": &File{
+		name:    "/9_password_hash.sql",
+		hash:    "2bd811b525df2ecc721127de2fd7e0f7cd8b264ecd1ff48e6a36a88e0767cdf5",
+		modTime: time.Unix(1792239327, 5052390),
+		payload: `
+H4sIAAAAAAACA6WPuwrCQBBF+3zFLRVdUMHKKsaARXwQomAlazImC3E37ERj/t4XPivFdu7cwz1CoLVTqZUlYVE4QoAp34qEOLZq
+o3SKTHJGjIO0NZRGTjotszY2sa2L8p5KS9AGudEpWWR0FKRjk1DiuEHkh4jcYeBjz2QZt4s3CxaTKQrJXBmbrC8gRKu5j6UbemM3
+bPT6/ebgrf98Vlya857fUBe7h+zIVPo/+JXc7XU+Rn4n+VI+AR4Gqe2DAQAA
 `,
 	},
 }
 
 var assetNames = []string{
+	"/10_user_identities.sql",
+	"/11_api_tokens.sql",
+	"/12_role_permissions.sql",
+	"/13_audit_events.sql",
+	"/14_impersonation.sql",
+	"/15_push_messages.sql",
+	"/16_login_challenges.sql",
+	"/17_push_broker_messages.sql",
 	"/1_init.sql",
 	"/2_data.sql",
+	"/3_sessions.sql",
+	"/4_totp.sql",
+	"/5_login_throttles.sql",
+	"/6_registration.sql",
+	"/7_password_resets.sql",
+	"/8_password_history.sql",
+	"/9_password_hash.sql",
 }
 
 // File represents a single embedded asset file.
@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS user_identities (
+  issuer  VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  user_id UUID         NOT NULL REFERENCES users (id),
+  email   VARCHAR(256),
+  created INTEGER      NOT NULL,
+  PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idxUserIdentitiesUser ON user_identities (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id         VARCHAR(64)   NOT NULL PRIMARY KEY,
+  user_id    UUID          NOT NULL REFERENCES users (id),
+  name       VARCHAR(64)   NOT NULL CHECK (LENGTH(BTRIM(name)) > 0),
+  token_hash CHAR(64)      NOT NULL UNIQUE,
+  scopes     VARCHAR(1024) NOT NULL,
+  created    INTEGER       NOT NULL,
+  expires    INTEGER       NOT NULL,
+  last_used  INTEGER,
+  revoked    INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idxAPITokensUser ON api_tokens (user_id, created);
+
+-- +migrate Down
+DROP TABLE IF EXISTS api_tokens;
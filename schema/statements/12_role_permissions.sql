@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id    UUID        NOT NULL REFERENCES roles (id),
+  permission VARCHAR(64) NOT NULL,
+  PRIMARY KEY (role_id, permission)
+);
+
+-- the builtin roles keep what they were allowed to do before permissions existed
+UPSERT INTO role_permissions (role_id, permission) VALUES ('ab9f2901-5aea-43b6-8f2b-7bf97dd30808', 'account');
+UPSERT INTO role_permissions (role_id, permission) VALUES ('05ed6375-0786-4e1a-bcb0-1533c837954d', 'users.manage');
+UPSERT INTO role_permissions (role_id, permission) VALUES ('05ed6375-0786-4e1a-bcb0-1533c837954d', 'roles.manage');
+
+-- +migrate Down
+DROP TABLE IF EXISTS role_permissions;
@@ -0,0 +1,24 @@
+-- +migrate Up
+-- no foreign keys, events outlive the users they refer to
+CREATE TABLE IF NOT EXISTS audit_events (
+  id             VARCHAR(64)  NOT NULL PRIMARY KEY,
+  created        INTEGER      NOT NULL,
+  action         VARCHAR(64)  NOT NULL,
+  outcome        VARCHAR(16)  NOT NULL,
+  actor_id       VARCHAR(64),
+  actor_name     VARCHAR(64),
+  target_id      VARCHAR(64),
+  message        VARCHAR(256),
+  ip_address     VARCHAR(64),
+  user_agent     VARCHAR(256),
+  correlation_id VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idxAuditEventsCreated ON audit_events (created);
+CREATE INDEX IF NOT EXISTS idxAuditEventsActor ON audit_events (actor_id, created);
+
+UPSERT INTO role_permissions (role_id, permission) VALUES ('05ed6375-0786-4e1a-bcb0-1533c837954d', 'audit.read');
+
+-- +migrate Down
+DELETE FROM role_permissions WHERE permission = 'audit.read';
+DROP TABLE IF EXISTS audit_events;
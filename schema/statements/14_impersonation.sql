@@ -0,0 +1,9 @@
+-- +migrate Up
+-- set for sessions issued to an administrator acting as the user
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS impersonator_id UUID;
+
+UPSERT INTO role_permissions (role_id, permission) VALUES ('05ed6375-0786-4e1a-bcb0-1533c837954d', 'users.impersonate');
+
+-- +migrate Down
+DELETE FROM role_permissions WHERE permission = 'users.impersonate';
+ALTER TABLE sessions DROP COLUMN IF EXISTS impersonator_id;
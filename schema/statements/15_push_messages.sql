@@ -0,0 +1,21 @@
+-- +migrate Up
+-- last push messages per user to replay to reconnecting clients, an empty user_id addresses all users
+CREATE TABLE IF NOT EXISTS push_messages (
+  seq        BIGINT        NOT NULL PRIMARY KEY,
+  user_id    VARCHAR(64)   NOT NULL,
+  session_id VARCHAR(64)   NOT NULL,
+  created    INTEGER       NOT NULL,
+  type       VARCHAR(64),
+  data       VARCHAR(4096)
+);
+
+CREATE INDEX IF NOT EXISTS idxPushMessagesUser ON push_messages (user_id, seq);
+
+-- the instances take the sequence numbers of the push messages from the database, so that they never collide
+-- it starts after the numbers the instances without a replay store count from their start time in microseconds
+CREATE SEQUENCE IF NOT EXISTS push_messages_seq;
+SELECT setval('push_messages_seq', CAST(EXTRACT(EPOCH FROM now()) * 1000000 AS BIGINT));
+
+-- +migrate Down
+DROP SEQUENCE IF EXISTS push_messages_seq;
+DROP TABLE IF EXISTS push_messages;
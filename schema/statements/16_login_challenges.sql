@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS login_challenges (
+  id       VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id  UUID        NOT NULL UNIQUE REFERENCES users (id),
+  expires  INTEGER     NOT NULL,
+  failures INTEGER     NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS login_challenges;
@@ -0,0 +1,20 @@
+-- +migrate Up
+-- push messages published to all instances by the database broker, which every instance polls, kept for a short while
+CREATE TABLE IF NOT EXISTS push_broker_messages (
+  origin     VARCHAR(64)   NOT NULL,
+  serial     BIGINT        NOT NULL,
+  created    INTEGER       NOT NULL,
+  user_id    VARCHAR(64)   NOT NULL,
+  session_id VARCHAR(64)   NOT NULL,
+  topic      VARCHAR(64)   NOT NULL,
+  control    VARCHAR(16),
+  id         VARCHAR(64),
+  type       VARCHAR(64),
+  data       VARCHAR(4096),
+  PRIMARY KEY (origin, serial)
+);
+
+CREATE INDEX IF NOT EXISTS idxPushBrokerMessagesCreated ON push_broker_messages (created);
+
+-- +migrate Down
+DROP TABLE IF EXISTS push_broker_messages;
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS sessions (
+  id         VARCHAR(64)  NOT NULL PRIMARY KEY,
+  user_id    UUID         NOT NULL REFERENCES users (id),
+  issued     INTEGER      NOT NULL,
+  last_seen  INTEGER      NOT NULL,
+  expires    INTEGER      NOT NULL,
+  revoked    INTEGER,
+  user_agent VARCHAR(256) NOT NULL,
+  ip_address VARCHAR(64)  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idxSessionsUser ON sessions (user_id, issued);
+
+-- +migrate Down
+DROP TABLE IF EXISTS sessions;
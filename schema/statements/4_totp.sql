@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id      UUID        NOT NULL PRIMARY KEY REFERENCES users (id),
+  secret       VARCHAR(64) NOT NULL,
+  last_counter INTEGER     NOT NULL,
+  created      INTEGER     NOT NULL,
+  confirmed    INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  user_id   UUID     NOT NULL REFERENCES users (id),
+  code_hash CHAR(64) NOT NULL,
+  used      INTEGER,
+  PRIMARY KEY (user_id, code_hash)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
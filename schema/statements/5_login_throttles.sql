@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS login_throttles (
+  scope        VARCHAR(16)  NOT NULL,
+  subject      VARCHAR(128) NOT NULL,
+  failures     INTEGER      NOT NULL,
+  last_failure INTEGER      NOT NULL,
+  locked_until INTEGER,
+  PRIMARY KEY (scope, subject)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS login_throttles;
@@ -0,0 +1,16 @@
+-- +migrate Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(256);
+
+CREATE TABLE IF NOT EXISTS user_verifications (
+  token_hash CHAR(64)     NOT NULL PRIMARY KEY,
+  user_id    UUID         NOT NULL REFERENCES users (id),
+  email      VARCHAR(256) NOT NULL,
+  created    INTEGER      NOT NULL,
+  expires    INTEGER      NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idxUserVerificationsUser ON user_verifications (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_verifications;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
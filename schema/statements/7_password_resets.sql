@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS password_resets (
+  token_hash CHAR(64) NOT NULL PRIMARY KEY,
+  user_id    UUID     NOT NULL REFERENCES users (id),
+  created    INTEGER  NOT NULL,
+  expires    INTEGER  NOT NULL,
+  used       INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idxPasswordResetsUser ON password_resets (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS password_resets;
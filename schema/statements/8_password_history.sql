@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS password_history (
+  user_id       UUID      NOT NULL REFERENCES users (id),
+  password_hash CHAR(120) NOT NULL,
+  created       INTEGER   NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idxPasswordHistoryUser ON password_history (user_id, created);
+
+-- the current passwords start the history, changed when last updated
+INSERT INTO password_history (user_id, password_hash, created)
+SELECT id, password_hash, updated
+FROM users;
+
+-- +migrate Down
+DROP TABLE IF EXISTS password_history;
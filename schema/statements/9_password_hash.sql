@@ -0,0 +1,8 @@
+-- +migrate Up
+-- self-describing hashes vary in length, bcrypt hashes are no longer hex-encoded
+ALTER TABLE users ALTER COLUMN password_hash TYPE VARCHAR(255);
+ALTER TABLE password_history ALTER COLUMN password_hash TYPE VARCHAR(255);
+
+-- +migrate Down
+ALTER TABLE password_history ALTER COLUMN password_hash TYPE CHAR(120);
+ALTER TABLE users ALTER COLUMN password_hash TYPE CHAR(120);
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	componentAdminService = "AdminService"
+	defaultUserPageSize   = 50
+	maxUserPageSize       = 200
+)
+
+type AdminUserRepository interface {
+	GetUser(context.Context, model.ReadOnlyTransaction, string) (*model.User, error)
+	ListUsers(context.Context, model.ReadOnlyTransaction, model.UserFilter) ([]model.User, int, error)
+	IsUsernameAvailable(context.Context, model.ReadOnlyTransaction, string) (bool, error)
+	SetUser(context.Context, model.WriteOnlyTransaction, model.User) error
+	DeleteUser(context.Context, model.WriteOnlyTransaction, string) error
+	GetRoles(context.Context, model.ReadOnlyTransaction) ([]model.Role, error)
+	GetRole(context.Context, model.ReadOnlyTransaction, string) (*model.Role, error)
+	GetRoleByName(context.Context, model.ReadOnlyTransaction, string) (*model.Role, error)
+	SetRole(context.Context, model.WriteOnlyTransaction, model.Role) error
+	DeleteRole(context.Context, model.WriteOnlyTransaction, string) error
+	SetRolePermissions(context.Context, model.WriteOnlyTransaction, string, []string) error
+	GetUserRoles(context.Context, model.ReadOnlyTransaction, string, *time.Time) ([]model.UserRole, error)
+	SetUserRole(context.Context, model.WriteOnlyTransaction, string, model.UserRole) error
+	DeleteUserRole(context.Context, model.WriteOnlyTransaction, string, string) error
+	DeleteLoginThrottle(context.Context, model.WriteOnlyTransaction, string, string) error
+	GetPasswordHistory(context.Context, model.ReadOnlyTransaction, string, int) ([]model.PasswordHistory, error)
+	AddPasswordHistory(context.Context, model.WriteOnlyTransaction, model.PasswordHistory) error
+}
+
+// AdminService implements user management for administrators.
+type AdminService struct {
+	db              model.Database
+	userRepo        AdminUserRepository
+	sessionRepo     SessionRepository
+	pushMessenger   PushMessenger
+	idgen           IdGenerator
+	passwordPolicy  model.PasswordPolicy
+	passwordHashing model.PasswordHashing
+	auditor         Auditor
+}
+
+func NewAdminService(db model.Database, userRepo AdminUserRepository, sessionRepo SessionRepository, pushMessenger PushMessenger, idgen IdGenerator) *AdminService {
+	return &AdminService{
+		db:              db,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		pushMessenger:   pushMessenger,
+		idgen:           idgen,
+		passwordPolicy:  model.DefaultPasswordPolicy,
+		passwordHashing: model.DefaultPasswordHashing,
+	}
+}
+
+// SetAuditor sets the auditor recording impersonations, password resets and changes to users.
+func (z *AdminService) SetAuditor(auditor Auditor) {
+	z.auditor = auditor
+}
+
+func (z *AdminService) ListUsers(ctx context.Context, req model.ListUsersRequest) model.ListUsersResponse {
+
+	logger := logging.New(ctx, componentAdminService, "ListUsers")
+
+	if req.Limit == 0 {
+		req.Limit = defaultUserPageSize
+	}
+	if req.Offset < 0 || req.Limit < 0 || req.Limit > maxUserPageSize {
+		return model.ListUsersResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid offset/limit",
+		}
+	}
+
+	filter := model.UserFilter{
+		Username: req.Username,
+		Disabled: req.Disabled,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	}
+
+	var users []model.User
+	var total int
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		us, t, errList := z.userRepo.ListUsers(ctx, tx, filter)
+		if errList != nil {
+			logger.Error().Err(errList).Msg("repo ListUsers")
+			return errList // 500
+		}
+		users = us
+		total = t
+		return nil
+	})
+
+	rsp := model.ListUsersResponse{}
+
+	if err != nil {
+		rsp.Code = http.StatusInternalServerError
+		rsp.Message = err.Error()
+		return rsp
+	}
+
+	rsp.Code = http.StatusOK
+	rsp.Total = total
+	rsp.Users = make([]model.UserInfo, 0, len(users))
+	for _, u := range users {
+		rsp.Users = append(rsp.Users, model.ToUserInfo(u))
+	}
+
+	return rsp
+
+}
+
+func (z *AdminService) CreateUser(ctx context.Context, req model.CreateUserRequest) model.CreateUserResponse {
+
+	logger := logging.New(ctx, componentAdminService, "CreateUser")
+
+	user := model.User{
+		ID:       z.idgen.NewID(),
+		Disabled: req.Disabled,
+		Username: req.Username,
+		Name:     req.Name,
+	}
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if !isValidUsername(req.Username) {
+			return model.NewValidationError("username not valid") // 400
+		}
+		if !isValidUsername(req.Name) {
+			return model.NewValidationError("name not valid") // 400
+		}
+		if err := z.passwordPolicy.Check(req.Password); err != nil {
+			return err // 400
+		}
+
+		ok, errCheckUsername := z.userRepo.IsUsernameAvailable(ctx, tx, req.Username)
+		if errCheckUsername != nil {
+			logger.Error().Err(errCheckUsername).Msg("repo IsUsernameAvailable")
+			return errCheckUsername // 500
+		}
+		if !ok {
+			return model.NewValidationError("username not available") // 400
+		}
+
+		if err := user.SetPasswordWith(z.passwordHashing, req.Password); err != nil {
+			logger.Error().Err(err).Msg("user SetPassword")
+			return err // 500
+		}
+		if err := z.userRepo.SetUser(ctx, tx, user); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+		if err := addPasswordHistory(ctx, tx, z.userRepo, user); err != nil {
+			return err // 500
+		}
+		role := model.UserRole{
+			ID:   model.RoleIDUser,
+			Name: model.RoleNameUser,
+		}
+		if err := z.userRepo.SetUserRole(ctx, tx, user.ID, role); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserRole")
+			return err // 500
+		}
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, user.ID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u != nil {
+			user = *u // pick up created, updated
+		}
+
+		return nil
+
+	})
+
+	rsp := model.CreateUserResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot create user")
+		rsp.Message = err.Error()
+		rsp.Rule = model.ValidationRule(err)
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", user.ID).Str("username", user.Username).Msg("user created")
+		info := model.ToUserInfo(user)
+		rsp.Code = http.StatusCreated
+		rsp.User = &info
+	}
+
+	return rsp
+
+}
+
+// SetUserDisabled disables or enables a user. Disabling a user also ends all of the user's sessions.
+func (z *AdminService) SetUserDisabled(ctx context.Context, req model.SetUserDisabledRequest) model.SetUserDisabledResponse {
+
+	logger := logging.New(ctx, componentAdminService, "SetUserDisabled")
+
+	var revoked []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if req.Disabled && model.TokenFromContext(ctx).ID == req.UserID {
+			return model.NewValidationError("cannot disable own user") // 400
+		}
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		u.Disabled = req.Disabled
+		if err := z.userRepo.SetUser(ctx, tx, *u); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+
+		if req.Disabled {
+			sessionIDs, errRevoke := revokeUserSessions(ctx, tx, z.sessionRepo, u.ID)
+			if errRevoke != nil {
+				logger.Error().Err(errRevoke).Msg("cannot revoke sessions")
+				return errRevoke // 500
+			}
+			revoked = sessionIDs
+		}
+
+		return nil
+
+	})
+
+	action := model.AuditEnableUser
+	if req.Disabled {
+		action = model.AuditDisableUser
+	}
+	audit(ctx, z.auditor, model.AuditEvent{Action: action, TargetID: req.UserID}, err)
+
+	rsp := model.SetUserDisabledResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot update user")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		disconnectSessions(z.pushMessenger, req.UserID, revoked)
+		logger.Info().Str("UserID", req.UserID).Bool("disabled", req.Disabled).Msg("user updated")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// UnlockUser lifts a lockout caused by failed logins and resets the failed login count of the user.
+func (z *AdminService) UnlockUser(ctx context.Context, req model.UnlockUserRequest) model.UnlockUserResponse {
+
+	logger := logging.New(ctx, componentAdminService, "UnlockUser")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		if err := z.userRepo.DeleteLoginThrottle(ctx, tx, model.ThrottleUsername, u.Username); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteLoginThrottle")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.UnlockUserResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot unlock user")
+		rsp.Message = err.Error()
+		if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Msg("user unlocked")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+func (z *AdminService) DeleteUser(ctx context.Context, req model.DeleteUserRequest) model.DeleteUserResponse {
+
+	logger := logging.New(ctx, componentAdminService, "DeleteUser")
+
+	var sessionIDs []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if model.TokenFromContext(ctx).ID == req.UserID {
+			return model.NewValidationError("cannot delete own user") // 400
+		}
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		now := time.Now()
+		sessions, errSessions := z.sessionRepo.GetUserSessions(ctx, tx, u.ID, &now)
+		if errSessions != nil {
+			logger.Error().Err(errSessions).Msg("repo GetUserSessions")
+			return errSessions // 500
+		}
+		for _, s := range sessions {
+			sessionIDs = append(sessionIDs, s.ID)
+		}
+
+		if err := z.userRepo.DeleteUser(ctx, tx, u.ID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteUser")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditDeleteUser, TargetID: req.UserID}, err)
+
+	rsp := model.DeleteUserResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot delete user")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		disconnectSessions(z.pushMessenger, req.UserID, sessionIDs)
+		logger.Info().Str("UserID", req.UserID).Msg("user deleted")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// ResetPassword sets a new password for a user and ends all of the user's sessions.
+func (z *AdminService) ResetPassword(ctx context.Context, req model.ResetPasswordRequest) model.ResetPasswordResponse {
+
+	logger := logging.New(ctx, componentAdminService, "ResetPassword")
+
+	var revoked []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+		if err := checkPassword(ctx, tx, z.userRepo, z.passwordPolicy, u.ID, req.Password); err != nil {
+			return err // 400, 500
+		}
+		if err := u.SetPasswordWith(z.passwordHashing, req.Password); err != nil {
+			logger.Error().Err(err).Msg("user SetPassword")
+			return err // 500
+		}
+		if err := z.userRepo.SetUser(ctx, tx, *u); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+		if err := addPasswordHistory(ctx, tx, z.userRepo, *u); err != nil {
+			return err // 500
+		}
+
+		sessionIDs, errRevoke := revokeUserSessions(ctx, tx, z.sessionRepo, u.ID)
+		if errRevoke != nil {
+			logger.Error().Err(errRevoke).Msg("cannot revoke sessions")
+			return errRevoke // 500
+		}
+		revoked = sessionIDs
+
+		return nil
+
+	})
+
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditResetPassword, TargetID: req.UserID}, err)
+
+	rsp := model.ResetPasswordResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("password NOT reset")
+		rsp.Message = err.Error()
+		rsp.Rule = model.ValidationRule(err)
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		disconnectSessions(z.pushMessenger, req.UserID, revoked)
+		logger.Info().Str("UserID", req.UserID).Msg("password reset")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
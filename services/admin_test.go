@@ -0,0 +1,680 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestListUsers(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	yes := true
+
+	users := []model.User{
+		{
+			ID:           "id1",
+			Username:     "alice",
+			Name:         "Alice",
+			PasswordHash: "hash",
+			Created:      now,
+			Updated:      now,
+		},
+		{
+			ID:           "id2",
+			Disabled:     true,
+			Username:     "bob",
+			Name:         "Bob",
+			PasswordHash: "hash",
+			Created:      now,
+			Updated:      now,
+		},
+	}
+
+	testCases := []struct {
+		Alias            string
+		OutputUsers      []model.User
+		OutputTotal      int
+		OutputListError  error
+		Request          model.ListUsersRequest
+		ExpectedFilter   model.UserFilter
+		ExpectedResponse model.ListUsersResponse
+	}{
+		{
+			Alias:          "success with default limit",
+			OutputUsers:    users,
+			OutputTotal:    12,
+			Request:        model.ListUsersRequest{Username: "b", Disabled: &yes},
+			ExpectedFilter: model.UserFilter{Username: "b", Disabled: &yes, Limit: 50},
+			ExpectedResponse: model.ListUsersResponse{
+				Code:  http.StatusOK,
+				Users: []model.UserInfo{model.ToUserInfo(users[0]), model.ToUserInfo(users[1])},
+				Total: 12,
+			},
+		},
+		{
+			Alias:          "empty",
+			OutputUsers:    []model.User{},
+			OutputTotal:    0,
+			Request:        model.ListUsersRequest{Offset: 10, Limit: 10},
+			ExpectedFilter: model.UserFilter{Offset: 10, Limit: 10},
+			ExpectedResponse: model.ListUsersResponse{
+				Code:  http.StatusOK,
+				Users: []model.UserInfo{},
+			},
+		},
+		{
+			Alias:   "limit too large",
+			Request: model.ListUsersRequest{Limit: 201},
+			ExpectedResponse: model.ListUsersResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid offset/limit",
+			},
+		},
+		{
+			Alias:   "negative offset",
+			Request: model.ListUsersRequest{Offset: -1},
+			ExpectedResponse: model.ListUsersResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid offset/limit",
+			},
+		},
+		{
+			Alias:           "list fails",
+			OutputListError: errors.New("just some error"),
+			Request:         model.ListUsersRequest{},
+			ExpectedFilter:  model.UserFilter{Limit: 50},
+			ExpectedResponse: model.ListUsersResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "just some error",
+			},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Users:     tCase.OutputUsers,
+				Total:     tCase.OutputTotal,
+				ListError: tCase.OutputListError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.ListUsers(context.Background(), tCase.Request)
+
+			if !reflect.DeepEqual(rsp, tCase.ExpectedResponse) {
+				t.Errorf("bad response %v, expected %v", rsp, tCase.ExpectedResponse)
+			}
+
+			if !reflect.DeepEqual(userRepo.ListFilter, tCase.ExpectedFilter) {
+				t.Errorf("bad filter %v, expected %v", userRepo.ListFilter, tCase.ExpectedFilter)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestCreateUser(b *testing.T) {
+
+	testCases := []struct {
+		Alias            string
+		OutputAvailable  bool
+		OutputSetError   error
+		Request          model.CreateUserRequest
+		ExpectedCode     int
+		ExpectedMessage  string
+		ExpectedUsername string
+	}{
+		{
+			Alias:           "success",
+			OutputAvailable: true,
+			Request: model.CreateUserRequest{
+				Username: "newuser",
+				Name:     "New User",
+				Password: "newpassword",
+			},
+			ExpectedCode:     http.StatusCreated,
+			ExpectedUsername: "newuser",
+		},
+		{
+			Alias:           "username not available",
+			OutputAvailable: false,
+			Request: model.CreateUserRequest{
+				Username: "newuser",
+				Name:     "New User",
+				Password: "newpassword",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not available",
+		},
+		{
+			Alias:           "username not valid",
+			OutputAvailable: true,
+			Request: model.CreateUserRequest{
+				Username: "nu",
+				Name:     "New User",
+				Password: "newpassword",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not valid",
+		},
+		{
+			Alias:           "name not valid",
+			OutputAvailable: true,
+			Request: model.CreateUserRequest{
+				Username: "newuser",
+				Name:     " ",
+				Password: "newpassword",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "name not valid",
+		},
+		{
+			Alias:           "password not valid",
+			OutputAvailable: true,
+			Request: model.CreateUserRequest{
+				Username: "newuser",
+				Name:     "New User",
+				Password: "short",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must have at least 8 characters",
+		},
+		{
+			Alias:           "set fails",
+			OutputAvailable: true,
+			OutputSetError:  errors.New("just some error"),
+			Request: model.CreateUserRequest{
+				Username: "newuser",
+				Name:     "New User",
+				Password: "newpassword",
+			},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Available: tCase.OutputAvailable,
+				SetError:  tCase.OutputSetError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "newid"})
+
+			rsp := adminService.CreateUser(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if tCase.ExpectedUsername == "" {
+				if rsp.User != nil {
+					t.Errorf("bad response user %v, expected nil", rsp.User)
+				}
+				return
+			}
+
+			if rsp.User == nil {
+				t.Fatal("nil response user, expected non-nil")
+			}
+			if got, want := rsp.User.ID, "newid"; got != want {
+				t.Errorf("bad user id %s, expected %s", got, want)
+			}
+			if got, want := rsp.User.Username, tCase.ExpectedUsername; got != want {
+				t.Errorf("bad username %s, expected %s", got, want)
+			}
+			if !userRepo.SavedUser.MatchPassword(tCase.Request.Password) {
+				t.Error("bad saved password")
+			}
+			if userRepo.GrantedRole == nil || userRepo.GrantedRole.ID != model.RoleIDUser {
+				t.Errorf("bad granted role %v, expected %s", userRepo.GrantedRole, model.RoleIDUser)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestSetUserDisabled(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias                string
+		OutputUser           *model.User
+		OutputSessions       []model.Session
+		Connected            map[string]bool
+		RequestSessionToken  model.SessionToken
+		Request              model.SetUserDisabledRequest
+		ExpectedCode         int
+		ExpectedMessage      string
+		ExpectedDisabled     bool
+		ExpectedRevoked      []string
+		ExpectedDisconnected []string
+	}{
+		{
+			Alias:      "disable",
+			OutputUser: &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputSessions: []model.Session{
+				{ID: "S1", UserID: "id"},
+				{ID: "S2", UserID: "id"},
+			},
+			Connected:            map[string]bool{"S2": true},
+			RequestSessionToken:  model.SessionToken{ID: "adminid"},
+			Request:              model.SetUserDisabledRequest{UserID: "id", Disabled: true},
+			ExpectedCode:         http.StatusOK,
+			ExpectedDisabled:     true,
+			ExpectedRevoked:      []string{"S1", "S2"},
+			ExpectedDisconnected: []string{"S1", "S2"},
+		},
+		{
+			Alias:               "enable",
+			OutputUser:          &model.User{ID: "id", Disabled: true, Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputSessions:      []model.Session{{ID: "S1", UserID: "id"}},
+			RequestSessionToken: model.SessionToken{ID: "adminid"},
+			Request:             model.SetUserDisabledRequest{UserID: "id", Disabled: false},
+			ExpectedCode:        http.StatusOK,
+			ExpectedDisabled:    false,
+		},
+		{
+			Alias:               "user not found",
+			OutputUser:          nil,
+			RequestSessionToken: model.SessionToken{ID: "adminid"},
+			Request:             model.SetUserDisabledRequest{UserID: "id", Disabled: true},
+			ExpectedCode:        http.StatusNotFound,
+			ExpectedMessage:     "user not found",
+		},
+		{
+			Alias:               "cannot disable self",
+			OutputUser:          &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			RequestSessionToken: model.SessionToken{ID: "id"},
+			Request:             model.SetUserDisabledRequest{UserID: "id", Disabled: true},
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "cannot disable own user",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User: tCase.OutputUser,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions: tCase.OutputSessions,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: tCase.Connected,
+			}
+			auditor := &AuditorMock{}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, sessionRepo, pushMessenger, &IdGeneratorMock{})
+			adminService.SetAuditor(auditor)
+
+			ctx := context.WithValue(context.Background(), model.UserKey, tCase.RequestSessionToken)
+			rsp := adminService.SetUserDisabled(ctx, tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			action := model.AuditEnableUser
+			if tCase.Request.Disabled {
+				action = model.AuditDisableUser
+			}
+			outcome := model.AuditSuccess
+			if rsp.Code != http.StatusOK {
+				outcome = model.AuditFailure
+			}
+			if got, want := len(auditor.Events), 1; got != want {
+				t.Fatalf("bad audit event count %d, expected %d", got, want)
+			}
+			if got, want := auditor.Events[0].Action, action; got != want {
+				t.Errorf("bad audit action %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].Outcome, outcome; got != want {
+				t.Errorf("bad audit outcome %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].TargetID, tCase.Request.UserID; got != want {
+				t.Errorf("bad audit target %s, expected %s", got, want)
+			}
+
+			if rsp.Code == http.StatusOK {
+				if got, want := userRepo.SavedUser.Disabled, tCase.ExpectedDisabled; got != want {
+					t.Errorf("bad disabled %t, expected %t", got, want)
+				}
+			}
+
+			if !reflect.DeepEqual(sessionRepo.RevokedIDs, tCase.ExpectedRevoked) {
+				t.Errorf("bad revoked sessions %v, expected %v", sessionRepo.RevokedIDs, tCase.ExpectedRevoked)
+			}
+
+			if !reflect.DeepEqual(pushMessenger.Disconnected, tCase.ExpectedDisconnected) {
+				t.Errorf("bad disconnected sessions %v, expected %v", pushMessenger.Disconnected, tCase.ExpectedDisconnected)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestDeleteUser(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias                string
+		OutputUser           *model.User
+		OutputDeleteError    error
+		OutputSessions       []model.Session
+		Connected            map[string]bool
+		RequestSessionToken  model.SessionToken
+		Request              model.DeleteUserRequest
+		ExpectedCode         int
+		ExpectedMessage      string
+		ExpectedDeleted      string
+		ExpectedDisconnected []string
+	}{
+		{
+			Alias:                "success",
+			OutputUser:           &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputSessions:       []model.Session{{ID: "S1", UserID: "id"}},
+			Connected:            map[string]bool{"S1": true},
+			RequestSessionToken:  model.SessionToken{ID: "adminid"},
+			Request:              model.DeleteUserRequest{UserID: "id"},
+			ExpectedCode:         http.StatusOK,
+			ExpectedDeleted:      "id",
+			ExpectedDisconnected: []string{"S1"},
+		},
+		{
+			Alias:               "user not found",
+			OutputUser:          nil,
+			RequestSessionToken: model.SessionToken{ID: "adminid"},
+			Request:             model.DeleteUserRequest{UserID: "id"},
+			ExpectedCode:        http.StatusNotFound,
+			ExpectedMessage:     "user not found",
+		},
+		{
+			Alias:               "cannot delete self",
+			OutputUser:          &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			RequestSessionToken: model.SessionToken{ID: "id"},
+			Request:             model.DeleteUserRequest{UserID: "id"},
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "cannot delete own user",
+		},
+		{
+			Alias:               "delete fails",
+			OutputUser:          &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputDeleteError:   errors.New("just some error"),
+			RequestSessionToken: model.SessionToken{ID: "adminid"},
+			Request:             model.DeleteUserRequest{UserID: "id"},
+			ExpectedCode:        http.StatusInternalServerError,
+			ExpectedMessage:     "just some error",
+			ExpectedDeleted:     "id",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:        tCase.OutputUser,
+				DeleteError: tCase.OutputDeleteError,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions: tCase.OutputSessions,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: tCase.Connected,
+			}
+			auditor := &AuditorMock{}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, sessionRepo, pushMessenger, &IdGeneratorMock{})
+			adminService.SetAuditor(auditor)
+
+			ctx := context.WithValue(context.Background(), model.UserKey, tCase.RequestSessionToken)
+			rsp := adminService.DeleteUser(ctx, tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			outcome := model.AuditSuccess
+			if rsp.Code != http.StatusOK {
+				outcome = model.AuditFailure
+			}
+			if got, want := len(auditor.Events), 1; got != want {
+				t.Fatalf("bad audit event count %d, expected %d", got, want)
+			}
+			if got, want := auditor.Events[0].Action, model.AuditDeleteUser; got != want {
+				t.Errorf("bad audit action %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].Outcome, outcome; got != want {
+				t.Errorf("bad audit outcome %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].TargetID, tCase.Request.UserID; got != want {
+				t.Errorf("bad audit target %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.DeletedUserID, tCase.ExpectedDeleted; got != want {
+				t.Errorf("bad deleted user %s, expected %s", got, want)
+			}
+
+			if !reflect.DeepEqual(pushMessenger.Disconnected, tCase.ExpectedDisconnected) {
+				t.Errorf("bad disconnected sessions %v, expected %v", pushMessenger.Disconnected, tCase.ExpectedDisconnected)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestResetPassword(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias           string
+		OutputUser      *model.User
+		OutputSetError  error
+		OutputSessions  []model.Session
+		Request         model.ResetPasswordRequest
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedRevoked []string
+	}{
+		{
+			Alias:           "success",
+			OutputUser:      &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputSessions:  []model.Session{{ID: "S1", UserID: "id"}},
+			Request:         model.ResetPasswordRequest{UserID: "id", Password: "newpassword"},
+			ExpectedCode:    http.StatusOK,
+			ExpectedRevoked: []string{"S1"},
+		},
+		{
+			Alias:           "user not found",
+			OutputUser:      nil,
+			Request:         model.ResetPasswordRequest{UserID: "id", Password: "newpassword"},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "user not found",
+		},
+		{
+			Alias:           "invalid password",
+			OutputUser:      &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			Request:         model.ResetPasswordRequest{UserID: "id", Password: "short"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must have at least 8 characters",
+		},
+		{
+			Alias:           "set fails",
+			OutputUser:      &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputSetError:  errors.New("just some error"),
+			Request:         model.ResetPasswordRequest{UserID: "id", Password: "newpassword"},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:     tCase.OutputUser,
+				SetError: tCase.OutputSetError,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions: tCase.OutputSessions,
+			}
+			auditor := &AuditorMock{}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, sessionRepo, &PushMessengerMock{}, &IdGeneratorMock{})
+			adminService.SetAuditor(auditor)
+
+			rsp := adminService.ResetPassword(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			outcome := model.AuditSuccess
+			if rsp.Code != http.StatusOK {
+				outcome = model.AuditFailure
+			}
+			if got, want := len(auditor.Events), 1; got != want {
+				t.Fatalf("bad audit event count %d, expected %d", got, want)
+			}
+			if got, want := auditor.Events[0].Action, model.AuditResetPassword; got != want {
+				t.Errorf("bad audit action %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].Outcome, outcome; got != want {
+				t.Errorf("bad audit outcome %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].TargetID, tCase.Request.UserID; got != want {
+				t.Errorf("bad audit target %s, expected %s", got, want)
+			}
+
+			if rsp.Code == http.StatusOK && !userRepo.SavedUser.MatchPassword(tCase.Request.Password) {
+				t.Error("bad saved password")
+			}
+
+			if !reflect.DeepEqual(sessionRepo.RevokedIDs, tCase.ExpectedRevoked) {
+				t.Errorf("bad revoked sessions %v, expected %v", sessionRepo.RevokedIDs, tCase.ExpectedRevoked)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestUnlockUser(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias             string
+		OutputUser        *model.User
+		OutputDeleteError error
+		Request           model.UnlockUserRequest
+		ExpectedCode      int
+		ExpectedMessage   string
+		ExpectedDeleted   []string
+	}{
+		{
+			Alias:           "success",
+			OutputUser:      &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			Request:         model.UnlockUserRequest{UserID: "id"},
+			ExpectedCode:    http.StatusOK,
+			ExpectedDeleted: []string{"username:demouser"},
+		},
+		{
+			Alias:           "user not found",
+			OutputUser:      nil,
+			Request:         model.UnlockUserRequest{UserID: "id"},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "user not found",
+		},
+		{
+			Alias:             "delete fails",
+			OutputUser:        &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now},
+			OutputDeleteError: errors.New("just some error"),
+			Request:           model.UnlockUserRequest{UserID: "id"},
+			ExpectedCode:      http.StatusInternalServerError,
+			ExpectedMessage:   "just some error",
+			ExpectedDeleted:   []string{"username:demouser"},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:        tCase.OutputUser,
+				DeleteError: tCase.OutputDeleteError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.UnlockUser(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if !reflect.DeepEqual(userRepo.DeletedThrottles, tCase.ExpectedDeleted) {
+				t.Errorf("bad deleted throttles %v, expected %v", userRepo.DeletedThrottles, tCase.ExpectedDeleted)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	componentAPITokenService = "APITokenService"
+	maxAPITokenNameLength    = 64
+)
+
+type APITokenRepository interface {
+	GetAPIToken(context.Context, model.ReadOnlyTransaction, string) (*model.APIToken, error)
+	GetUserAPITokens(context.Context, model.ReadOnlyTransaction, string) ([]model.APIToken, error)
+	SetAPIToken(context.Context, model.WriteOnlyTransaction, model.APIToken) error
+	RevokeAPIToken(context.Context, model.WriteOnlyTransaction, string, string, time.Time) (bool, error)
+}
+
+type APITokenService struct {
+	db        model.Database
+	tokenRepo APITokenRepository
+	userRepo  SessionUserRepository
+	idgen     IdGenerator
+}
+
+func NewAPITokenService(db model.Database, tokenRepo APITokenRepository, userRepo SessionUserRepository, idgen IdGenerator) *APITokenService {
+	return &APITokenService{
+		db:        db,
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+		idgen:     idgen,
+	}
+}
+
+// CreateAPIToken creates a named token limited to roles the user currently has.
+// The token is returned only once.
+func (z *APITokenService) CreateAPIToken(ctx context.Context, req model.CreateAPITokenRequest) model.CreateAPITokenResponse {
+
+	logger := logging.New(ctx, componentAPITokenService, "CreateAPIToken")
+
+	var token string
+	var apiToken *model.APIToken
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		name := strings.TrimSpace(req.Name)
+		if len(name) == 0 || len(name) > maxAPITokenNameLength {
+			return model.NewValidationError("name not valid") // 400
+		}
+		lifetime := time.Duration(req.ExpiresInDays) * time.Hour * 24
+		if lifetime <= 0 || lifetime > model.APITokenMaxLifetime {
+			return model.NewValidationError("expiry not valid") // 400
+		}
+		if len(req.Scopes) == 0 {
+			return model.NewValidationError("scopes required") // 400
+		}
+
+		u, errUser := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errUser != nil {
+			logger.Error().Err(errUser).Msg("repo GetUser")
+			return errUser // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		now := time.Now().Truncate(time.Second)
+		roles, errRoles := z.userRepo.GetUserRoles(ctx, tx, u.ID, &now)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles // 500
+		}
+		granted := make(map[string]bool)
+		for _, role := range roles {
+			granted[role.Name] = true
+		}
+		var scopes []string
+		seen := make(map[string]bool)
+		for _, scope := range req.Scopes {
+			if !granted[scope] {
+				return model.NewValidationError(fmt.Sprintf("scope not granted: %s", scope)) // 400
+			}
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+
+		t, errToken := newToken()
+		if errToken != nil {
+			logger.Error().Err(errToken).Msg("cannot create token")
+			return errToken // 500
+		}
+		t = model.APITokenPrefix + t
+
+		a := model.APIToken{
+			ID:        z.idgen.NewID(),
+			UserID:    u.ID,
+			Name:      name,
+			TokenHash: model.HashToken(t),
+			Scopes:    scopes,
+			Created:   now,
+			Expires:   now.Add(lifetime),
+		}
+		if err := z.tokenRepo.SetAPIToken(ctx, tx, a); err != nil {
+			logger.Error().Err(err).Msg("repo SetAPIToken")
+			return err // 500
+		}
+
+		token = t
+		apiToken = &a
+		return nil
+
+	})
+
+	rsp := model.CreateAPITokenResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot create API token")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Str("APITokenID", apiToken.ID).Strs("scopes", apiToken.Scopes).Msg("API token created")
+		rsp.Code = http.StatusCreated
+		rsp.Token = token
+		rsp.APIToken = apiToken
+	}
+
+	return rsp
+
+}
+
+// ListAPITokens returns all API tokens of the user, including expired and revoked ones.
+func (z *APITokenService) ListAPITokens(ctx context.Context, req model.ListAPITokensRequest) model.ListAPITokensResponse {
+
+	logger := logging.New(ctx, componentAPITokenService, "ListAPITokens")
+
+	var apiTokens []model.APIToken
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		a, errGet := z.tokenRepo.GetUserAPITokens(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUserAPITokens")
+			return errGet // 500
+		}
+		apiTokens = a
+		return nil
+	})
+
+	if err != nil {
+		return model.ListAPITokensResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+	}
+
+	return model.ListAPITokensResponse{
+		Code:      http.StatusOK,
+		APITokens: apiTokens,
+	}
+
+}
+
+// RevokeAPIToken revokes an API token of the user so that it is no longer accepted.
+func (z *APITokenService) RevokeAPIToken(ctx context.Context, req model.RevokeAPITokenRequest) model.RevokeAPITokenResponse {
+
+	logger := logging.New(ctx, componentAPITokenService, "RevokeAPIToken")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		ok, errRevoke := z.tokenRepo.RevokeAPIToken(ctx, tx, req.UserID, req.APITokenID, time.Now())
+		if errRevoke != nil {
+			logger.Error().Err(errRevoke).Msg("repo RevokeAPIToken")
+			return errRevoke // 500
+		}
+		if !ok {
+			return model.NewNotFoundError("API token not found") // 404
+		}
+		return nil
+	})
+
+	rsp := model.RevokeAPITokenResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot revoke API token")
+		rsp.Message = err.Error()
+		if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Str("APITokenID", req.APITokenID).Msg("API token revoked")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// ValidateAPIToken returns the session token of an active API token of an enabled user, nil otherwise.
+// The roles of the session token are the current roles of the user limited to the scopes of the API token.
+// The last-used time of the token is updated at most once per sessionTouchInterval.
+func (z *APITokenService) ValidateAPIToken(ctx context.Context, token string) (*model.SessionToken, error) {
+
+	logger := logging.New(ctx, componentAPITokenService, "ValidateAPIToken")
+
+	if !model.IsAPIToken(token) {
+		return nil, nil
+	}
+
+	var sessionToken *model.SessionToken
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		apiToken, errGet := z.tokenRepo.GetAPIToken(ctx, tx, model.HashToken(token))
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetAPIToken")
+			return errGet
+		}
+
+		now := time.Now().Truncate(time.Second)
+		if !apiToken.IsActive(now) {
+			return nil
+		}
+
+		u, errUser := z.userRepo.GetUser(ctx, tx, apiToken.UserID)
+		if errUser != nil {
+			logger.Error().Err(errUser).Msg("repo GetUser")
+			return errUser
+		}
+		if u == nil || u.Disabled {
+			return nil
+		}
+
+		roles, errRoles := z.userRepo.GetUserRoles(ctx, tx, u.ID, &now)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles
+		}
+
+		if apiToken.LastUsed == nil || now.Sub(*apiToken.LastUsed) >= sessionTouchInterval {
+			apiToken.LastUsed = &now
+			if err := z.tokenRepo.SetAPIToken(ctx, tx, *apiToken); err != nil {
+				logger.Error().Err(err).Msg("repo SetAPIToken")
+				return err
+			}
+		}
+
+		sessionToken = model.ToSessionToken("", u, apiToken.ScopedRoles(roles), apiToken.Created, apiToken.Expires)
+		sessionToken.APITokenID = apiToken.ID
+
+		return nil
+
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionToken == nil {
+		logger.Debug().Msg("API token invalid")
+	}
+
+	return sessionToken, nil
+
+}
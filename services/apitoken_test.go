@@ -0,0 +1,358 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestCreateAPIToken(b *testing.T) {
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+	}
+	roles := []model.UserRole{{ID: model.RoleIDUser, Name: model.RoleNameUser}, {ID: model.RoleIDAdmin, Name: model.RoleNameAdmin}}
+
+	testCases := []struct {
+		Alias          string
+		Request        model.CreateAPITokenRequest
+		OutputUser     *model.User
+		OutputSetError error
+		ExpectedCode   int
+		ExpectedMsg    string
+		ExpectedScopes []string
+	}{
+		{
+			Alias:          "success",
+			Request:        model.CreateAPITokenRequest{UserID: "id", Name: " backup ", Scopes: []string{model.RoleNameUser, model.RoleNameUser}, ExpiresInDays: 30},
+			OutputUser:     demouser,
+			ExpectedCode:   http.StatusCreated,
+			ExpectedScopes: []string{model.RoleNameUser},
+		},
+		{
+			Alias:        "no name",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: " ", Scopes: []string{model.RoleNameUser}, ExpiresInDays: 30},
+			OutputUser:   demouser,
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "name not valid",
+		},
+		{
+			Alias:        "no expiry",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{model.RoleNameUser}},
+			OutputUser:   demouser,
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "expiry not valid",
+		},
+		{
+			Alias:        "expiry too long",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{model.RoleNameUser}, ExpiresInDays: 366},
+			OutputUser:   demouser,
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "expiry not valid",
+		},
+		{
+			Alias:        "no scopes",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: "backup", ExpiresInDays: 30},
+			OutputUser:   demouser,
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "scopes required",
+		},
+		{
+			Alias:        "scope not granted",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{"superuser"}, ExpiresInDays: 30},
+			OutputUser:   demouser,
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "scope not granted: superuser",
+		},
+		{
+			Alias:        "user not found",
+			Request:      model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{model.RoleNameUser}, ExpiresInDays: 30},
+			ExpectedCode: http.StatusNotFound,
+			ExpectedMsg:  "user not found",
+		},
+		{
+			Alias:          "save fails",
+			Request:        model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{model.RoleNameUser}, ExpiresInDays: 30},
+			OutputUser:     demouser,
+			OutputSetError: errors.New("just some error"),
+			ExpectedCode:   http.StatusInternalServerError,
+			ExpectedMsg:    "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			tokenRepo := &APITokenRepositoryMock{
+				SetError: tCase.OutputSetError,
+			}
+			userRepo := &UserRepositoryMock{
+				User:  tCase.OutputUser,
+				Roles: roles,
+			}
+			apiTokenService := services.NewAPITokenService(&DatabaseMock{}, tokenRepo, userRepo, &IdGeneratorMock{ID: "T123"})
+
+			rsp := apiTokenService.CreateAPIToken(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if tCase.ExpectedCode != http.StatusCreated {
+				if len(rsp.Token) != 0 || rsp.APIToken != nil {
+					t.Errorf("bad token %s, expected none", rsp.Token)
+				}
+				return
+			}
+
+			if !strings.HasPrefix(rsp.Token, model.APITokenPrefix) {
+				t.Errorf("bad token %s, expected prefix %s", rsp.Token, model.APITokenPrefix)
+			}
+			saved := tokenRepo.SavedAPIToken
+			if saved == nil {
+				t.Fatal("API token not saved")
+			}
+			if got, want := saved.TokenHash, model.HashToken(rsp.Token); got != want {
+				t.Errorf("bad token hash %s, expected %s", got, want)
+			}
+			if got, want := saved.ID, "T123"; got != want {
+				t.Errorf("bad ID %s, expected %s", got, want)
+			}
+			if got, want := saved.Name, "backup"; got != want {
+				t.Errorf("bad name %s, expected %s", got, want)
+			}
+			if got, want := saved.Scopes, tCase.ExpectedScopes; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad scopes %v, expected %v", got, want)
+			}
+			if got, want := saved.Expires.Sub(saved.Created), time.Duration(tCase.Request.ExpiresInDays)*time.Hour*24; got != want {
+				t.Errorf("bad lifetime %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRevokeAPIToken(b *testing.T) {
+
+	testCases := []struct {
+		Alias             string
+		OutputRevoked     bool
+		OutputRevokeError error
+		ExpectedCode      int
+		ExpectedMsg       string
+	}{
+		{
+			Alias:         "success",
+			OutputRevoked: true,
+			ExpectedCode:  http.StatusOK,
+		},
+		{
+			Alias:        "not found",
+			ExpectedCode: http.StatusNotFound,
+			ExpectedMsg:  "API token not found",
+		},
+		{
+			Alias:             "revoke fails",
+			OutputRevokeError: errors.New("just some error"),
+			ExpectedCode:      http.StatusInternalServerError,
+			ExpectedMsg:       "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			tokenRepo := &APITokenRepositoryMock{
+				Revoked:     tCase.OutputRevoked,
+				RevokeError: tCase.OutputRevokeError,
+			}
+			apiTokenService := services.NewAPITokenService(&DatabaseMock{}, tokenRepo, &UserRepositoryMock{}, &IdGeneratorMock{})
+
+			rsp := apiTokenService.RevokeAPIToken(context.Background(), model.RevokeAPITokenRequest{UserID: "id", APITokenID: "T123"})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := tokenRepo.RevokedID, "T123"; got != want {
+				t.Errorf("bad revoked ID %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestValidateAPIToken(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	revoked := now.Add(-time.Hour)
+	lastUsed := now
+
+	const token = model.APITokenPrefix + "secret"
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+	}
+	disabled := *demouser
+	disabled.Disabled = true
+
+	roles := []model.UserRole{{ID: model.RoleIDUser, Name: model.RoleNameUser}, {ID: model.RoleIDAdmin, Name: model.RoleNameAdmin}}
+
+	active := model.APIToken{
+		ID:        "T123",
+		UserID:    "id",
+		Name:      "backup",
+		TokenHash: model.HashToken(token),
+		Scopes:    []string{model.RoleNameUser, "superuser"},
+		Created:   now.Add(-time.Hour),
+		Expires:   now.Add(time.Hour),
+	}
+	used := active
+	used.LastUsed = &lastUsed
+	expired := active
+	expired.Expires = now.Add(-time.Minute)
+	revokedToken := active
+	revokedToken.Revoked = &revoked
+
+	testCases := []struct {
+		Alias            string
+		Token            string
+		OutputAPIToken   *model.APIToken
+		OutputUser       *model.User
+		OutputGetError   error
+		ExpectedValid    bool
+		ExpectedRoles    []string
+		ExpectedError    error
+		ExpectedSetCount int
+	}{
+		{
+			Alias:            "success with last used update",
+			Token:            token,
+			OutputAPIToken:   &active,
+			OutputUser:       demouser,
+			ExpectedValid:    true,
+			ExpectedRoles:    []string{model.RoleNameUser},
+			ExpectedSetCount: 1,
+		},
+		{
+			Alias:          "success recently used",
+			Token:          token,
+			OutputAPIToken: &used,
+			OutputUser:     demouser,
+			ExpectedValid:  true,
+			ExpectedRoles:  []string{model.RoleNameUser},
+		},
+		{
+			Alias:          "unknown token",
+			Token:          model.APITokenPrefix + "other",
+			OutputAPIToken: &active,
+			OutputUser:     demouser,
+		},
+		{
+			Alias:          "not an API token",
+			Token:          "secret",
+			OutputAPIToken: &active,
+			OutputUser:     demouser,
+		},
+		{
+			Alias:          "expired",
+			Token:          token,
+			OutputAPIToken: &expired,
+			OutputUser:     demouser,
+		},
+		{
+			Alias:          "revoked",
+			Token:          token,
+			OutputAPIToken: &revokedToken,
+			OutputUser:     demouser,
+		},
+		{
+			Alias:          "user disabled",
+			Token:          token,
+			OutputAPIToken: &active,
+			OutputUser:     &disabled,
+		},
+		{
+			Alias:          "get fails",
+			Token:          token,
+			OutputGetError: errors.New("just some error"),
+			ExpectedError:  errors.New("just some error"),
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			tokenRepo := &APITokenRepositoryMock{
+				APIToken: tCase.OutputAPIToken,
+				GetError: tCase.OutputGetError,
+			}
+			userRepo := &UserRepositoryMock{
+				User:  tCase.OutputUser,
+				Roles: roles,
+			}
+			apiTokenService := services.NewAPITokenService(&DatabaseMock{}, tokenRepo, userRepo, &IdGeneratorMock{})
+
+			sessionToken, err := apiTokenService.ValidateAPIToken(context.Background(), tCase.Token)
+
+			if err == nil && tCase.ExpectedError != nil {
+				t.Errorf("nil error, expected %s", tCase.ExpectedError)
+			} else if err != nil && tCase.ExpectedError == nil {
+				t.Errorf("bad error %s, expected nil", err)
+			} else if err != nil && err.Error() != tCase.ExpectedError.Error() {
+				t.Errorf("bad error %s, expected %s", err, tCase.ExpectedError)
+			}
+
+			if got, want := sessionToken != nil, tCase.ExpectedValid; got != want {
+				t.Fatalf("bad session token %v, expected %t", sessionToken, want)
+			}
+			if got, want := tokenRepo.SetCount, tCase.ExpectedSetCount; got != want {
+				t.Errorf("bad set count %d, expected %d", got, want)
+			}
+			if !tCase.ExpectedValid {
+				return
+			}
+
+			if got, want := sessionToken.APITokenID, "T123"; got != want {
+				t.Errorf("bad API token ID %s, expected %s", got, want)
+			}
+			if got, want := sessionToken.SessionID, ""; got != want {
+				t.Errorf("bad session ID %s, expected none", got)
+			}
+			if got, want := sessionToken.Roles, tCase.ExpectedRoles; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad roles %v, expected %v", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	componentAuditService = "AuditService"
+	defaultAuditPageSize  = 50
+	maxAuditPageSize      = 500
+	maxAuditMessage       = 256
+)
+
+// Auditor records security relevant actions.
+type Auditor interface {
+	Audit(ctx context.Context, event model.AuditEvent)
+}
+
+type AuditRepository interface {
+	AddAuditEvent(context.Context, model.WriteOnlyTransaction, model.AuditEvent) error
+	ListAuditEvents(context.Context, model.ReadOnlyTransaction, model.AuditFilter) ([]model.AuditEvent, int, error)
+}
+
+// TopicPublisher sends push messages to the sessions subscribed to a topic.
+type TopicPublisher interface {
+	PublishToTopic(topic string, msg model.PushMessage) int
+}
+
+type AuditService struct {
+	db        model.Database
+	auditRepo AuditRepository
+	idgen     IdGenerator
+	publisher TopicPublisher
+}
+
+func NewAuditService(db model.Database, auditRepo AuditRepository, idgen IdGenerator) *AuditService {
+	return &AuditService{
+		db:        db,
+		auditRepo: auditRepo,
+		idgen:     idgen,
+	}
+}
+
+// SetPublisher publishes the events recorded to model.TopicAudit.
+func (z *AuditService) SetPublisher(publisher TopicPublisher) {
+	z.publisher = publisher
+}
+
+// Audit saves the event in its own transaction, so that failures are recorded even if the action is rolled back.
+// The correlation ID, client and actor are taken from the context unless given,
+// the actor of an impersonated session is the impersonator.
+// Errors are logged, not returned, an action does not fail because it cannot be audited.
+func (z *AuditService) Audit(ctx context.Context, event model.AuditEvent) {
+
+	logger := logging.New(ctx, componentAuditService, "Audit")
+
+	event.ID = z.idgen.NewID()
+	event.Created = time.Now()
+	event.CorrelationID = model.CorrelationIDFromContext(ctx)
+	event.Message = truncate(event.Message, maxAuditMessage)
+
+	client := model.ClientFromContext(ctx)
+	if len(event.IPAddress) == 0 {
+		event.IPAddress = client.IPAddress
+	}
+	if len(event.UserAgent) == 0 {
+		event.UserAgent = client.UserAgent
+	}
+	event.UserAgent = truncate(event.UserAgent, maxUserAgentLength)
+
+	if len(event.ActorID) == 0 {
+		if user := model.TokenFromContext(ctx); len(user.Impersonator) != 0 {
+			event.ActorID = user.Impersonator
+		} else if len(user.ID) != 0 {
+			event.ActorID = user.ID
+			event.ActorName = user.Username
+		}
+	}
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		return z.auditRepo.AddAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("action", event.Action).Str("outcome", event.Outcome).Msg("cannot save audit event")
+		return
+	}
+
+	if z.publisher != nil {
+		data, errData := json.Marshal(event)
+		if errData != nil {
+			logger.Error().Err(errData).Msg("cannot marshal audit event")
+			return
+		}
+		z.publisher.PublishToTopic(model.TopicAudit, model.PushMessage{
+			Type: model.PushTypeAudit,
+			Data: string(data),
+		})
+	}
+
+}
+
+func (z *AuditService) ListAuditEvents(ctx context.Context, req model.ListAuditEventsRequest) model.ListAuditEventsResponse {
+
+	logger := logging.New(ctx, componentAuditService, "ListAuditEvents")
+
+	filter := req.Filter
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Offset < 0 || filter.Limit < 0 || filter.Limit > maxAuditPageSize {
+		return model.ListAuditEventsResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid offset/limit",
+		}
+	}
+
+	var events []model.AuditEvent
+	var total int
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		e, t, errList := z.auditRepo.ListAuditEvents(ctx, tx, filter)
+		if errList != nil {
+			logger.Error().Err(errList).Msg("repo ListAuditEvents")
+			return errList // 500
+		}
+		events = e
+		total = t
+		return nil
+	})
+
+	rsp := model.ListAuditEventsResponse{}
+
+	if err != nil {
+		rsp.Code = http.StatusInternalServerError
+		rsp.Message = err.Error()
+	} else {
+		rsp.Code = http.StatusOK
+		rsp.Events = events
+		rsp.Total = total
+	}
+
+	return rsp
+
+}
+
+// ExportAuditEvents passes all events matching the filter to fn, most recent first, ignoring the paging of the filter.
+// Events are read in pages, each in its own transaction, continuing after the last event of the previous page.
+func (z *AuditService) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, fn func(model.AuditEvent) error) error {
+
+	logger := logging.New(ctx, componentAuditService, "ExportAuditEvents")
+
+	filter.BeforeCreated = nil
+	filter.BeforeID = ""
+	filter.Offset = 0
+	filter.Limit = maxAuditPageSize
+
+	for {
+
+		var events []model.AuditEvent
+		err := z.db.Run(func(tx model.Transaction) error {
+			e, _, errList := z.auditRepo.ListAuditEvents(ctx, tx, filter)
+			if errList != nil {
+				logger.Error().Err(errList).Msg("repo ListAuditEvents")
+				return errList
+			}
+			events = e
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < filter.Limit {
+			return nil
+		}
+		last := events[len(events)-1]
+		filter.BeforeCreated = &last.Created
+		filter.BeforeID = last.ID
+
+	}
+
+}
+
+// audit passes the event to the auditor, if set, with the outcome given by err.
+func audit(ctx context.Context, auditor Auditor, event model.AuditEvent, err error) {
+	if auditor == nil {
+		return
+	}
+	if err != nil {
+		event.Outcome = model.AuditFailure
+		event.Message = err.Error()
+	} else {
+		event.Outcome = model.AuditSuccess
+	}
+	auditor.Audit(ctx, event)
+}
@@ -0,0 +1,332 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestAudit(t *testing.T) {
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, model.CorrelationIDKey, "C123")
+	ctx = context.WithValue(ctx, model.ClientKey, model.Client{IPAddress: "192.0.2.1", UserAgent: "test-agent"})
+	ctx = context.WithValue(ctx, model.UserKey, model.SessionToken{ID: "id", Username: "demouser"})
+
+	auditRepo := &AuditRepositoryMock{
+		AddError: errors.New("just some error"), // logged only
+	}
+	auditService := services.NewAuditService(&DatabaseMock{}, auditRepo, &IdGeneratorMock{ID: "A123"})
+
+	auditService.Audit(ctx, model.AuditEvent{Action: model.AuditChangeProfile, Outcome: model.AuditSuccess, TargetID: "id"})
+	auditService.Audit(ctx, model.AuditEvent{Action: model.AuditLogin, Outcome: model.AuditFailure, ActorID: "other", IPAddress: "192.0.2.2"})
+	auditService.Audit(ctx, model.AuditEvent{Action: model.AuditLogin, Outcome: model.AuditFailure, UserAgent: strings.Repeat("a", 255) + "é"})
+
+	if got, want := len(auditRepo.SavedEvents), 3; got != want {
+		t.Fatalf("bad event count %d, expected %d", got, want)
+	}
+
+	event := auditRepo.SavedEvents[0]
+	if event.Created.IsZero() {
+		t.Error("bad created time, expected non-zero")
+	}
+	event.Created = model.AuditEvent{}.Created
+	expected := model.AuditEvent{
+		ID:            "A123",
+		Action:        model.AuditChangeProfile,
+		Outcome:       model.AuditSuccess,
+		ActorID:       "id",
+		ActorName:     "demouser",
+		TargetID:      "id",
+		IPAddress:     "192.0.2.1",
+		UserAgent:     "test-agent",
+		CorrelationID: "C123",
+	}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("bad event %v, expected %v", event, expected)
+	}
+
+	// given values are kept
+	event = auditRepo.SavedEvents[1]
+	if got, want := event.ActorID, "other"; got != want {
+		t.Errorf("bad actor %s, expected %s", got, want)
+	}
+	if got, want := event.ActorName, ""; got != want {
+		t.Errorf("bad actor name %s, expected none", got)
+	}
+	if got, want := event.IPAddress, "192.0.2.2"; got != want {
+		t.Errorf("bad IP address %s, expected %s", got, want)
+	}
+
+	// long values are cut at a rune boundary
+	event = auditRepo.SavedEvents[2]
+	if got, want := event.UserAgent, strings.Repeat("a", 255); got != want {
+		t.Errorf("bad user agent %q, expected %q", got, want)
+	}
+
+}
+
+func TestAuditPublish(t *testing.T) {
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, model.CorrelationIDKey, "C123")
+
+	auditRepo := &AuditRepositoryMock{}
+	publisher := &PushMessengerMock{}
+	auditService := services.NewAuditService(&DatabaseMock{}, auditRepo, &IdGeneratorMock{ID: "A123"})
+	auditService.SetPublisher(publisher)
+
+	auditService.Audit(ctx, model.AuditEvent{Action: model.AuditLogin, Outcome: model.AuditSuccess, ActorID: "id"})
+
+	if got, want := publisher.SentTopic, model.TopicAudit; got != want {
+		t.Errorf("bad topic %s, expected %s", got, want)
+	}
+	if got, want := len(publisher.Sent), 1; got != want {
+		t.Fatalf("bad message count %d, expected %d", got, want)
+	}
+	msg := publisher.Sent[0]
+	if got, want := msg.Type, model.PushTypeAudit; got != want {
+		t.Errorf("bad message type %s, expected %s", got, want)
+	}
+	var event model.AuditEvent
+	if err := json.Unmarshal([]byte(msg.Data), &event); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := event.ID, "A123"; got != want {
+		t.Errorf("bad event id %s, expected %s", got, want)
+	}
+
+	// events not saved are not published
+	auditRepo.AddError = errors.New("just some error")
+	auditService.Audit(ctx, model.AuditEvent{Action: model.AuditLogin, Outcome: model.AuditFailure, ActorID: "id"})
+	if got, want := len(publisher.Sent), 1; got != want {
+		t.Errorf("bad message count %d, expected %d", got, want)
+	}
+
+}
+
+func TestListAuditEvents(b *testing.T) {
+
+	events := []model.AuditEvent{{ID: "A1"}, {ID: "A2"}}
+
+	testCases := []struct {
+		Alias           string
+		Filter          model.AuditFilter
+		OutputListError error
+		ExpectedCode    int
+		ExpectedMsg     string
+		ExpectedLimit   int
+		ExpectedEvents  int
+	}{
+		{
+			Alias:          "success with default limit",
+			Filter:         model.AuditFilter{Action: model.AuditLogin},
+			ExpectedCode:   http.StatusOK,
+			ExpectedLimit:  50,
+			ExpectedEvents: 2,
+		},
+		{
+			Alias:          "success with offset",
+			Filter:         model.AuditFilter{Offset: 1, Limit: 10},
+			ExpectedCode:   http.StatusOK,
+			ExpectedLimit:  10,
+			ExpectedEvents: 1,
+		},
+		{
+			Alias:        "negative offset",
+			Filter:       model.AuditFilter{Offset: -1},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid offset/limit",
+		},
+		{
+			Alias:        "limit too large",
+			Filter:       model.AuditFilter{Limit: 501},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid offset/limit",
+		},
+		{
+			Alias:           "list fails",
+			OutputListError: errors.New("just some error"),
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMsg:     "just some error",
+			ExpectedLimit:   50,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			auditRepo := &AuditRepositoryMock{
+				Events:    events,
+				ListError: tCase.OutputListError,
+			}
+			auditService := services.NewAuditService(&DatabaseMock{}, auditRepo, &IdGeneratorMock{})
+
+			rsp := auditService.ListAuditEvents(context.Background(), model.ListAuditEventsRequest{Filter: tCase.Filter})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := len(rsp.Events), tCase.ExpectedEvents; got != want {
+				t.Errorf("bad event count %d, expected %d", got, want)
+			}
+			if tCase.ExpectedLimit == 0 {
+				if len(auditRepo.Filters) != 0 {
+					t.Error("bad list, expected none")
+				}
+				return
+			}
+			if got, want := auditRepo.Filters[0].Limit, tCase.ExpectedLimit; got != want {
+				t.Errorf("bad limit %d, expected %d", got, want)
+			}
+			if tCase.ExpectedCode == http.StatusOK {
+				if got, want := rsp.Total, len(events); got != want {
+					t.Errorf("bad total %d, expected %d", got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestExportAuditEvents(t *testing.T) {
+
+	events := make([]model.AuditEvent, 0)
+	for i := 0; i < 1200; i++ {
+		events = append(events, model.AuditEvent{ID: strconv.Itoa(i)})
+	}
+
+	auditRepo := &AuditRepositoryMock{
+		Events: events,
+	}
+	auditService := services.NewAuditService(&DatabaseMock{}, auditRepo, &IdGeneratorMock{})
+
+	var exported []model.AuditEvent
+	err := auditService.ExportAuditEvents(context.Background(), model.AuditFilter{Offset: 5, Limit: 1}, func(event model.AuditEvent) error {
+		exported = append(exported, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("bad error %s, expected nil", err)
+	}
+	if !reflect.DeepEqual(exported, events) {
+		t.Errorf("bad export of %d events, expected %d", len(exported), len(events))
+	}
+	if got, want := len(auditRepo.Filters), 3; got != want {
+		t.Errorf("bad page count %d, expected %d", got, want)
+	}
+	for i, filter := range auditRepo.Filters[1:] {
+		last := events[(i+1)*500-1]
+		if filter.Offset != 0 || filter.BeforeCreated == nil || filter.BeforeID != last.ID {
+			t.Errorf("bad page %d after %v/%s offset %d, expected after event %s", i+1, filter.BeforeCreated, filter.BeforeID, filter.Offset, last.ID)
+		}
+	}
+
+	// stops at the first error
+	count := 0
+	err = auditService.ExportAuditEvents(context.Background(), model.AuditFilter{}, func(event model.AuditEvent) error {
+		count++
+		return errors.New("just some error")
+	})
+	if err == nil || count != 1 {
+		t.Errorf("bad error %v after %d events, expected error after 1", err, count)
+	}
+
+}
+
+func TestLoginAudit(b *testing.T) {
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+	}
+	if err := demouser.SetPassword("demouser"); err != nil {
+		b.Fatal(err)
+	}
+
+	testCases := []struct {
+		Alias           string
+		Password        string
+		ExpectedOutcome string
+		ExpectedActorID string
+		ExpectedMsg     string
+	}{
+		{
+			Alias:           "success",
+			Password:        "demouser",
+			ExpectedOutcome: model.AuditSuccess,
+			ExpectedActorID: "id",
+		},
+		{
+			Alias:           "bad password",
+			Password:        "demouser2",
+			ExpectedOutcome: model.AuditFailure,
+			ExpectedMsg:     "invalid username/password",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			auditor := &AuditorMock{}
+			userRepo := &UserRepositoryMock{
+				User: demouser,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "S123"})
+			userService.SetAuditor(auditor)
+
+			userService.Login(context.Background(), model.LoginRequest{
+				Username:  "demouser",
+				Password:  tCase.Password,
+				IPAddress: "192.0.2.1",
+				UserAgent: "test-agent",
+			})
+
+			if got, want := len(auditor.Events), 1; got != want {
+				t.Fatalf("bad event count %d, expected %d", got, want)
+			}
+			event := auditor.Events[0]
+			if got, want := event.Action, model.AuditLogin; got != want {
+				t.Errorf("bad action %s, expected %s", got, want)
+			}
+			if got, want := event.Outcome, tCase.ExpectedOutcome; got != want {
+				t.Errorf("bad outcome %s, expected %s", got, want)
+			}
+			if got, want := event.ActorID, tCase.ExpectedActorID; got != want {
+				t.Errorf("bad actor %s, expected %s", got, want)
+			}
+			if got, want := event.ActorName, "demouser"; got != want {
+				t.Errorf("bad actor name %s, expected %s", got, want)
+			}
+			if got, want := event.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := event.IPAddress, "192.0.2.1"; got != want {
+				t.Errorf("bad IP address %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,10 @@
+package services
+
+import (
+	"time"
+)
+
+// SetClock replaces the time of the logins, so that tests do not depend on the wall clock.
+func (z *UserService) SetClock(clock func() time.Time) {
+	z.clock = clock
+}
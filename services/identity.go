@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// SetProvisioningEnabled allows LoginExternal to create a user with the user role for an unknown identity.
+func (z *UserService) SetProvisioningEnabled(enabled bool) {
+	z.provisioning = enabled
+}
+
+// LoginExternal creates a session for the user linked to an identity verified by an external identity provider.
+// Unknown identities are refused unless provisioning is enabled.
+// The identity provider is trusted to have authenticated the user, no second factor is asked.
+func (z *UserService) LoginExternal(ctx context.Context, req model.ExternalLoginRequest) model.LoginResponse {
+
+	logger := logging.New(ctx, componentUserService, "LoginExternal")
+
+	if len(req.Issuer) == 0 || len(req.Subject) == 0 {
+		msg := "invalid identity"
+		logger.Debug().Msg(msg)
+		return model.LoginResponse{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var user *model.User
+	var roles []model.UserRole
+	var refused string
+	var provisioned bool
+
+	sessionID := z.idgen.NewID()
+	issued := z.clock().Truncate(time.Minute)
+	expires := issued.Add(model.LoginTimeout)
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		now := z.clock()
+		identity, errIdentity := z.userRepo.GetUserIdentity(ctx, tx, req.Issuer, req.Subject)
+		if errIdentity != nil {
+			logger.Error().Err(errIdentity).Msg("repo GetUserIdentity")
+			return errIdentity // 500
+		}
+		var usr *model.User
+		if identity != nil {
+			u, errGet := z.userRepo.GetUser(ctx, tx, identity.UserID)
+			if errGet != nil {
+				logger.Error().Err(errGet).Msg("repo GetUser")
+				return errGet // 500
+			}
+			if u == nil || u.Disabled {
+				refused = "user disabled" // 403
+				return nil
+			}
+			usr = u
+		} else if !z.provisioning {
+			refused = "no user linked to identity" // 403
+			return nil
+		} else {
+			u, errProvision := z.provisionUser(ctx, tx, req, now)
+			if errProvision != nil {
+				return errProvision // 400, 500
+			}
+			usr = u
+			provisioned = true
+		}
+		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, usr.ID, &now)
+		if errRoles != nil {
+			return errRoles
+		}
+		session := newSession(sessionID, usr.ID, req.UserAgent, req.IPAddress, issued, now, expires)
+		if err := z.sessionRepo.SetSession(ctx, tx, session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err // 500
+		}
+		user = usr
+		roles = rs
+		return nil
+	})
+
+	event := model.AuditEvent{Action: model.AuditLoginExternal, ActorName: req.Issuer, IPAddress: req.IPAddress, UserAgent: req.UserAgent}
+	errAudit := err
+	if err == nil && len(refused) != 0 {
+		errAudit = errors.New(refused)
+	} else if err == nil {
+		event.ActorID = user.ID
+		event.ActorName = user.Username
+		event.TargetID = user.ID
+	}
+	audit(ctx, z.auditor, event, errAudit)
+
+	rsp := model.LoginResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot login")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else if len(refused) != 0 {
+		logger.Info().Str("issuer", req.Issuer).Str("subject", req.Subject).Msg(refused)
+		rsp.Code = http.StatusForbidden
+		rsp.Message = refused
+	} else {
+		if provisioned {
+			logger.Info().Str("username", user.Username).Str("UserID", user.ID).Str("issuer", req.Issuer).Msg("user provisioned")
+		}
+		rsp.Code = http.StatusOK
+		rsp.SessionToken = model.ToSessionToken(sessionID, user, roles, issued, expires)
+		logger.Info().Str("username", user.Username).Str("UserID", user.ID).Str("SessionID", sessionID).Str("issuer", req.Issuer).Msg("login")
+	}
+
+	return rsp
+
+}
+
+// provisionUser creates a user with the user role linked to the identity of the request.
+// The user gets a random password and can set one with ForgotPassword.
+func (z *UserService) provisionUser(ctx context.Context, tx model.Transaction, req model.ExternalLoginRequest, now time.Time) (*model.User, error) {
+
+	logger := logging.New(ctx, componentUserService, "provisionUser")
+
+	var email string
+	if req.EmailVerified && isValidEmail(req.Email) {
+		email = req.Email
+	}
+
+	var username string
+	for _, candidate := range []string{req.PreferredUsername, email} {
+		if !isValidUsername(candidate) {
+			continue
+		}
+		ok, err := z.userRepo.IsUsernameAvailable(ctx, tx, candidate)
+		if err != nil {
+			logger.Error().Err(err).Msg("repo IsUsernameAvailable")
+			return nil, err // 500
+		}
+		if ok {
+			username = candidate
+			break
+		}
+	}
+	if len(username) == 0 {
+		return nil, model.NewValidationError("username not available") // 400
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if !isValidUsername(name) {
+		name = username
+	}
+
+	user := model.User{
+		ID:       z.idgen.NewID(),
+		Username: username,
+		Name:     name,
+		Email:    email,
+	}
+
+	password, errToken := newToken()
+	if errToken != nil {
+		logger.Error().Err(errToken).Msg("cannot create password")
+		return nil, errToken // 500
+	}
+	if err := user.SetPasswordWith(z.passwordHashing, password); err != nil {
+		logger.Error().Err(err).Msg("user SetPassword")
+		return nil, err // 500
+	}
+	if err := z.userRepo.SetUser(ctx, tx, user); err != nil {
+		logger.Error().Err(err).Msg("repo SetUser")
+		return nil, err // 500
+	}
+	role := model.UserRole{
+		ID:   model.RoleIDUser,
+		Name: model.RoleNameUser,
+	}
+	if err := z.userRepo.SetUserRole(ctx, tx, user.ID, role); err != nil {
+		logger.Error().Err(err).Msg("repo SetUserRole")
+		return nil, err // 500
+	}
+
+	identity := model.UserIdentity{
+		Issuer:  req.Issuer,
+		Subject: req.Subject,
+		UserID:  user.ID,
+		Email:   email,
+		Created: now.Truncate(time.Second),
+	}
+	if err := z.userRepo.SetUserIdentity(ctx, tx, identity); err != nil {
+		logger.Error().Err(err).Msg("repo SetUserIdentity")
+		return nil, err // 500
+	}
+
+	return &user, nil
+
+}
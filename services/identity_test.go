@@ -0,0 +1,189 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestLoginExternal(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	issuer := "https://idp.example.com"
+	demouser := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now}
+	disabled := &model.User{ID: "id", Disabled: true, Username: "demouser", Name: "Demo User", Created: now, Updated: now}
+	identity := &model.UserIdentity{Issuer: issuer, Subject: "sub", UserID: "id", Created: now}
+
+	validRequest := model.ExternalLoginRequest{
+		Issuer:            issuer,
+		Subject:           "sub",
+		Email:             "newuser@example.com",
+		EmailVerified:     true,
+		Name:              "New User",
+		PreferredUsername: "newuser",
+	}
+
+	testCases := []struct {
+		Alias               string
+		Provisioning        bool
+		OutputIdentity      *model.UserIdentity
+		OutputIdentityError error
+		OutputUser          *model.User
+		OutputAvailable     bool
+		Request             model.ExternalLoginRequest
+		ExpectedCode        int
+		ExpectedMessage     string
+		ExpectedUsername    string
+		ExpectedEmail       string
+		ExpectedProvision   bool
+	}{
+		{
+			Alias:            "linked user",
+			OutputIdentity:   identity,
+			OutputUser:       demouser,
+			Request:          validRequest,
+			ExpectedCode:     http.StatusOK,
+			ExpectedUsername: "demouser",
+		},
+		{
+			Alias:           "linked user disabled",
+			OutputIdentity:  identity,
+			OutputUser:      disabled,
+			Request:         validRequest,
+			ExpectedCode:    http.StatusForbidden,
+			ExpectedMessage: "user disabled",
+		},
+		{
+			Alias:           "unknown identity",
+			Request:         validRequest,
+			ExpectedCode:    http.StatusForbidden,
+			ExpectedMessage: "no user linked to identity",
+		},
+		{
+			Alias:             "provisioned",
+			Provisioning:      true,
+			OutputAvailable:   true,
+			Request:           validRequest,
+			ExpectedCode:      http.StatusOK,
+			ExpectedUsername:  "newuser",
+			ExpectedEmail:     "newuser@example.com",
+			ExpectedProvision: true,
+		},
+		{
+			Alias:           "provisioned email not verified",
+			Provisioning:    true,
+			OutputAvailable: true,
+			Request: model.ExternalLoginRequest{
+				Issuer:  issuer,
+				Subject: "sub",
+				Email:   "newuser@example.com",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not available",
+		},
+		{
+			Alias:           "provisioned username not available",
+			Provisioning:    true,
+			OutputAvailable: false,
+			Request:         validRequest,
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not available",
+		},
+		{
+			Alias:           "invalid identity",
+			Request:         model.ExternalLoginRequest{Issuer: issuer},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid identity",
+		},
+		{
+			Alias:               "identity fails",
+			OutputIdentityError: errors.New("just some error"),
+			Request:             validRequest,
+			ExpectedCode:        http.StatusInternalServerError,
+			ExpectedMessage:     "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:          tCase.OutputUser,
+				Identity:      tCase.OutputIdentity,
+				IdentityError: tCase.OutputIdentityError,
+				Available:     tCase.OutputAvailable,
+			}
+			sessionRepo := &SessionRepositoryMock{}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, sessionRepo, &PushMessengerMock{}, &IdGeneratorMock{ID: "S1"})
+			userService.SetClock(func() time.Time { return now })
+			userService.SetProvisioningEnabled(tCase.Provisioning)
+
+			rsp := userService.LoginExternal(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.SavedIdentity != nil, tCase.ExpectedProvision; got != want {
+				t.Errorf("bad saved identity %v, expected %t", userRepo.SavedIdentity, want)
+			}
+
+			if tCase.ExpectedCode != http.StatusOK {
+				if rsp.SessionToken != nil {
+					t.Errorf("bad session token %v, expected nil", rsp.SessionToken)
+				}
+				return
+			}
+
+			if rsp.SessionToken == nil {
+				t.Fatal("bad session token nil")
+			}
+			if got, want := rsp.SessionToken.Username, tCase.ExpectedUsername; got != want {
+				t.Errorf("bad session username %s, expected %s", got, want)
+			}
+			if sessionRepo.SavedSession == nil {
+				t.Error("bad saved session nil")
+			}
+
+			if !tCase.ExpectedProvision {
+				return
+			}
+
+			saved := userRepo.SavedUser
+			if saved == nil {
+				t.Fatal("bad saved user nil")
+			}
+			if got, want := saved.Email, tCase.ExpectedEmail; got != want {
+				t.Errorf("bad saved email %s, expected %s", got, want)
+			}
+			if got, want := saved.Name, tCase.Request.Name; got != want {
+				t.Errorf("bad saved name %s, expected %s", got, want)
+			}
+			if userRepo.GrantedRole == nil || userRepo.GrantedRole.ID != model.RoleIDUser {
+				t.Errorf("bad granted role %v, expected %s", userRepo.GrantedRole, model.RoleIDUser)
+			}
+			if got, want := userRepo.SavedIdentity.UserID, saved.ID; got != want {
+				t.Errorf("bad identity user %s, expected %s", got, want)
+			}
+			if got, want := userRepo.SavedIdentity.Subject, tCase.Request.Subject; got != want {
+				t.Errorf("bad identity subject %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
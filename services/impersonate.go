@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// Impersonate issues a session for the user to the administrator in the context, so that they see what the user sees.
+// The session is marked with the impersonator, expires after ImpersonationTimeout and is not renewed.
+// Users with a permission the administrator does not have cannot be impersonated.
+func (z *AdminService) Impersonate(ctx context.Context, req model.ImpersonateRequest) model.ImpersonateResponse {
+
+	logger := logging.New(ctx, componentAdminService, "Impersonate")
+
+	impersonator := model.TokenFromContext(ctx)
+
+	var user *model.User
+	var roles []model.UserRole
+
+	sessionID := z.idgen.NewID()
+	issued := time.Now().Truncate(time.Second)
+	expires := issued.Add(model.ImpersonationTimeout)
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if len(impersonator.Impersonator) != 0 {
+			return model.NewValidationError("already impersonating") // 400
+		}
+		if impersonator.ID == req.UserID {
+			return model.NewValidationError("cannot impersonate yourself") // 400
+		}
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+		if u.Disabled {
+			return model.NewValidationError("user disabled") // 400
+		}
+
+		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, u.ID, &issued)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles // 500
+		}
+		for _, permission := range model.RolePermissions(rs) {
+			if !impersonator.HasPermission(permission) {
+				return model.NewValidationError("permission not held: " + permission) // 400
+			}
+		}
+
+		session := newSession(sessionID, u.ID, req.UserAgent, req.IPAddress, issued, issued, expires)
+		session.ImpersonatorID = impersonator.ID
+		if err := z.sessionRepo.SetSession(ctx, tx, session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err // 500
+		}
+
+		user = u
+		roles = rs
+		return nil
+
+	})
+
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditImpersonate, TargetID: req.UserID, IPAddress: req.IPAddress, UserAgent: req.UserAgent, Message: sessionID}, err)
+
+	rsp := model.ImpersonateResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot impersonate")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		rsp.Code = http.StatusOK
+		rsp.SessionToken = model.ToSessionToken(sessionID, user, roles, issued, expires)
+		rsp.SessionToken.Impersonator = impersonator.ID
+		logger.Info().Str("UserID", user.ID).Str("SessionID", sessionID).Str("ImpersonatorID", impersonator.ID).Msg("impersonating")
+	}
+
+	return rsp
+
+}
@@ -0,0 +1,162 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestImpersonate(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	admin := model.SessionToken{ID: "adminid", Username: "admin", Permissions: []string{model.PermissionAccount, model.PermissionImpersonate}}
+	demouser := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now}
+	disabled := *demouser
+	disabled.Disabled = true
+	userRoles := []model.UserRole{{ID: model.RoleIDUser, Name: model.RoleNameUser, Permissions: []string{model.PermissionAccount}}}
+	adminRoles := []model.UserRole{{ID: model.RoleIDAdmin, Name: model.RoleNameAdmin, Permissions: []string{model.PermissionManageUsers}}}
+
+	testCases := []struct {
+		Alias               string
+		OutputUser          *model.User
+		OutputRoles         []model.UserRole
+		OutputSetError      error
+		RequestSessionToken model.SessionToken
+		ExpectedCode        int
+		ExpectedMessage     string
+		ExpectedOutcome     string
+	}{
+		{
+			Alias:               "success",
+			OutputUser:          demouser,
+			OutputRoles:         userRoles,
+			RequestSessionToken: admin,
+			ExpectedCode:        http.StatusOK,
+			ExpectedOutcome:     model.AuditSuccess,
+		},
+		{
+			Alias:               "user not found",
+			RequestSessionToken: admin,
+			ExpectedCode:        http.StatusNotFound,
+			ExpectedMessage:     "user not found",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+		{
+			Alias:               "user disabled",
+			OutputUser:          &disabled,
+			OutputRoles:         userRoles,
+			RequestSessionToken: admin,
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "user disabled",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+		{
+			Alias:               "cannot impersonate self",
+			OutputUser:          demouser,
+			RequestSessionToken: model.SessionToken{ID: "id", Permissions: admin.Permissions},
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "cannot impersonate yourself",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+		{
+			Alias:               "already impersonating",
+			OutputUser:          demouser,
+			RequestSessionToken: model.SessionToken{ID: "other", Impersonator: "adminid", Permissions: admin.Permissions},
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "already impersonating",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+		{
+			Alias:               "more permissions",
+			OutputUser:          demouser,
+			OutputRoles:         adminRoles,
+			RequestSessionToken: admin,
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "permission not held: users.manage",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+		{
+			Alias:               "save fails",
+			OutputUser:          demouser,
+			OutputRoles:         userRoles,
+			OutputSetError:      errors.New("just some error"),
+			RequestSessionToken: admin,
+			ExpectedCode:        http.StatusInternalServerError,
+			ExpectedMessage:     "just some error",
+			ExpectedOutcome:     model.AuditFailure,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:  tCase.OutputUser,
+				Roles: tCase.OutputRoles,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				SetError: tCase.OutputSetError,
+			}
+			auditor := &AuditorMock{}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, sessionRepo, &PushMessengerMock{}, &IdGeneratorMock{ID: "S456"})
+			adminService.SetAuditor(auditor)
+
+			ctx := context.WithValue(context.Background(), model.UserKey, tCase.RequestSessionToken)
+			rsp := adminService.Impersonate(ctx, model.ImpersonateRequest{UserID: "id", IPAddress: "192.0.2.1"})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := len(auditor.Events), 1; got != want {
+				t.Fatalf("bad audit event count %d, expected %d", got, want)
+			}
+			if got, want := auditor.Events[0].Action, model.AuditImpersonate; got != want {
+				t.Errorf("bad audit action %s, expected %s", got, want)
+			}
+			if got, want := auditor.Events[0].Outcome, tCase.ExpectedOutcome; got != want {
+				t.Errorf("bad audit outcome %s, expected %s", got, want)
+			}
+
+			if tCase.ExpectedCode != http.StatusOK {
+				if rsp.SessionToken != nil {
+					t.Error("bad session token, expected nil")
+				}
+				return
+			}
+
+			token := rsp.SessionToken
+			if token == nil {
+				t.Fatal("nil session token, expected non-nil")
+			}
+			if got, want := token.Impersonator, "adminid"; got != want {
+				t.Errorf("bad impersonator %s, expected %s", got, want)
+			}
+			if got, want := token.ID, "id"; got != want {
+				t.Errorf("bad user %s, expected %s", got, want)
+			}
+			if got, want := token.Expires.Sub(token.Issued), model.ImpersonationTimeout; got != want {
+				t.Errorf("bad lifetime %s, expected %s", got, want)
+			}
+			saved := sessionRepo.SavedSession
+			if saved == nil || saved.ID != "S456" || saved.ImpersonatorID != "adminid" {
+				t.Errorf("bad saved session %v", saved)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
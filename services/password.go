@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, user model.User, subject, body string) error
+}
+
+// SetNotifier sets the notifier used to deliver password reset tokens.
+func (z *UserService) SetNotifier(notifier Notifier) {
+	z.notifier = notifier
+}
+
+// ForgotPassword sends a single-use token to set a new password to the user.
+// The response does not tell if the user exists, is enabled or could be notified:
+// the user is notified in the background, so that the response does not wait for the notifier either.
+func (z *UserService) ForgotPassword(ctx context.Context, req model.ForgotPasswordRequest) model.ForgotPasswordResponse {
+
+	logger := logging.New(ctx, componentUserService, "ForgotPassword")
+
+	if z.notifier == nil {
+		msg := "password reset disabled"
+		logger.Debug().Msg(msg)
+		return model.ForgotPasswordResponse{
+			Code:    http.StatusForbidden,
+			Message: msg,
+		}
+	}
+
+	if len(req.Username) == 0 {
+		msg := "username not valid"
+		logger.Debug().Msg(msg)
+		return model.ForgotPasswordResponse{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var user *model.User
+	var token string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetActiveUserByUsername(ctx, tx, req.Username)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetActiveUserByUsername")
+			return errGet // 500
+		}
+		if u == nil {
+			return nil // 202
+		}
+
+		t, errToken := newToken()
+		if errToken != nil {
+			logger.Error().Err(errToken).Msg("cannot create token")
+			return errToken // 500
+		}
+		now := time.Now().Truncate(time.Second)
+		reset := model.PasswordReset{
+			TokenHash: model.HashToken(t),
+			UserID:    u.ID,
+			Created:   now,
+			Expires:   now.Add(model.PasswordResetTimeout),
+		}
+		if err := z.userRepo.SetPasswordReset(ctx, tx, reset); err != nil {
+			logger.Error().Err(err).Msg("repo SetPasswordReset")
+			return err // 500
+		}
+
+		user = u
+		token = t
+		return nil
+
+	})
+
+	if err != nil {
+		return model.ForgotPasswordResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+	}
+
+	if user == nil {
+		logger.Debug().Str("username", req.Username).Msg("user not found")
+	} else {
+		// detached from the request, which ends with the response
+		notifyCtx := context.WithValue(context.Background(), model.CorrelationIDKey, model.CorrelationIDFromContext(ctx))
+		go z.notifyPasswordReset(notifyCtx, *user, token)
+	}
+
+	return model.ForgotPasswordResponse{
+		Code: http.StatusAccepted,
+	}
+
+}
+
+// ResetForgottenPassword sets a new password with a token sent by ForgotPassword.
+// All sessions of the user are revoked and the user is unlocked.
+func (z *UserService) ResetForgottenPassword(ctx context.Context, req model.ResetForgottenPasswordRequest) model.ResetForgottenPasswordResponse {
+
+	logger := logging.New(ctx, componentUserService, "ResetForgottenPassword")
+
+	if len(req.Token) == 0 {
+		msg := "invalid token"
+		logger.Debug().Msg(msg)
+		return model.ResetForgottenPasswordResponse{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var userID string
+	var revoked []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		reset, errGet := z.userRepo.GetPasswordReset(ctx, tx, model.HashToken(req.Token))
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetPasswordReset")
+			return errGet // 500
+		}
+		now := time.Now()
+		if !reset.IsValid(now) {
+			return model.NewValidationError("invalid token") // 400
+		}
+		userID = reset.UserID
+
+		u, errUser := z.userRepo.GetUser(ctx, tx, reset.UserID)
+		if errUser != nil {
+			logger.Error().Err(errUser).Msg("repo GetUser")
+			return errUser // 500
+		}
+		if u == nil || u.Disabled {
+			return model.NewValidationError("invalid token") // 400
+		}
+
+		if err := checkPassword(ctx, tx, z.userRepo, z.passwordPolicy, u.ID, req.Password); err != nil {
+			return err // 400, 500
+		}
+		if err := u.SetPasswordWith(z.passwordHashing, req.Password); err != nil {
+			logger.Error().Err(err).Msg("user SetPassword")
+			return err // 500
+		}
+		if err := z.userRepo.SetUser(ctx, tx, *u); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+		if err := addPasswordHistory(ctx, tx, z.userRepo, *u); err != nil {
+			return err // 500
+		}
+		if err := z.userRepo.UsePasswordResets(ctx, tx, u.ID, now); err != nil {
+			logger.Error().Err(err).Msg("repo UsePasswordResets")
+			return err // 500
+		}
+		if err := z.userRepo.DeleteLoginThrottle(ctx, tx, model.ThrottleUsername, u.Username); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteLoginThrottle")
+			return err // 500
+		}
+
+		sessionIDs, errRevoke := revokeUserSessions(ctx, tx, z.sessionRepo, u.ID)
+		if errRevoke != nil {
+			logger.Error().Err(errRevoke).Msg("cannot revoke sessions")
+			return errRevoke // 500
+		}
+
+		revoked = sessionIDs
+		return nil
+
+	})
+
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditForgotPassword, TargetID: userID}, err)
+
+	rsp := model.ResetForgottenPasswordResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot reset password")
+		rsp.Message = err.Error()
+		rsp.Rule = model.ValidationRule(err)
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+		return rsp
+	}
+
+	z.pushMessenger.SendMessage(model.PushMessage{
+		Type: model.PushTypePasswordChanged,
+	}, userID, "")
+	disconnectSessions(z.pushMessenger, userID, revoked)
+
+	logger.Info().Str("UserID", userID).Int("sessions", len(revoked)).Msg("password reset")
+	rsp.Code = http.StatusOK
+
+	return rsp
+
+}
+
+func (z *UserService) notifyPasswordReset(ctx context.Context, user model.User, token string) {
+
+	logger := logging.New(ctx, componentUserService, "notifyPasswordReset")
+
+	if err := z.notifier.Notify(ctx, user, "Reset your password", passwordResetMessage(user, z.publicURL, token)); err != nil {
+		logger.Warn().Err(err).Str("UserID", user.ID).Msg("cannot notify user")
+		return
+	}
+	logger.Info().Str("UserID", user.ID).Msg("password reset sent")
+
+}
+
+func passwordResetMessage(user model.User, publicURL, token string) string {
+	link := publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	return fmt.Sprintf("Hello %s,\n\nplease open the following link within %d minutes to set a new password for your account %s:\n\n%s\n\nIf you did not ask for a new password, you can ignore this message.\n",
+		user.Name, int(model.PasswordResetTimeout.Minutes()), user.Username, link)
+}
@@ -0,0 +1,342 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestForgotPassword(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Email: "demouser@example.com", Created: now, Updated: now}
+
+	testCases := []struct {
+		Alias             string
+		Disabled          bool
+		OutputUser        *model.User
+		OutputSetError    error
+		OutputNotifyError error
+		Request           model.ForgotPasswordRequest
+		ExpectedCode      int
+		ExpectedMessage   string
+		ExpectedNotify    bool
+	}{
+		{
+			Alias:          "success",
+			OutputUser:     demouser,
+			Request:        model.ForgotPasswordRequest{Username: "demouser"},
+			ExpectedCode:   http.StatusAccepted,
+			ExpectedNotify: true,
+		},
+		{
+			Alias:        "unknown user",
+			OutputUser:   nil,
+			Request:      model.ForgotPasswordRequest{Username: "bogus"},
+			ExpectedCode: http.StatusAccepted,
+		},
+		{
+			Alias:             "notify fails",
+			OutputUser:        demouser,
+			OutputNotifyError: errors.New("just some error"),
+			Request:           model.ForgotPasswordRequest{Username: "demouser"},
+			ExpectedCode:      http.StatusAccepted,
+			ExpectedNotify:    true,
+		},
+		{
+			Alias:           "no username",
+			OutputUser:      demouser,
+			Request:         model.ForgotPasswordRequest{},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not valid",
+		},
+		{
+			Alias:           "reset disabled",
+			Disabled:        true,
+			OutputUser:      demouser,
+			Request:         model.ForgotPasswordRequest{Username: "demouser"},
+			ExpectedCode:    http.StatusForbidden,
+			ExpectedMessage: "password reset disabled",
+		},
+		{
+			Alias:           "set fails",
+			OutputUser:      demouser,
+			OutputSetError:  errors.New("just some error"),
+			Request:         model.ForgotPasswordRequest{Username: "demouser"},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:     tCase.OutputUser,
+				SetError: tCase.OutputSetError,
+			}
+			notifier := &NotifierMock{
+				NotifyError: tCase.OutputNotifyError,
+				Notified:    make(chan bool, 1),
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+			userService.SetMailer(&MailerMock{}, "https://example.com/")
+			if !tCase.Disabled {
+				userService.SetNotifier(notifier)
+			}
+
+			rsp := userService.ForgotPassword(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			// the user is notified in the background
+			if !tCase.ExpectedNotify {
+				if notifier.User != nil {
+					t.Errorf("bad notify %v, expected none", notifier.User)
+				}
+				return
+			}
+			select {
+			case <-notifier.Notified:
+			case <-time.After(time.Second):
+				t.Fatal("bad notify, expected a notification")
+			}
+
+			if got, want := notifier.User.ID, tCase.OutputUser.ID; got != want {
+				t.Errorf("bad notified user %s, expected %s", got, want)
+			}
+
+			// the sent token must match the saved hash
+			prefix := "https://example.com/reset-password?token="
+			i := strings.Index(notifier.Body, prefix)
+			if i < 0 {
+				t.Fatalf("bad notify body, no link: %s", notifier.Body)
+			}
+			link := strings.Fields(notifier.Body[i:])[0]
+			u, errURL := url.Parse(link)
+			if errURL != nil {
+				t.Fatal(errURL)
+			}
+			reset := userRepo.SavedPasswordReset
+			if reset == nil {
+				t.Fatal("bad password reset nil, expected non-nil")
+			}
+			if got, want := reset.TokenHash, model.HashToken(u.Query().Get("token")); got != want {
+				t.Errorf("bad password reset hash %s, expected %s", got, want)
+			}
+			if got, want := reset.Expires, reset.Created.Add(model.PasswordResetTimeout); !got.Equal(want) {
+				t.Errorf("bad password reset expires %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestResetForgottenPassword(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := func() *model.User {
+		u := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Email: "demouser@example.com", Created: now, Updated: now}
+		if err := u.SetPassword("oldpassword"); err != nil {
+			b.Fatal(err)
+		}
+		return u
+	}
+	reset := func(expires time.Time, used *time.Time) *model.PasswordReset {
+		return &model.PasswordReset{TokenHash: model.HashToken("token"), UserID: "id", Created: now, Expires: expires, Used: used}
+	}
+	sessions := []model.Session{
+		{ID: "S1", UserID: "id"},
+		{ID: "S2", UserID: "id"},
+	}
+
+	testCases := []struct {
+		Alias           string
+		OutputUser      *model.User
+		OutputReset     *model.PasswordReset
+		OutputSetError  error
+		Request         model.ResetForgottenPasswordRequest
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedRule    string
+		ExpectedReset   bool
+	}{
+		{
+			Alias:         "success",
+			OutputUser:    demouser(),
+			OutputReset:   reset(now.Add(time.Hour), nil),
+			Request:       model.ResetForgottenPasswordRequest{Token: "token", Password: "newpassword"},
+			ExpectedCode:  http.StatusOK,
+			ExpectedReset: true,
+		},
+		{
+			Alias:           "unknown token",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(time.Hour), nil),
+			Request:         model.ResetForgottenPasswordRequest{Token: "bogus", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid token",
+		},
+		{
+			Alias:           "no token",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(time.Hour), nil),
+			Request:         model.ResetForgottenPasswordRequest{Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid token",
+		},
+		{
+			Alias:           "expired token",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(-time.Second), nil),
+			Request:         model.ResetForgottenPasswordRequest{Token: "token", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid token",
+		},
+		{
+			Alias:           "used token",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(time.Hour), &now),
+			Request:         model.ResetForgottenPasswordRequest{Token: "token", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid token",
+		},
+		{
+			Alias: "user disabled",
+			OutputUser: func() *model.User {
+				u := demouser()
+				u.Disabled = true
+				return u
+			}(),
+			OutputReset:     reset(now.Add(time.Hour), nil),
+			Request:         model.ResetForgottenPasswordRequest{Token: "token", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "invalid token",
+		},
+		{
+			Alias:           "password not valid",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(time.Hour), nil),
+			Request:         model.ResetForgottenPasswordRequest{Token: "token", Password: "short"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must have at least 8 characters",
+			ExpectedRule:    model.PasswordRuleMinLength,
+		},
+		{
+			Alias:           "set fails",
+			OutputUser:      demouser(),
+			OutputReset:     reset(now.Add(time.Hour), nil),
+			OutputSetError:  errors.New("just some error"),
+			Request:         model.ResetForgottenPasswordRequest{Token: "token", Password: "newpassword"},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:          tCase.OutputUser,
+				PasswordReset: tCase.OutputReset,
+				SetError:      tCase.OutputSetError,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions: sessions,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: map[string]bool{"S1": true},
+			}
+			auditor := &AuditorMock{}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, sessionRepo, pushMessenger, &IdGeneratorMock{})
+			userService.SetNotifier(&NotifierMock{})
+			userService.SetAuditor(auditor)
+
+			rsp := userService.ResetForgottenPassword(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.Rule, tCase.ExpectedRule; got != want {
+				t.Errorf("bad response rule %s, expected %s", got, want)
+			}
+
+			if len(tCase.Request.Token) != 0 {
+				outcome := model.AuditSuccess
+				if !tCase.ExpectedReset {
+					outcome = model.AuditFailure
+				}
+				if got, want := len(auditor.Events), 1; got != want {
+					t.Fatalf("bad audit event count %d, expected %d", got, want)
+				}
+				if got, want := auditor.Events[0].Action, model.AuditForgotPassword; got != want {
+					t.Errorf("bad audit action %s, expected %s", got, want)
+				}
+				if got, want := auditor.Events[0].Outcome, outcome; got != want {
+					t.Errorf("bad audit outcome %s, expected %s", got, want)
+				}
+			}
+
+			if !tCase.ExpectedReset {
+				if len(pushMessenger.Sent) != 0 || len(pushMessenger.Disconnected) != 0 {
+					t.Errorf("bad push %v, disconnected %v, expected none", pushMessenger.Sent, pushMessenger.Disconnected)
+				}
+				return
+			}
+
+			if saved := userRepo.SavedUser; saved == nil || !saved.MatchPassword(tCase.Request.Password) {
+				t.Errorf("bad saved user %v", saved)
+			}
+			if got, want := userRepo.UsedPasswordResets, "id"; got != want {
+				t.Errorf("bad used password resets %s, expected %s", got, want)
+			}
+			if got, want := userRepo.DeletedThrottles, []string{model.ThrottleUsername + ":demouser"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad deleted throttles %v, expected %v", got, want)
+			}
+			if got, want := sessionRepo.RevokedIDs, []string{"S1", "S2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad revoked sessions %v, expected %v", got, want)
+			}
+			if got, want := len(pushMessenger.Sent), 1; got != want {
+				t.Fatalf("bad push count %d, expected %d", got, want)
+			}
+			if got, want := pushMessenger.Sent[0].Type, model.PushTypePasswordChanged; got != want {
+				t.Errorf("bad push type %s, expected %s", got, want)
+			}
+			if got, want := pushMessenger.Disconnected, []string{"S1", "S2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad disconnected %v, expected %v", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type PasswordHistoryRepository interface {
+	GetPasswordHistory(context.Context, model.ReadOnlyTransaction, string, int) ([]model.PasswordHistory, error)
+	AddPasswordHistory(context.Context, model.WriteOnlyTransaction, model.PasswordHistory) error
+}
+
+// SetPasswordPolicy sets the rules for new passwords and the maximum password age checked at login.
+func (z *UserService) SetPasswordPolicy(policy model.PasswordPolicy) {
+	z.passwordPolicy = policy
+}
+
+// SetPasswordPolicy sets the rules for passwords set by administrators.
+func (z *AdminService) SetPasswordPolicy(policy model.PasswordPolicy) {
+	z.passwordPolicy = policy
+}
+
+// SetPasswordHashing sets the algorithm and cost of new password hashes.
+// Outdated hashes are upgraded at login.
+func (z *UserService) SetPasswordHashing(hashing model.PasswordHashing) {
+	z.passwordHashing = hashing
+}
+
+// SetPasswordHashing sets the algorithm and cost of password hashes set by administrators.
+func (z *AdminService) SetPasswordHashing(hashing model.PasswordHashing) {
+	z.passwordHashing = hashing
+}
+
+// checkPassword tests a new password of an existing user against the policy and the user's password history.
+func checkPassword(ctx context.Context, tx model.ReadOnlyTransaction, repo PasswordHistoryRepository, policy model.PasswordPolicy, userID, password string) error {
+
+	if err := policy.Check(password); err != nil {
+		return err // 400
+	}
+	if policy.History == 0 {
+		return nil
+	}
+
+	history, errHistory := repo.GetPasswordHistory(ctx, tx, userID, policy.History)
+	if errHistory != nil {
+		logger := logging.New(ctx, "checkPassword")
+		logger.Error().Err(errHistory).Msg("repo GetPasswordHistory")
+		return errHistory // 500
+	}
+
+	return policy.CheckHistory(password, history) // 400
+
+}
+
+// addPasswordHistory records the password of the user, after the user has been saved.
+func addPasswordHistory(ctx context.Context, tx model.WriteOnlyTransaction, repo PasswordHistoryRepository, user model.User) error {
+	err := repo.AddPasswordHistory(ctx, tx, model.PasswordHistory{
+		UserID:       user.ID,
+		PasswordHash: user.PasswordHash,
+		Created:      time.Now().Truncate(time.Second),
+	})
+	if err != nil {
+		logger := logging.New(ctx, "addPasswordHistory")
+		logger.Error().Err(err).Msg("repo AddPasswordHistory")
+	}
+	return err
+}
+
+// LoadBannedPasswords reads a list of banned passwords, one per line.
+// Empty lines and lines starting with # are skipped, passwords are lowercased.
+func LoadBannedPasswords(filename string) (map[string]bool, error) {
+
+	f, errOpen := os.Open(filename)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer f.Close()
+
+	banned := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		banned[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return banned, nil
+
+}
@@ -0,0 +1,339 @@
+package services_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestPasswordPolicyHistory(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	policy := model.PasswordPolicy{
+		MinLength: 8,
+		History:   2,
+	}
+
+	history := func(passwords ...string) []model.PasswordHistory {
+		var h []model.PasswordHistory
+		for _, password := range passwords {
+			u := model.User{}
+			if err := u.SetPassword(password); err != nil {
+				b.Fatal(err)
+			}
+			h = append(h, model.PasswordHistory{UserID: "id", PasswordHash: u.PasswordHash})
+		}
+		return h
+	}
+
+	testCases := []struct {
+		Alias           string
+		OutputHistory   []model.PasswordHistory
+		NewPassword     string
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedRule    string
+	}{
+		{
+			Alias:         "success",
+			OutputHistory: history("demouser", "demouser1", "demouser2"),
+			NewPassword:   "demouser2",
+			ExpectedCode:  http.StatusOK,
+		},
+		{
+			Alias:           "current password",
+			OutputHistory:   history("demouser", "demouser1", "demouser2"),
+			NewPassword:     "demouser",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must differ from the last 2 passwords",
+			ExpectedRule:    model.PasswordRuleHistory,
+		},
+		{
+			Alias:           "previous password",
+			OutputHistory:   history("demouser", "demouser1", "demouser2"),
+			NewPassword:     "demouser1",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must differ from the last 2 passwords",
+			ExpectedRule:    model.PasswordRuleHistory,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			u := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now}
+			if err := u.SetPassword("demouser"); err != nil {
+				t.Fatal(err)
+			}
+			userRepo := &UserRepositoryMock{
+				User:            u,
+				PasswordHistory: tCase.OutputHistory,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, nil)
+			userService.SetPasswordPolicy(policy)
+
+			ctx := context.WithValue(context.Background(), model.UserKey, &model.SessionToken{ID: "id", SessionID: "S1"})
+			rsp := userService.ChangePassword(ctx, model.ChangePasswordRequest{
+				UserID:      "id",
+				Password:    "demouser",
+				NewPassword: tCase.NewPassword,
+			})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.Rule, tCase.ExpectedRule; got != want {
+				t.Errorf("bad response rule %s, expected %s", got, want)
+			}
+
+			if tCase.ExpectedCode != http.StatusOK {
+				if len(userRepo.AddedHistory) != 0 {
+					t.Errorf("bad history %v, expected none", userRepo.AddedHistory)
+				}
+				return
+			}
+
+			if got, want := len(userRepo.AddedHistory), 1; got != want {
+				t.Fatalf("bad history count %d, expected %d", got, want)
+			}
+			if got, want := userRepo.AddedHistory[0].PasswordHash, userRepo.SavedUser.PasswordHash; got != want {
+				t.Errorf("bad history hash %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestPasswordPolicyMaxAge(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.User{ID: "id", Username: "demouser", Name: "Demo User", Created: now, Updated: now}
+	if err := demouser.SetPassword("demouser"); err != nil {
+		b.Fatal(err)
+	}
+
+	testCases := []struct {
+		Alias           string
+		MaxAge          time.Duration
+		OutputHistory   []model.PasswordHistory
+		ExpectedCode    int
+		ExpectedMessage string
+	}{
+		{
+			Alias:         "no maximum age",
+			OutputHistory: []model.PasswordHistory{{UserID: "id", Created: now.Add(-time.Hour * 24 * 365)}},
+			ExpectedCode:  http.StatusOK,
+		},
+		{
+			Alias:         "password current",
+			MaxAge:        time.Hour * 24 * 90,
+			OutputHistory: []model.PasswordHistory{{UserID: "id", Created: now.Add(-time.Hour * 24)}},
+			ExpectedCode:  http.StatusOK,
+		},
+		{
+			Alias:           "password expired",
+			MaxAge:          time.Hour * 24 * 90,
+			OutputHistory:   []model.PasswordHistory{{UserID: "id", Created: now.Add(-time.Hour * 24 * 91)}},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password expired",
+		},
+		{
+			Alias:        "no history",
+			MaxAge:       time.Hour * 24 * 90,
+			ExpectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:            demouser,
+				PasswordHistory: tCase.OutputHistory,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "S1"})
+			userService.SetPasswordPolicy(model.PasswordPolicy{
+				MinLength: 8,
+				MaxAge:    tCase.MaxAge,
+			})
+
+			rsp := userService.Login(context.Background(), model.LoginRequest{
+				Username: "demouser",
+				Password: "demouser",
+			})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.SessionToken != nil, tCase.ExpectedCode == http.StatusOK; got != want {
+				t.Errorf("bad session token %t, expected %t", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestPasswordHashingRehash(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	hashing := model.PasswordHashing{
+		Algorithm:     model.PasswordHashArgon2id,
+		BcryptCost:    bcrypt.MinCost,
+		Argon2Time:    1,
+		Argon2Memory:  1024,
+		Argon2Threads: 1,
+	}
+
+	hash := func(h model.PasswordHashing) string {
+		u := model.User{}
+		if err := u.SetPasswordWith(h, "demouser"); err != nil {
+			b.Fatal(err)
+		}
+		return u.PasswordHash
+	}
+	legacy, errLegacy := bcrypt.GenerateFromPassword([]byte("demouser"), bcrypt.MinCost)
+	if errLegacy != nil {
+		b.Fatal(errLegacy)
+	}
+	bcryptHashing := hashing
+	bcryptHashing.Algorithm = model.PasswordHashBcrypt
+	weakHashing := hashing
+	weakHashing.Argon2Memory = 512
+
+	testCases := []struct {
+		Alias          string
+		PasswordHash   string
+		ExpectedRehash bool
+	}{
+		{
+			Alias:        "current",
+			PasswordHash: hash(hashing),
+		},
+		{
+			Alias:          "legacy hex bcrypt",
+			PasswordHash:   hex.EncodeToString(legacy),
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "bcrypt",
+			PasswordHash:   hash(bcryptHashing),
+			ExpectedRehash: true,
+		},
+		{
+			Alias:          "argon2id parameters",
+			PasswordHash:   hash(weakHashing),
+			ExpectedRehash: true,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User: &model.User{ID: "id", Username: "demouser", Name: "Demo User", PasswordHash: tCase.PasswordHash, Created: now, Updated: now},
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "S1"})
+			userService.SetPasswordHashing(hashing)
+
+			rsp := userService.Login(context.Background(), model.LoginRequest{
+				Username: "demouser",
+				Password: "demouser",
+			})
+
+			if got, want := rsp.Code, http.StatusOK; got != want {
+				t.Fatalf("bad response code %d, expected %d", got, want)
+			}
+
+			if !tCase.ExpectedRehash {
+				if userRepo.SavedUser != nil {
+					t.Errorf("bad saved user %v, expected none", userRepo.SavedUser)
+				}
+				return
+			}
+
+			saved := userRepo.SavedUser
+			if saved == nil {
+				t.Fatal("bad saved user nil, expected rehash")
+			}
+			if hashing.NeedsRehash(saved.PasswordHash) {
+				t.Errorf("bad password hash %s, expected current hashing", saved.PasswordHash)
+			}
+			if !saved.MatchPassword("demouser") {
+				t.Error("bad password hash, expected match")
+			}
+			if len(userRepo.AddedHistory) != 0 {
+				t.Errorf("bad history %v, expected none", userRepo.AddedHistory)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestLoadBannedPasswords(t *testing.T) {
+
+	f, errTemp := ioutil.TempFile("", "banned")
+	if errTemp != nil {
+		t.Fatal(errTemp)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("# common passwords\nPassword1\n\n  letmein  \n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	banned, errLoad := services.LoadBannedPasswords(f.Name())
+	if errLoad != nil {
+		t.Fatalf("Bad load error: %s", errLoad)
+	}
+
+	expected := map[string]bool{"password1": true, "letmein": true}
+	if !reflect.DeepEqual(banned, expected) {
+		t.Errorf("Bad banned passwords: %v, expected %v", banned, expected)
+	}
+
+	if _, err := services.LoadBannedPasswords(f.Name() + ".missing"); err == nil {
+		t.Error("Bad load error: nil, expected error")
+	}
+
+}
@@ -0,0 +1,190 @@
+package push
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// Broker fans the push messages out to the messengers of all instances.
+type Broker interface {
+	Publish(msg model.BrokerMessage) error
+	// Subscribe registers the function receiving the messages published by all instances, including this one.
+	Subscribe(fn func(msg model.BrokerMessage))
+}
+
+// MemoryBroker connects the messengers of a single process, used for tests.
+type MemoryBroker struct {
+	lock        sync.RWMutex
+	subscribers []func(msg model.BrokerMessage)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (z *MemoryBroker) Publish(msg model.BrokerMessage) error {
+	z.lock.RLock()
+	subscribers := z.subscribers
+	z.lock.RUnlock()
+	for _, fn := range subscribers {
+		fn(msg)
+	}
+	return nil
+}
+
+func (z *MemoryBroker) Subscribe(fn func(msg model.BrokerMessage)) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.subscribers = append(z.subscribers, fn)
+}
+
+// the messages of the DatabaseBroker are polled for BrokerWindow and deleted after BrokerRetention
+var (
+	BrokerWindow    = time.Second * 10
+	BrokerRetention = time.Minute
+)
+
+type BrokerRepository interface {
+	AddBrokerMessage(ctx context.Context, tx model.WriteOnlyTransaction, msg model.BrokerMessage, created time.Time) error
+	ListBrokerMessages(ctx context.Context, tx model.ReadOnlyTransaction, since time.Time) ([]model.BrokerMessage, error)
+	DeleteBrokerMessages(ctx context.Context, tx model.WriteOnlyTransaction, before time.Time) error
+}
+
+// DatabaseBroker publishes the messages to a table of the database, which every instance polls.
+// Unlike LISTEN/NOTIFY it works with CockroachDB.
+// A poll reads the messages of the last BrokerWindow, so that the messages committed late are received as well,
+// and skips the ones received before. The messages older than BrokerRetention are deleted.
+type DatabaseBroker struct {
+	db          model.Database
+	repo        BrokerRepository
+	interval    time.Duration
+	lock        sync.RWMutex
+	subscribers []func(msg model.BrokerMessage)
+	seen        map[string]time.Time // when received, used by one poll at a time
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewDatabaseBroker polls the messages every interval, starting with the ones published from now on.
+func NewDatabaseBroker(db model.Database, repo BrokerRepository, interval time.Duration) *DatabaseBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	z := &DatabaseBroker{
+		db:       db,
+		repo:     repo,
+		interval: interval,
+		seen:     make(map[string]time.Time),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	z.poll(false)
+	go z.run(ctx)
+	return z
+}
+
+func (z *DatabaseBroker) Publish(msg model.BrokerMessage) error {
+	ctx := context.Background()
+	return z.db.Run(func(tx model.Transaction) error {
+		return z.repo.AddBrokerMessage(ctx, tx, msg, time.Now().UTC())
+	})
+}
+
+func (z *DatabaseBroker) Subscribe(fn func(msg model.BrokerMessage)) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.subscribers = append(z.subscribers, fn)
+}
+
+// Close stops polling.
+func (z *DatabaseBroker) Close() error {
+	z.cancel()
+	<-z.done
+	return nil
+}
+
+func (z *DatabaseBroker) run(ctx context.Context) {
+
+	logger := logging.New(nil, "push", "DatabaseBroker")
+	logger.Debug().Msg("starting...")
+
+	ticker := time.NewTicker(z.interval)
+	deleted := time.Now()
+
+Loop:
+	for {
+		select {
+		case t := <-ticker.C:
+			z.poll(true)
+			if t.Sub(deleted) >= BrokerRetention {
+				z.deleteExpired(t)
+				deleted = t
+			}
+		case <-ctx.Done():
+			break Loop
+		}
+	}
+
+	ticker.Stop()
+	logger.Debug().Msg("exiting")
+	close(z.done)
+
+}
+
+// poll delivers the messages not seen before to the subscribers, if deliver is set.
+func (z *DatabaseBroker) poll(deliver bool) {
+
+	ctx := context.Background()
+	logger := logging.New(ctx, "push", "DatabaseBroker.poll")
+	now := time.Now()
+
+	var messages []model.BrokerMessage
+	err := z.db.Run(func(tx model.Transaction) error {
+		m, err := z.repo.ListBrokerMessages(ctx, tx, now.Add(-BrokerWindow).UTC())
+		messages = m
+		return err
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("cannot list broker messages")
+		return
+	}
+
+	z.lock.RLock()
+	subscribers := z.subscribers
+	z.lock.RUnlock()
+
+	for _, msg := range messages {
+		key := msg.Origin + "/" + strconv.FormatUint(msg.Serial, 10)
+		if _, ok := z.seen[key]; ok {
+			continue
+		}
+		z.seen[key] = now
+		if deliver {
+			for _, fn := range subscribers {
+				fn(msg)
+			}
+		}
+	}
+
+	// listed again until they leave the window, which the clocks of the instances may see a bit late
+	for key, t := range z.seen {
+		if now.Sub(t) > BrokerWindow*2 {
+			delete(z.seen, key)
+		}
+	}
+
+}
+
+func (z *DatabaseBroker) deleteExpired(now time.Time) {
+	ctx := context.Background()
+	logger := logging.New(ctx, "push", "DatabaseBroker.deleteExpired")
+	err := z.db.Run(func(tx model.Transaction) error {
+		return z.repo.DeleteBrokerMessages(ctx, tx, now.Add(-BrokerRetention).UTC())
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("cannot delete broker messages")
+	}
+}
@@ -0,0 +1,279 @@
+package push_test
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services/push"
+)
+
+func TestBroker(t *testing.T) {
+
+	broker := push.NewMemoryBroker()
+	var published []model.BrokerMessage
+	broker.Subscribe(func(bm model.BrokerMessage) {
+		published = append(published, bm)
+	})
+	a := push.New(push.DefaultOptions)
+	a.SetBroker(broker, "a")
+	b := push.New(push.DefaultOptions)
+	b.SetBroker(broker, "b")
+	if err := b.SetReplayStore(push.NewMemoryReplayStore(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	messagesA, disconnectA := a.ConnectClient("u1", "s1", "")
+	defer disconnectA()
+	messagesB, disconnectB := b.ConnectClient("u1", "s2", "")
+	defer disconnectB()
+
+	if got, want := a.SendMessage(model.PushMessage{Data: "hello"}, "u1", ""), 1; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	a.SendMessage(model.PushMessage{Data: "beat", Transient: true}, "", "")
+
+	// each instance delivers to its own clients once, the other instances after the broker
+	if got, want := len(messagesA), 2; got != want {
+		t.Fatalf("bad queue length a: %d, expected %d", got, want)
+	}
+	if got, want := waitForQueue(messagesB, 1), 1; got != want {
+		t.Fatalf("bad queue length b: %d, expected %d", got, want)
+	}
+	sent := <-messagesA
+	received := <-messagesB
+	if sent != received {
+		t.Errorf("bad message %v, expected %v", received, sent)
+	}
+
+	// a message published twice is delivered once
+	broker.Publish(published[0])
+	if got, want := len(messagesB), 0; got != want {
+		t.Errorf("bad queue length after duplicate: %d, expected %d", got, want)
+	}
+
+	// the sequence of the other instance is ahead, the replay store of b has kept the message of a
+	b.SendMessage(model.PushMessage{Data: "world"}, "u1", "s2")
+	next := <-messagesB
+	seqSent, _ := strconv.ParseUint(sent.ID, 10, 64)
+	seqNext, _ := strconv.ParseUint(next.ID, 10, 64)
+	if seqNext <= seqSent {
+		t.Errorf("bad sequence %d, expected after %d", seqNext, seqSent)
+	}
+
+	missed, disconnectReplay := b.ConnectClient("u1", "s2", "0")
+	defer disconnectReplay()
+	if got, want := len(missed), 2; got != want {
+		t.Errorf("bad replay length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestBrokerLive(t *testing.T) {
+
+	broker := push.NewMemoryBroker()
+	a := push.New(push.DefaultOptions)
+	a.SetBroker(broker, "a")
+	b := push.New(push.DefaultOptions)
+	b.SetBroker(broker, "b")
+	if err := b.SetReplayStore(push.NewMemoryReplayStore(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, disconnect := b.ConnectClient("u1", "s2", "")
+	defer disconnect()
+
+	// a live message reaches the other instances without a number and is not replayed
+	a.SendMessage(model.PushMessage{ID: "x", Type: model.PushTypeRead, Data: "11", Live: true}, "u1", "")
+	if got, want := waitForQueue(messages, 1), 1; got != want {
+		t.Fatalf("bad queue length: %d, expected %d", got, want)
+	}
+	if msg := <-messages; msg.ID != "" || msg.Data != "11" {
+		t.Errorf("bad message %v, expected data 11 without id", msg)
+	}
+
+	missed, disconnectReplay := b.ConnectClient("u1", "s3", "0")
+	defer disconnectReplay()
+	if got, want := len(missed), 0; got != want {
+		t.Errorf("bad replay length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestBrokerTopic(t *testing.T) {
+
+	broker := push.NewMemoryBroker()
+	a := push.New(push.DefaultOptions)
+	a.SetBroker(broker, "a")
+	b := push.New(push.DefaultOptions)
+	b.SetBroker(broker, "b")
+	b.RegisterTopic("news", nil)
+
+	token := model.SessionToken{ID: "u1", SessionID: "s1"}
+	messages, disconnect := b.ConnectClient(token.ID, token.SessionID, "")
+	defer disconnect()
+	if err := b.Subscribe(token, "news"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := a.PublishToTopic("news", model.PushMessage{Data: "hello"}), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := waitForQueue(messages, 1), 1; got != want {
+		t.Errorf("bad queue length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestBrokerDisconnect(t *testing.T) {
+
+	broker := push.NewMemoryBroker()
+	a := push.New(push.DefaultOptions)
+	a.SetBroker(broker, "a")
+	b := push.New(push.DefaultOptions)
+	b.SetBroker(broker, "b")
+
+	messagesA, disconnectA := a.ConnectClient("u1", "s1", "")
+	defer disconnectA()
+	messagesB, disconnectB := b.ConnectClient("u1", "s1", "")
+	defer disconnectB()
+	otherB, disconnectOther := b.ConnectClient("u1", "s2", "")
+	defer disconnectOther()
+
+	// a revoked session is disconnected on every instance, the other sessions stay
+	a.DisconnectClient("u1", "s1")
+	for alias, messages := range map[string]<-chan model.PushMessage{"a": messagesA, "b": messagesB} {
+		select {
+		case _, ok := <-messages:
+			if ok {
+				t.Errorf("bad message on %s, expected closed", alias)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("client on %s not disconnected", alias)
+		}
+	}
+	if !b.IsClientConnected("u1", "s2") {
+		t.Error("other session disconnected")
+	}
+	if got, want := len(otherB), 0; got != want {
+		t.Errorf("bad queue length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestBrokerSharedReplayStore(t *testing.T) {
+
+	store := push.NewDatabaseReplayStore(&DatabaseMock{}, &ReplayRepositoryMock{Last: 42}, 10)
+	broker := push.NewMemoryBroker()
+	a := push.New(push.DefaultOptions)
+	a.SetBroker(broker, "a")
+	b := push.New(push.DefaultOptions)
+	b.SetBroker(broker, "b")
+	for _, messenger := range []*push.PushMessenger{a, b} {
+		if err := messenger.SetReplayStore(store); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messagesB, disconnectB := b.ConnectClient("u1", "s1", "")
+	defer disconnectB()
+
+	// both instances take the next number of the store
+	a.SendMessage(model.PushMessage{Data: "hello"}, "u1", "")
+	b.SendMessage(model.PushMessage{Data: "world"}, "u1", "")
+	if got, want := waitForQueue(messagesB, 2), 2; got != want {
+		t.Fatalf("bad queue length: %d, expected %d", got, want)
+	}
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		ids[(<-messagesB).ID] = true
+	}
+	if got, want := ids, map[string]bool{"43": true, "44": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad IDs %v, expected %v", got, want)
+	}
+
+	// stored once, by the sending instance
+	missed, disconnectReplay := b.ConnectClient("u1", "s2", "42")
+	defer disconnectReplay()
+	if got, want := len(missed), 2; got != want {
+		t.Errorf("bad replay length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestDatabaseBroker(t *testing.T) {
+
+	repo := &BrokerRepositoryMock{}
+	repo.AddBrokerMessage(context.Background(), nil, model.BrokerMessage{Origin: "a", Serial: 1}, time.Now())
+
+	a := push.NewDatabaseBroker(&DatabaseMock{}, repo, time.Millisecond*10)
+	defer a.Close()
+	b := push.NewDatabaseBroker(&DatabaseMock{}, repo, time.Millisecond*10)
+	defer b.Close()
+	received := make(chan model.BrokerMessage, 10)
+	b.Subscribe(func(msg model.BrokerMessage) {
+		received <- msg
+	})
+
+	sent := model.BrokerMessage{Origin: "a", Serial: 2, UserID: "u1", Message: model.PushMessage{ID: "43", Data: "hello"}}
+	if err := a.Publish(sent); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg != sent {
+			t.Errorf("bad message %v, expected %v", msg, sent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	// polled again while in the window, but received once, and the message published before the start never
+	time.Sleep(time.Millisecond * 50)
+	if got, want := len(received), 0; got != want {
+		t.Errorf("bad messages received: %d, expected %d", got, want)
+	}
+
+}
+
+// waitForQueue waits a while for the published messages to arrive and returns the length of the queue.
+func waitForQueue(queue <-chan model.PushMessage, length int) int {
+	for i := 0; i < 100 && len(queue) < length; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	return len(queue)
+}
+
+type BrokerRepositoryMock struct {
+	lock     sync.Mutex
+	messages []model.BrokerMessage
+	created  []time.Time
+}
+
+func (z *BrokerRepositoryMock) AddBrokerMessage(ctx context.Context, tx model.WriteOnlyTransaction, msg model.BrokerMessage, created time.Time) error {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.messages = append(z.messages, msg)
+	z.created = append(z.created, created)
+	return nil
+}
+
+func (z *BrokerRepositoryMock) ListBrokerMessages(ctx context.Context, tx model.ReadOnlyTransaction, since time.Time) ([]model.BrokerMessage, error) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	var messages []model.BrokerMessage
+	for i, msg := range z.messages {
+		if !z.created[i].Before(since) {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (z *BrokerRepositoryMock) DeleteBrokerMessages(ctx context.Context, tx model.WriteOnlyTransaction, before time.Time) error {
+	return nil
+}
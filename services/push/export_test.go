@@ -0,0 +1,8 @@
+package push
+
+// Users returns the number of users the store keeps messages for.
+func (z *MemoryReplayStore) Users() int {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	return len(z.rings)
+}
@@ -64,8 +64,9 @@ func (z *HeartbeatService) SendHeartbeat(t time.Time, userID, sessionID string)
 	}
 
 	hb := model.PushMessage{
-		Type: "heartbeat",
-		Data: string(data),
+		Type:      "heartbeat",
+		Data:      string(data),
+		Transient: true, // the next one replaces it
 	}
 
 	count := z.messageBus.SendMessage(hb, userID, sessionID)
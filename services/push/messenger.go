@@ -1,7 +1,12 @@
 package push
 
 import (
+	"errors"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -9,22 +14,134 @@ import (
 	"wallawire/model"
 )
 
-type MessageChannel chan model.PushMessage
-type SessionMap map[string]MessageChannel
+const (
+	// PolicyDropOldest discards the oldest queued message to make room for a new one.
+	PolicyDropOldest = "drop-oldest"
+	// PolicyDropNewest discards the new message.
+	PolicyDropNewest = "drop-newest"
+	// PolicyEvict disconnects the client.
+	PolicyEvict = "evict"
+
+	reasonReplaced     = "replaced by a new connection"
+	reasonSlowConsumer = "slow consumer"
+	seenSize           = 1024 // broker messages remembered to drop duplicates
+	publishQueueSize   = 1024 // messages waiting to be published to the broker
+)
+
+// Options configures the delivery to the clients.
+// Every client has a queue of QueueSize messages. Once it is full, the Policy decides what to drop,
+// and a client that has dropped MaxDropped messages in a row is disconnected (0 never with the drop policies).
+type Options struct {
+	QueueSize  int
+	Policy     string
+	MaxDropped int
+}
+
+var DefaultOptions = Options{
+	QueueSize:  64,
+	Policy:     PolicyDropOldest,
+	MaxDropped: 64,
+}
+
+func (z Options) Validate() error {
+	if z.QueueSize < 1 {
+		return errors.New("queue size must be at least 1")
+	}
+	if z.MaxDropped < 0 {
+		return errors.New("max dropped must not be negative")
+	}
+	switch z.Policy {
+	case PolicyDropOldest, PolicyDropNewest, PolicyEvict:
+		return nil
+	}
+	return errors.New("unknown policy " + z.Policy)
+}
+
+type SessionMap map[string]*client
 type UserMap map[string]SessionMap
 
+// TopicAuthorizer decides if the user session may subscribe to the topic.
+type TopicAuthorizer func(token model.SessionToken, topic string) bool
+
+// PushMessenger delivers messages to the inboxes of the connected user sessions.
+// Senders never wait for a client: messages are queued per client and the clients drain their queues themselves.
+// Every message that can be replayed gets a sequence number as ID, with a replay store the messages after the last ID
+// a client has seen are sent again when it reconnects. A replay store shared by the instances numbers the messages it stores.
+// Transient and live messages and messages to a topic have no ID, so that a client resumes after the last numbered message it has seen.
+// With a broker the messages are published to the other instances, which deliver them to their own clients.
+// Connected sessions can subscribe to registered topics, messages to a topic are delivered live and not replayed.
 type PushMessenger struct {
 	clientsLock          sync.RWMutex
 	clients              UserMap
+	topics               map[string]TopicAuthorizer
+	subscribers          map[string]map[*client]bool
 	onConnectTriggers    []func(userID, sessionID string)
 	onDisconnectTriggers []func(userID, sessionID string)
+	options              Options
+	replay               ReplayStore
+	broker               Broker
+	publishQueue         chan model.BrokerMessage
+	instanceID           string
+	seenLock             sync.Mutex
+	seen                 map[string]bool
+	seenOrder            []string
+	seq                  uint64
+	published            uint64
+	delivered            uint64
+	dropped              uint64
+	evicted              uint64
 	logger               *zerolog.Logger
 }
 
-func New() *PushMessenger {
+func New(options Options) *PushMessenger {
 	return &PushMessenger{
-		clients: make(UserMap),
-		logger:  logging.New(nil, "push"),
+		clients:     make(UserMap),
+		topics:      make(map[string]TopicAuthorizer),
+		subscribers: make(map[string]map[*client]bool),
+		options:     options,
+		seen:        make(map[string]bool),
+		seq:         uint64(time.Now().UnixNano() / int64(time.Microsecond)), // increases across restarts
+		logger:      logging.New(nil, "push"),
+	}
+}
+
+// SetReplayStore keeps the messages to replay them to reconnecting clients.
+// The sequence continues after the last message stored, with a shared store exactly there,
+// so that the messages numbered while the store fails follow the ones the store numbers.
+func (z *PushMessenger) SetReplayStore(store ReplayStore) error {
+	last, err := store.LastSeq()
+	if err != nil {
+		return err
+	}
+	z.clientsLock.Lock()
+	defer z.clientsLock.Unlock()
+	z.replay = store
+	if last > atomic.LoadUint64(&z.seq) || store.Shared() {
+		atomic.StoreUint64(&z.seq, last)
+	}
+	return nil
+}
+
+// SetBroker publishes the messages sent to the other instances and delivers the messages they publish.
+// The instanceID must be unique among the instances.
+// The messages are published in the background, in the order sent, so that senders never wait for the broker.
+func (z *PushMessenger) SetBroker(broker Broker, instanceID string) {
+	queue := make(chan model.BrokerMessage, publishQueueSize)
+	z.clientsLock.Lock()
+	z.broker = broker
+	z.publishQueue = queue
+	z.instanceID = instanceID
+	z.clientsLock.Unlock()
+	broker.Subscribe(z.receive)
+	go z.publish(broker, queue)
+}
+
+// publish hands the queued messages to the broker.
+func (z *PushMessenger) publish(broker Broker, queue <-chan model.BrokerMessage) {
+	for bm := range queue {
+		if err := broker.Publish(bm); err != nil {
+			z.logger.Warn().Err(err).Str("UserID", bm.UserID).Str("Topic", bm.Topic).Msg("cannot publish message")
+		}
 	}
 }
 
@@ -40,89 +157,578 @@ func (z *PushMessenger) AddOnClientDisconnectTrigger(fn func(userID, sessionID s
 	z.onDisconnectTriggers = append(z.onDisconnectTriggers, fn)
 }
 
-func (z *PushMessenger) ConnectClient(userID, sessionID string, messageChannel chan model.PushMessage) {
+// ConnectClient opens the inbox of the user session and returns its messages
+// and the function to close it, which the caller must call when done.
+// The messages are closed when the client is disconnected, either by the caller or by the server.
+// A previous connection of the same session is disconnected.
+// If lastEventID is the ID of a message, the stored messages after it are queued first.
+func (z *PushMessenger) ConnectClient(userID, sessionID, lastEventID string) (<-chan model.PushMessage, func()) {
+
+	lastSeq, err := strconv.ParseUint(lastEventID, 10, 64)
+
 	z.clientsLock.Lock()
-	defer z.clientsLock.Unlock()
+
+	replay := z.replay
+	if err != nil {
+		replay = nil
+	}
+
+	c := newClient(userID, sessionID, z.options.QueueSize)
+	c.replaying = replay != nil
 
 	sessionMap := z.clients[userID]
 	if sessionMap == nil {
 		sessionMap = make(SessionMap)
 		z.clients[userID] = sessionMap
 	}
-
-	sessionMap[sessionID] = messageChannel
-
+	previous := sessionMap[sessionID]
+	sessionMap[sessionID] = c
+	if previous != nil {
+		z.unsubscribeAll(previous)
+	}
 	for _, tr := range z.onConnectTriggers {
 		go tr(userID, sessionID)
 	}
+	z.clientsLock.Unlock()
+
+	if previous != nil {
+		previous.close(reasonReplaced)
+	}
+
+	// senders store a message before they look for its recipients, so each one is either replayed or sent live
+	replayed := 0
+	if replay != nil {
+		missed, err := replay.Since(userID, sessionID, lastSeq)
+		if err != nil {
+			z.logger.Warn().Err(err).Str("UserID", userID).Str("SessionID", sessionID).Msg("cannot replay messages")
+		}
+		replayed = c.replay(missed)
+	}
 
-	z.logger.Debug().Str("UserID", userID).Str("SessionID", sessionID).Msg("client connected")
+	z.logger.Debug().Str("UserID", userID).Str("SessionID", sessionID).Int("replayed", replayed).Msg("client connected")
+
+	return c.queue, func() {
+		z.disconnect(c, "")
+	}
 
 }
 
+// DisconnectClient closes the inbox of the user session, if connected, here and on the other instances.
 func (z *PushMessenger) DisconnectClient(userID, sessionID string) {
+	z.clientsLock.RLock()
+	c := z.clients[userID][sessionID]
+	queue, instanceID := z.publishQueue, z.instanceID
+	z.clientsLock.RUnlock()
+	if c != nil {
+		z.disconnect(c, "")
+	}
+	if queue != nil {
+		z.enqueue(queue, model.BrokerMessage{
+			Origin:    instanceID,
+			UserID:    userID,
+			SessionID: sessionID,
+			Control:   model.BrokerControlDisconnect,
+		})
+	}
+}
+
+// disconnect removes the client, unless replaced already, and closes it with the reason.
+func (z *PushMessenger) disconnect(c *client, reason string) {
+
+	z.clientsLock.Lock()
+	removed := false
+	if sessionMap := z.clients[c.userID]; sessionMap[c.sessionID] == c {
+		delete(sessionMap, c.sessionID)
+		if len(sessionMap) == 0 {
+			delete(z.clients, c.userID)
+		}
+		z.unsubscribeAll(c)
+		removed = true
+		for _, tr := range z.onDisconnectTriggers {
+			go tr(c.userID, c.sessionID)
+		}
+	}
+	z.clientsLock.Unlock()
+
+	c.close(reason)
+
+	if removed {
+		z.logger.Debug().Str("UserID", c.userID).Str("SessionID", c.sessionID).Str("reason", reason).Msg("client disconnected")
+	}
+
+}
+
+// IsClientConnected tests if the given user session currently has an open inbox.
+func (z *PushMessenger) IsClientConnected(userID, sessionID string) bool {
+	z.clientsLock.RLock()
+	defer z.clientsLock.RUnlock()
+	_, ok := z.clients[userID][sessionID]
+	return ok
+}
+
+// RegisterTopic allows the sessions to subscribe to the topic, if authorized. A nil authorizer allows everyone.
+func (z *PushMessenger) RegisterTopic(topic string, authorize TopicAuthorizer) {
 	z.clientsLock.Lock()
 	defer z.clientsLock.Unlock()
+	z.topics[topic] = authorize
+}
 
-	sessionMap := z.clients[userID]
-	if sessionMap != nil {
-		if messageChan, ok := sessionMap[sessionID]; ok {
-			close(messageChan)
+// Subscribe adds the connected session of the token to the subscribers of the topic,
+// until it unsubscribes or disconnects.
+// Unknown topics and sessions without an inbox are not found errors, unauthorized ones and API tokens forbidden errors.
+func (z *PushMessenger) Subscribe(token model.SessionToken, topic string) error {
+
+	if len(token.APITokenID) != 0 {
+		return model.NewForbiddenError("API tokens cannot subscribe to topics")
+	}
+
+	z.clientsLock.RLock()
+	authorize, ok := z.topics[topic]
+	z.clientsLock.RUnlock()
+	if !ok {
+		return model.NewNotFoundError("unknown topic " + topic)
+	}
+	if authorize != nil && !authorize(token, topic) {
+		return model.NewForbiddenError("forbidden topic " + topic)
+	}
+
+	z.clientsLock.Lock()
+	defer z.clientsLock.Unlock()
+	c := z.clients[token.ID][token.SessionID]
+	if c == nil {
+		return model.NewNotFoundError("inbox not connected")
+	}
+	subscribers := z.subscribers[topic]
+	if subscribers == nil {
+		subscribers = make(map[*client]bool)
+		z.subscribers[topic] = subscribers
+	}
+	subscribers[c] = true
+	c.topics[topic] = true
+	c.token = token
+
+	return nil
+
+}
+
+// UpdateToken replaces the token of a connected session with the one renewed with the current roles of the user.
+// The session is unsubscribed from the topics the renewed token is no longer authorized for.
+func (z *PushMessenger) UpdateToken(token model.SessionToken) {
+	z.clientsLock.Lock()
+	defer z.clientsLock.Unlock()
+	c := z.clients[token.ID][token.SessionID]
+	if c == nil {
+		return
+	}
+	c.token = token
+	for topic := range c.topics {
+		if authorize := z.topics[topic]; authorize != nil && !authorize(token, topic) {
+			z.unsubscribe(c, topic)
 		}
-		delete(sessionMap, sessionID)
 	}
+}
 
-	if len(sessionMap) == 0 {
-		delete(z.clients, userID)
+// UnsubscribeUser removes all sessions of the user from the subscribers of all topics,
+// for example when a role has been revoked. The sessions can subscribe again with a renewed token.
+func (z *PushMessenger) UnsubscribeUser(userID string) {
+	z.clientsLock.Lock()
+	defer z.clientsLock.Unlock()
+	for _, c := range z.clients[userID] {
+		z.unsubscribeAll(c)
 	}
+}
 
-	for _, tr := range z.onDisconnectTriggers {
-		go tr(userID, sessionID)
+// Unsubscribe removes the session from the subscribers of the topic, if subscribed.
+func (z *PushMessenger) Unsubscribe(userID, sessionID, topic string) {
+	z.clientsLock.Lock()
+	defer z.clientsLock.Unlock()
+	if c := z.clients[userID][sessionID]; c != nil {
+		z.unsubscribe(c, topic)
+	}
+}
+
+// Topics returns the topics the session has subscribed to, sorted.
+func (z *PushMessenger) Topics(userID, sessionID string) []string {
+	z.clientsLock.RLock()
+	defer z.clientsLock.RUnlock()
+	topics := []string{}
+	if c := z.clients[userID][sessionID]; c != nil {
+		for topic := range c.topics {
+			topics = append(topics, topic)
+		}
 	}
+	sort.Strings(topics)
+	return topics
+}
 
-	z.logger.Debug().Str("UserID", userID).Str("SessionID", sessionID).Msg("client disconnected")
+// unsubscribe must be called with the write lock held.
+func (z *PushMessenger) unsubscribe(c *client, topic string) {
+	delete(c.topics, topic)
+	if subscribers := z.subscribers[topic]; subscribers != nil {
+		delete(subscribers, c)
+		if len(subscribers) == 0 {
+			delete(z.subscribers, topic)
+		}
+	}
+}
 
+// unsubscribeAll must be called with the write lock held.
+func (z *PushMessenger) unsubscribeAll(c *client) {
+	for topic := range c.topics {
+		z.unsubscribe(c, topic)
+	}
 }
 
 // SendMessage will send a message to all connected users if userID and sessionID are empty.
 // It will send to all sessions of a specific user if sessionID is empty
 // and to a specific user session if all three arguments are given.
+// It returns the number of clients of this instance the message was queued for and does not wait for any of them.
+// The ID of the message is replaced by the next sequence number, or removed if the message is transient or live.
+// A message with data longer than model.PushDataMaxLength is dropped.
 func (z *PushMessenger) SendMessage(msg model.PushMessage, userID, sessionID string) int {
+	return z.send(msg, userID, sessionID, "")
+}
+
+// PublishToTopic sends the message to the sessions subscribed to the topic.
+// It returns the number of clients of this instance the message was queued for.
+func (z *PushMessenger) PublishToTopic(topic string, msg model.PushMessage) int {
+	return z.send(msg, "", "", topic)
+}
+
+func (z *PushMessenger) send(msg model.PushMessage, userID, sessionID, topic string) int {
+
+	if len(msg.Data) > model.PushDataMaxLength {
+		z.logger.Warn().Str("UserID", userID).Str("Topic", topic).Str("Type", msg.Type).Int("length", len(msg.Data)).Msg("message too long, dropped")
+		return 0
+	}
 
 	z.clientsLock.RLock()
-	defer z.clientsLock.RUnlock()
+	replay, queue, instanceID := z.replay, z.publishQueue, z.instanceID
+	z.clientsLock.RUnlock()
 
-	counter := 0
+	// only the messages that can be replayed take a number, a client resumes after the last one it has seen
+	msg.ID = ""
+	if !msg.Transient && !msg.Live && topic == "" {
+		seq := atomic.AddUint64(&z.seq, 1)
+		msg.ID = strconv.FormatUint(seq, 10)
+		if replay != nil {
+			msg.ID = strconv.FormatUint(z.store(replay, msg, seq, userID, sessionID), 10)
+		}
+	}
+
+	counter := z.dispatch(msg, userID, sessionID, topic)
+
+	if queue != nil && !msg.Transient {
+		z.enqueue(queue, model.BrokerMessage{
+			Origin:    instanceID,
+			UserID:    userID,
+			SessionID: sessionID,
+			Topic:     topic,
+			Message:   msg,
+		})
+	}
+
+	return counter
+
+}
+
+// enqueue numbers the message and queues it to be published without waiting, it is dropped if the queue is full.
+func (z *PushMessenger) enqueue(queue chan<- model.BrokerMessage, bm model.BrokerMessage) {
+	bm.Serial = atomic.AddUint64(&z.published, 1)
+	select {
+	case queue <- bm:
+	default:
+		z.logger.Warn().Str("UserID", bm.UserID).Str("Topic", bm.Topic).Msg("cannot publish message, queue full")
+	}
+}
+
+// receive delivers a message published by another instance, once.
+func (z *PushMessenger) receive(bm model.BrokerMessage) {
+
+	z.clientsLock.RLock()
+	replay, instanceID := z.replay, z.instanceID
+	z.clientsLock.RUnlock()
+	if bm.Origin == instanceID {
+		return // delivered when sent
+	}
+	if !z.markSeen(bm.Origin + "/" + strconv.FormatUint(bm.Serial, 10)) {
+		return
+	}
+
+	if bm.Control == model.BrokerControlDisconnect {
+		z.clientsLock.RLock()
+		c := z.clients[bm.UserID][bm.SessionID]
+		z.clientsLock.RUnlock()
+		if c != nil {
+			z.disconnect(c, "")
+		}
+		return
+	}
+
+	// messages to a topic are not numbered
+	if len(bm.Message.ID) != 0 {
+		seq, err := strconv.ParseUint(bm.Message.ID, 10, 64)
+		if err != nil {
+			z.logger.Warn().Err(err).Str("Origin", bm.Origin).Str("ID", bm.Message.ID).Msg("bad message id")
+			return
+		}
+		z.advance(seq)
+		// a shared store has the message from the sending instance
+		if replay != nil && !replay.Shared() {
+			z.store(replay, bm.Message, seq, bm.UserID, bm.SessionID)
+		}
+	}
 
-	if userID == "" {
+	z.dispatch(bm.Message, bm.UserID, bm.SessionID, bm.Topic)
+
+}
+
+// advance keeps the sequence at least at seq, so that the IDs seen by a client keep increasing
+// when the IDs come from the other instances or the store.
+func (z *PushMessenger) advance(seq uint64) {
+	for {
+		current := atomic.LoadUint64(&z.seq)
+		if seq <= current || atomic.CompareAndSwapUint64(&z.seq, current, seq) {
+			break
+		}
+	}
+}
+
+// store keeps the message for replay and returns its sequence number.
+// The message is stored before it is dispatched, so that a client connecting in between replays it.
+func (z *PushMessenger) store(replay ReplayStore, msg model.PushMessage, seq uint64, userID, sessionID string) uint64 {
+
+	stored, err := replay.Add(model.ReplayMessage{
+		Seq:       seq,
+		UserID:    userID,
+		SessionID: sessionID,
+		Created:   time.Now().UTC(),
+		Message:   msg,
+	})
+	if err != nil {
+		z.logger.Warn().Err(err).Str("UserID", userID).Msg("cannot store message for replay")
+		return seq
+	}
+
+	z.advance(stored)
+	return stored
+
+}
+
+// markSeen remembers the key of the last broker messages, it returns false if the key has been seen already.
+func (z *PushMessenger) markSeen(key string) bool {
+	z.seenLock.Lock()
+	defer z.seenLock.Unlock()
+	if z.seen[key] {
+		return false
+	}
+	z.seen[key] = true
+	z.seenOrder = append(z.seenOrder, key)
+	if len(z.seenOrder) > seenSize {
+		delete(z.seen, z.seenOrder[0])
+		z.seenOrder = z.seenOrder[1:]
+	}
+	return true
+}
+
+// dispatch queues the message for the connected clients of this instance.
+func (z *PushMessenger) dispatch(msg model.PushMessage, userID, sessionID, topic string) int {
+
+	var recipients []*client
+
+	z.clientsLock.RLock()
+	if topic != "" {
+		// subscribers still authorized
+		authorize := z.topics[topic]
+		for c := range z.subscribers[topic] {
+			if authorize == nil || authorize(c.token, topic) {
+				recipients = append(recipients, c)
+			}
+		}
+	} else if userID == "" {
 		// all
 		for _, sessionMap := range z.clients {
-			for _, messageChannel := range sessionMap {
-				messageChannel <- msg
-				counter += 1
+			for _, c := range sessionMap {
+				recipients = append(recipients, c)
 			}
 		}
-		// z.logger.Debug().Interface("message", msg).Int("count", counter).Msg("sent message")
 	} else if sessionID == "" {
 		// all sessions for user
-		if sessionMap, ok := z.clients[userID]; ok {
-			for _, messageChannel := range sessionMap {
-				messageChannel <- msg
-				counter += 1
-			}
+		for _, c := range z.clients[userID] {
+			recipients = append(recipients, c)
 		}
-		// z.logger.Debug().Interface("message", msg).Str("UserID", userID).Int("count", counter).Msg("sent message")
-	} else {
+	} else if c, ok := z.clients[userID][sessionID]; ok {
 		// single session
-		if sessionMap, ok := z.clients[userID]; ok {
-			if messageChannel, ok2 := sessionMap[sessionID]; ok2 {
-				messageChannel <- msg
-				counter += 1
-			}
+		recipients = append(recipients, c)
+	}
+	z.clientsLock.RUnlock()
+
+	counter := 0
+	for _, c := range recipients {
+		queued, dropped, evict := c.deliver(msg, z.options)
+		if queued {
+			counter += 1
+			atomic.AddUint64(&z.delivered, 1)
+		}
+		if dropped {
+			atomic.AddUint64(&z.dropped, 1)
+		}
+		if evict {
+			atomic.AddUint64(&z.evicted, 1)
+			z.logger.Info().Str("UserID", c.userID).Str("SessionID", c.sessionID).Msg("evicting slow client")
+			z.disconnect(c, reasonSlowConsumer)
 		}
-		// z.logger.Debug().Interface("message", msg).Str("UserID", userID).Str("sessionID", sessionID).Int("count", counter).Msg("sent message")
 	}
 
 	return counter
 
 }
+
+// Stats returns the number of connected clients and the delivery counters.
+func (z *PushMessenger) Stats() model.PushStats {
+	z.clientsLock.RLock()
+	clients := 0
+	for _, sessionMap := range z.clients {
+		clients += len(sessionMap)
+	}
+	z.clientsLock.RUnlock()
+	return model.PushStats{
+		Clients:   clients,
+		Delivered: atomic.LoadUint64(&z.delivered),
+		Dropped:   atomic.LoadUint64(&z.dropped),
+		Evicted:   atomic.LoadUint64(&z.evicted),
+	}
+}
+
+// client is the inbox of a connected user session.
+type client struct {
+	lock      sync.Mutex // serializes deliver and close
+	userID    string
+	sessionID string
+	queue     chan model.PushMessage
+	topics    map[string]bool    // guarded by the lock of the messenger
+	token     model.SessionToken // of the last subscription, guarded by the lock of the messenger
+	replaying bool               // live messages are held back in pending until the replay is queued
+	pending   []model.PushMessage
+	dropped   int // in a row
+	closed    bool
+}
+
+func newClient(userID, sessionID string, queueSize int) *client {
+	return &client{
+		userID:    userID,
+		sessionID: sessionID,
+		queue:     make(chan model.PushMessage, queueSize),
+		topics:    make(map[string]bool),
+	}
+}
+
+// deliver queues the message without waiting.
+// A full queue is resolved by the policy, evict tells the messenger to disconnect the client.
+func (z *client) deliver(msg model.PushMessage, options Options) (queued, dropped, evict bool) {
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	if z.closed {
+		return false, false, false
+	}
+
+	if z.replaying {
+		// the queue is not handed out yet, keep the newest ones
+		z.pending = append(z.pending, msg)
+		if len(z.pending) > options.QueueSize {
+			z.pending = z.pending[1:]
+			return true, true, false
+		}
+		return true, false, false
+	}
+
+	select {
+	case z.queue <- msg:
+		z.dropped = 0
+		return true, false, false
+	default:
+	}
+
+	switch options.Policy {
+	case PolicyEvict:
+		return false, false, true
+	case PolicyDropOldest:
+		// only the consumer receives concurrently, so there is room after this
+		select {
+		case <-z.queue:
+		default:
+		}
+		z.queue <- msg
+		queued = true
+	}
+
+	dropped = true
+	z.dropped++
+	evict = options.MaxDropped > 0 && z.dropped >= options.MaxDropped
+	return queued, dropped, evict
+
+}
+
+// replay queues the missed messages before the live ones held back meanwhile, which may have been stored as well.
+// It returns the number of missed messages queued.
+func (z *client) replay(missed []model.PushMessage) int {
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	z.replaying = false
+	pending := z.pending
+	z.pending = nil
+
+	if z.closed {
+		return 0
+	}
+
+	replayed := make(map[model.PushMessage]bool, len(missed)) // a message not stored may have the ID of a stored one
+	queue := make(chan model.PushMessage, cap(z.queue)+len(missed))
+	for _, msg := range missed {
+		replayed[msg] = true
+		queue <- msg
+	}
+	for _, msg := range pending {
+		if !replayed[msg] {
+			queue <- msg
+		}
+	}
+	z.queue = queue
+
+	return len(missed)
+
+}
+
+// close replaces the queued messages by a disconnect message with the reason, if any, and closes the queue.
+func (z *client) close(reason string) {
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	if z.closed {
+		return
+	}
+	z.closed = true
+
+	if len(reason) != 0 {
+	Drain:
+		for {
+			select {
+			case <-z.queue:
+			default:
+				break Drain
+			}
+		}
+		z.queue <- model.PushMessage{
+			Type: model.PushTypeDisconnect,
+			Data: reason,
+		}
+	}
+
+	close(z.queue)
+
+}
@@ -0,0 +1,513 @@
+package push_test
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"wallawire/model"
+	"wallawire/services/push"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSendMessage(b *testing.T) {
+
+	testCases := []struct {
+		Alias     string
+		UserID    string
+		SessionID string
+		Expected  map[string]int
+	}{
+		{
+			Alias:    "all",
+			Expected: map[string]int{"u1/s1": 1, "u1/s2": 1, "u2/s3": 1},
+		},
+		{
+			Alias:    "user",
+			UserID:   "u1",
+			Expected: map[string]int{"u1/s1": 1, "u1/s2": 1, "u2/s3": 0},
+		},
+		{
+			Alias:     "session",
+			UserID:    "u1",
+			SessionID: "s2",
+			Expected:  map[string]int{"u1/s1": 0, "u1/s2": 1, "u2/s3": 0},
+		},
+		{
+			Alias:     "not connected",
+			UserID:    "u3",
+			SessionID: "s4",
+			Expected:  map[string]int{"u1/s1": 0, "u1/s2": 0, "u2/s3": 0},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			messenger := push.New(push.DefaultOptions)
+			inboxes := make(map[string]<-chan model.PushMessage)
+			for _, key := range []string{"u1/s1", "u1/s2", "u2/s3"} {
+				messages, disconnect := messenger.ConnectClient(key[:2], key[3:], "")
+				defer disconnect()
+				inboxes[key] = messages
+			}
+
+			total := 0
+			for _, n := range tCase.Expected {
+				total += n
+			}
+			if got, want := messenger.SendMessage(model.PushMessage{Data: "hello"}, tCase.UserID, tCase.SessionID), total; got != want {
+				t.Errorf("bad count %d, expected %d", got, want)
+			}
+			for key, messages := range inboxes {
+				if got, want := len(messages), tCase.Expected[key]; got != want {
+					t.Errorf("bad queue length %s: %d, expected %d", key, got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestOverflowPolicies(b *testing.T) {
+
+	testCases := []struct {
+		Alias           string
+		Options         push.Options
+		Sent            int
+		ExpectedData    []string
+		ExpectedStats   model.PushStats
+		ExpectConnected bool
+	}{
+		{
+			Alias:           "drop oldest",
+			Options:         push.Options{QueueSize: 2, Policy: push.PolicyDropOldest},
+			Sent:            4,
+			ExpectedData:    []string{"2", "3"},
+			ExpectedStats:   model.PushStats{Clients: 1, Delivered: 4, Dropped: 2},
+			ExpectConnected: true,
+		},
+		{
+			Alias:           "drop newest",
+			Options:         push.Options{QueueSize: 2, Policy: push.PolicyDropNewest},
+			Sent:            4,
+			ExpectedData:    []string{"0", "1"},
+			ExpectedStats:   model.PushStats{Clients: 1, Delivered: 2, Dropped: 2},
+			ExpectConnected: true,
+		},
+		{
+			Alias:         "evict",
+			Options:       push.Options{QueueSize: 2, Policy: push.PolicyEvict},
+			Sent:          4,
+			ExpectedData:  []string{"slow consumer"},
+			ExpectedStats: model.PushStats{Clients: 0, Delivered: 2, Evicted: 1},
+		},
+		{
+			Alias:         "evict after max dropped",
+			Options:       push.Options{QueueSize: 2, Policy: push.PolicyDropNewest, MaxDropped: 2},
+			Sent:          5,
+			ExpectedData:  []string{"slow consumer"},
+			ExpectedStats: model.PushStats{Clients: 0, Delivered: 2, Dropped: 2, Evicted: 1},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			messenger := push.New(tCase.Options)
+			messages, disconnect := messenger.ConnectClient("u1", "s1", "")
+
+			for i := 0; i < tCase.Sent; i++ {
+				messenger.SendMessage(model.PushMessage{Data: strconv.Itoa(i)}, "", "")
+			}
+
+			if got, want := messenger.IsClientConnected("u1", "s1"), tCase.ExpectConnected; got != want {
+				t.Errorf("connected %t, expected %t", got, want)
+			}
+			if got, want := messenger.Stats(), tCase.ExpectedStats; got != want {
+				t.Errorf("bad stats %+v, expected %+v", got, want)
+			}
+
+			disconnect()
+			var data []string
+			for msg := range messages {
+				data = append(data, msg.Data)
+			}
+			if got, want := len(data), len(tCase.ExpectedData); got != want {
+				t.Fatalf("bad messages %v, expected %v", data, tCase.ExpectedData)
+			}
+			for i := range data {
+				if got, want := data[i], tCase.ExpectedData[i]; got != want {
+					t.Errorf("bad message %d: %s, expected %s", i, got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestReconnect(t *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	first, disconnectFirst := messenger.ConnectClient("u1", "s1", "")
+	second, disconnectSecond := messenger.ConnectClient("u1", "s1", "")
+	defer disconnectSecond()
+
+	msg, ok := <-first
+	if !ok || msg.Type != model.PushTypeDisconnect || msg.Data != "replaced by a new connection" {
+		t.Errorf("bad disconnect message %+v", msg)
+	}
+	if _, ok := <-first; ok {
+		t.Errorf("first connection not closed")
+	}
+
+	// the late disconnect of the first connection must not affect the second
+	disconnectFirst()
+	if !messenger.IsClientConnected("u1", "s1") {
+		t.Fatalf("second connection disconnected")
+	}
+	if got, want := messenger.SendMessage(model.PushMessage{Data: "hello"}, "u1", "s1"), 1; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := (<-second).Data, "hello"; got != want {
+		t.Errorf("bad message %s, expected %s", got, want)
+	}
+
+}
+
+func TestDisconnectClient(t *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	disconnected := make(chan string, 1)
+	messenger.AddOnClientDisconnectTrigger(func(userID, sessionID string) {
+		disconnected <- userID + "/" + sessionID
+	})
+
+	messages, disconnect := messenger.ConnectClient("u1", "s1", "")
+	messenger.DisconnectClient("u1", "s1")
+	disconnect() // idempotent
+
+	if _, ok := <-messages; ok {
+		t.Errorf("messages not closed")
+	}
+	if got, want := <-disconnected, "u1/s1"; got != want {
+		t.Errorf("bad trigger %s, expected %s", got, want)
+	}
+	if got, want := messenger.Stats().Clients, 0; got != want {
+		t.Errorf("bad clients %d, expected %d", got, want)
+	}
+
+}
+
+func TestSendMessageTooLong(t *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	messages, disconnect := messenger.ConnectClient("u1", "s1", "")
+	defer disconnect()
+
+	data := strings.Repeat("x", model.PushDataMaxLength+1)
+	if got, want := messenger.SendMessage(model.PushMessage{Data: data}, "u1", ""), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := messenger.SendMessage(model.PushMessage{Data: data[1:]}, "u1", ""), 1; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := len(messages), 1; got != want {
+		t.Errorf("bad queue length %d, expected %d", got, want)
+	}
+
+}
+
+// TestStalledClient tests that a client that never reads neither blocks the senders nor the other clients.
+func TestStalledClient(t *testing.T) {
+
+	const (
+		readers  = 50
+		senders  = 4
+		messages = 200
+	)
+
+	messenger := push.New(push.Options{QueueSize: 8, Policy: push.PolicyDropOldest, MaxDropped: 16})
+
+	_, disconnectStalled := messenger.ConnectClient("stalled", "s0", "")
+	defer disconnectStalled()
+
+	var wgReaders sync.WaitGroup
+	received := make([]int, readers)
+	for i := 0; i < readers; i++ {
+		inbox, disconnect := messenger.ConnectClient("u"+strconv.Itoa(i), "s"+strconv.Itoa(i), "")
+		wgReaders.Add(1)
+		go func(i int) {
+			defer wgReaders.Done()
+			defer disconnect()
+			for msg := range inbox {
+				if msg.Type == model.PushTypeDisconnect {
+					continue
+				}
+				received[i]++
+				if received[i] == senders*messages {
+					return
+				}
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var wgSenders sync.WaitGroup
+		for s := 0; s < senders; s++ {
+			wgSenders.Add(1)
+			go func() {
+				defer wgSenders.Done()
+				for m := 0; m < messages; m++ {
+					messenger.SendMessage(model.PushMessage{Data: "x"}, "", "")
+					if m%8 == 0 {
+						time.Sleep(time.Millisecond) // let the readers keep up
+					}
+				}
+			}()
+		}
+		wgSenders.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("senders blocked")
+	}
+
+	if messenger.IsClientConnected("stalled", "s0") {
+		t.Errorf("stalled client not evicted")
+	}
+	stats := messenger.Stats()
+	if stats.Evicted == 0 || stats.Dropped == 0 {
+		t.Errorf("bad stats %+v", stats)
+	}
+
+	messenger.SendMessage(model.PushMessage{Type: "end"}, "", "") // readers that lost messages stop at disconnect
+	for i := 0; i < readers; i++ {
+		messenger.DisconnectClient("u"+strconv.Itoa(i), "s"+strconv.Itoa(i))
+	}
+	wgReaders.Wait()
+
+}
+
+// TestConcurrentClients connects, sends and disconnects from many goroutines at once, run it with -race.
+func TestConcurrentClients(t *testing.T) {
+
+	const clients = 100
+
+	messenger := push.New(push.Options{QueueSize: 4, Policy: push.PolicyEvict})
+	messenger.AddOnClientConnectTrigger(func(userID, sessionID string) {
+		messenger.SendMessage(model.PushMessage{Type: "heartbeat"}, userID, sessionID)
+	})
+
+	stop := make(chan struct{})
+	var wgSenders sync.WaitGroup
+	for s := 0; s < 4; s++ {
+		wgSenders.Add(1)
+		go func(s int) {
+			defer wgSenders.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				messenger.SendMessage(model.PushMessage{Data: "all"}, "", "")
+				messenger.SendMessage(model.PushMessage{Data: "user"}, "u"+strconv.Itoa(s), "")
+				messenger.Stats()
+			}
+		}(s)
+	}
+
+	var wgClients sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wgClients.Add(1)
+		go func(i int) {
+			defer wgClients.Done()
+			userID, sessionID := "u"+strconv.Itoa(i%10), "s"+strconv.Itoa(i)
+			for round := 0; round < 5; round++ {
+				inbox, disconnect := messenger.ConnectClient(userID, sessionID, "")
+				for n := 0; n < 10; n++ {
+					if _, ok := <-inbox; !ok {
+						break
+					}
+				}
+				if i%3 == 0 {
+					messenger.DisconnectClient(userID, sessionID)
+				}
+				disconnect()
+				for range inbox {
+				}
+			}
+		}(i)
+	}
+
+	wgClients.Wait()
+	close(stop)
+	wgSenders.Wait()
+
+	if got, want := messenger.Stats().Clients, 0; got != want {
+		t.Errorf("bad clients %d, expected %d", got, want)
+	}
+
+}
+
+func TestTopics(t *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	if err := messenger.SetReplayStore(push.NewMemoryReplayStore(10)); err != nil {
+		t.Fatal(err)
+	}
+	messenger.RegisterTopic("news", nil)
+	messenger.RegisterTopic("secret", func(token model.SessionToken, topic string) bool {
+		return token.HasRole("admin")
+	})
+
+	user := model.SessionToken{ID: "u1", SessionID: "s1"}
+	admin := model.SessionToken{ID: "u2", SessionID: "s2", Roles: []string{"admin"}}
+	other := model.SessionToken{ID: "u3", SessionID: "s3"}
+
+	if err := messenger.Subscribe(user, "news"); !model.IsNotFoundError(err) {
+		t.Errorf("bad error for a session without inbox: %v", err)
+	}
+
+	messagesUser, disconnectUser := messenger.ConnectClient(user.ID, user.SessionID, "")
+	defer disconnectUser()
+	messagesAdmin, disconnectAdmin := messenger.ConnectClient(admin.ID, admin.SessionID, "")
+	defer disconnectAdmin()
+	messagesOther, disconnectOther := messenger.ConnectClient(other.ID, other.SessionID, "")
+	defer disconnectOther()
+
+	if err := messenger.Subscribe(user, "unknown"); !model.IsNotFoundError(err) {
+		t.Errorf("bad error for an unknown topic: %v", err)
+	}
+	if err := messenger.Subscribe(user, "secret"); !model.IsForbiddenError(err) {
+		t.Errorf("bad error for a forbidden topic: %v", err)
+	}
+	if err := messenger.Subscribe(model.SessionToken{ID: user.ID, APITokenID: "t1"}, "news"); !model.IsForbiddenError(err) {
+		t.Errorf("bad error for an API token: %v", err)
+	}
+	for _, token := range []model.SessionToken{user, admin} {
+		if err := messenger.Subscribe(token, "news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := messenger.Subscribe(admin, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := messenger.Topics(admin.ID, admin.SessionID), []string{"news", "secret"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad topics %v, expected %v", got, want)
+	}
+
+	if got, want := messenger.PublishToTopic("news", model.PushMessage{Data: "hello"}), 2; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := messenger.PublishToTopic("secret", model.PushMessage{Data: "hush"}), 1; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := len(messagesUser), 1; got != want {
+		t.Errorf("bad queue length user: %d, expected %d", got, want)
+	}
+	if got, want := len(messagesAdmin), 2; got != want {
+		t.Errorf("bad queue length admin: %d, expected %d", got, want)
+	}
+	if got, want := len(messagesOther), 0; got != want {
+		t.Errorf("bad queue length other: %d, expected %d", got, want)
+	}
+
+	// unsubscribed, disconnected and reconnected sessions no longer receive the topic
+	messenger.Unsubscribe(admin.ID, admin.SessionID, "news")
+	disconnectUser()
+	_, disconnectReconnected := messenger.ConnectClient(admin.ID, admin.SessionID, "")
+	defer disconnectReconnected()
+	if got, want := messenger.PublishToTopic("news", model.PushMessage{Data: "again"}), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	if got, want := messenger.PublishToTopic("secret", model.PushMessage{Data: "again"}), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+
+	// topic messages are not replayed
+	missed, disconnectReplay := messenger.ConnectClient(other.ID, other.SessionID, "0")
+	defer disconnectReplay()
+	if got, want := len(missed), 0; got != want {
+		t.Errorf("bad replay length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestTopicsRoleChange(t *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	open := true
+	messenger.RegisterTopic("news", func(token model.SessionToken, topic string) bool {
+		return open
+	})
+	messenger.RegisterTopic("secret", func(token model.SessionToken, topic string) bool {
+		return token.HasRole("admin")
+	})
+
+	admin := model.SessionToken{ID: "u1", SessionID: "s1", Roles: []string{"admin"}}
+	messages, disconnect := messenger.ConnectClient(admin.ID, admin.SessionID, "")
+	defer disconnect()
+	for _, topic := range []string{"news", "secret"} {
+		if err := messenger.Subscribe(admin, topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the authorizer is asked again at delivery
+	open = false
+	if got, want := messenger.PublishToTopic("news", model.PushMessage{Data: "hello"}), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+	open = true
+
+	// a renewed token without the role drops the topic
+	messenger.UpdateToken(model.SessionToken{ID: admin.ID, SessionID: admin.SessionID, Roles: []string{"user"}})
+	if got, want := messenger.Topics(admin.ID, admin.SessionID), []string{"news"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad topics %v, expected %v", got, want)
+	}
+	if got, want := messenger.PublishToTopic("secret", model.PushMessage{Data: "hush"}), 0; got != want {
+		t.Errorf("bad count %d, expected %d", got, want)
+	}
+
+	// a revoked role drops all topics
+	messenger.UnsubscribeUser(admin.ID)
+	if got, want := messenger.Topics(admin.ID, admin.SessionID), []string{}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad topics %v, expected %v", got, want)
+	}
+	if got, want := len(messages), 0; got != want {
+		t.Errorf("bad queue length: %d, expected %d", got, want)
+	}
+
+}
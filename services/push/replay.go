@@ -0,0 +1,192 @@
+package push
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// ReplayStore keeps the last messages per user, so that a client reconnecting with the ID
+// of the last message it has seen can be sent the messages it missed.
+type ReplayStore interface {
+	// Add stores the message and returns its sequence number, which a shared store assigns itself.
+	Add(msg model.ReplayMessage) (uint64, error)
+	// Since returns the messages to the user session after the given sequence number, oldest first.
+	Since(userID, sessionID string, seq uint64) ([]model.PushMessage, error)
+	// LastSeq returns the highest sequence number stored, so that a restarted messenger continues after it.
+	LastSeq() (uint64, error)
+	// Shared tells if the instances store their messages in the same place,
+	// then each one stores the messages it sends only, with the sequence number given by the store.
+	Shared() bool
+}
+
+// messages older than ReplayMaxAge are not replayed, the MemoryReplayStore drops them every replaySweepInterval
+var ReplayMaxAge = time.Hour
+
+const replaySweepInterval = time.Minute
+
+// MemoryReplayStore keeps a ring of the last messages per user, and one for the messages to all users.
+// The rings of the users without messages in the last ReplayMaxAge are removed.
+type MemoryReplayStore struct {
+	lock  sync.Mutex
+	size  int
+	rings map[string][]model.ReplayMessage
+	last  uint64
+	swept time.Time
+}
+
+func NewMemoryReplayStore(size int) *MemoryReplayStore {
+	return &MemoryReplayStore{
+		size:  size,
+		rings: make(map[string][]model.ReplayMessage),
+	}
+}
+
+func (z *MemoryReplayStore) Add(msg model.ReplayMessage) (uint64, error) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if msg.Created.Sub(z.swept) >= replaySweepInterval {
+		z.sweep(msg.Created.Add(-ReplayMaxAge))
+		z.swept = msg.Created
+	}
+	ring := append(z.rings[msg.UserID], msg)
+	if len(ring) > z.size {
+		ring = ring[len(ring)-z.size:]
+	}
+	z.rings[msg.UserID] = ring
+	if msg.Seq > z.last {
+		z.last = msg.Seq
+	}
+	return msg.Seq, nil
+}
+
+// sweep drops the messages created before the given time and the rings left empty.
+func (z *MemoryReplayStore) sweep(before time.Time) {
+	for key, ring := range z.rings {
+		i := 0
+		for i < len(ring) && ring[i].Created.Before(before) {
+			i++
+		}
+		if i == len(ring) {
+			delete(z.rings, key)
+		} else if i > 0 {
+			z.rings[key] = append([]model.ReplayMessage(nil), ring[i:]...)
+		}
+	}
+}
+
+func (z *MemoryReplayStore) Since(userID, sessionID string, seq uint64) ([]model.PushMessage, error) {
+
+	oldest := time.Now().Add(-ReplayMaxAge)
+
+	z.lock.Lock()
+	var matches []model.ReplayMessage
+	for _, key := range []string{userID, ""} {
+		for _, msg := range z.rings[key] {
+			if msg.Seq > seq && !msg.Created.Before(oldest) && (msg.SessionID == "" || msg.SessionID == sessionID) {
+				matches = append(matches, msg)
+			}
+		}
+		if userID == "" {
+			break
+		}
+	}
+	z.lock.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Seq < matches[j].Seq
+	})
+
+	result := make([]model.PushMessage, len(matches))
+	for i, msg := range matches {
+		result[i] = msg.Message
+	}
+	return result, nil
+
+}
+
+func (z *MemoryReplayStore) LastSeq() (uint64, error) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	return z.last, nil
+}
+
+func (z *MemoryReplayStore) Shared() bool {
+	return false
+}
+
+type ReplayRepository interface {
+	AddPushMessage(ctx context.Context, tx model.Transaction, msg model.ReplayMessage, keep int) (uint64, error)
+	ListPushMessages(ctx context.Context, tx model.ReadOnlyTransaction, userID, sessionID string, seq uint64) ([]model.ReplayMessage, error)
+	GetLastPushMessageSeq(ctx context.Context, tx model.ReadOnlyTransaction) (uint64, error)
+}
+
+// DatabaseReplayStore keeps the last messages per user in the database, so that they survive a restart.
+// The database is shared by the instances and numbers the messages.
+type DatabaseReplayStore struct {
+	db   model.Database
+	repo ReplayRepository
+	size int
+}
+
+func NewDatabaseReplayStore(db model.Database, repo ReplayRepository, size int) *DatabaseReplayStore {
+	return &DatabaseReplayStore{
+		db:   db,
+		repo: repo,
+		size: size,
+	}
+}
+
+func (z *DatabaseReplayStore) Add(msg model.ReplayMessage) (uint64, error) {
+	ctx := context.Background()
+	var seq uint64
+	err := z.db.Run(func(tx model.Transaction) error {
+		s, err := z.repo.AddPushMessage(ctx, tx, msg, z.size)
+		seq = s
+		return err
+	})
+	return seq, err
+}
+
+func (z *DatabaseReplayStore) Since(userID, sessionID string, seq uint64) ([]model.PushMessage, error) {
+
+	ctx := context.Background()
+	logger := logging.New(ctx, "push", "DatabaseReplayStore.Since")
+
+	var stored []model.ReplayMessage
+	err := z.db.Run(func(tx model.Transaction) error {
+		messages, err := z.repo.ListPushMessages(ctx, tx, userID, sessionID, seq)
+		stored = messages
+		return err
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("cannot list push messages")
+		return nil, err
+	}
+
+	result := make([]model.PushMessage, len(stored))
+	for i, msg := range stored {
+		result[i] = msg.Message
+	}
+	return result, nil
+
+}
+
+func (z *DatabaseReplayStore) LastSeq() (uint64, error) {
+	ctx := context.Background()
+	var seq uint64
+	err := z.db.Run(func(tx model.Transaction) error {
+		s, err := z.repo.GetLastPushMessageSeq(ctx, tx)
+		seq = s
+		return err
+	})
+	return seq, err
+}
+
+func (z *DatabaseReplayStore) Shared() bool {
+	return true
+}
@@ -0,0 +1,282 @@
+package push_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services/push"
+)
+
+func TestReplay(b *testing.T) {
+
+	messenger := push.New(push.DefaultOptions)
+	if err := messenger.SetReplayStore(push.NewMemoryReplayStore(3)); err != nil {
+		b.Fatalf("cannot set replay store: %s", err)
+	}
+
+	// the first connection sees the first message, then disconnects
+	inbox, disconnect := messenger.ConnectClient("u1", "s1", "")
+	messenger.SendMessage(model.PushMessage{Data: "seen"}, "u1", "")
+	seen := <-inbox
+	disconnect()
+
+	messenger.SendMessage(model.PushMessage{Data: "all"}, "", "")
+	messenger.SendMessage(model.PushMessage{Data: "user"}, "u1", "")
+	messenger.SendMessage(model.PushMessage{Data: "session"}, "u1", "s1")
+	messenger.SendMessage(model.PushMessage{Data: "other session"}, "u1", "s2")
+	messenger.SendMessage(model.PushMessage{Data: "other user"}, "u2", "")
+	messenger.SendMessage(model.PushMessage{Data: "heartbeat", Transient: true}, "", "")
+
+	testCases := []struct {
+		Alias        string
+		LastEventID  string
+		ExpectedData []string
+	}{
+		{
+			Alias:        "missed",
+			LastEventID:  seen.ID,
+			ExpectedData: []string{"all", "user", "session"},
+		},
+		{
+			Alias:        "first connection",
+			LastEventID:  "",
+			ExpectedData: nil,
+		},
+		{
+			Alias:        "bad ID",
+			LastEventID:  "abc",
+			ExpectedData: nil,
+		},
+		{
+			Alias:        "before buffer",
+			LastEventID:  "0",
+			ExpectedData: []string{"all", "user", "session"}, // the first message has left the ring of u1
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			inbox, disconnect := messenger.ConnectClient("u1", "s1", tCase.LastEventID)
+			disconnect()
+
+			var data []string
+			last := uint64(0)
+			for msg := range inbox {
+				data = append(data, msg.Data)
+				seq, err := strconv.ParseUint(msg.ID, 10, 64)
+				if err != nil || seq <= last {
+					t.Errorf("bad ID %s after %d", msg.ID, last)
+				}
+				last = seq
+			}
+			if got, want := len(data), len(tCase.ExpectedData); got != want {
+				t.Fatalf("bad messages %v, expected %v", data, tCase.ExpectedData)
+			}
+			for i := range data {
+				if got, want := data[i], tCase.ExpectedData[i]; got != want {
+					t.Errorf("bad message %d: %s, expected %s", i, got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestReplaySequence(t *testing.T) {
+
+	store := push.NewMemoryReplayStore(10)
+	store.Add(model.ReplayMessage{Seq: 1 << 62, UserID: "u1", Message: model.PushMessage{Data: "before restart"}})
+
+	messenger := push.New(push.DefaultOptions)
+	if err := messenger.SetReplayStore(store); err != nil {
+		t.Fatalf("cannot set replay store: %s", err)
+	}
+
+	inbox, disconnect := messenger.ConnectClient("u1", "s1", "")
+	defer disconnect()
+	messenger.SendMessage(model.PushMessage{Data: "after restart"}, "u1", "")
+
+	if got, want := (<-inbox).ID, strconv.FormatUint(1<<62+1, 10); got != want {
+		t.Errorf("bad ID %s, expected %s", got, want)
+	}
+
+}
+
+func TestReplayMaxAge(t *testing.T) {
+
+	now := time.Now()
+	store := push.NewMemoryReplayStore(10)
+	store.Add(model.ReplayMessage{Seq: 1, UserID: "u1", Created: now.Add(-push.ReplayMaxAge - time.Minute), Message: model.PushMessage{Data: "old"}})
+	store.Add(model.ReplayMessage{Seq: 2, UserID: "u2", Created: now.Add(-push.ReplayMaxAge + time.Minute), Message: model.PushMessage{Data: "recent"}})
+
+	// too old to be replayed
+	if missed, _ := store.Since("u1", "s1", 0); len(missed) != 0 {
+		t.Errorf("bad replay %v, expected none", missed)
+	}
+	if missed, _ := store.Since("u2", "s1", 0); len(missed) != 1 {
+		t.Errorf("bad replay %v, expected 1 message", missed)
+	}
+
+	// the users without recent messages are dropped by the next message
+	store.Add(model.ReplayMessage{Seq: 3, UserID: "u3", Created: now, Message: model.PushMessage{Data: "new"}})
+	if got, want := store.Users(), 2; got != want {
+		t.Errorf("bad users %d, expected %d", got, want)
+	}
+
+}
+
+func TestReplayAfterUnnumbered(t *testing.T) {
+
+	store := push.NewDatabaseReplayStore(&DatabaseMock{}, &ReplayRepositoryMock{Last: 100}, 10)
+	messenger := push.New(push.DefaultOptions)
+	if err := messenger.SetReplayStore(store); err != nil {
+		t.Fatalf("cannot set replay store: %s", err)
+	}
+	messenger.RegisterTopic("news", nil)
+
+	// the client sees the messages not stored, which must not move the ID it resumes from
+	token := model.SessionToken{ID: "u1", SessionID: "s1"}
+	inbox, disconnect := messenger.ConnectClient(token.ID, token.SessionID, "100")
+	if err := messenger.Subscribe(token, "news"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		messenger.SendMessage(model.PushMessage{Data: "heartbeat", Transient: true}, "", "")
+	}
+	messenger.PublishToTopic("news", model.PushMessage{Data: "news"})
+	for i := 0; i < 6; i++ {
+		if msg := <-inbox; len(msg.ID) != 0 {
+			t.Errorf("bad ID %s of %s, expected none", msg.ID, msg.Data)
+		}
+	}
+	disconnect()
+
+	messenger.SendMessage(model.PushMessage{Data: "missed"}, "u1", "")
+
+	missed, disconnectReplay := messenger.ConnectClient(token.ID, token.SessionID, "100")
+	defer disconnectReplay()
+	if got, want := len(missed), 1; got != want {
+		t.Fatalf("bad replay length: %d, expected %d", got, want)
+	}
+	if got, want := (<-missed).ID, "101"; got != want {
+		t.Errorf("bad ID %s, expected %s", got, want)
+	}
+
+}
+
+func TestReplayWhileSending(t *testing.T) {
+
+	store := &SendingReplayStore{MemoryReplayStore: push.NewMemoryReplayStore(10)}
+	messenger := push.New(push.DefaultOptions)
+	if err := messenger.SetReplayStore(store); err != nil {
+		t.Fatalf("cannot set replay store: %s", err)
+	}
+
+	messenger.SendMessage(model.PushMessage{Data: "missed"}, "u1", "")
+	store.Send = func() {
+		// stored in time for the replay and sent live as well
+		messenger.SendMessage(model.PushMessage{Data: "meanwhile"}, "u1", "")
+	}
+
+	inbox, disconnect := messenger.ConnectClient("u1", "s1", "0")
+	defer disconnect()
+	messenger.SendMessage(model.PushMessage{Data: "live"}, "u1", "")
+
+	for _, want := range []string{"missed", "meanwhile", "live"} {
+		if got := (<-inbox).Data; got != want {
+			t.Errorf("bad message %s, expected %s", got, want)
+		}
+	}
+	if got, want := len(inbox), 0; got != want {
+		t.Errorf("bad queue length: %d, expected %d", got, want)
+	}
+
+}
+
+func TestDatabaseReplayStore(t *testing.T) {
+
+	repo := &ReplayRepositoryMock{Last: 42}
+	store := push.NewDatabaseReplayStore(&DatabaseMock{}, repo, 5)
+
+	if seq, err := store.LastSeq(); err != nil || seq != 42 {
+		t.Errorf("bad last seq %d, %v", seq, err)
+	}
+
+	msg := model.ReplayMessage{Seq: 7, UserID: "u1", Message: model.PushMessage{ID: "7", Data: "hello"}}
+	seq, err := store.Add(msg)
+	if err != nil {
+		t.Fatalf("cannot add: %s", err)
+	}
+	if got, want := seq, uint64(43); got != want {
+		t.Errorf("bad seq %d, expected %d", got, want)
+	}
+	msg.Message.ID = "43"
+	if got, want := repo.Keep, 5; got != want {
+		t.Errorf("bad keep %d, expected %d", got, want)
+	}
+
+	messages, err := store.Since("u1", "s1", 42)
+	if err != nil {
+		t.Fatalf("cannot list: %s", err)
+	}
+	if len(messages) != 1 || messages[0] != msg.Message {
+		t.Errorf("bad messages %v", messages)
+	}
+	if got, want := repo.Listed, "u1/s1/42"; got != want {
+		t.Errorf("bad list arguments %s, expected %s", got, want)
+	}
+
+}
+
+// SendingReplayStore sends a message while a client is fetching its replay.
+type SendingReplayStore struct {
+	*push.MemoryReplayStore
+	Send func()
+}
+
+func (z *SendingReplayStore) Since(userID, sessionID string, seq uint64) ([]model.PushMessage, error) {
+	if z.Send != nil {
+		z.Send()
+	}
+	return z.MemoryReplayStore.Since(userID, sessionID, seq)
+}
+
+type DatabaseMock struct{}
+
+func (z *DatabaseMock) Run(fn func(tx model.Transaction) error) error {
+	return fn(nil)
+}
+
+type ReplayRepositoryMock struct {
+	Messages []model.ReplayMessage
+	Keep     int
+	Listed   string
+	Last     uint64
+}
+
+func (z *ReplayRepositoryMock) AddPushMessage(ctx context.Context, tx model.Transaction, msg model.ReplayMessage, keep int) (uint64, error) {
+	z.Last++
+	msg.Seq = z.Last
+	msg.Message.ID = strconv.FormatUint(z.Last, 10)
+	z.Messages = append(z.Messages, msg)
+	z.Keep = keep
+	return z.Last, nil
+}
+
+func (z *ReplayRepositoryMock) ListPushMessages(ctx context.Context, tx model.ReadOnlyTransaction, userID, sessionID string, seq uint64) ([]model.ReplayMessage, error) {
+	z.Listed = userID + "/" + sessionID + "/" + strconv.FormatUint(seq, 10)
+	return z.Messages, nil
+}
+
+func (z *ReplayRepositoryMock) GetLastPushMessageSeq(ctx context.Context, tx model.ReadOnlyTransaction) (uint64, error) {
+	return z.Last, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	maxEmailLength = 256
+	tokenSize      = 32
+)
+
+type Mailer interface {
+	Send(context.Context, model.MailMessage) error
+}
+
+// SetMailer sets the mailer and the public base URL of the server used in links sent by mail.
+func (z *UserService) SetMailer(mailer Mailer, publicURL string) {
+	z.mailer = mailer
+	z.publicURL = strings.TrimSuffix(publicURL, "/")
+}
+
+// SetRegistrationEnabled allows or refuses self-service registration. It requires a mailer.
+func (z *UserService) SetRegistrationEnabled(enabled bool) {
+	z.registration = enabled
+}
+
+func isValidEmail(email string) bool {
+	if len(email) == 0 || len(email) > maxEmailLength {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// newToken returns a random URL-safe token to be sent by mail.
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Register creates a disabled user with the user role and mails a link to verify the email address.
+// The user is enabled by VerifyEmail. Registrations not verified in time are deleted by the next registration,
+// which frees their usernames.
+func (z *UserService) Register(ctx context.Context, req model.RegisterRequest) model.RegisterResponse {
+
+	logger := logging.New(ctx, componentUserService, "Register")
+
+	if !z.registration || z.mailer == nil {
+		msg := "registration disabled"
+		logger.Debug().Msg(msg)
+		return model.RegisterResponse{
+			Code:    http.StatusForbidden,
+			Message: msg,
+		}
+	}
+
+	if len(strings.TrimSpace(req.Name)) == 0 {
+		req.Name = req.Username
+	}
+
+	user := model.User{
+		ID:       z.idgen.NewID(),
+		Disabled: true,
+		Username: req.Username,
+		Name:     req.Name,
+		Email:    req.Email,
+	}
+
+	var token string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if !isValidUsername(req.Username) {
+			return model.NewValidationError("username not valid") // 400
+		}
+		if !isValidUsername(req.Name) {
+			return model.NewValidationError("name not valid") // 400
+		}
+		if !isValidEmail(req.Email) {
+			return model.NewValidationError("email not valid") // 400
+		}
+		if err := z.passwordPolicy.Check(req.Password); err != nil {
+			return err // 400
+		}
+
+		now := time.Now().Truncate(time.Second)
+		if err := expireRegistrations(ctx, tx, z.userRepo, now); err != nil {
+			return err // 500
+		}
+
+		ok, errCheckUsername := z.userRepo.IsUsernameAvailable(ctx, tx, req.Username)
+		if errCheckUsername != nil {
+			logger.Error().Err(errCheckUsername).Msg("repo IsUsernameAvailable")
+			return errCheckUsername // 500
+		}
+		if !ok {
+			return model.NewValidationError("username not available") // 400
+		}
+
+		if err := user.SetPasswordWith(z.passwordHashing, req.Password); err != nil {
+			logger.Error().Err(err).Msg("user SetPassword")
+			return err // 500
+		}
+		if err := z.userRepo.SetUser(ctx, tx, user); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+		if err := addPasswordHistory(ctx, tx, z.userRepo, user); err != nil {
+			return err // 500
+		}
+		role := model.UserRole{
+			ID:   model.RoleIDUser,
+			Name: model.RoleNameUser,
+		}
+		if err := z.userRepo.SetUserRole(ctx, tx, user.ID, role); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserRole")
+			return err // 500
+		}
+
+		t, errToken := newToken()
+		if errToken != nil {
+			logger.Error().Err(errToken).Msg("cannot create token")
+			return errToken // 500
+		}
+		verification := model.UserVerification{
+			TokenHash: model.HashToken(t),
+			UserID:    user.ID,
+			Email:     user.Email,
+			Created:   now,
+			Expires:   now.Add(model.VerificationTimeout),
+		}
+		if err := z.userRepo.SetUserVerification(ctx, tx, verification); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserVerification")
+			return err // 500
+		}
+
+		token = t
+		return nil
+
+	})
+
+	// after the commit, so that the link is only sent for a saved user
+	if err == nil {
+		if errSend := z.mailer.Send(ctx, verificationMessage(user, z.publicURL, token)); errSend != nil {
+			logger.Error().Err(errSend).Msg("mailer Send")
+			err = errSend // 500
+			// so that the user can register again
+			errDelete := z.db.Run(func(tx model.Transaction) error {
+				return z.userRepo.DeleteUser(ctx, tx, user.ID)
+			})
+			if errDelete != nil {
+				logger.Error().Err(errDelete).Str("UserID", user.ID).Msg("cannot delete registration")
+			}
+		}
+	}
+
+	rsp := model.RegisterResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot register user")
+		rsp.Message = err.Error()
+		rsp.Rule = model.ValidationRule(err)
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", user.ID).Str("username", user.Username).Msg("user registered")
+		rsp.Code = http.StatusCreated
+	}
+
+	return rsp
+
+}
+
+// VerifyEmail enables a registered user with the token mailed by Register.
+func (z *UserService) VerifyEmail(ctx context.Context, req model.VerifyEmailRequest) model.VerifyEmailResponse {
+
+	logger := logging.New(ctx, componentUserService, "VerifyEmail")
+
+	if len(req.Token) == 0 {
+		msg := "invalid token"
+		logger.Debug().Msg(msg)
+		return model.VerifyEmailResponse{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var userID string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		verification, errGet := z.userRepo.GetUserVerification(ctx, tx, model.HashToken(req.Token))
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUserVerification")
+			return errGet // 500
+		}
+		if verification == nil || verification.IsExpired(time.Now()) {
+			return model.NewValidationError("invalid token") // 400
+		}
+
+		u, errUser := z.userRepo.GetUser(ctx, tx, verification.UserID)
+		if errUser != nil {
+			logger.Error().Err(errUser).Msg("repo GetUser")
+			return errUser // 500
+		}
+		if u == nil || u.Email != verification.Email {
+			return model.NewValidationError("invalid token") // 400
+		}
+
+		u.Disabled = false
+		if err := z.userRepo.SetUser(ctx, tx, *u); err != nil {
+			logger.Error().Err(err).Msg("repo SetUser")
+			return err // 500
+		}
+		if err := z.userRepo.DeleteUserVerifications(ctx, tx, u.ID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteUserVerifications")
+			return err // 500
+		}
+
+		userID = u.ID
+		return nil
+
+	})
+
+	rsp := model.VerifyEmailResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot verify email")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", userID).Msg("email verified")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// expireRegistrations deletes the users that have not verified their email address in time and the expired verifications.
+func expireRegistrations(ctx context.Context, tx model.Transaction, userRepo UserRepository, now time.Time) error {
+
+	logger := logging.New(ctx, componentUserService, "expireRegistrations")
+
+	userIDs, errGet := userRepo.GetExpiredRegistrations(ctx, tx, now)
+	if errGet != nil {
+		logger.Error().Err(errGet).Msg("repo GetExpiredRegistrations")
+		return errGet
+	}
+	for _, userID := range userIDs {
+		if err := userRepo.DeleteUser(ctx, tx, userID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteUser")
+			return err
+		}
+		logger.Info().Str("UserID", userID).Msg("registration expired")
+	}
+
+	if err := userRepo.DeleteExpiredUserVerifications(ctx, tx, now); err != nil {
+		logger.Error().Err(err).Msg("repo DeleteExpiredUserVerifications")
+		return err
+	}
+
+	return nil
+
+}
+
+func verificationMessage(user model.User, publicURL, token string) model.MailMessage {
+	link := publicURL + "/api/register/verify?token=" + url.QueryEscape(token)
+	return model.MailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease open the following link within %d hours to activate your account %s:\n\n%s\n",
+			user.Name, int(model.VerificationTimeout.Hours()), user.Username, link),
+	}
+}
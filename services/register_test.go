@@ -0,0 +1,314 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestRegister(b *testing.T) {
+
+	validRequest := model.RegisterRequest{
+		Username: "newuser",
+		Name:     "New User",
+		Email:    "newuser@example.com",
+		Password: "newpassword",
+	}
+
+	testCases := []struct {
+		Alias             string
+		Disabled          bool
+		OutputAvailable   bool
+		OutputSetError    error
+		OutputSendError   error
+		OutputExpired     []string
+		Request           model.RegisterRequest
+		ExpectedCode      int
+		ExpectedMessage   string
+		ExpectedRule      string
+		ExpectedMail      bool
+		ExpectedGrantedID string
+		ExpectedDeleted   string
+	}{
+		{
+			Alias:             "success",
+			OutputAvailable:   true,
+			Request:           validRequest,
+			ExpectedCode:      http.StatusCreated,
+			ExpectedMail:      true,
+			ExpectedGrantedID: model.RoleIDUser,
+		},
+		{
+			Alias:           "registration disabled",
+			Disabled:        true,
+			OutputAvailable: true,
+			Request:         validRequest,
+			ExpectedCode:    http.StatusForbidden,
+			ExpectedMessage: "registration disabled",
+		},
+		{
+			Alias:           "username not available",
+			OutputAvailable: false,
+			Request:         validRequest,
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not available",
+		},
+		{
+			Alias:           "username not valid",
+			OutputAvailable: true,
+			Request:         model.RegisterRequest{Username: "x", Email: "x@example.com", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "username not valid",
+		},
+		{
+			Alias:           "email not valid",
+			OutputAvailable: true,
+			Request:         model.RegisterRequest{Username: "newuser", Email: "New User <newuser@example.com>", Password: "newpassword"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "email not valid",
+		},
+		{
+			Alias:           "password not valid",
+			OutputAvailable: true,
+			Request:         model.RegisterRequest{Username: "newuser", Email: "newuser@example.com", Password: "short"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "password must have at least 8 characters",
+			ExpectedRule:    model.PasswordRuleMinLength,
+		},
+		{
+			Alias:           "set fails",
+			OutputAvailable: true,
+			OutputSetError:  errors.New("just some error"),
+			Request:         validRequest,
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+		{
+			Alias:             "send fails",
+			OutputAvailable:   true,
+			OutputSendError:   errors.New("just some error"),
+			Request:           validRequest,
+			ExpectedCode:      http.StatusInternalServerError,
+			ExpectedMessage:   "just some error",
+			ExpectedMail:      true,
+			ExpectedGrantedID: model.RoleIDUser,
+			ExpectedDeleted:   "newid",
+		},
+		{
+			Alias:             "expired registration",
+			OutputAvailable:   true,
+			OutputExpired:     []string{"oldid"},
+			Request:           validRequest,
+			ExpectedCode:      http.StatusCreated,
+			ExpectedMail:      true,
+			ExpectedGrantedID: model.RoleIDUser,
+			ExpectedDeleted:   "oldid",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Available:            tCase.OutputAvailable,
+				SetError:             tCase.OutputSetError,
+				ExpiredRegistrations: tCase.OutputExpired,
+			}
+			mailer := &MailerMock{
+				SendError: tCase.OutputSendError,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "newid"})
+			userService.SetMailer(mailer, "https://example.com/")
+			userService.SetRegistrationEnabled(!tCase.Disabled)
+
+			rsp := userService.Register(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.Rule, tCase.ExpectedRule; got != want {
+				t.Errorf("bad response rule %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.DeletedUserID, tCase.ExpectedDeleted; got != want {
+				t.Errorf("bad deleted user %s, expected %s", got, want)
+			}
+
+			if tCase.ExpectedGrantedID == "" {
+				if userRepo.GrantedRole != nil {
+					t.Errorf("bad granted role %v, expected none", userRepo.GrantedRole)
+				}
+			} else if userRepo.GrantedRole == nil || userRepo.GrantedRole.ID != tCase.ExpectedGrantedID {
+				t.Errorf("bad granted role %v, expected %s", userRepo.GrantedRole, tCase.ExpectedGrantedID)
+			}
+
+			if got, want := len(mailer.Sent) == 1, tCase.ExpectedMail; got != want {
+				t.Fatalf("bad mail %v, expected %t", mailer.Sent, want)
+			}
+			if !tCase.ExpectedMail {
+				return
+			}
+
+			if saved := userRepo.SavedUser; saved == nil || !saved.Disabled || saved.Email != tCase.Request.Email || !saved.MatchPassword(tCase.Request.Password) {
+				t.Errorf("bad saved user %v", saved)
+			}
+
+			msg := mailer.Sent[0]
+			if got, want := msg.To, tCase.Request.Email; got != want {
+				t.Errorf("bad mail recipient %s, expected %s", got, want)
+			}
+
+			// the mailed token must match the saved hash
+			prefix := "https://example.com/api/register/verify?token="
+			i := strings.Index(msg.Body, prefix)
+			if i < 0 {
+				t.Fatalf("bad mail body, no link: %s", msg.Body)
+			}
+			link := strings.Fields(msg.Body[i:])[0]
+			u, errURL := url.Parse(link)
+			if errURL != nil {
+				t.Fatal(errURL)
+			}
+			v := userRepo.SavedVerification
+			if v == nil {
+				t.Fatal("bad verification nil, expected non-nil")
+			}
+			if got, want := v.TokenHash, model.HashToken(u.Query().Get("token")); got != want {
+				t.Errorf("bad verification hash %s, expected %s", got, want)
+			}
+			if got, want := v.UserID, "newid"; got != want {
+				t.Errorf("bad verification user %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestVerifyEmail(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	newuser := func() *model.User {
+		return &model.User{ID: "id", Disabled: true, Username: "newuser", Name: "New User", Email: "newuser@example.com", Created: now, Updated: now}
+	}
+	verification := func(expires time.Time) *model.UserVerification {
+		return &model.UserVerification{TokenHash: model.HashToken("token"), UserID: "id", Email: "newuser@example.com", Created: now, Expires: expires}
+	}
+
+	testCases := []struct {
+		Alias              string
+		OutputUser         *model.User
+		OutputVerification *model.UserVerification
+		Request            model.VerifyEmailRequest
+		ExpectedCode       int
+		ExpectedMessage    string
+		ExpectedEnabled    bool
+	}{
+		{
+			Alias:              "success",
+			OutputUser:         newuser(),
+			OutputVerification: verification(now.Add(time.Hour)),
+			Request:            model.VerifyEmailRequest{Token: "token"},
+			ExpectedCode:       http.StatusOK,
+			ExpectedEnabled:    true,
+		},
+		{
+			Alias:              "unknown token",
+			OutputUser:         newuser(),
+			OutputVerification: verification(now.Add(time.Hour)),
+			Request:            model.VerifyEmailRequest{Token: "bogus"},
+			ExpectedCode:       http.StatusBadRequest,
+			ExpectedMessage:    "invalid token",
+		},
+		{
+			Alias:              "no token",
+			OutputUser:         newuser(),
+			OutputVerification: verification(now.Add(time.Hour)),
+			Request:            model.VerifyEmailRequest{},
+			ExpectedCode:       http.StatusBadRequest,
+			ExpectedMessage:    "invalid token",
+		},
+		{
+			Alias:              "expired token",
+			OutputUser:         newuser(),
+			OutputVerification: verification(now.Add(-time.Second)),
+			Request:            model.VerifyEmailRequest{Token: "token"},
+			ExpectedCode:       http.StatusBadRequest,
+			ExpectedMessage:    "invalid token",
+		},
+		{
+			Alias: "email changed",
+			OutputUser: func() *model.User {
+				u := newuser()
+				u.Email = "other@example.com"
+				return u
+			}(),
+			OutputVerification: verification(now.Add(time.Hour)),
+			Request:            model.VerifyEmailRequest{Token: "token"},
+			ExpectedCode:       http.StatusBadRequest,
+			ExpectedMessage:    "invalid token",
+		},
+		{
+			Alias:              "user deleted",
+			OutputUser:         nil,
+			OutputVerification: verification(now.Add(time.Hour)),
+			Request:            model.VerifyEmailRequest{Token: "token"},
+			ExpectedCode:       http.StatusBadRequest,
+			ExpectedMessage:    "invalid token",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:         tCase.OutputUser,
+				Verification: tCase.OutputVerification,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := userService.VerifyEmail(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.SavedUser != nil && !userRepo.SavedUser.Disabled, tCase.ExpectedEnabled; got != want {
+				t.Errorf("bad enabled %t, expected %t", got, want)
+			}
+
+			if tCase.ExpectedEnabled {
+				if got, want := userRepo.DeletedVerifications, "id"; got != want {
+					t.Errorf("bad deleted verifications %s, expected %s", got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
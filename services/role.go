@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+// role names end up in the comma-separated roles claim of the session token
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,63}$`)
+
+func isValidRoleName(name string) bool {
+	return roleNamePattern.MatchString(name)
+}
+
+func (z *AdminService) ListRoles(ctx context.Context, req model.ListRolesRequest) model.ListRolesResponse {
+
+	logger := logging.New(ctx, componentAdminService, "ListRoles")
+
+	var roles []model.Role
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		rs, errRoles := z.userRepo.GetRoles(ctx, tx)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetRoles")
+			return errRoles // 500
+		}
+		roles = rs
+		return nil
+	})
+
+	if err != nil {
+		return model.ListRolesResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+	}
+
+	return model.ListRolesResponse{
+		Code:  http.StatusOK,
+		Roles: roles,
+	}
+
+}
+
+func (z *AdminService) CreateRole(ctx context.Context, req model.CreateRoleRequest) model.CreateRoleResponse {
+
+	logger := logging.New(ctx, componentAdminService, "CreateRole")
+
+	role := model.Role{
+		ID:   z.idgen.NewID(),
+		Name: req.Name,
+	}
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if !isValidRoleName(req.Name) {
+			return model.NewValidationError("role name not valid") // 400
+		}
+
+		existing, errExisting := z.userRepo.GetRoleByName(ctx, tx, req.Name)
+		if errExisting != nil {
+			logger.Error().Err(errExisting).Msg("repo GetRoleByName")
+			return errExisting // 500
+		}
+		if existing != nil {
+			return model.NewValidationError("role name not available") // 400
+		}
+
+		if err := z.userRepo.SetRole(ctx, tx, role); err != nil {
+			logger.Error().Err(err).Msg("repo SetRole")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.CreateRoleResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot create role")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("RoleID", role.ID).Str("name", role.Name).Msg("role created")
+		rsp.Code = http.StatusCreated
+		rsp.Role = &role
+	}
+
+	return rsp
+
+}
+
+// DeleteRole removes a custom role and all grants of it. The builtin roles cannot be deleted.
+func (z *AdminService) DeleteRole(ctx context.Context, req model.DeleteRoleRequest) model.DeleteRoleResponse {
+
+	logger := logging.New(ctx, componentAdminService, "DeleteRole")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		role, errGet := z.userRepo.GetRole(ctx, tx, req.RoleID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetRole")
+			return errGet // 500
+		}
+		if role == nil {
+			return model.NewNotFoundError("role not found") // 404
+		}
+		if role.IsBuiltin() {
+			return model.NewValidationError("cannot delete builtin role") // 400
+		}
+
+		if err := z.userRepo.DeleteRole(ctx, tx, role.ID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteRole")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.DeleteRoleResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot delete role")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("RoleID", req.RoleID).Msg("role deleted")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// ListUserRoles returns all grants of a user, including those not yet or no longer valid.
+// SetRolePermissions replaces the permissions of a role. Only known permissions can be assigned,
+// and the admin role keeps the permission to manage roles so that it cannot be locked out.
+// Like roles, permissions are read into the session token when it is issued.
+func (z *AdminService) SetRolePermissions(ctx context.Context, req model.SetRolePermissionsRequest) model.SetRolePermissionsResponse {
+
+	logger := logging.New(ctx, componentAdminService, "SetRolePermissions")
+
+	var permissions []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		seen := make(map[string]bool)
+		for _, permission := range req.Permissions {
+			if !model.IsValidPermission(permission) {
+				return model.NewValidationError(fmt.Sprintf("permission not valid: %s", permission)) // 400
+			}
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+		if req.RoleID == model.RoleIDAdmin && !seen[model.PermissionManageRoles] {
+			return model.NewValidationError(fmt.Sprintf("admin role requires permission %s", model.PermissionManageRoles)) // 400
+		}
+
+		role, errGet := z.userRepo.GetRole(ctx, tx, req.RoleID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetRole")
+			return errGet // 500
+		}
+		if role == nil {
+			return model.NewNotFoundError("role not found") // 404
+		}
+
+		if err := z.userRepo.SetRolePermissions(ctx, tx, role.ID, permissions); err != nil {
+			logger.Error().Err(err).Msg("repo SetRolePermissions")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.SetRolePermissionsResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot set role permissions")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("RoleID", req.RoleID).Strs("permissions", permissions).Msg("role permissions set")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+func (z *AdminService) ListUserRoles(ctx context.Context, req model.ListUserRolesRequest) model.ListUserRolesResponse {
+
+	logger := logging.New(ctx, componentAdminService, "ListUserRoles")
+
+	var roles []model.UserRole
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, u.ID, nil)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles // 500
+		}
+		roles = rs
+
+		return nil
+
+	})
+
+	rsp := model.ListUserRolesResponse{}
+
+	if err != nil {
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		rsp.Code = http.StatusOK
+		rsp.Roles = roles
+	}
+
+	return rsp
+
+}
+
+// GrantRole grants a role to a user, optionally limited to a validity window.
+// An existing grant of the same role is replaced.
+// Roles are read into the session token when it is issued,
+// so a grant takes effect, and an expired grant drops out, with the next token issued to the user.
+func (z *AdminService) GrantRole(ctx context.Context, req model.GrantRoleRequest) model.GrantRoleResponse {
+
+	logger := logging.New(ctx, componentAdminService, "GrantRole")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if req.ValidFrom != nil && req.ValidTo != nil && !req.ValidTo.After(*req.ValidFrom) {
+			return model.NewValidationError("validTo must be after validFrom") // 400
+		}
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+
+		role, errRole := z.userRepo.GetRole(ctx, tx, req.RoleID)
+		if errRole != nil {
+			logger.Error().Err(errRole).Msg("repo GetRole")
+			return errRole // 500
+		}
+		if role == nil {
+			return model.NewNotFoundError("role not found") // 404
+		}
+
+		grant := model.UserRole{
+			ID:        role.ID,
+			Name:      role.Name,
+			ValidFrom: req.ValidFrom,
+			ValidTo:   req.ValidTo,
+		}
+		if err := z.userRepo.SetUserRole(ctx, tx, u.ID, grant); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserRole")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.GrantRoleResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot grant role")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Str("RoleID", req.RoleID).Msg("role granted")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+func (z *AdminService) RevokeRole(ctx context.Context, req model.RevokeRoleRequest) model.RevokeRoleResponse {
+
+	logger := logging.New(ctx, componentAdminService, "RevokeRole")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		if req.RoleID == model.RoleIDAdmin && model.TokenFromContext(ctx).ID == req.UserID {
+			return model.NewValidationError("cannot revoke own admin role") // 400
+		}
+
+		roles, errRoles := z.userRepo.GetUserRoles(ctx, tx, req.UserID, nil)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles // 500
+		}
+		granted := false
+		for _, role := range roles {
+			if role.ID == req.RoleID {
+				granted = true
+			}
+		}
+		if !granted {
+			return model.NewNotFoundError("role not granted") // 404
+		}
+
+		if err := z.userRepo.DeleteUserRole(ctx, tx, req.UserID, req.RoleID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteUserRole")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.RevokeRoleResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot revoke role")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Str("RoleID", req.RoleID).Msg("role revoked")
+		z.pushMessenger.UnsubscribeUser(req.UserID) // topics may have required the role
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
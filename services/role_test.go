@@ -0,0 +1,515 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestListRoles(b *testing.T) {
+
+	roles := []model.Role{
+		{ID: model.RoleIDAdmin, Name: model.RoleNameAdmin},
+		{ID: model.RoleIDUser, Name: model.RoleNameUser},
+	}
+
+	testCases := []struct {
+		Alias            string
+		OutputRoles      []model.Role
+		OutputRoleError  error
+		ExpectedResponse model.ListRolesResponse
+	}{
+		{
+			Alias:       "success",
+			OutputRoles: roles,
+			ExpectedResponse: model.ListRolesResponse{
+				Code:  http.StatusOK,
+				Roles: roles,
+			},
+		},
+		{
+			Alias:           "list fails",
+			OutputRoleError: errors.New("just some error"),
+			ExpectedResponse: model.ListRolesResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "just some error",
+			},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				RoleList:  tCase.OutputRoles,
+				RoleError: tCase.OutputRoleError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.ListRoles(context.Background(), model.ListRolesRequest{})
+
+			if !reflect.DeepEqual(rsp, tCase.ExpectedResponse) {
+				t.Errorf("bad response %v, expected %v", rsp, tCase.ExpectedResponse)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestCreateRole(b *testing.T) {
+
+	testCases := []struct {
+		Alias            string
+		OutputRoleByName *model.Role
+		OutputSetError   error
+		Request          model.CreateRoleRequest
+		ExpectedCode     int
+		ExpectedMessage  string
+		ExpectedSaved    bool
+	}{
+		{
+			Alias:         "success",
+			Request:       model.CreateRoleRequest{Name: "editor"},
+			ExpectedCode:  http.StatusCreated,
+			ExpectedSaved: true,
+		},
+		{
+			Alias:           "bad name",
+			Request:         model.CreateRoleRequest{Name: "editor,admin"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "role name not valid",
+		},
+		{
+			Alias:           "uppercase name",
+			Request:         model.CreateRoleRequest{Name: "Editor"},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "role name not valid",
+		},
+		{
+			Alias:            "name taken",
+			OutputRoleByName: &model.Role{ID: "other", Name: "editor"},
+			Request:          model.CreateRoleRequest{Name: "editor"},
+			ExpectedCode:     http.StatusBadRequest,
+			ExpectedMessage:  "role name not available",
+		},
+		{
+			Alias:           "save fails",
+			OutputSetError:  errors.New("just some error"),
+			Request:         model.CreateRoleRequest{Name: "editor"},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+			ExpectedSaved:   true,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				RoleByName: tCase.OutputRoleByName,
+				SetError:   tCase.OutputSetError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{ID: "roleid"})
+
+			rsp := adminService.CreateRole(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := userRepo.SavedRole != nil, tCase.ExpectedSaved; got != want {
+				t.Errorf("bad saved %t, expected %t", got, want)
+			}
+			if rsp.Code == http.StatusCreated {
+				expected := model.Role{ID: "roleid", Name: tCase.Request.Name}
+				if rsp.Role == nil || !reflect.DeepEqual(*rsp.Role, expected) {
+					t.Errorf("bad role %v, expected %v", rsp.Role, expected)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestDeleteRole(b *testing.T) {
+
+	testCases := []struct {
+		Alias             string
+		OutputRole        *model.Role
+		OutputDeleteError error
+		ExpectedCode      int
+		ExpectedMessage   string
+		ExpectedDeleted   string
+	}{
+		{
+			Alias:           "success",
+			OutputRole:      &model.Role{ID: "roleid", Name: "editor"},
+			ExpectedCode:    http.StatusOK,
+			ExpectedDeleted: "roleid",
+		},
+		{
+			Alias:           "not found",
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "role not found",
+		},
+		{
+			Alias:           "builtin",
+			OutputRole:      &model.Role{ID: model.RoleIDUser, Name: model.RoleNameUser},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "cannot delete builtin role",
+		},
+		{
+			Alias:             "delete fails",
+			OutputRole:        &model.Role{ID: "roleid", Name: "editor"},
+			OutputDeleteError: errors.New("just some error"),
+			ExpectedCode:      http.StatusInternalServerError,
+			ExpectedMessage:   "just some error",
+			ExpectedDeleted:   "roleid",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Role:        tCase.OutputRole,
+				DeleteError: tCase.OutputDeleteError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.DeleteRole(context.Background(), model.DeleteRoleRequest{RoleID: "roleid"})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := userRepo.DeletedRoleID, tCase.ExpectedDeleted; got != want {
+				t.Errorf("bad deleted role %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestSetRolePermissions(b *testing.T) {
+
+	editor := &model.Role{ID: "roleid", Name: "editor"}
+	admin := &model.Role{ID: model.RoleIDAdmin, Name: model.RoleNameAdmin}
+
+	testCases := []struct {
+		Alias               string
+		OutputRole          *model.Role
+		OutputSetError      error
+		Request             model.SetRolePermissionsRequest
+		ExpectedCode        int
+		ExpectedMessage     string
+		ExpectedPermissions []string
+	}{
+		{
+			Alias:               "success",
+			OutputRole:          editor,
+			Request:             model.SetRolePermissionsRequest{RoleID: "roleid", Permissions: []string{model.PermissionAccount, model.PermissionAccount, model.PermissionManageUsers}},
+			ExpectedCode:        http.StatusOK,
+			ExpectedPermissions: []string{model.PermissionAccount, model.PermissionManageUsers},
+		},
+		{
+			Alias:        "remove all",
+			OutputRole:   editor,
+			Request:      model.SetRolePermissionsRequest{RoleID: "roleid"},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Alias:           "unknown permission",
+			OutputRole:      editor,
+			Request:         model.SetRolePermissionsRequest{RoleID: "roleid", Permissions: []string{"bogus"}},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "permission not valid: bogus",
+		},
+		{
+			Alias:               "admin keeps role management",
+			OutputRole:          admin,
+			Request:             model.SetRolePermissionsRequest{RoleID: model.RoleIDAdmin, Permissions: []string{model.PermissionManageUsers}},
+			ExpectedCode:        http.StatusBadRequest,
+			ExpectedMessage:     "admin role requires permission roles.manage",
+			ExpectedPermissions: nil,
+		},
+		{
+			Alias:           "role not found",
+			Request:         model.SetRolePermissionsRequest{RoleID: "roleid", Permissions: []string{model.PermissionAccount}},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "role not found",
+		},
+		{
+			Alias:           "save fails",
+			OutputRole:      editor,
+			OutputSetError:  errors.New("just some error"),
+			Request:         model.SetRolePermissionsRequest{RoleID: "roleid", Permissions: []string{model.PermissionAccount}},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+			// saved before failing
+			ExpectedPermissions: []string{model.PermissionAccount},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Role:     tCase.OutputRole,
+				SetError: tCase.OutputSetError,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.SetRolePermissions(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := userRepo.SavedPermissions, tCase.ExpectedPermissions; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad saved permissions %v, expected %v", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestListUserRoles(b *testing.T) {
+
+	validTo := time.Now().Add(time.Hour).Truncate(time.Second)
+	roles := []model.UserRole{
+		{ID: "roleid", Name: "editor", ValidTo: &validTo},
+	}
+
+	testCases := []struct {
+		Alias            string
+		OutputUser       *model.User
+		OutputRoles      []model.UserRole
+		ExpectedResponse model.ListUserRolesResponse
+	}{
+		{
+			Alias:       "success",
+			OutputUser:  &model.User{ID: "id"},
+			OutputRoles: roles,
+			ExpectedResponse: model.ListUserRolesResponse{
+				Code:  http.StatusOK,
+				Roles: roles,
+			},
+		},
+		{
+			Alias: "user not found",
+			ExpectedResponse: model.ListUserRolesResponse{
+				Code:    http.StatusNotFound,
+				Message: "user not found",
+			},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:  tCase.OutputUser,
+				Roles: tCase.OutputRoles,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.ListUserRoles(context.Background(), model.ListUserRolesRequest{UserID: "id"})
+
+			if !reflect.DeepEqual(rsp, tCase.ExpectedResponse) {
+				t.Errorf("bad response %v, expected %v", rsp, tCase.ExpectedResponse)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestGrantRole(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	later := now.Add(time.Hour)
+
+	testCases := []struct {
+		Alias           string
+		OutputUser      *model.User
+		OutputRole      *model.Role
+		Request         model.GrantRoleRequest
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedGrant   *model.UserRole
+	}{
+		{
+			Alias:         "unbounded",
+			OutputUser:    &model.User{ID: "id"},
+			OutputRole:    &model.Role{ID: "roleid", Name: "editor"},
+			Request:       model.GrantRoleRequest{UserID: "id", RoleID: "roleid"},
+			ExpectedCode:  http.StatusOK,
+			ExpectedGrant: &model.UserRole{ID: "roleid", Name: "editor"},
+		},
+		{
+			Alias:         "time bounded",
+			OutputUser:    &model.User{ID: "id"},
+			OutputRole:    &model.Role{ID: "roleid", Name: "editor"},
+			Request:       model.GrantRoleRequest{UserID: "id", RoleID: "roleid", ValidFrom: &now, ValidTo: &later},
+			ExpectedCode:  http.StatusOK,
+			ExpectedGrant: &model.UserRole{ID: "roleid", Name: "editor", ValidFrom: &now, ValidTo: &later},
+		},
+		{
+			Alias:           "window reversed",
+			OutputUser:      &model.User{ID: "id"},
+			OutputRole:      &model.Role{ID: "roleid", Name: "editor"},
+			Request:         model.GrantRoleRequest{UserID: "id", RoleID: "roleid", ValidFrom: &later, ValidTo: &now},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "validTo must be after validFrom",
+		},
+		{
+			Alias:           "user not found",
+			OutputRole:      &model.Role{ID: "roleid", Name: "editor"},
+			Request:         model.GrantRoleRequest{UserID: "id", RoleID: "roleid"},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "user not found",
+		},
+		{
+			Alias:           "role not found",
+			OutputUser:      &model.User{ID: "id"},
+			Request:         model.GrantRoleRequest{UserID: "id", RoleID: "roleid"},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "role not found",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User: tCase.OutputUser,
+				Role: tCase.OutputRole,
+			}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := adminService.GrantRole(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if !reflect.DeepEqual(userRepo.GrantedRole, tCase.ExpectedGrant) {
+				t.Errorf("bad grant %v, expected %v", userRepo.GrantedRole, tCase.ExpectedGrant)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRevokeRole(b *testing.T) {
+
+	adminToken := model.SessionToken{ID: "adminid"}
+
+	testCases := []struct {
+		Alias           string
+		OutputRoles     []model.UserRole
+		Request         model.RevokeRoleRequest
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedRevoked string
+	}{
+		{
+			Alias:           "success",
+			OutputRoles:     []model.UserRole{{ID: "roleid", Name: "editor"}},
+			Request:         model.RevokeRoleRequest{UserID: "id", RoleID: "roleid"},
+			ExpectedCode:    http.StatusOK,
+			ExpectedRevoked: "roleid",
+		},
+		{
+			Alias:           "not granted",
+			OutputRoles:     []model.UserRole{{ID: "otherid", Name: "reporter"}},
+			Request:         model.RevokeRoleRequest{UserID: "id", RoleID: "roleid"},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "role not granted",
+		},
+		{
+			Alias:           "own admin role",
+			OutputRoles:     []model.UserRole{{ID: model.RoleIDAdmin, Name: model.RoleNameAdmin}},
+			Request:         model.RevokeRoleRequest{UserID: "adminid", RoleID: model.RoleIDAdmin},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "cannot revoke own admin role",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				Roles: tCase.OutputRoles,
+			}
+			pushMessenger := &PushMessengerMock{}
+			adminService := services.NewAdminService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, pushMessenger, &IdGeneratorMock{})
+
+			ctx := context.WithValue(context.Background(), model.UserKey, adminToken)
+			rsp := adminService.RevokeRole(ctx, tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad code %d, expected %d", got, want)
+			}
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad message %s, expected %s", got, want)
+			}
+			if got, want := userRepo.RevokedRoleID, tCase.ExpectedRevoked; got != want {
+				t.Errorf("bad revoked role %s, expected %s", got, want)
+			}
+			if got, want := len(pushMessenger.Unsubscribed) != 0, tCase.ExpectedRevoked != ""; got != want {
+				t.Errorf("bad unsubscribed %t, expected %t", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -25,13 +25,56 @@ func (z *TransactionMock) Exec(query string, params map[string]interface{}) (mod
 }
 
 type UserRepositoryMock struct {
-	User           *model.User
-	Roles          []model.UserRole
-	Available      bool
-	AvailableError error
-	GetError       error
-	RolesError     error
-	SetError       error
+	User                 *model.User
+	Roles                []model.UserRole
+	Available            bool
+	AvailableError       error
+	GetError             error
+	RolesError           error
+	SetError             error
+	TOTP                 *model.UserTOTP
+	TOTPError            error
+	SetTOTPError         error
+	RecoveryCodes        map[string]bool // hash: unused
+	SavedTOTP            *model.UserTOTP
+	DeletedTOTP          bool
+	Users                []model.User
+	Total                int
+	ListError            error
+	ListFilter           model.UserFilter
+	DeleteError          error
+	SavedUser            *model.User
+	DeletedUserID        string
+	RoleList             []model.Role
+	Role                 *model.Role
+	RoleByName           *model.Role
+	RoleError            error
+	SavedRole            *model.Role
+	DeletedRoleID        string
+	GrantedRole          *model.UserRole
+	RevokedRoleID        string
+	Throttles            map[string]*model.LoginThrottle // scope:subject
+	ThrottleError        error
+	SavedThrottles       []model.LoginThrottle
+	DeletedThrottles     []string
+	Challenge            *model.LoginChallenge
+	SavedChallenge       *model.LoginChallenge
+	DeletedChallenge     string
+	Verification         *model.UserVerification
+	SavedVerification    *model.UserVerification
+	DeletedVerifications string
+	ExpiredRegistrations []string
+	DeletedExpired       *time.Time
+	PasswordReset        *model.PasswordReset
+	SavedPasswordReset   *model.PasswordReset
+	UsedPasswordResets   string
+	PasswordHistory      []model.PasswordHistory
+	HistoryError         error
+	AddedHistory         []model.PasswordHistory
+	Identity             *model.UserIdentity
+	IdentityError        error
+	SavedIdentity        *model.UserIdentity
+	SavedPermissions     []string
 }
 
 func (z *UserRepositoryMock) IsUsernameAvailable(ctx context.Context, tx model.ReadOnlyTransaction, username string) (bool, error) {
@@ -43,9 +86,20 @@ func (z *UserRepositoryMock) GetUser(ctx context.Context, tx model.ReadOnlyTrans
 }
 
 func (z *UserRepositoryMock) SetUser(ctx context.Context, tx model.WriteOnlyTransaction, user model.User) error {
+	z.SavedUser = &user
 	return z.SetError
 }
 
+func (z *UserRepositoryMock) ListUsers(ctx context.Context, tx model.ReadOnlyTransaction, filter model.UserFilter) ([]model.User, int, error) {
+	z.ListFilter = filter
+	return z.Users, z.Total, z.ListError
+}
+
+func (z *UserRepositoryMock) DeleteUser(ctx context.Context, tx model.WriteOnlyTransaction, userID string) error {
+	z.DeletedUserID = userID
+	return z.DeleteError
+}
+
 func (z *UserRepositoryMock) GetActiveUserByUsername(ctx context.Context, tx model.ReadOnlyTransaction, username string) (*model.User, error) {
 	return z.User, z.GetError
 }
@@ -54,6 +108,173 @@ func (z *UserRepositoryMock) GetUserRoles(ctx context.Context, tx model.ReadOnly
 	return z.Roles, z.RolesError
 }
 
+func (z *UserRepositoryMock) GetRoles(ctx context.Context, tx model.ReadOnlyTransaction) ([]model.Role, error) {
+	return z.RoleList, z.RoleError
+}
+
+func (z *UserRepositoryMock) GetRole(ctx context.Context, tx model.ReadOnlyTransaction, roleID string) (*model.Role, error) {
+	return z.Role, z.RoleError
+}
+
+func (z *UserRepositoryMock) GetRoleByName(ctx context.Context, tx model.ReadOnlyTransaction, name string) (*model.Role, error) {
+	return z.RoleByName, z.RoleError
+}
+
+func (z *UserRepositoryMock) SetRole(ctx context.Context, tx model.WriteOnlyTransaction, role model.Role) error {
+	z.SavedRole = &role
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) DeleteRole(ctx context.Context, tx model.WriteOnlyTransaction, roleID string) error {
+	z.DeletedRoleID = roleID
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) SetRolePermissions(ctx context.Context, tx model.WriteOnlyTransaction, roleID string, permissions []string) error {
+	z.SavedPermissions = permissions
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) SetUserRole(ctx context.Context, tx model.WriteOnlyTransaction, userID string, role model.UserRole) error {
+	z.GrantedRole = &role
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) DeleteUserRole(ctx context.Context, tx model.WriteOnlyTransaction, userID, roleID string) error {
+	z.RevokedRoleID = roleID
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) GetUserTOTP(ctx context.Context, tx model.ReadOnlyTransaction, userID string) (*model.UserTOTP, error) {
+	return z.TOTP, z.TOTPError
+}
+
+func (z *UserRepositoryMock) SetUserTOTP(ctx context.Context, tx model.WriteOnlyTransaction, userTOTP model.UserTOTP) error {
+	z.SavedTOTP = &userTOTP
+	return z.SetTOTPError
+}
+
+func (z *UserRepositoryMock) DeleteUserTOTP(ctx context.Context, tx model.WriteOnlyTransaction, userID string) error {
+	z.DeletedTOTP = true
+	return nil
+}
+
+func (z *UserRepositoryMock) SetRecoveryCodes(ctx context.Context, tx model.WriteOnlyTransaction, userID string, codeHashes []string) error {
+	z.RecoveryCodes = make(map[string]bool)
+	for _, codeHash := range codeHashes {
+		z.RecoveryCodes[codeHash] = true
+	}
+	return nil
+}
+
+func (z *UserRepositoryMock) UseRecoveryCode(ctx context.Context, tx model.WriteOnlyTransaction, userID, codeHash string, t time.Time) (bool, error) {
+	if z.RecoveryCodes[codeHash] {
+		z.RecoveryCodes[codeHash] = false
+		return true, nil
+	}
+	return false, nil
+}
+
+func (z *UserRepositoryMock) GetLoginThrottle(ctx context.Context, tx model.ReadOnlyTransaction, scope, subject string) (*model.LoginThrottle, error) {
+	if throttle, ok := z.Throttles[scope+":"+subject]; ok {
+		t := *throttle
+		return &t, z.ThrottleError
+	}
+	return nil, z.ThrottleError
+}
+
+func (z *UserRepositoryMock) SetLoginThrottle(ctx context.Context, tx model.WriteOnlyTransaction, throttle model.LoginThrottle) error {
+	z.SavedThrottles = append(z.SavedThrottles, throttle)
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) DeleteLoginThrottle(ctx context.Context, tx model.WriteOnlyTransaction, scope, subject string) error {
+	z.DeletedThrottles = append(z.DeletedThrottles, scope+":"+subject)
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) GetLoginChallenge(ctx context.Context, tx model.ReadOnlyTransaction, challengeID string) (*model.LoginChallenge, error) {
+	if z.Challenge != nil && z.Challenge.ID == challengeID {
+		c := *z.Challenge
+		return &c, z.GetError
+	}
+	return nil, z.GetError
+}
+
+func (z *UserRepositoryMock) SetLoginChallenge(ctx context.Context, tx model.WriteOnlyTransaction, challenge model.LoginChallenge) error {
+	z.SavedChallenge = &challenge
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) DeleteLoginChallenge(ctx context.Context, tx model.WriteOnlyTransaction, challengeID string) error {
+	z.DeletedChallenge = challengeID
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) GetUserVerification(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.UserVerification, error) {
+	if z.Verification != nil && z.Verification.TokenHash == tokenHash {
+		return z.Verification, z.GetError
+	}
+	return nil, z.GetError
+}
+
+func (z *UserRepositoryMock) SetUserVerification(ctx context.Context, tx model.WriteOnlyTransaction, verification model.UserVerification) error {
+	z.SavedVerification = &verification
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) DeleteUserVerifications(ctx context.Context, tx model.WriteOnlyTransaction, userID string) error {
+	z.DeletedVerifications = userID
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) GetExpiredRegistrations(ctx context.Context, tx model.ReadOnlyTransaction, t time.Time) ([]string, error) {
+	return z.ExpiredRegistrations, z.GetError
+}
+
+func (z *UserRepositoryMock) DeleteExpiredUserVerifications(ctx context.Context, tx model.WriteOnlyTransaction, t time.Time) error {
+	z.DeletedExpired = &t
+	return z.DeleteError
+}
+
+func (z *UserRepositoryMock) GetPasswordReset(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.PasswordReset, error) {
+	if z.PasswordReset != nil && z.PasswordReset.TokenHash == tokenHash {
+		return z.PasswordReset, z.GetError
+	}
+	return nil, z.GetError
+}
+
+func (z *UserRepositoryMock) SetPasswordReset(ctx context.Context, tx model.WriteOnlyTransaction, reset model.PasswordReset) error {
+	z.SavedPasswordReset = &reset
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) UsePasswordResets(ctx context.Context, tx model.WriteOnlyTransaction, userID string, t time.Time) error {
+	z.UsedPasswordResets = userID
+	return z.SetError
+}
+
+func (z *UserRepositoryMock) GetPasswordHistory(ctx context.Context, tx model.ReadOnlyTransaction, userID string, limit int) ([]model.PasswordHistory, error) {
+	if len(z.PasswordHistory) > limit {
+		return z.PasswordHistory[:limit], z.HistoryError
+	}
+	return z.PasswordHistory, z.HistoryError
+}
+
+func (z *UserRepositoryMock) AddPasswordHistory(ctx context.Context, tx model.WriteOnlyTransaction, h model.PasswordHistory) error {
+	z.AddedHistory = append(z.AddedHistory, h)
+	return z.HistoryError
+}
+
+func (z *UserRepositoryMock) GetUserIdentity(ctx context.Context, tx model.ReadOnlyTransaction, issuer, subject string) (*model.UserIdentity, error) {
+	return z.Identity, z.IdentityError
+}
+
+func (z *UserRepositoryMock) SetUserIdentity(ctx context.Context, tx model.WriteOnlyTransaction, identity model.UserIdentity) error {
+	z.SavedIdentity = &identity
+	return z.SetError
+}
+
 type IdGeneratorMock struct {
 	ID string
 }
@@ -61,3 +282,175 @@ type IdGeneratorMock struct {
 func (z *IdGeneratorMock) NewID() string {
 	return z.ID
 }
+
+type SessionRepositoryMock struct {
+	Session       *model.Session
+	Sessions      []model.Session
+	GetError      error
+	SessionsError error
+	SetError      error
+	RevokeError   error
+	SetCount      int
+	RevokeCount   int
+	RevokedIDs    []string
+	SavedSession  *model.Session
+}
+
+func (z *SessionRepositoryMock) GetSession(ctx context.Context, tx model.ReadOnlyTransaction, sessionID string) (*model.Session, error) {
+	return z.Session, z.GetError
+}
+
+func (z *SessionRepositoryMock) GetUserSessions(ctx context.Context, tx model.ReadOnlyTransaction, userID string, t *time.Time) ([]model.Session, error) {
+	return z.Sessions, z.SessionsError
+}
+
+func (z *SessionRepositoryMock) SetSession(ctx context.Context, tx model.WriteOnlyTransaction, session model.Session) error {
+	z.SetCount++
+	z.SavedSession = &session
+	return z.SetError
+}
+
+func (z *SessionRepositoryMock) RevokeSession(ctx context.Context, tx model.WriteOnlyTransaction, sessionID string, t time.Time) error {
+	z.RevokeCount++
+	z.RevokedIDs = append(z.RevokedIDs, sessionID)
+	return z.RevokeError
+}
+
+type PushMessengerMock struct {
+	Connected    map[string]bool
+	Disconnected []string
+	Sent         []model.PushMessage
+	SentUserID   string
+	SentTopic    string
+	Updated      []model.SessionToken
+	Unsubscribed []string
+}
+
+func (z *PushMessengerMock) IsClientConnected(userID, sessionID string) bool {
+	return z.Connected[sessionID]
+}
+
+func (z *PushMessengerMock) UpdateToken(token model.SessionToken) {
+	z.Updated = append(z.Updated, token)
+}
+
+func (z *PushMessengerMock) UnsubscribeUser(userID string) {
+	z.Unsubscribed = append(z.Unsubscribed, userID)
+}
+
+func (z *PushMessengerMock) DisconnectClient(userID, sessionID string) {
+	z.Disconnected = append(z.Disconnected, sessionID)
+}
+
+func (z *PushMessengerMock) SendMessage(msg model.PushMessage, userID, sessionID string) int {
+	z.Sent = append(z.Sent, msg)
+	z.SentUserID = userID
+	return 1
+}
+
+func (z *PushMessengerMock) PublishToTopic(topic string, msg model.PushMessage) int {
+	z.Sent = append(z.Sent, msg)
+	z.SentTopic = topic
+	return 1
+}
+
+type MailerMock struct {
+	Sent      []model.MailMessage
+	SendError error
+}
+
+func (z *MailerMock) Send(ctx context.Context, msg model.MailMessage) error {
+	z.Sent = append(z.Sent, msg)
+	return z.SendError
+}
+
+type NotifierMock struct {
+	User        *model.User
+	Body        string
+	NotifyError error
+	Notified    chan bool // receives after each notification, if set
+}
+
+func (z *NotifierMock) Notify(ctx context.Context, user model.User, subject, body string) error {
+	z.User = &user
+	z.Body = body
+	if z.Notified != nil {
+		defer func() { z.Notified <- true }()
+	}
+	return z.NotifyError
+}
+
+type APITokenRepositoryMock struct {
+	APIToken      *model.APIToken
+	APITokens     []model.APIToken
+	GetError      error
+	SetError      error
+	RevokeError   error
+	Revoked       bool
+	SetCount      int
+	SavedAPIToken *model.APIToken
+	RevokedID     string
+}
+
+func (z *APITokenRepositoryMock) GetAPIToken(ctx context.Context, tx model.ReadOnlyTransaction, tokenHash string) (*model.APIToken, error) {
+	if z.APIToken != nil && z.APIToken.TokenHash != tokenHash {
+		return nil, z.GetError
+	}
+	return z.APIToken, z.GetError
+}
+
+func (z *APITokenRepositoryMock) GetUserAPITokens(ctx context.Context, tx model.ReadOnlyTransaction, userID string) ([]model.APIToken, error) {
+	return z.APITokens, z.GetError
+}
+
+func (z *APITokenRepositoryMock) SetAPIToken(ctx context.Context, tx model.WriteOnlyTransaction, apiToken model.APIToken) error {
+	z.SetCount++
+	z.SavedAPIToken = &apiToken
+	return z.SetError
+}
+
+func (z *APITokenRepositoryMock) RevokeAPIToken(ctx context.Context, tx model.WriteOnlyTransaction, userID, apiTokenID string, t time.Time) (bool, error) {
+	z.RevokedID = apiTokenID
+	return z.Revoked, z.RevokeError
+}
+
+type AuditRepositoryMock struct {
+	Events      []model.AuditEvent
+	AddError    error
+	ListError   error
+	SavedEvents []model.AuditEvent
+	Filters     []model.AuditFilter
+}
+
+func (z *AuditRepositoryMock) AddAuditEvent(ctx context.Context, tx model.WriteOnlyTransaction, event model.AuditEvent) error {
+	z.SavedEvents = append(z.SavedEvents, event)
+	return z.AddError
+}
+
+func (z *AuditRepositoryMock) ListAuditEvents(ctx context.Context, tx model.ReadOnlyTransaction, filter model.AuditFilter) ([]model.AuditEvent, int, error) {
+	z.Filters = append(z.Filters, filter)
+	if z.ListError != nil {
+		return nil, 0, z.ListError
+	}
+	start := filter.Offset
+	if filter.BeforeCreated != nil {
+		for i, e := range z.Events {
+			if e.ID == filter.BeforeID {
+				start = i + 1
+			}
+		}
+	}
+	events := make([]model.AuditEvent, 0)
+	for i := start; i < len(z.Events) && i < start+filter.Limit; i++ {
+		events = append(events, z.Events[i])
+	}
+	return events, len(z.Events), nil
+}
+
+type AuditorMock struct {
+	Events []model.AuditEvent
+}
+
+func (z *AuditorMock) Audit(ctx context.Context, event model.AuditEvent) {
+	z.Events = append(z.Events, event)
+}
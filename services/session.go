@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	componentSessionService = "SessionService"
+	maxUserAgentLength      = 256
+	sessionTouchInterval    = time.Minute
+)
+
+type PushMessenger interface {
+	IsClientConnected(userID, sessionID string) bool
+	DisconnectClient(userID, sessionID string)
+	SendMessage(msg model.PushMessage, userID, sessionID string) int
+	UpdateToken(token model.SessionToken)
+	UnsubscribeUser(userID string)
+}
+
+type SessionUserRepository interface {
+	GetUser(context.Context, model.ReadOnlyTransaction, string) (*model.User, error)
+	GetUserRoles(context.Context, model.ReadOnlyTransaction, string, *time.Time) ([]model.UserRole, error)
+}
+
+type SessionService struct {
+	db            model.Database
+	sessionRepo   SessionRepository
+	userRepo      SessionUserRepository
+	pushMessenger PushMessenger
+	auditor       Auditor
+}
+
+func NewSessionService(db model.Database, sessionRepo SessionRepository, userRepo SessionUserRepository, pushMessenger PushMessenger) *SessionService {
+	return &SessionService{
+		db:            db,
+		sessionRepo:   sessionRepo,
+		userRepo:      userRepo,
+		pushMessenger: pushMessenger,
+	}
+}
+
+// SetAuditor sets the auditor recording logouts.
+func (z *SessionService) SetAuditor(auditor Auditor) {
+	z.auditor = auditor
+}
+
+// ValidateSession tests that the session exists, belongs to the given user and is neither revoked nor expired.
+// The last-seen time of a valid session is updated at most once per sessionTouchInterval.
+func (z *SessionService) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+
+	logger := logging.New(ctx, componentSessionService, "ValidateSession")
+
+	if len(userID) == 0 || len(sessionID) == 0 {
+		return false, nil
+	}
+
+	valid := false
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		session, errGet := z.sessionRepo.GetSession(ctx, tx, sessionID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetSession")
+			return errGet
+		}
+
+		now := time.Now().Truncate(time.Second)
+		if session == nil || session.UserID != userID || !session.IsActive(now) {
+			return nil
+		}
+		valid = true
+
+		if now.Sub(session.LastSeen) < sessionTouchInterval {
+			return nil
+		}
+
+		session.LastSeen = now
+		if err := z.sessionRepo.SetSession(ctx, tx, *session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err
+		}
+
+		return nil
+
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	if !valid {
+		logger.Debug().Str("UserID", userID).Str("SessionID", sessionID).Msg("session invalid")
+	}
+
+	return valid, nil
+
+}
+
+// RenewSession extends an active session by LoginTimeout and returns a new session token for it.
+// The token is built from the current user record and roles, so role changes take effect and expired grants drop out.
+// Sessions are not extended past SessionLifetime after login, impersonation sessions are not extended at all.
+// A nil token is returned if the session cannot be renewed.
+func (z *SessionService) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+
+	logger := logging.New(ctx, componentSessionService, "RenewSession")
+
+	var sessionToken *model.SessionToken
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		session, errGet := z.sessionRepo.GetSession(ctx, tx, sessionID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetSession")
+			return errGet
+		}
+
+		now := time.Now().Truncate(time.Second)
+		if session == nil || session.UserID != userID || !session.IsActive(now) {
+			return nil
+		}
+		if len(session.ImpersonatorID) != 0 {
+			return nil // impersonation is limited to ImpersonationTimeout
+		}
+
+		expires := now.Add(model.LoginTimeout)
+		if limit := session.Issued.Add(model.SessionLifetime); expires.After(limit) {
+			expires = limit
+		}
+		if !expires.After(session.Expires) {
+			return nil // session has reached its lifetime
+		}
+
+		u, errUser := z.userRepo.GetUser(ctx, tx, userID)
+		if errUser != nil {
+			logger.Error().Err(errUser).Msg("repo GetUser")
+			return errUser
+		}
+		if u == nil || u.Disabled {
+			return nil
+		}
+
+		roles, errRoles := z.userRepo.GetUserRoles(ctx, tx, userID, &now)
+		if errRoles != nil {
+			logger.Error().Err(errRoles).Msg("repo GetUserRoles")
+			return errRoles
+		}
+
+		session.LastSeen = now
+		session.Expires = expires
+		if err := z.sessionRepo.SetSession(ctx, tx, *session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err
+		}
+
+		sessionToken = model.ToSessionToken(sessionID, u, roles, now, expires)
+
+		return nil
+
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionToken != nil {
+		logger.Info().Str("UserID", userID).Str("SessionID", sessionID).Time("expires", sessionToken.Expires).Msg("session renewed")
+		z.pushMessenger.UpdateToken(*sessionToken) // topics follow the current roles
+	}
+
+	return sessionToken, nil
+
+}
+
+// RevokeSession revokes the session of the given user so that its token is no longer accepted.
+// An open inbox for the session is closed.
+func (z *SessionService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+
+	logger := logging.New(ctx, componentSessionService, "RevokeSession")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		session, errGet := z.sessionRepo.GetSession(ctx, tx, sessionID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetSession")
+			return errGet // 500
+		}
+		if session == nil || session.UserID != userID {
+			return model.NewNotFoundError("session not found") // 404
+		}
+
+		if err := z.sessionRepo.RevokeSession(ctx, tx, sessionID, time.Now()); err != nil {
+			logger.Error().Err(err).Msg("repo RevokeSession")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	action := model.AuditTerminateSession
+	if model.TokenFromContext(ctx).SessionID == sessionID {
+		action = model.AuditLogout
+	}
+	audit(ctx, z.auditor, model.AuditEvent{Action: action, TargetID: userID, Message: sessionID}, err)
+
+	if err == nil {
+		logger.Info().Str("UserID", userID).Str("SessionID", sessionID).Msg("session revoked")
+		disconnectSessions(z.pushMessenger, userID, []string{sessionID})
+	}
+
+	return err
+
+}
+
+func (z *SessionService) ListSessions(ctx context.Context, req model.ListSessionsRequest) model.ListSessionsResponse {
+
+	logger := logging.New(ctx, componentSessionService, "ListSessions")
+
+	var sessions []model.Session
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		now := time.Now()
+		ss, errSessions := z.sessionRepo.GetUserSessions(ctx, tx, req.UserID, &now)
+		if errSessions != nil {
+			logger.Error().Err(errSessions).Msg("repo GetUserSessions")
+			return errSessions // 500
+		}
+		sessions = ss
+		return nil
+	})
+
+	rsp := model.ListSessionsResponse{}
+
+	if err != nil {
+		rsp.Code = http.StatusInternalServerError
+		rsp.Message = err.Error()
+		return rsp
+	}
+
+	rsp.Code = http.StatusOK
+	rsp.Sessions = make([]model.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		rsp.Sessions = append(rsp.Sessions, model.SessionInfo{
+			ID:        s.ID,
+			Device:    describeDevice(s.UserAgent),
+			UserAgent: s.UserAgent,
+			IPAddress: s.IPAddress,
+			Created:   s.Issued,
+			LastSeen:  s.LastSeen,
+			Expires:   s.Expires,
+			Current:   s.ID == req.SessionID,
+			Connected: z.pushMessenger.IsClientConnected(req.UserID, s.ID),
+		})
+	}
+
+	return rsp
+
+}
+
+func (z *SessionService) TerminateSession(ctx context.Context, req model.TerminateSessionRequest) model.TerminateSessionResponse {
+
+	rsp := model.TerminateSessionResponse{}
+
+	if req.SessionID == req.CurrentSessionID {
+		rsp.Code = http.StatusBadRequest
+		rsp.Message = "cannot terminate current session, use logout"
+		return rsp
+	}
+
+	err := z.RevokeSession(ctx, req.UserID, req.SessionID)
+
+	if err != nil {
+		rsp.Message = err.Error()
+		if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// TerminateOtherSessions revokes all active sessions of the user except the current one.
+func (z *SessionService) TerminateOtherSessions(ctx context.Context, req model.TerminateOtherSessionsRequest) model.TerminateOtherSessionsResponse {
+
+	logger := logging.New(ctx, componentSessionService, "TerminateOtherSessions")
+
+	var revoked []string
+
+	err := z.db.Run(func(tx model.Transaction) error {
+		now := time.Now()
+		sessions, errSessions := z.sessionRepo.GetUserSessions(ctx, tx, req.UserID, &now)
+		if errSessions != nil {
+			logger.Error().Err(errSessions).Msg("repo GetUserSessions")
+			return errSessions // 500
+		}
+		for _, s := range sessions {
+			if s.ID == req.SessionID {
+				continue
+			}
+			if err := z.sessionRepo.RevokeSession(ctx, tx, s.ID, now); err != nil {
+				logger.Error().Err(err).Msg("repo RevokeSession")
+				return err // 500
+			}
+			revoked = append(revoked, s.ID)
+		}
+		return nil
+	})
+
+	rsp := model.TerminateOtherSessionsResponse{}
+
+	if err != nil {
+		rsp.Code = http.StatusInternalServerError
+		rsp.Message = err.Error()
+		return rsp
+	}
+
+	disconnectSessions(z.pushMessenger, req.UserID, revoked)
+
+	logger.Info().Str("UserID", req.UserID).Int("count", len(revoked)).Msg("sessions revoked")
+	rsp.Code = http.StatusOK
+	rsp.Count = len(revoked)
+
+	return rsp
+
+}
+
+// revokeUserSessions revokes all active sessions of the user and returns their IDs.
+func revokeUserSessions(ctx context.Context, tx model.Transaction, sessionRepo SessionRepository, userID string) ([]string, error) {
+
+	now := time.Now()
+	sessions, errSessions := sessionRepo.GetUserSessions(ctx, tx, userID, &now)
+	if errSessions != nil {
+		return nil, errSessions
+	}
+
+	var revoked []string
+	for _, s := range sessions {
+		if err := sessionRepo.RevokeSession(ctx, tx, s.ID, now); err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, s.ID)
+	}
+
+	return revoked, nil
+
+}
+
+// disconnectSessions closes the open inboxes of the given sessions on all instances,
+// which is why it does not ask if they are connected to this one.
+func disconnectSessions(pushMessenger PushMessenger, userID string, sessionIDs []string) {
+	for _, sessionID := range sessionIDs {
+		pushMessenger.DisconnectClient(userID, sessionID)
+	}
+}
+
+// describeDevice returns a short human-readable description of the browser and platform in a user agent.
+func describeDevice(userAgent string) string {
+
+	if len(userAgent) == 0 {
+		return "unknown"
+	}
+
+	// order matters: Chrome agents contain Safari, Edge and Opera agents contain Chrome
+	browsers := []struct{ token, name string }{
+		{"Edge/", "Edge"},
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	platforms := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "Chrome OS"},
+		{"Linux", "Linux"},
+	}
+
+	browser := ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+
+	return "unknown"
+
+}
+
+// truncate cuts value to at most length bytes without splitting a UTF-8 encoded rune.
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	for length > 0 && !utf8.RuneStart(value[length]) {
+		length--
+	}
+	return value[:length]
+}
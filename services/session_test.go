@@ -0,0 +1,877 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+)
+
+func TestValidateSession(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	revoked := now.Add(-time.Hour)
+
+	testCases := []struct {
+		Alias            string
+		UserID           string
+		SessionID        string
+		OutputSession    *model.Session
+		OutputGetError   error
+		OutputSetError   error
+		ExpectedValid    bool
+		ExpectedError    error
+		ExpectedSetCount int
+	}{
+		{
+			Alias:     "success",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour),
+				LastSeen: now,
+				Expires:  now.Add(time.Hour),
+			},
+			ExpectedValid:    true,
+			ExpectedSetCount: 0,
+		},
+		{
+			Alias:     "success with last seen update",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour),
+			},
+			ExpectedValid:    true,
+			ExpectedSetCount: 1,
+		},
+		{
+			Alias:     "last seen update fails",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour),
+			},
+			OutputSetError:   errors.New("just some error"),
+			ExpectedValid:    false,
+			ExpectedError:    errors.New("just some error"),
+			ExpectedSetCount: 1,
+		},
+		{
+			Alias:     "revoked",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour * 2),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour),
+				Revoked:  &revoked,
+			},
+			ExpectedValid: false,
+		},
+		{
+			Alias:     "expired",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-model.LoginTimeout * 2),
+				LastSeen: now.Add(-model.LoginTimeout),
+				Expires:  now.Add(-time.Hour),
+			},
+			ExpectedValid: false,
+		},
+		{
+			Alias:     "other user",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id2",
+				Issued:   now.Add(-time.Hour),
+				LastSeen: now,
+				Expires:  now.Add(time.Hour),
+			},
+			ExpectedValid: false,
+		},
+		{
+			Alias:         "not found",
+			UserID:        "id",
+			SessionID:     "S123",
+			OutputSession: nil,
+			ExpectedValid: false,
+		},
+		{
+			Alias:         "no session id",
+			UserID:        "id",
+			SessionID:     "",
+			OutputSession: nil,
+			ExpectedValid: false,
+		},
+		{
+			Alias:          "get session fails",
+			UserID:         "id",
+			SessionID:      "S123",
+			OutputGetError: errors.New("just some error"),
+			ExpectedValid:  false,
+			ExpectedError:  errors.New("just some error"),
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			db := &DatabaseMock{}
+			sessionRepo := &SessionRepositoryMock{
+				Session:  tCase.OutputSession,
+				GetError: tCase.OutputGetError,
+				SetError: tCase.OutputSetError,
+			}
+			sessionService := services.NewSessionService(db, sessionRepo, &UserRepositoryMock{}, &PushMessengerMock{})
+
+			valid, err := sessionService.ValidateSession(context.Background(), tCase.UserID, tCase.SessionID)
+
+			if got, want := valid, tCase.ExpectedValid; got != want {
+				t.Errorf("bad valid %t, expected %t", got, want)
+			}
+
+			if err == nil && tCase.ExpectedError != nil {
+				t.Errorf("nil error, expected %s", tCase.ExpectedError)
+			} else if err != nil && tCase.ExpectedError == nil {
+				t.Errorf("bad error %s, expected nil", err)
+			} else if err != nil && err.Error() != tCase.ExpectedError.Error() {
+				t.Errorf("bad error %s, expected %s", err, tCase.ExpectedError)
+			}
+
+			if got, want := sessionRepo.SetCount, tCase.ExpectedSetCount; got != want {
+				t.Errorf("bad set count %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRenewSession(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	revoked := now.Add(-time.Hour)
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+	}
+
+	testCases := []struct {
+		Alias            string
+		OutputSession    *model.Session
+		OutputUser       *model.User
+		OutputRoles      []model.UserRole
+		OutputRolesError error
+		ExpectedRenewed  bool
+		ExpectedRoles    []string
+		ExpectedLifetime time.Duration
+		ExpectedError    error
+	}{
+		{
+			Alias: "success",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour * 13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser: demouser,
+			OutputRoles: []model.UserRole{
+				{ID: model.RoleIDUser, Name: model.RoleNameUser},
+			},
+			ExpectedRenewed:  true,
+			ExpectedRoles:    []string{model.RoleNameUser},
+			ExpectedLifetime: model.LoginTimeout,
+		},
+		{
+			Alias: "limited by session lifetime",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-model.SessionLifetime + time.Hour*13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser:       demouser,
+			ExpectedRenewed:  true,
+			ExpectedLifetime: time.Hour * 13,
+		},
+		{
+			Alias: "session lifetime reached",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-model.SessionLifetime + time.Hour*11),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser:      demouser,
+			ExpectedRenewed: false,
+		},
+		{
+			Alias: "impersonation not renewed",
+			OutputSession: &model.Session{
+				ID:             "S123",
+				UserID:         "id",
+				Issued:         now.Add(-time.Minute * 40),
+				LastSeen:       now.Add(-time.Minute),
+				Expires:        now.Add(time.Minute * 20),
+				ImpersonatorID: "adminid",
+			},
+			OutputUser:      demouser,
+			ExpectedRenewed: false,
+		},
+		{
+			Alias: "revoked",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour * 13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+				Revoked:  &revoked,
+			},
+			OutputUser:      demouser,
+			ExpectedRenewed: false,
+		},
+		{
+			Alias: "other user",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id2",
+				Issued:   now.Add(-time.Hour * 13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser:      demouser,
+			ExpectedRenewed: false,
+		},
+		{
+			Alias: "disabled user",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour * 13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser: &model.User{
+				ID:       "id",
+				Disabled: true,
+			},
+			ExpectedRenewed: false,
+		},
+		{
+			Alias:           "not found",
+			ExpectedRenewed: false,
+		},
+		{
+			Alias: "roles lookup fails",
+			OutputSession: &model.Session{
+				ID:       "S123",
+				UserID:   "id",
+				Issued:   now.Add(-time.Hour * 13),
+				LastSeen: now.Add(-time.Hour),
+				Expires:  now.Add(time.Hour * 11),
+			},
+			OutputUser:       demouser,
+			OutputRolesError: errors.New("just some error"),
+			ExpectedRenewed:  false,
+			ExpectedError:    errors.New("just some error"),
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			sessionRepo := &SessionRepositoryMock{
+				Session: tCase.OutputSession,
+			}
+			userRepo := &UserRepositoryMock{
+				User:       tCase.OutputUser,
+				Roles:      tCase.OutputRoles,
+				RolesError: tCase.OutputRolesError,
+			}
+			pushMessenger := &PushMessengerMock{}
+			sessionService := services.NewSessionService(&DatabaseMock{}, sessionRepo, userRepo, pushMessenger)
+
+			sessionToken, err := sessionService.RenewSession(context.Background(), "id", "S123")
+
+			if !reflect.DeepEqual(err, tCase.ExpectedError) {
+				t.Errorf("bad error %v, expected %v", err, tCase.ExpectedError)
+			}
+
+			if got, want := sessionToken != nil, tCase.ExpectedRenewed; got != want {
+				t.Fatalf("bad renewed %t, expected %t", got, want)
+			}
+			if got, want := len(pushMessenger.Updated) != 0, tCase.ExpectedRenewed; got != want {
+				t.Errorf("bad token update %t, expected %t", got, want)
+			}
+
+			if !tCase.ExpectedRenewed {
+				if sessionRepo.SetCount != 0 {
+					t.Errorf("bad set count %d, expected 0", sessionRepo.SetCount)
+				}
+				return
+			}
+
+			if got, want := sessionToken.SessionID, "S123"; got != want {
+				t.Errorf("bad session id %s, expected %s", got, want)
+			}
+			if !reflect.DeepEqual(sessionToken.Roles, tCase.ExpectedRoles) {
+				t.Errorf("bad roles %v, expected %v", sessionToken.Roles, tCase.ExpectedRoles)
+			}
+			if got, want := sessionToken.Expires.Sub(sessionToken.Issued), tCase.ExpectedLifetime; got < want-time.Second || got > want {
+				t.Errorf("bad token lifetime %s, expected %s", got, want)
+			}
+			if sessionRepo.SavedSession == nil || !sessionRepo.SavedSession.Expires.Equal(sessionToken.Expires) {
+				t.Errorf("bad saved session %v, expected expiry %s", sessionRepo.SavedSession, sessionToken.Expires)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRevokeSession(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias               string
+		UserID              string
+		SessionID           string
+		OutputSession       *model.Session
+		OutputGetError      error
+		OutputRevokeError   error
+		ExpectedNotFound    bool
+		ExpectedError       error
+		ExpectedRevokeCount int
+	}{
+		{
+			Alias:     "success",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:      "S123",
+				UserID:  "id",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			ExpectedRevokeCount: 1,
+		},
+		{
+			Alias:     "other user",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:      "S123",
+				UserID:  "id2",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			ExpectedNotFound:    true,
+			ExpectedError:       errors.New("session not found"),
+			ExpectedRevokeCount: 0,
+		},
+		{
+			Alias:               "not found",
+			UserID:              "id",
+			SessionID:           "S123",
+			ExpectedNotFound:    true,
+			ExpectedError:       errors.New("session not found"),
+			ExpectedRevokeCount: 0,
+		},
+		{
+			Alias:               "get session fails",
+			UserID:              "id",
+			SessionID:           "S123",
+			OutputGetError:      errors.New("just some error"),
+			ExpectedError:       errors.New("just some error"),
+			ExpectedRevokeCount: 0,
+		},
+		{
+			Alias:     "revoke fails",
+			UserID:    "id",
+			SessionID: "S123",
+			OutputSession: &model.Session{
+				ID:      "S123",
+				UserID:  "id",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			OutputRevokeError:   errors.New("just some error"),
+			ExpectedError:       errors.New("just some error"),
+			ExpectedRevokeCount: 1,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			db := &DatabaseMock{}
+			sessionRepo := &SessionRepositoryMock{
+				Session:     tCase.OutputSession,
+				GetError:    tCase.OutputGetError,
+				RevokeError: tCase.OutputRevokeError,
+			}
+			sessionService := services.NewSessionService(db, sessionRepo, &UserRepositoryMock{}, &PushMessengerMock{})
+
+			err := sessionService.RevokeSession(context.Background(), tCase.UserID, tCase.SessionID)
+
+			if err == nil && tCase.ExpectedError != nil {
+				t.Errorf("nil error, expected %s", tCase.ExpectedError)
+			} else if err != nil && tCase.ExpectedError == nil {
+				t.Errorf("bad error %s, expected nil", err)
+			} else if err != nil && err.Error() != tCase.ExpectedError.Error() {
+				t.Errorf("bad error %s, expected %s", err, tCase.ExpectedError)
+			}
+
+			if got, want := model.IsNotFoundError(err), tCase.ExpectedNotFound; got != want {
+				t.Errorf("bad not found %t, expected %t", got, want)
+			}
+
+			if got, want := sessionRepo.RevokeCount, tCase.ExpectedRevokeCount; got != want {
+				t.Errorf("bad revoke count %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestListSessions(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	sessions := []model.Session{
+		{
+			ID:        "S123",
+			UserID:    "id",
+			Issued:    now,
+			LastSeen:  now,
+			Expires:   now.Add(time.Hour),
+			UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:65.0) Gecko/20100101 Firefox/65.0",
+			IPAddress: "10.0.0.1",
+		},
+		{
+			ID:        "S456",
+			UserID:    "id",
+			Issued:    now.Add(-time.Hour),
+			LastSeen:  now.Add(-time.Minute),
+			Expires:   now.Add(time.Hour),
+			UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 12_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0 Mobile/15E148 Safari/604.1",
+			IPAddress: "10.0.0.2",
+		},
+		{
+			ID:        "S789",
+			UserID:    "id",
+			Issued:    now.Add(-time.Hour * 2),
+			LastSeen:  now.Add(-time.Hour),
+			Expires:   now.Add(time.Hour),
+			UserAgent: "",
+			IPAddress: "10.0.0.3",
+		},
+	}
+
+	testCases := []struct {
+		Alias               string
+		OutputSessions      []model.Session
+		OutputSessionsError error
+		Connected           map[string]bool
+		Request             model.ListSessionsRequest
+		ExpectedCode        int
+		ExpectedMessage     string
+		ExpectedSessions    []model.SessionInfo
+	}{
+		{
+			Alias:          "success",
+			OutputSessions: sessions,
+			Connected: map[string]bool{
+				"S456": true,
+			},
+			Request: model.ListSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode: http.StatusOK,
+			ExpectedSessions: []model.SessionInfo{
+				{
+					ID:        "S123",
+					Device:    "Firefox on Linux",
+					UserAgent: sessions[0].UserAgent,
+					IPAddress: "10.0.0.1",
+					Created:   sessions[0].Issued,
+					LastSeen:  sessions[0].LastSeen,
+					Expires:   sessions[0].Expires,
+					Current:   true,
+					Connected: false,
+				},
+				{
+					ID:        "S456",
+					Device:    "Safari on iOS",
+					UserAgent: sessions[1].UserAgent,
+					IPAddress: "10.0.0.2",
+					Created:   sessions[1].Issued,
+					LastSeen:  sessions[1].LastSeen,
+					Expires:   sessions[1].Expires,
+					Current:   false,
+					Connected: true,
+				},
+				{
+					ID:        "S789",
+					Device:    "unknown",
+					UserAgent: "",
+					IPAddress: "10.0.0.3",
+					Created:   sessions[2].Issued,
+					LastSeen:  sessions[2].LastSeen,
+					Expires:   sessions[2].Expires,
+					Current:   false,
+					Connected: false,
+				},
+			},
+		},
+		{
+			Alias:          "no sessions",
+			OutputSessions: []model.Session{},
+			Request: model.ListSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedSessions: []model.SessionInfo{},
+		},
+		{
+			Alias:               "get sessions fails",
+			OutputSessionsError: errors.New("just some error"),
+			Request: model.ListSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			db := &DatabaseMock{}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions:      tCase.OutputSessions,
+				SessionsError: tCase.OutputSessionsError,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: tCase.Connected,
+			}
+			sessionService := services.NewSessionService(db, sessionRepo, &UserRepositoryMock{}, pushMessenger)
+
+			rsp := sessionService.ListSessions(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if !reflect.DeepEqual(rsp.Sessions, tCase.ExpectedSessions) {
+				t.Errorf("bad response sessions %#v, expected %#v", rsp.Sessions, tCase.ExpectedSessions)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestTerminateSession(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias                string
+		OutputSession        *model.Session
+		Connected            map[string]bool
+		Request              model.TerminateSessionRequest
+		ExpectedCode         int
+		ExpectedMessage      string
+		ExpectedDisconnected []string
+	}{
+		{
+			Alias: "success connected",
+			OutputSession: &model.Session{
+				ID:      "S456",
+				UserID:  "id",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			Connected: map[string]bool{
+				"S456": true,
+			},
+			Request: model.TerminateSessionRequest{
+				UserID:           "id",
+				SessionID:        "S456",
+				CurrentSessionID: "S123",
+			},
+			ExpectedCode:         http.StatusOK,
+			ExpectedDisconnected: []string{"S456"},
+		},
+		{
+			Alias: "success connected elsewhere",
+			OutputSession: &model.Session{
+				ID:      "S456",
+				UserID:  "id",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			Request: model.TerminateSessionRequest{
+				UserID:           "id",
+				SessionID:        "S456",
+				CurrentSessionID: "S123",
+			},
+			ExpectedCode:         http.StatusOK,
+			ExpectedDisconnected: []string{"S456"}, // on every instance
+		},
+		{
+			Alias: "current session",
+			OutputSession: &model.Session{
+				ID:      "S123",
+				UserID:  "id",
+				Issued:  now,
+				Expires: now.Add(time.Hour),
+			},
+			Request: model.TerminateSessionRequest{
+				UserID:           "id",
+				SessionID:        "S123",
+				CurrentSessionID: "S123",
+			},
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "cannot terminate current session, use logout",
+		},
+		{
+			Alias:         "not found",
+			OutputSession: nil,
+			Request: model.TerminateSessionRequest{
+				UserID:           "id",
+				SessionID:        "S456",
+				CurrentSessionID: "S123",
+			},
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "session not found",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			db := &DatabaseMock{}
+			sessionRepo := &SessionRepositoryMock{
+				Session: tCase.OutputSession,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: tCase.Connected,
+			}
+			sessionService := services.NewSessionService(db, sessionRepo, &UserRepositoryMock{}, pushMessenger)
+
+			rsp := sessionService.TerminateSession(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := strings.Join(pushMessenger.Disconnected, ","), strings.Join(tCase.ExpectedDisconnected, ","); got != want {
+				t.Errorf("bad disconnected sessions %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestTerminateOtherSessions(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	sessions := []model.Session{
+		{
+			ID:      "S123",
+			UserID:  "id",
+			Issued:  now,
+			Expires: now.Add(time.Hour),
+		},
+		{
+			ID:      "S456",
+			UserID:  "id",
+			Issued:  now,
+			Expires: now.Add(time.Hour),
+		},
+		{
+			ID:      "S789",
+			UserID:  "id",
+			Issued:  now,
+			Expires: now.Add(time.Hour),
+		},
+	}
+
+	testCases := []struct {
+		Alias                string
+		OutputSessions       []model.Session
+		OutputSessionsError  error
+		OutputRevokeError    error
+		Connected            map[string]bool
+		Request              model.TerminateOtherSessionsRequest
+		ExpectedCode         int
+		ExpectedMessage      string
+		ExpectedCount        int
+		ExpectedRevoked      []string
+		ExpectedDisconnected []string
+	}{
+		{
+			Alias:          "success",
+			OutputSessions: sessions,
+			Connected: map[string]bool{
+				"S123": true,
+				"S789": true,
+			},
+			Request: model.TerminateOtherSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:         http.StatusOK,
+			ExpectedCount:        2,
+			ExpectedRevoked:      []string{"S456", "S789"},
+			ExpectedDisconnected: []string{"S456", "S789"},
+		},
+		{
+			Alias:          "only current",
+			OutputSessions: sessions[:1],
+			Request: model.TerminateOtherSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:  http.StatusOK,
+			ExpectedCount: 0,
+		},
+		{
+			Alias:               "get sessions fails",
+			OutputSessionsError: errors.New("just some error"),
+			Request: model.TerminateOtherSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+		},
+		{
+			Alias:             "revoke fails",
+			OutputSessions:    sessions,
+			OutputRevokeError: errors.New("just some error"),
+			Connected: map[string]bool{
+				"S456": true,
+			},
+			Request: model.TerminateOtherSessionsRequest{
+				UserID:    "id",
+				SessionID: "S123",
+			},
+			ExpectedCode:    http.StatusInternalServerError,
+			ExpectedMessage: "just some error",
+			ExpectedRevoked: []string{"S456"},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			db := &DatabaseMock{}
+			sessionRepo := &SessionRepositoryMock{
+				Sessions:      tCase.OutputSessions,
+				SessionsError: tCase.OutputSessionsError,
+				RevokeError:   tCase.OutputRevokeError,
+			}
+			pushMessenger := &PushMessengerMock{
+				Connected: tCase.Connected,
+			}
+			sessionService := services.NewSessionService(db, sessionRepo, &UserRepositoryMock{}, pushMessenger)
+
+			rsp := sessionService.TerminateOtherSessions(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMessage; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.Count, tCase.ExpectedCount; got != want {
+				t.Errorf("bad response count %d, expected %d", got, want)
+			}
+
+			if got, want := strings.Join(sessionRepo.RevokedIDs, ","), strings.Join(tCase.ExpectedRevoked, ","); got != want {
+				t.Errorf("bad revoked sessions %s, expected %s", got, want)
+			}
+
+			if got, want := strings.Join(pushMessenger.Disconnected, ","), strings.Join(tCase.ExpectedDisconnected, ","); got != want {
+				t.Errorf("bad disconnected sessions %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
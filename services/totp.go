@@ -0,0 +1,453 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+	"wallawire/totp"
+)
+
+const (
+	totpIssuer        = "wallawire"
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10 // bytes, 16 characters when encoded
+)
+
+// EnrollTOTP generates a new TOTP secret for the user which must be confirmed with ConfirmTOTP
+// before two-factor authentication is enabled. A previous unconfirmed enrollment is replaced.
+func (z *UserService) EnrollTOTP(ctx context.Context, req model.EnrollTOTPRequest) model.EnrollTOTPResponse {
+
+	logger := logging.New(ctx, componentUserService, "EnrollTOTP")
+
+	secret, errSecret := totp.NewSecret()
+	if errSecret != nil {
+		logger.Error().Err(errSecret).Msg("totp NewSecret")
+		return model.EnrollTOTPResponse{
+			Code:    http.StatusInternalServerError,
+			Message: errSecret.Error(),
+		}
+	}
+
+	var user *model.User
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+		if !u.MatchPassword(req.Password) {
+			return model.NewValidationError("password incorrect") // 400
+		}
+
+		userTOTP, errTOTP := z.userRepo.GetUserTOTP(ctx, tx, u.ID)
+		if errTOTP != nil {
+			logger.Error().Err(errTOTP).Msg("repo GetUserTOTP")
+			return errTOTP // 500
+		}
+		if userTOTP.IsEnabled() {
+			return model.NewValidationError("two-factor authentication already enabled") // 400
+		}
+
+		pending := model.UserTOTP{
+			UserID:  u.ID,
+			Secret:  secret,
+			Created: time.Now(),
+		}
+		if err := z.userRepo.SetUserTOTP(ctx, tx, pending); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserTOTP")
+			return err // 500
+		}
+
+		user = u
+		return nil
+
+	})
+
+	rsp := model.EnrollTOTPResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot enroll totp")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Debug().Msg("totp enrollment started")
+		rsp.Code = http.StatusOK
+		rsp.Secret = secret
+		rsp.URI = totp.URI(totpIssuer, user.Username, secret)
+	}
+
+	return rsp
+
+}
+
+// ConfirmTOTP enables two-factor authentication if the code matches the pending enrollment.
+// The returned recovery codes are only available in plain text in this response.
+func (z *UserService) ConfirmTOTP(ctx context.Context, req model.ConfirmTOTPRequest) model.ConfirmTOTPResponse {
+
+	logger := logging.New(ctx, componentUserService, "ConfirmTOTP")
+
+	codes, errCodes := newRecoveryCodes(recoveryCodeCount)
+	if errCodes != nil {
+		logger.Error().Err(errCodes).Msg("cannot generate recovery codes")
+		return model.ConfirmTOTPResponse{
+			Code:    http.StatusInternalServerError,
+			Message: errCodes.Error(),
+		}
+	}
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		userTOTP, errTOTP := z.userRepo.GetUserTOTP(ctx, tx, req.UserID)
+		if errTOTP != nil {
+			logger.Error().Err(errTOTP).Msg("repo GetUserTOTP")
+			return errTOTP // 500
+		}
+		if userTOTP == nil {
+			return model.NewNotFoundError("two-factor enrollment not found") // 404
+		}
+		if userTOTP.IsEnabled() {
+			return model.NewValidationError("two-factor authentication already enabled") // 400
+		}
+
+		now := time.Now()
+		counter, ok := totp.Validate(userTOTP.Secret, req.Code, now)
+		if !ok {
+			return model.NewValidationError("invalid code") // 400
+		}
+
+		userTOTP.Confirmed = &now
+		userTOTP.LastCounter = counter
+		if err := z.userRepo.SetUserTOTP(ctx, tx, *userTOTP); err != nil {
+			logger.Error().Err(err).Msg("repo SetUserTOTP")
+			return err // 500
+		}
+
+		var hashes []string
+		for _, code := range codes {
+			hashes = append(hashes, model.HashRecoveryCode(code))
+		}
+		if err := z.userRepo.SetRecoveryCodes(ctx, tx, req.UserID, hashes); err != nil {
+			logger.Error().Err(err).Msg("repo SetRecoveryCodes")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.ConfirmTOTPResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot confirm totp")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Msg("two-factor authentication enabled")
+		rsp.Code = http.StatusOK
+		rsp.RecoveryCodes = codes
+	}
+
+	return rsp
+
+}
+
+// DisableTOTP turns off two-factor authentication, requiring both the password and a current code or recovery code.
+func (z *UserService) DisableTOTP(ctx context.Context, req model.DisableTOTPRequest) model.DisableTOTPResponse {
+
+	logger := logging.New(ctx, componentUserService, "DisableTOTP")
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		u, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if u == nil {
+			return model.NewNotFoundError("user not found") // 404
+		}
+		if !u.MatchPassword(req.Password) {
+			return model.NewValidationError("password incorrect") // 400
+		}
+
+		userTOTP, errTOTP := z.userRepo.GetUserTOTP(ctx, tx, u.ID)
+		if errTOTP != nil {
+			logger.Error().Err(errTOTP).Msg("repo GetUserTOTP")
+			return errTOTP // 500
+		}
+		if !userTOTP.IsEnabled() {
+			return model.NewValidationError("two-factor authentication not enabled") // 400
+		}
+
+		ok, errVerify := z.verifySecondFactor(ctx, tx, userTOTP, req.Code, time.Now())
+		if errVerify != nil {
+			logger.Error().Err(errVerify).Msg("cannot verify second factor")
+			return errVerify // 500
+		}
+		if !ok {
+			return model.NewValidationError("invalid code") // 400
+		}
+
+		if err := z.userRepo.DeleteUserTOTP(ctx, tx, u.ID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteUserTOTP")
+			return err // 500
+		}
+
+		return nil
+
+	})
+
+	rsp := model.DisableTOTPResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot disable totp")
+		rsp.Message = err.Error()
+		if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else if model.IsNotFoundError(err) {
+			rsp.Code = http.StatusNotFound
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		logger.Info().Str("UserID", req.UserID).Msg("two-factor authentication disabled")
+		rsp.Code = http.StatusOK
+	}
+
+	return rsp
+
+}
+
+// LoginOTP completes a login for a user with two-factor authentication enabled
+// after the password has been verified by Login.
+// The challenge issued by Login is consumed by a successful login or after too many wrong codes,
+// which count as failed logins of the username and IP address like wrong passwords.
+func (z *UserService) LoginOTP(ctx context.Context, req model.LoginOTPRequest) model.LoginResponse {
+
+	logger := logging.New(ctx, componentUserService, "LoginOTP")
+
+	if len(req.UserID) == 0 || len(strings.TrimSpace(req.Code)) == 0 {
+		msg := "invalid code"
+		logger.Debug().Msg(msg)
+		return model.LoginResponse{
+			Code:    http.StatusBadRequest,
+			Message: msg,
+		}
+	}
+
+	var user *model.User
+	var roles []model.UserRole
+	var errLogin error
+	var lockedUser *model.User
+	var lockedUntil time.Time
+
+	sessionID := z.idgen.NewID()
+	issued := z.clock().Truncate(time.Minute)
+	expires := issued.Add(model.LoginTimeout)
+
+	err := z.db.Run(func(tx model.Transaction) error {
+
+		now := z.clock()
+		challenge, errChallenge := z.userRepo.GetLoginChallenge(ctx, tx, req.ChallengeID)
+		if errChallenge != nil {
+			logger.Error().Err(errChallenge).Msg("repo GetLoginChallenge")
+			return errChallenge // 500
+		}
+		if !challenge.IsValid(req.UserID, now) {
+			return model.NewValidationError("invalid or expired challenge") // 400
+		}
+
+		usr, errGet := z.userRepo.GetUser(ctx, tx, req.UserID)
+		if errGet != nil {
+			logger.Error().Err(errGet).Msg("repo GetUser")
+			return errGet // 500
+		}
+		if usr == nil || usr.Disabled {
+			return model.NewValidationError("invalid code") // 400
+		}
+
+		throttles, errThrottles := z.getLoginThrottles(ctx, tx, model.LoginRequest{Username: usr.Username, IPAddress: req.IPAddress})
+		if errThrottles != nil {
+			logger.Error().Err(errThrottles).Msg("repo GetLoginThrottle")
+			return errThrottles // 500
+		}
+		var retryAfter time.Duration
+		for _, throttle := range throttles {
+			if d := throttle.RetryAfter(now); d > retryAfter {
+				retryAfter = d
+			}
+		}
+		if retryAfter > 0 {
+			return model.NewLockedError("too many failed logins", retryAfter) // 429
+		}
+
+		userTOTP, errTOTP := z.userRepo.GetUserTOTP(ctx, tx, usr.ID)
+		if errTOTP != nil {
+			logger.Error().Err(errTOTP).Msg("repo GetUserTOTP")
+			return errTOTP // 500
+		}
+		if !userTOTP.IsEnabled() {
+			return model.NewValidationError("invalid code") // 400
+		}
+
+		ok, errVerify := z.verifySecondFactor(ctx, tx, userTOTP, req.Code, now)
+		if errVerify != nil {
+			logger.Error().Err(errVerify).Msg("cannot verify second factor")
+			return errVerify // 500
+		}
+		if !ok {
+			errLogin = model.NewValidationError("invalid code") // 400
+			lockout, usernameLockedUntil, errFailures := z.addLoginFailures(ctx, tx, throttles, now)
+			if errFailures != nil {
+				return errFailures // 500
+			}
+			if lockout > 0 {
+				errLogin = model.NewLockedError("too many failed logins", lockout) // 429
+			}
+			if usernameLockedUntil != nil {
+				lockedUser = usr
+				lockedUntil = *usernameLockedUntil
+			}
+			challenge.Failures++
+			if challenge.Failures >= model.LoginChallengeMaxFailures || lockout > 0 {
+				if err := z.userRepo.DeleteLoginChallenge(ctx, tx, challenge.ID); err != nil {
+					logger.Error().Err(err).Msg("repo DeleteLoginChallenge")
+					return err // 500
+				}
+			} else if err := z.userRepo.SetLoginChallenge(ctx, tx, *challenge); err != nil {
+				logger.Error().Err(err).Msg("repo SetLoginChallenge")
+				return err // 500
+			}
+			return nil // commit the failure
+		}
+
+		if err := z.userRepo.DeleteLoginChallenge(ctx, tx, challenge.ID); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteLoginChallenge")
+			return err // 500
+		}
+		if err := z.userRepo.DeleteLoginThrottle(ctx, tx, model.ThrottleUsername, usr.Username); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteLoginThrottle")
+			return err // 500
+		}
+		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, usr.ID, &now)
+		if errRoles != nil {
+			return errRoles
+		}
+		session := newSession(sessionID, usr.ID, req.UserAgent, req.IPAddress, issued, now, expires)
+		if err := z.sessionRepo.SetSession(ctx, tx, session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err // 500
+		}
+		user = usr
+		roles = rs
+		return nil
+
+	})
+	if err == nil {
+		err = errLogin
+	}
+
+	if lockedUser != nil {
+		logger.Warn().Str("username", lockedUser.Username).Str("UserID", lockedUser.ID).Time("lockedUntil", lockedUntil).Msg("account locked")
+		z.sendLockout(ctx, lockedUser.ID, lockedUntil)
+	}
+
+	event := model.AuditEvent{Action: model.AuditLoginOTP, TargetID: req.UserID, IPAddress: req.IPAddress, UserAgent: req.UserAgent}
+	if err == nil {
+		event.ActorID = user.ID
+		event.ActorName = user.Username
+	}
+	audit(ctx, z.auditor, event, err)
+
+	rsp := model.LoginResponse{}
+
+	if err != nil {
+		logger.Debug().Err(err).Msg("cannot login")
+		rsp.Message = err.Error()
+		if lockedErr, ok := err.(*model.LockedError); ok {
+			rsp.Code = http.StatusTooManyRequests
+			rsp.RetryAfter = lockedErr.RetryAfter
+		} else if model.IsValidationError(err) {
+			rsp.Code = http.StatusBadRequest
+		} else {
+			rsp.Code = http.StatusInternalServerError
+		}
+	} else {
+		rsp.Code = http.StatusOK
+		rsp.SessionToken = model.ToSessionToken(sessionID, user, roles, issued, expires)
+		logger.Info().Str("username", user.Username).Str("UserID", user.ID).Str("SessionID", sessionID).Msg("login")
+	}
+
+	return rsp
+
+}
+
+// verifySecondFactor accepts either a current TOTP code, which may not be reused, or an unused recovery code.
+func (z *UserService) verifySecondFactor(ctx context.Context, tx model.Transaction, userTOTP *model.UserTOTP, code string, now time.Time) (bool, error) {
+
+	code = strings.TrimSpace(code)
+
+	if isNumeric(code) {
+		counter, ok := totp.Validate(userTOTP.Secret, code, now)
+		if !ok || counter <= userTOTP.LastCounter {
+			return false, nil
+		}
+		userTOTP.LastCounter = counter
+		if err := z.userRepo.SetUserTOTP(ctx, tx, *userTOTP); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return z.userRepo.UseRecoveryCode(ctx, tx, userTOTP.UserID, model.HashRecoveryCode(code), now)
+
+}
+
+func newRecoveryCodes(count int) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, count)
+	for i := range codes {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+	}
+	return codes, nil
+}
+
+func isNumeric(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
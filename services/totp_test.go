@@ -0,0 +1,710 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/services"
+	"wallawire/totp"
+)
+
+const (
+	testSecret       = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	testRecoveryCode = "abcd-efgh-ijkl-mnop"
+)
+
+func currentCode(offset int64) string {
+	code, err := totp.Code(testSecret, totp.Counter(time.Now())+offset)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+func TestEnrollTOTP(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := func() *model.User {
+		u := &model.User{
+			ID:       "id",
+			Username: "demouser",
+			Name:     "Demo User",
+			Created:  now,
+			Updated:  now,
+		}
+		if err := u.SetPassword("demouser"); err != nil {
+			b.Fatal(err)
+		}
+		return u
+	}
+
+	testCases := []struct {
+		Alias          string
+		OutputUser     *model.User
+		OutputGetError error
+		OutputTOTP     *model.UserTOTP
+		OutputSetError error
+		Request        model.EnrollTOTPRequest
+		ExpectedCode   int
+		ExpectedMsg    string
+	}{
+		{
+			Alias:      "success",
+			OutputUser: demouser(),
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Alias:      "replace pending enrollment",
+			OutputUser: demouser(),
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now},
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Alias:      "already enabled",
+			OutputUser: demouser(),
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "two-factor authentication already enabled",
+		},
+		{
+			Alias:      "bad password",
+			OutputUser: demouser(),
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser2",
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "password incorrect",
+		},
+		{
+			Alias:      "user not found",
+			OutputUser: nil,
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+			},
+			ExpectedCode: http.StatusNotFound,
+			ExpectedMsg:  "user not found",
+		},
+		{
+			Alias:          "set fails",
+			OutputUser:     demouser(),
+			OutputSetError: errors.New("just some error"),
+			Request: model.EnrollTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+			},
+			ExpectedCode: http.StatusInternalServerError,
+			ExpectedMsg:  "just some error",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:         tCase.OutputUser,
+				GetError:     tCase.OutputGetError,
+				TOTP:         tCase.OutputTOTP,
+				SetTOTPError: tCase.OutputSetError,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := userService.EnrollTOTP(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if rsp.Code == http.StatusOK {
+				if userRepo.SavedTOTP == nil {
+					t.Fatal("nil saved totp, expected non-nil")
+				}
+				if got, want := rsp.Secret, userRepo.SavedTOTP.Secret; got != want {
+					t.Errorf("bad response secret %s, expected %s", got, want)
+				}
+				if userRepo.SavedTOTP.IsEnabled() {
+					t.Error("bad saved totp enabled: true, expected false")
+				}
+				if !strings.HasPrefix(rsp.URI, "otpauth://totp/wallawire:demouser?") {
+					t.Errorf("bad response uri %s", rsp.URI)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestConfirmTOTP(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		Alias        string
+		OutputTOTP   *model.UserTOTP
+		Request      model.ConfirmTOTPRequest
+		ExpectedCode int
+		ExpectedMsg  string
+	}{
+		{
+			Alias:      "success",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now},
+			Request: model.ConfirmTOTPRequest{
+				UserID: "id",
+				Code:   currentCode(0),
+			},
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Alias:      "bad code",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now},
+			Request: model.ConfirmTOTPRequest{
+				UserID: "id",
+				Code:   currentCode(-5),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid code",
+		},
+		{
+			Alias:      "not enrolled",
+			OutputTOTP: nil,
+			Request: model.ConfirmTOTPRequest{
+				UserID: "id",
+				Code:   currentCode(0),
+			},
+			ExpectedCode: http.StatusNotFound,
+			ExpectedMsg:  "two-factor enrollment not found",
+		},
+		{
+			Alias:      "already enabled",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.ConfirmTOTPRequest{
+				UserID: "id",
+				Code:   currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "two-factor authentication already enabled",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				TOTP: tCase.OutputTOTP,
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := userService.ConfirmTOTP(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if rsp.Code == http.StatusOK {
+				if got, want := len(rsp.RecoveryCodes), 10; got != want {
+					t.Fatalf("bad recovery code count %d, expected %d", got, want)
+				}
+				for _, code := range rsp.RecoveryCodes {
+					if !userRepo.RecoveryCodes[model.HashRecoveryCode(code)] {
+						t.Errorf("recovery code not stored: %s", code)
+					}
+				}
+				if !userRepo.SavedTOTP.IsEnabled() {
+					t.Error("bad saved totp enabled: false, expected true")
+				}
+			} else if rsp.RecoveryCodes != nil {
+				t.Errorf("bad recovery codes %v, expected nil", rsp.RecoveryCodes)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestDisableTOTP(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+		Created:  now,
+		Updated:  now,
+	}
+	if err := demouser.SetPassword("demouser"); err != nil {
+		b.Fatal(err)
+	}
+
+	testCases := []struct {
+		Alias           string
+		OutputTOTP      *model.UserTOTP
+		Request         model.DisableTOTPRequest
+		ExpectedCode    int
+		ExpectedMsg     string
+		ExpectedDeleted bool
+	}{
+		{
+			Alias:      "success with code",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.DisableTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+				Code:     currentCode(0),
+			},
+			ExpectedCode:    http.StatusOK,
+			ExpectedDeleted: true,
+		},
+		{
+			Alias:      "success with recovery code",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.DisableTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+				Code:     strings.ToUpper(testRecoveryCode),
+			},
+			ExpectedCode:    http.StatusOK,
+			ExpectedDeleted: true,
+		},
+		{
+			Alias:      "bad password",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.DisableTOTPRequest{
+				UserID:   "id",
+				Password: "demouser2",
+				Code:     currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "password incorrect",
+		},
+		{
+			Alias:      "bad code",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now, Confirmed: &now},
+			Request: model.DisableTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+				Code:     "000000x",
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid code",
+		},
+		{
+			Alias:      "not enabled",
+			OutputTOTP: &model.UserTOTP{UserID: "id", Secret: testSecret, Created: now},
+			Request: model.DisableTOTPRequest{
+				UserID:   "id",
+				Password: "demouser",
+				Code:     currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "two-factor authentication not enabled",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:          demouser,
+				TOTP:          tCase.OutputTOTP,
+				RecoveryCodes: map[string]bool{model.HashRecoveryCode(testRecoveryCode): true},
+			}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, &IdGeneratorMock{})
+
+			rsp := userService.DisableTOTP(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.DeletedTOTP, tCase.ExpectedDeleted; got != want {
+				t.Errorf("bad deleted %t, expected %t", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestLoginOTP(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := func(disabled bool) *model.User {
+		return &model.User{
+			ID:       "id",
+			Disabled: disabled,
+			Username: "demouser",
+			Name:     "Demo User",
+			Created:  now,
+			Updated:  now,
+		}
+	}
+
+	enabledTOTP := func(lastCounter int64) *model.UserTOTP {
+		return &model.UserTOTP{
+			UserID:      "id",
+			Secret:      testSecret,
+			LastCounter: lastCounter,
+			Created:     now,
+			Confirmed:   &now,
+		}
+	}
+
+	challenge := func(userID string, expires time.Time, failures int) *model.LoginChallenge {
+		return &model.LoginChallenge{
+			ID:       "C123",
+			UserID:   userID,
+			Expires:  expires,
+			Failures: failures,
+		}
+	}
+
+	lockedUntil := now.Add(time.Minute)
+
+	testCases := []struct {
+		Alias             string
+		OutputUser        *model.User
+		OutputTOTP        *model.UserTOTP
+		OutputChallenge   *model.LoginChallenge
+		OutputThrottles   map[string]*model.LoginThrottle
+		OutputSetError    error
+		Request           model.LoginOTPRequest
+		ExpectedCode      int
+		ExpectedMsg       string
+		ExpectedSession   bool
+		ExpectedConsumed  bool
+		ExpectedFailures  int // of the saved challenge
+		ExpectedThrottles int // saved failures
+		ExpectedLockout   bool
+	}{
+		{
+			Alias:           "success with code",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedSession:  true,
+			ExpectedConsumed: true,
+		},
+		{
+			Alias:           "success with recovery code",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        testRecoveryCode,
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedSession:  true,
+			ExpectedConsumed: true,
+		},
+		{
+			Alias:           "code reused",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(totp.Counter(time.Now()) + 1),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode:      http.StatusBadRequest,
+			ExpectedMsg:       "invalid code",
+			ExpectedFailures:  1,
+			ExpectedThrottles: 1,
+		},
+		{
+			Alias:           "unknown recovery code",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        "aaaa-bbbb-cccc-dddd",
+				IPAddress:   "192.0.2.1",
+			},
+			ExpectedCode:      http.StatusBadRequest,
+			ExpectedMsg:       "invalid code",
+			ExpectedFailures:  1,
+			ExpectedThrottles: 2,
+		},
+		{
+			Alias:           "last failure consumes challenge",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), model.LoginChallengeMaxFailures-1),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        "aaaa-bbbb-cccc-dddd",
+			},
+			ExpectedCode:      http.StatusBadRequest,
+			ExpectedMsg:       "invalid code",
+			ExpectedConsumed:  true,
+			ExpectedThrottles: 1,
+		},
+		{
+			Alias:           "failure locks username",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {
+					Scope:       model.ThrottleUsername,
+					Subject:     "demouser",
+					Failures:    model.UsernameThrottlePolicy.Threshold - 1,
+					LastFailure: now,
+				},
+			},
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        "aaaa-bbbb-cccc-dddd",
+			},
+			ExpectedCode:      http.StatusTooManyRequests,
+			ExpectedMsg:       "too many failed logins",
+			ExpectedConsumed:  true,
+			ExpectedThrottles: 1,
+			ExpectedLockout:   true,
+		},
+		{
+			Alias:           "username locked",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {
+					Scope:       model.ThrottleUsername,
+					Subject:     "demouser",
+					Failures:    model.UsernameThrottlePolicy.Threshold,
+					LastFailure: now,
+					LockedUntil: &lockedUntil,
+				},
+			},
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusTooManyRequests,
+			ExpectedMsg:  "too many failed logins",
+		},
+		{
+			Alias:           "unknown challenge",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: nil,
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid or expired challenge",
+		},
+		{
+			Alias:           "expired challenge",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(-time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid or expired challenge",
+		},
+		{
+			Alias:           "challenge of other user",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("other", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid or expired challenge",
+		},
+		{
+			Alias:           "user disabled",
+			OutputUser:      demouser(true),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid code",
+		},
+		{
+			Alias:           "not enabled",
+			OutputUser:      demouser(false),
+			OutputTOTP:      nil,
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid code",
+		},
+		{
+			Alias:           "missing code",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        " ",
+			},
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedMsg:  "invalid code",
+		},
+		{
+			Alias:           "set session fails",
+			OutputUser:      demouser(false),
+			OutputTOTP:      enabledTOTP(0),
+			OutputChallenge: challenge("id", now.Add(time.Minute), 0),
+			OutputSetError:  errors.New("just some error"),
+			Request: model.LoginOTPRequest{
+				UserID:      "id",
+				ChallengeID: "C123",
+				Code:        currentCode(0),
+			},
+			ExpectedCode:     http.StatusInternalServerError,
+			ExpectedMsg:      "just some error",
+			ExpectedConsumed: true, // rolled back
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:          tCase.OutputUser,
+				TOTP:          tCase.OutputTOTP,
+				Challenge:     tCase.OutputChallenge,
+				Throttles:     tCase.OutputThrottles,
+				RecoveryCodes: map[string]bool{model.HashRecoveryCode(testRecoveryCode): true},
+			}
+			sessionRepo := &SessionRepositoryMock{
+				SetError: tCase.OutputSetError,
+			}
+			pushMessenger := &PushMessengerMock{}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, sessionRepo, pushMessenger, &IdGeneratorMock{ID: "S123"})
+			userService.SetClock(func() time.Time { return now })
+
+			rsp := userService.LoginOTP(context.Background(), tCase.Request)
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.Message, tCase.ExpectedMsg; got != want {
+				t.Errorf("bad response message %s, expected %s", got, want)
+			}
+
+			if got, want := userRepo.DeletedChallenge == "C123", tCase.ExpectedConsumed; got != want {
+				t.Errorf("bad challenge consumed %t, expected %t", got, want)
+			}
+
+			failures := 0
+			if userRepo.SavedChallenge != nil {
+				failures = userRepo.SavedChallenge.Failures
+			}
+			if got, want := failures, tCase.ExpectedFailures; got != want {
+				t.Errorf("bad challenge failures %d, expected %d", got, want)
+			}
+
+			if got, want := len(userRepo.SavedThrottles), tCase.ExpectedThrottles; got != want {
+				t.Errorf("bad saved throttles %d, expected %d", got, want)
+			}
+
+			if got, want := len(pushMessenger.Sent) != 0, tCase.ExpectedLockout; got != want {
+				t.Errorf("bad lockout sent %t, expected %t", got, want)
+			}
+
+			if got, want := rsp.SessionToken != nil, tCase.ExpectedSession; got != want {
+				t.Fatalf("bad session token %t, expected %t", got, want)
+			}
+
+			if rsp.SessionToken != nil {
+				if got, want := rsp.SessionToken.SessionID, "S123"; got != want {
+					t.Errorf("bad response sessiontoken SessionID %s, expected %s", got, want)
+				}
+				if got, want := sessionRepo.SetCount, 1; got != want {
+					t.Errorf("bad session count %d, expected %d", got, want)
+				}
+				if got, want := strings.Join(userRepo.DeletedThrottles, ","), "username:demouser"; got != want {
+					t.Errorf("bad deleted throttles %s, expected %s", got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
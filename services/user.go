@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
@@ -20,18 +21,42 @@ type UserRepository interface {
 	GetUserRoles(context.Context, model.ReadOnlyTransaction, string, *time.Time) ([]model.UserRole, error)
 	IsUsernameAvailable(context.Context, model.ReadOnlyTransaction, string) (bool, error)
 	SetUser(context.Context, model.WriteOnlyTransaction, model.User) error
+	DeleteUser(context.Context, model.WriteOnlyTransaction, string) error
+	GetUserTOTP(context.Context, model.ReadOnlyTransaction, string) (*model.UserTOTP, error)
+	SetUserTOTP(context.Context, model.WriteOnlyTransaction, model.UserTOTP) error
+	DeleteUserTOTP(context.Context, model.WriteOnlyTransaction, string) error
+	SetRecoveryCodes(context.Context, model.WriteOnlyTransaction, string, []string) error
+	UseRecoveryCode(context.Context, model.WriteOnlyTransaction, string, string, time.Time) (bool, error)
+	GetLoginThrottle(context.Context, model.ReadOnlyTransaction, string, string) (*model.LoginThrottle, error)
+	SetLoginThrottle(context.Context, model.WriteOnlyTransaction, model.LoginThrottle) error
+	DeleteLoginThrottle(context.Context, model.WriteOnlyTransaction, string, string) error
+	GetLoginChallenge(context.Context, model.ReadOnlyTransaction, string) (*model.LoginChallenge, error)
+	SetLoginChallenge(context.Context, model.WriteOnlyTransaction, model.LoginChallenge) error
+	DeleteLoginChallenge(context.Context, model.WriteOnlyTransaction, string) error
+	SetUserRole(context.Context, model.WriteOnlyTransaction, string, model.UserRole) error
+	GetUserVerification(context.Context, model.ReadOnlyTransaction, string) (*model.UserVerification, error)
+	SetUserVerification(context.Context, model.WriteOnlyTransaction, model.UserVerification) error
+	DeleteUserVerifications(context.Context, model.WriteOnlyTransaction, string) error
+	GetExpiredRegistrations(context.Context, model.ReadOnlyTransaction, time.Time) ([]string, error)
+	DeleteExpiredUserVerifications(context.Context, model.WriteOnlyTransaction, time.Time) error
+	GetPasswordReset(context.Context, model.ReadOnlyTransaction, string) (*model.PasswordReset, error)
+	SetPasswordReset(context.Context, model.WriteOnlyTransaction, model.PasswordReset) error
+	UsePasswordResets(context.Context, model.WriteOnlyTransaction, string, time.Time) error
+	GetPasswordHistory(context.Context, model.ReadOnlyTransaction, string, int) ([]model.PasswordHistory, error)
+	AddPasswordHistory(context.Context, model.WriteOnlyTransaction, model.PasswordHistory) error
+	GetUserIdentity(context.Context, model.ReadOnlyTransaction, string, string) (*model.UserIdentity, error)
+	SetUserIdentity(context.Context, model.WriteOnlyTransaction, model.UserIdentity) error
 }
 
-type IdGenerator interface {
-	NewID() string
+type SessionRepository interface {
+	GetSession(context.Context, model.ReadOnlyTransaction, string) (*model.Session, error)
+	GetUserSessions(context.Context, model.ReadOnlyTransaction, string, *time.Time) ([]model.Session, error)
+	SetSession(context.Context, model.WriteOnlyTransaction, model.Session) error
+	RevokeSession(context.Context, model.WriteOnlyTransaction, string, time.Time) error
 }
 
-// TODO: need policy
-func isValidPassword(password string) bool {
-	if l := len(strings.TrimSpace(password)); l >= 8 && l <= 72 {
-		return true
-	}
-	return false
+type IdGenerator interface {
+	NewID() string
 }
 
 // TODO: need policy
@@ -43,19 +68,40 @@ func isValidUsername(username string) bool {
 }
 
 type UserService struct {
-	db       model.Database
-	userRepo UserRepository
-	idgen    IdGenerator
+	db              model.Database
+	userRepo        UserRepository
+	sessionRepo     SessionRepository
+	pushMessenger   PushMessenger
+	idgen           IdGenerator
+	passwordPolicy  model.PasswordPolicy
+	passwordHashing model.PasswordHashing
+	mailer          Mailer
+	notifier        Notifier
+	auditor         Auditor
+	publicURL       string
+	registration    bool
+	provisioning    bool
+	clock           func() time.Time
 }
 
-func NewUserService(db model.Database, userRepo UserRepository, idgen IdGenerator) *UserService {
+func NewUserService(db model.Database, userRepo UserRepository, sessionRepo SessionRepository, pushMessenger PushMessenger, idgen IdGenerator) *UserService {
 	return &UserService{
-		db:       db,
-		userRepo: userRepo,
-		idgen:    idgen,
+		db:              db,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		pushMessenger:   pushMessenger,
+		idgen:           idgen,
+		passwordPolicy:  model.DefaultPasswordPolicy,
+		passwordHashing: model.DefaultPasswordHashing,
+		clock:           time.Now,
 	}
 }
 
+// SetAuditor sets the auditor recording logins, password resets and account changes.
+func (z *UserService) SetAuditor(auditor Auditor) {
+	z.auditor = auditor
+}
+
 func (z *UserService) ChangeUsername(ctx context.Context, req model.ChangeUsernameRequest) model.ChangeUsernameResponse {
 
 	logger := logging.New(ctx, componentUserService, "ChangeUsername")
@@ -106,6 +152,8 @@ func (z *UserService) ChangeUsername(ctx context.Context, req model.ChangeUserna
 		return nil
 	})
 
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditChangeUsername, TargetID: req.UserID}, err)
+
 	rsp := model.ChangeUsernameResponse{}
 
 	if err != nil {
@@ -149,10 +197,10 @@ func (z *UserService) ChangePassword(ctx context.Context, req model.ChangePasswo
 		if !u.MatchPassword(req.Password) {
 			return model.NewValidationError("password incorrect") // 400
 		}
-		if !isValidPassword(req.NewPassword) {
-			return model.NewValidationError("invalid new password") // 400
+		if err := checkPassword(ctx, tx, z.userRepo, z.passwordPolicy, u.ID, req.NewPassword); err != nil {
+			return err // 400, 500
 		}
-		if err := u.SetPassword(req.NewPassword); err != nil {
+		if err := u.SetPasswordWith(z.passwordHashing, req.NewPassword); err != nil {
 			logger.Error().Err(err).Msg("user SetPassword")
 			return err // 500
 		}
@@ -160,6 +208,9 @@ func (z *UserService) ChangePassword(ctx context.Context, req model.ChangePasswo
 			logger.Error().Err(err).Msg("repo SetUser")
 			return err // 500
 		}
+		if err := addPasswordHistory(ctx, tx, z.userRepo, *u); err != nil {
+			return err // 500
+		}
 		now := time.Now()
 		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, u.ID, &now)
 		if errRoles != nil {
@@ -169,11 +220,15 @@ func (z *UserService) ChangePassword(ctx context.Context, req model.ChangePasswo
 		roles = rs
 		return nil
 	});
+
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditChangePassword, TargetID: req.UserID}, err)
+
 	rsp := model.ChangePasswordResponse{}
 
 	if err != nil {
 		logger.Debug().Err(err).Msg("password NOT updated")
 		rsp.Message = err.Error()
+		rsp.Rule = model.ValidationRule(err)
 		if model.IsValidationError(err) {
 			rsp.Code = http.StatusBadRequest
 		} else if model.IsNotFoundError(err) {
@@ -233,6 +288,8 @@ func (z *UserService) ChangeProfile(ctx context.Context, req model.ChangeProfile
 		return nil
 	})
 
+	audit(ctx, z.auditor, model.AuditEvent{Action: model.AuditChangeProfile, TargetID: req.UserID}, err)
+
 	rsp := model.ChangeProfileResponse{}
 
 	if err != nil {
@@ -261,8 +318,8 @@ func (z *UserService) Login(ctx context.Context, req model.LoginRequest) model.L
 
 	logger := logging.New(ctx, componentUserService, "Login")
 
-	// db username field is 64, go bcrypt max is 72
-	if x, y := len(req.Username), len(req.Password); x == 0 || y == 0 || x > 64 || y > 72 {
+	// db username field is 64
+	if x, y := len(req.Username), len(req.Password); x == 0 || y == 0 || x > 64 || y > model.PasswordMaxLength {
 		msg := "invalid username/password"
 		logger.Debug().Msg(msg)
 		return model.LoginResponse{
@@ -273,47 +330,254 @@ func (z *UserService) Login(ctx context.Context, req model.LoginRequest) model.L
 
 	var user *model.User
 	var roles []model.UserRole
+	var otpRequired bool
+	var challengeID string
+	var errLogin error
+	var lockedUser *model.User
+	var lockedUntil time.Time
+
+	sessionID := z.idgen.NewID()
+	issued := z.clock().Truncate(time.Minute)
+	expires := issued.Add(model.LoginTimeout)
 
 	err := z.db.Run(func(tx model.Transaction) error {
+		now := z.clock()
+		throttles, errThrottles := z.getLoginThrottles(ctx, tx, req)
+		if errThrottles != nil {
+			logger.Error().Err(errThrottles).Msg("repo GetLoginThrottle")
+			return errThrottles // 500
+		}
+		var retryAfter time.Duration
+		for _, throttle := range throttles {
+			if d := throttle.RetryAfter(now); d > retryAfter {
+				retryAfter = d
+			}
+		}
+		if retryAfter > 0 {
+			return model.NewLockedError("too many failed logins", retryAfter) // 429
+		}
 		usr, errGet := z.userRepo.GetActiveUserByUsername(ctx, tx, req.Username)
 		if errGet != nil {
 			logger.Error().Err(errGet).Msg("repo GetActiveUserByUsername")
 			return errGet // 500
 		}
-		if usr == nil {
-			// 400 - do not give out info that user does not exist at login
-			return model.NewValidationError("invalid username/password")
+		// do not give out info that user does not exist at login
+		if usr == nil || !usr.MatchPassword(req.Password) {
+			errLogin = model.NewValidationError("invalid username/password") // 400
+			lockout, usernameLockedUntil, errFailures := z.addLoginFailures(ctx, tx, throttles, now)
+			if errFailures != nil {
+				return errFailures // 500
+			}
+			if lockout > 0 {
+				errLogin = model.NewLockedError("too many failed logins", lockout) // 429
+			}
+			if usernameLockedUntil != nil && usr != nil {
+				lockedUser = usr
+				lockedUntil = *usernameLockedUntil
+			}
+			return nil // commit the failure
 		}
-		if !usr.MatchPassword(req.Password) {
-			return model.NewValidationError("invalid username/password") // 400
+		if err := z.userRepo.DeleteLoginThrottle(ctx, tx, model.ThrottleUsername, req.Username); err != nil {
+			logger.Error().Err(err).Msg("repo DeleteLoginThrottle")
+			return err // 500
+		}
+		if z.passwordHashing.NeedsRehash(usr.PasswordHash) {
+			// upgrade the hash while the password is known, the password history keeps the old hash
+			if err := usr.SetPasswordWith(z.passwordHashing, req.Password); err != nil {
+				logger.Error().Err(err).Msg("user SetPasswordWith")
+				return err // 500
+			}
+			if err := z.userRepo.SetUser(ctx, tx, *usr); err != nil {
+				logger.Error().Err(err).Msg("repo SetUser")
+				return err // 500
+			}
+			logger.Info().Str("UserID", usr.ID).Msg("password rehashed")
+		}
+		if z.passwordPolicy.MaxAge > 0 {
+			history, errHistory := z.userRepo.GetPasswordHistory(ctx, tx, usr.ID, 1)
+			if errHistory != nil {
+				logger.Error().Err(errHistory).Msg("repo GetPasswordHistory")
+				return errHistory // 500
+			}
+			if len(history) != 0 && z.passwordPolicy.IsExpired(history[0].Created, now) {
+				// the password has to be reset
+				errLogin = model.NewRuleValidationError(model.PasswordRuleExpired, "password expired") // 400
+				return nil
+			}
+		}
+		userTOTP, errTOTP := z.userRepo.GetUserTOTP(ctx, tx, usr.ID)
+		if errTOTP != nil {
+			logger.Error().Err(errTOTP).Msg("repo GetUserTOTP")
+			return errTOTP // 500
+		}
+		if userTOTP.IsEnabled() {
+			// second factor required, session is created by LoginOTP with the challenge
+			challenge := model.LoginChallenge{
+				ID:      z.idgen.NewID(),
+				UserID:  usr.ID,
+				Expires: now.Add(model.LoginChallengeTimeout),
+			}
+			if err := z.userRepo.SetLoginChallenge(ctx, tx, challenge); err != nil {
+				logger.Error().Err(err).Msg("repo SetLoginChallenge")
+				return err // 500
+			}
+			challengeID = challenge.ID
+			otpRequired = true
+			user = usr
+			return nil
 		}
-		now := time.Now()
 		rs, errRoles := z.userRepo.GetUserRoles(ctx, tx, usr.ID, &now)
 		if errRoles != nil {
 			return errRoles
 		}
+		session := newSession(sessionID, usr.ID, req.UserAgent, req.IPAddress, issued, now, expires)
+		if err := z.sessionRepo.SetSession(ctx, tx, session); err != nil {
+			logger.Error().Err(err).Msg("repo SetSession")
+			return err // 500
+		}
 		user = usr
 		roles = rs
 		return nil
-	});
+	})
+	if err == nil {
+		err = errLogin
+	}
+
+	if lockedUser != nil {
+		logger.Warn().Str("username", lockedUser.Username).Str("UserID", lockedUser.ID).Time("lockedUntil", lockedUntil).Msg("account locked")
+		z.sendLockout(ctx, lockedUser.ID, lockedUntil)
+	}
+
+	if err != nil || !otpRequired {
+		// a login requiring a second factor is audited by LoginOTP
+		event := model.AuditEvent{Action: model.AuditLogin, ActorName: req.Username, IPAddress: req.IPAddress, UserAgent: req.UserAgent}
+		if user != nil {
+			event.ActorID = user.ID
+			event.TargetID = user.ID
+		}
+		audit(ctx, z.auditor, event, err)
+	}
+
 	rsp := model.LoginResponse{}
 
 	if err != nil {
 		logger.Debug().Err(err).Msg("cannot login")
 		rsp.Message = err.Error()
-		if model.IsValidationError(err) {
+		if lockedErr, ok := err.(*model.LockedError); ok {
+			rsp.Code = http.StatusTooManyRequests
+			rsp.RetryAfter = lockedErr.RetryAfter
+		} else if model.IsValidationError(err) {
 			rsp.Code = http.StatusBadRequest
 		} else {
 			rsp.Code = http.StatusInternalServerError
 		}
+	} else if otpRequired {
+		rsp.Code = http.StatusOK
+		rsp.OTPRequired = true
+		rsp.UserID = user.ID
+		rsp.ChallengeID = challengeID
+		logger.Info().Str("username", user.Username).Str("UserID", user.ID).Msg("login requires second factor")
 	} else {
-		sessionID := z.idgen.NewID()
 		rsp.Code = http.StatusOK
-		issued := time.Now().Truncate(time.Minute)
-		expires := issued.Add(model.LoginTimeout)
 		rsp.SessionToken = model.ToSessionToken(sessionID, user, roles, issued, expires)
 		logger.Info().Str("username", user.Username).Str("UserID", user.ID).Str("SessionID", sessionID).Msg("login")
 	}
 
 	return rsp
 }
+
+// getLoginThrottles returns the failed login counts for the username and, if known, the IP address of a login request.
+func (z *UserService) getLoginThrottles(ctx context.Context, tx model.ReadOnlyTransaction, req model.LoginRequest) ([]*model.LoginThrottle, error) {
+
+	subjects := map[string]string{
+		model.ThrottleUsername:  req.Username,
+		model.ThrottleIPAddress: req.IPAddress,
+	}
+
+	var throttles []*model.LoginThrottle
+	for _, scope := range []string{model.ThrottleUsername, model.ThrottleIPAddress} {
+		subject := subjects[scope]
+		if len(subject) == 0 {
+			continue
+		}
+		throttle, err := z.userRepo.GetLoginThrottle(ctx, tx, scope, subject)
+		if err != nil {
+			return nil, err
+		}
+		if throttle == nil {
+			throttle = &model.LoginThrottle{
+				Scope:   scope,
+				Subject: subject,
+			}
+		}
+		throttles = append(throttles, throttle)
+	}
+
+	return throttles, nil
+
+}
+
+// addLoginFailures records a failed login in the throttles.
+// It returns the longest lockout caused by the failure and when the username is locked, if it is.
+func (z *UserService) addLoginFailures(ctx context.Context, tx model.WriteOnlyTransaction, throttles []*model.LoginThrottle, now time.Time) (time.Duration, *time.Time, error) {
+
+	logger := logging.New(ctx, componentUserService, "addLoginFailures")
+
+	var retryAfter time.Duration
+	var usernameLockedUntil *time.Time
+	for _, throttle := range throttles {
+		policy := model.IPAddressThrottlePolicy
+		if throttle.Scope == model.ThrottleUsername {
+			policy = model.UsernameThrottlePolicy
+		}
+		if throttle.AddFailure(policy, now) {
+			if d := throttle.RetryAfter(now); d > retryAfter {
+				retryAfter = d
+			}
+			if throttle.Scope == model.ThrottleUsername {
+				usernameLockedUntil = throttle.LockedUntil
+			}
+		}
+		if err := z.userRepo.SetLoginThrottle(ctx, tx, *throttle); err != nil {
+			logger.Error().Err(err).Msg("repo SetLoginThrottle")
+			return 0, nil, err
+		}
+	}
+
+	return retryAfter, usernameLockedUntil, nil
+
+}
+
+// sendLockout notifies the open sessions of a user that the account has been locked by failed logins.
+func (z *UserService) sendLockout(ctx context.Context, userID string, lockedUntil time.Time) {
+
+	logger := logging.New(ctx, componentUserService, "sendLockout")
+
+	data, errData := json.Marshal(struct {
+		LockedUntil time.Time `json:"lockedUntil"`
+	}{
+		LockedUntil: lockedUntil,
+	})
+	if errData != nil {
+		logger.Warn().Err(errData).Msg("cannot serialize lockout")
+		return
+	}
+
+	z.pushMessenger.SendMessage(model.PushMessage{
+		Type: model.PushTypeLockout,
+		Data: string(data),
+	}, userID, "")
+
+}
+
+func newSession(sessionID, userID, userAgent, ipAddress string, issued, lastSeen, expires time.Time) model.Session {
+	return model.Session{
+		ID:        sessionID,
+		UserID:    userID,
+		Issued:    issued,
+		LastSeen:  lastSeen,
+		Expires:   expires,
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+		IPAddress: ipAddress,
+	}
+}
@@ -212,7 +212,7 @@ func TestChangeUsername(b *testing.T) {
 				GetError:       tCase.OutputGetError,
 				SetError:       tCase.OutputSetError,
 			}
-			userService := services.NewUserService(db, userRepo, idg)
+			userService := services.NewUserService(db, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, idg)
 			ctx := context.Background()
 			ctx = context.WithValue(ctx, model.UserKey, tCase.RequestSessionToken)
 
@@ -390,7 +390,7 @@ func TestChangeProfile(b *testing.T) {
 				GetError: tCase.OutputGetError,
 				SetError: tCase.OutputSetError,
 			}
-			userService := services.NewUserService(db, userRepo, nil) // idgen only used for login
+			userService := services.NewUserService(db, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, nil) // idgen only used for login
 			ctx := context.Background()
 			ctx = context.WithValue(ctx, model.UserKey, tCase.RequestSessionToken)
 
@@ -544,7 +544,8 @@ func TestChangePassword(b *testing.T) {
 			},
 			ExpectedResponse: model.ChangePasswordResponse{
 				Code:    http.StatusBadRequest,
-				Message: "invalid new password",
+				Message: "password must have at least 8 characters",
+				Rule:    model.PasswordRuleMinLength,
 			},
 		},
 		{
@@ -591,7 +592,7 @@ func TestChangePassword(b *testing.T) {
 				Roles:      tCase.OutputRoles,
 				RolesError: tCase.OutputRolesError,
 			}
-			userService := services.NewUserService(db, userRepo, nil) // only used in login
+			userService := services.NewUserService(db, userRepo, &SessionRepositoryMock{}, &PushMessengerMock{}, nil) // only used in login
 			ctx := context.Background()
 			ctx = context.WithValue(ctx, model.UserKey, tCase.RequestSessionToken)
 
@@ -605,6 +606,10 @@ func TestChangePassword(b *testing.T) {
 				t.Errorf("bad response message %s, expected %s", got, want)
 			}
 
+			if got, want := rsp.Rule, tCase.ExpectedResponse.Rule; got != want {
+				t.Errorf("bad response rule %s, expected %s", got, want)
+			}
+
 			if rsp.SessionToken == nil && tCase.ExpectedResponse.SessionToken != nil {
 				t.Fatal("nil response session, expected non-nil")
 			}
@@ -684,6 +689,8 @@ func TestLogin(b *testing.T) {
 		OutputRoles      []model.UserRole
 		OutputGetError   error
 		OutputRolesError error
+		OutputSetError   error
+		OutputTOTP       *model.UserTOTP
 		Request          model.LoginRequest
 		ExpectedResponse model.LoginResponse
 	}{
@@ -703,6 +710,51 @@ func TestLogin(b *testing.T) {
 				SessionToken: model.ToSessionToken("S123", demouser(), userroles, now.Truncate(time.Minute), now.Truncate(time.Minute).Add(model.LoginTimeout)),
 			},
 		},
+		{
+			Alias:            "second factor required",
+			OutputUser:       demouser(),
+			OutputRoles:      userroles,
+			OutputSessionID:  "S123",
+			OutputGetError:   nil,
+			OutputRolesError: nil,
+			OutputTOTP: &model.UserTOTP{
+				UserID:    "id",
+				Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				Created:   now,
+				Confirmed: &now,
+			},
+			Request: model.LoginRequest{
+				Username: "demouser",
+				Password: "demouser",
+			},
+			ExpectedResponse: model.LoginResponse{
+				Code:        http.StatusOK,
+				OTPRequired: true,
+				UserID:      "id",
+				ChallengeID: "S123",
+			},
+		},
+		{
+			Alias:            "unconfirmed second factor ignored",
+			OutputUser:       demouser(),
+			OutputRoles:      userroles,
+			OutputSessionID:  "S123",
+			OutputGetError:   nil,
+			OutputRolesError: nil,
+			OutputTOTP: &model.UserTOTP{
+				UserID:  "id",
+				Secret:  "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				Created: now,
+			},
+			Request: model.LoginRequest{
+				Username: "demouser",
+				Password: "demouser",
+			},
+			ExpectedResponse: model.LoginResponse{
+				Code:         http.StatusOK,
+				SessionToken: model.ToSessionToken("S123", demouser(), userroles, now.Truncate(time.Minute), now.Truncate(time.Minute).Add(model.LoginTimeout)),
+			},
+		},
 		{
 			Alias:            "get user fails",
 			OutputUser:       demouser(),
@@ -767,6 +819,23 @@ func TestLogin(b *testing.T) {
 				Message: "just some error",
 			},
 		},
+		{
+			Alias:            "set session fails",
+			OutputUser:       demouser(),
+			OutputRoles:      userroles,
+			OutputSessionID:  "S123",
+			OutputGetError:   nil,
+			OutputRolesError: nil,
+			OutputSetError:   errors.New("just some error"),
+			Request: model.LoginRequest{
+				Username: "demouser",
+				Password: "demouser",
+			},
+			ExpectedResponse: model.LoginResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "just some error",
+			},
+		},
 		{
 			Alias:            "username too short",
 			OutputUser:       demouser(),
@@ -847,8 +916,13 @@ func TestLogin(b *testing.T) {
 				Roles:      tCase.OutputRoles,
 				GetError:   tCase.OutputGetError,
 				RolesError: tCase.OutputRolesError,
+				TOTP:       tCase.OutputTOTP,
+			}
+			sessionRepo := &SessionRepositoryMock{
+				SetError: tCase.OutputSetError,
 			}
-			userService := services.NewUserService(db, userRepo, idgen)
+			userService := services.NewUserService(db, userRepo, sessionRepo, &PushMessengerMock{}, idgen)
+			userService.SetClock(func() time.Time { return now })
 			ctx := context.Background()
 
 			rsp := userService.Login(ctx, tCase.Request)
@@ -861,6 +935,26 @@ func TestLogin(b *testing.T) {
 				t.Errorf("bad response message %s, expected %s", got, want)
 			}
 
+			if got, want := rsp.OTPRequired, tCase.ExpectedResponse.OTPRequired; got != want {
+				t.Errorf("bad response OTPRequired %t, expected %t", got, want)
+			}
+
+			if got, want := rsp.UserID, tCase.ExpectedResponse.UserID; got != want {
+				t.Errorf("bad response UserID %s, expected %s", got, want)
+			}
+
+			if got, want := rsp.ChallengeID, tCase.ExpectedResponse.ChallengeID; got != want {
+				t.Errorf("bad response ChallengeID %s, expected %s", got, want)
+			}
+
+			if rsp.OTPRequired && sessionRepo.SetCount != 0 {
+				t.Errorf("bad session count %d, expected 0", sessionRepo.SetCount)
+			}
+
+			if rsp.OTPRequired && (userRepo.SavedChallenge == nil || userRepo.SavedChallenge.ID != rsp.ChallengeID || userRepo.SavedChallenge.UserID != rsp.UserID) {
+				t.Errorf("bad saved challenge %v", userRepo.SavedChallenge)
+			}
+
 			if rsp.SessionToken == nil && tCase.ExpectedResponse.SessionToken != nil {
 				t.Fatal("nil response session, expected non-nil")
 			}
@@ -907,3 +1001,162 @@ func TestLogin(b *testing.T) {
 	} // cases
 
 }
+
+func TestLoginThrottle(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	lockedUntil := now.Add(time.Minute)
+	expired := now.Add(-time.Second)
+	threshold := model.UsernameThrottlePolicy.Threshold
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+		Created:  now,
+		Updated:  now,
+	}
+	if err := demouser.SetPassword("demouser"); err != nil {
+		b.Fatal(err)
+	}
+
+	testCases := []struct {
+		Alias              string
+		OutputUser         *model.User
+		OutputThrottles    map[string]*model.LoginThrottle
+		Password           string
+		ExpectedCode       int
+		ExpectedRetryAfter bool
+		ExpectedFailures   map[string]int // saved throttles, scope: failures
+		ExpectedDeleted    string
+		ExpectedLockout    bool
+	}{
+		{
+			Alias:        "failures counted",
+			OutputUser:   demouser,
+			Password:     "bogus",
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedFailures: map[string]int{
+				model.ThrottleUsername:  1,
+				model.ThrottleIPAddress: 1,
+			},
+		},
+		{
+			Alias:      "username locked",
+			OutputUser: demouser,
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {Scope: model.ThrottleUsername, Subject: "demouser", Failures: threshold, LastFailure: now, LockedUntil: &lockedUntil},
+			},
+			Password:           "demouser",
+			ExpectedCode:       http.StatusTooManyRequests,
+			ExpectedRetryAfter: true,
+		},
+		{
+			Alias:      "ip address locked",
+			OutputUser: demouser,
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"ip:10.0.0.1": {Scope: model.ThrottleIPAddress, Subject: "10.0.0.1", Failures: 100, LastFailure: now, LockedUntil: &lockedUntil},
+			},
+			Password:           "demouser",
+			ExpectedCode:       http.StatusTooManyRequests,
+			ExpectedRetryAfter: true,
+		},
+		{
+			Alias:      "failure locks account",
+			OutputUser: demouser,
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {Scope: model.ThrottleUsername, Subject: "demouser", Failures: threshold - 1, LastFailure: now},
+			},
+			Password:           "bogus",
+			ExpectedCode:       http.StatusTooManyRequests,
+			ExpectedRetryAfter: true,
+			ExpectedFailures: map[string]int{
+				model.ThrottleUsername:  threshold,
+				model.ThrottleIPAddress: 1,
+			},
+			ExpectedLockout: true,
+		},
+		{
+			Alias:      "unknown user locked",
+			OutputUser: nil,
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {Scope: model.ThrottleUsername, Subject: "demouser", Failures: threshold - 1, LastFailure: now},
+			},
+			Password:           "bogus",
+			ExpectedCode:       http.StatusTooManyRequests,
+			ExpectedRetryAfter: true,
+			ExpectedFailures: map[string]int{
+				model.ThrottleUsername:  threshold,
+				model.ThrottleIPAddress: 1,
+			},
+		},
+		{
+			Alias:      "success after lockout",
+			OutputUser: demouser,
+			OutputThrottles: map[string]*model.LoginThrottle{
+				"username:demouser": {Scope: model.ThrottleUsername, Subject: "demouser", Failures: threshold, LastFailure: now, LockedUntil: &expired},
+			},
+			Password:        "demouser",
+			ExpectedCode:    http.StatusOK,
+			ExpectedDeleted: "username:demouser",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			userRepo := &UserRepositoryMock{
+				User:      tCase.OutputUser,
+				Throttles: tCase.OutputThrottles,
+			}
+			pushMessenger := &PushMessengerMock{}
+			userService := services.NewUserService(&DatabaseMock{}, userRepo, &SessionRepositoryMock{}, pushMessenger, &IdGeneratorMock{ID: "S123"})
+			userService.SetClock(func() time.Time { return now })
+
+			rsp := userService.Login(context.Background(), model.LoginRequest{
+				Username:  "demouser",
+				Password:  tCase.Password,
+				IPAddress: "10.0.0.1",
+			})
+
+			if got, want := rsp.Code, tCase.ExpectedCode; got != want {
+				t.Errorf("bad response code %d, expected %d", got, want)
+			}
+
+			if got, want := rsp.RetryAfter > 0, tCase.ExpectedRetryAfter; got != want {
+				t.Errorf("bad response RetryAfter %s, expected %t", rsp.RetryAfter, want)
+			}
+
+			if got, want := len(userRepo.SavedThrottles), len(tCase.ExpectedFailures); got != want {
+				t.Errorf("bad saved throttles %d, expected %d", got, want)
+			}
+			for _, throttle := range userRepo.SavedThrottles {
+				if got, want := throttle.Failures, tCase.ExpectedFailures[throttle.Scope]; got != want {
+					t.Errorf("bad %s failures %d, expected %d", throttle.Scope, got, want)
+				}
+			}
+
+			if got, want := strings.Join(userRepo.DeletedThrottles, ","), tCase.ExpectedDeleted; got != want {
+				t.Errorf("bad deleted throttles %s, expected %s", got, want)
+			}
+
+			if got, want := len(pushMessenger.Sent) == 1, tCase.ExpectedLockout; got != want {
+				t.Fatalf("bad lockout message %v, expected %t", pushMessenger.Sent, want)
+			}
+			if tCase.ExpectedLockout {
+				if got, want := pushMessenger.Sent[0].Type, model.PushTypeLockout; got != want {
+					t.Errorf("bad message type %s, expected %s", got, want)
+				}
+				if got, want := pushMessenger.SentUserID, demouser.ID; got != want {
+					t.Errorf("bad message user %s, expected %s", got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,114 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/totp"
+)
+
+// secret is the RFC 6238 SHA1 test key "12345678901234567890"
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(b *testing.T) {
+
+	// expected values are the last six digits of the RFC 6238 appendix B test vectors
+	testCases := []struct {
+		Alias    string
+		Time     int64
+		Expected string
+	}{
+		{Alias: "59", Time: 59, Expected: "287082"},
+		{Alias: "1111111109", Time: 1111111109, Expected: "081804"},
+		{Alias: "1111111111", Time: 1111111111, Expected: "050471"},
+		{Alias: "1234567890", Time: 1234567890, Expected: "005924"},
+		{Alias: "2000000000", Time: 2000000000, Expected: "279037"},
+		{Alias: "20000000000", Time: 20000000000, Expected: "353130"},
+	}
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+			code, err := totp.Code(secret, totp.Counter(time.Unix(tc.Time, 0)))
+			if err != nil {
+				t.Fatalf("Bad error: %s", err)
+			}
+			if got, want := code, tc.Expected; got != want {
+				t.Errorf("Bad code: %s, expected %s", got, want)
+			}
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
+
+func TestValidate(b *testing.T) {
+
+	now := time.Unix(1234567890, 0)
+
+	testCases := []struct {
+		Alias           string
+		Code            string
+		ExpectedOK      bool
+		ExpectedCounter int64
+	}{
+		{Alias: "current", Code: "005924", ExpectedOK: true, ExpectedCounter: totp.Counter(now)},
+		{Alias: "previous", Code: mustCode(totp.Counter(now) - 1), ExpectedOK: true, ExpectedCounter: totp.Counter(now) - 1},
+		{Alias: "next", Code: mustCode(totp.Counter(now) + 1), ExpectedOK: true, ExpectedCounter: totp.Counter(now) + 1},
+		{Alias: "too old", Code: mustCode(totp.Counter(now) - 2), ExpectedOK: false},
+		{Alias: "wrong", Code: "123456", ExpectedOK: false},
+		{Alias: "too short", Code: "00592", ExpectedOK: false},
+		{Alias: "empty", Code: "", ExpectedOK: false},
+	}
+
+	for _, tc := range testCases {
+
+		testFn := func(t *testing.T) {
+			counter, ok := totp.Validate(secret, tc.Code, now)
+			if got, want := ok, tc.ExpectedOK; got != want {
+				t.Fatalf("Bad ok: %t, expected %t", got, want)
+			}
+			if got, want := counter, tc.ExpectedCounter; got != want {
+				t.Errorf("Bad counter: %d, expected %d", got, want)
+			}
+		}
+
+		b.Run(tc.Alias, testFn)
+
+	}
+
+}
+
+func TestNewSecret(t *testing.T) {
+	s, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("Bad error: %s", err)
+	}
+	if got, want := len(s), 32; got != want {
+		t.Errorf("Bad secret length: %d, expected %d", got, want)
+	}
+	if _, err := totp.Code(s, 1); err != nil {
+		t.Errorf("Bad secret: %s", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("wallawire", "demouser", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/wallawire:demouser?") {
+		t.Errorf("Bad uri prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Bad uri secret: %s", uri)
+	}
+}
+
+func mustCode(counter int64) string {
+	code, err := totp.Code(secret, counter)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
@@ -1,3 +1,5 @@
+import "./nonce";
+
 import "typeface-roboto";
 
 import * as React from "react";
@@ -0,0 +1,12 @@
+// The server marks index.html with the nonce of its Content-Security-Policy and publishes it in the csp-nonce meta element.
+// style-loader adds __webpack_nonce__ to the style elements it injects for the imported css, like typeface-roboto,
+// and JSS, which styles material-ui, reads the nonce from the meta element itself.
+// Imported first, before any style is injected.
+
+// tslint:disable-next-line:variable-name
+declare let __webpack_nonce__: string;
+
+const meta = document.querySelector("meta[property='csp-nonce']");
+if (meta) {
+    __webpack_nonce__ = meta.getAttribute("content") || "";
+}
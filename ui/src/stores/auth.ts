@@ -2,7 +2,7 @@ import { distanceInWordsToNow } from "date-fns";
 import * as Cookies from "js-cookie";
 import { action, computed, observable } from "mobx";
 import { AuthStatus, IAuthResponse } from "../model/auth";
-import { to } from "./util";
+import { csrfHeaders, to } from "./util";
 
 export class AuthStore {
     @observable public name = "";
@@ -53,6 +53,7 @@ export class AuthStore {
             credentials: "same-origin",
             headers: {
                 "Content-Type": "application/json",
+                ...csrfHeaders(),
             },
             method: "POST",
             mode: "same-origin",
@@ -93,6 +94,7 @@ export class AuthStore {
         const [rsp, errRsp] = await to(fetch("/api/logout", {
             cache: "no-cache",
             credentials: "same-origin",
+            headers: csrfHeaders(),
             method: "POST",
             mode: "same-origin",
         }));
@@ -1,6 +1,6 @@
 import { action, autorun, observable } from "mobx";
 import { ChangeProfileStatus, IChangeProfileResponse } from "../model/profile";
-import { to } from "./util";
+import { csrfHeaders, to } from "./util";
 
 interface IAuthStore {
     username: string;
@@ -108,6 +108,7 @@ export class ProfileStore {
                 credentials: "same-origin",
                 headers: {
                     "Content-Type": "application/json",
+                    ...csrfHeaders(),
                 },
                 method: "POST",
                 mode: "same-origin",
@@ -157,6 +158,7 @@ export class ProfileStore {
                 credentials: "same-origin",
                 headers: {
                     "Content-Type": "application/json",
+                    ...csrfHeaders(),
                 },
                 method: "POST",
                 mode: "same-origin",
@@ -205,6 +207,7 @@ export class ProfileStore {
             credentials: "same-origin",
             headers: {
                 "Content-Type": "application/json",
+                ...csrfHeaders(),
             },
             method: "POST",
             mode: "same-origin",
@@ -1,5 +1,12 @@
+import * as Cookies from "js-cookie";
+
 export function to(p: Promise<any>): Promise<[any, Error | null]> {
     return p.then((data: any): [any, Error | null] => {
        return [data, null];
     }).catch((err: Error): [any, Error | null] => [null, err]);
  }
+
+// csrfHeaders returns the double-submit token the server expects on requests that change state.
+export function csrfHeaders(): { [key: string]: string } {
+    return { "X-CSRF-Token": Cookies.get("csrf") || "" };
+}
@@ -1,14 +1,22 @@
 package accesslog
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 )
 
 var _ http.Flusher = &flushWriter{}
+var _ http.Hijacker = &hijackWriter{}
 
 func WrapWriter(w http.ResponseWriter) WriterProxy {
 	bw := basicWriter{ResponseWriter: w}
-	if _, ok := w.(http.Flusher); ok {
+	_, fl := w.(http.Flusher)
+	_, hj := w.(http.Hijacker)
+	if fl && hj {
+		return &hijackWriter{flushWriter{bw}}
+	}
+	if fl {
 		return &flushWriter{bw}
 	}
 	return &bw
@@ -63,3 +71,15 @@ func (f *flushWriter) Flush() {
 	fl := f.basicWriter.ResponseWriter.(http.Flusher)
 	fl.Flush()
 }
+
+// hijackWriter allows websocket upgrades, logged as switching protocols.
+type hijackWriter struct {
+	flushWriter
+}
+
+func (f *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj := f.basicWriter.ResponseWriter.(http.Hijacker)
+	f.basicWriter.code = http.StatusSwitchingProtocols
+	f.basicWriter.wroteHeader = true
+	return hj.Hijack()
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"wallawire/logging"
+)
+
+const (
+	ParamRoleID = "roleID"
+	ParamUserID = "userID"
+)
+
+const (
+	hContentLength = "Content-Length"
+	hContentType   = "Content-Type"
+	mimeTypeJson   = "application/json"
+)
+
+// readJson unmarshals the request body into req.
+// If false is returned, an error response has already been sent.
+func readJson(ctx context.Context, w http.ResponseWriter, r *http.Request, req interface{}) bool {
+
+	logger := logging.New(ctx, "readJson")
+
+	if r.Header.Get(hContentType) != mimeTypeJson {
+		msg := "bad or missing content type"
+		logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return false
+	}
+
+	body, errBody := ioutil.ReadAll(r.Body)
+	if errBody != nil {
+		msg := "cannot read request"
+		logger.Debug().Err(errBody).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, req); err != nil {
+		msg := "bad json payload"
+		logger.Debug().Err(err).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return false
+	}
+
+	return true
+
+}
+
+func sendJson(ctx context.Context, w http.ResponseWriter, statusCode int, payload interface{}) {
+	logger := logging.New(ctx, "sendJson")
+	msg, errMsg := json.Marshal(payload)
+	if errMsg != nil {
+		logger.Error().Err(errMsg).Msg("Cannot marshal json payload")
+		sendJsonMessage(ctx, w, http.StatusInternalServerError, "")
+		return
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
+
+func sendJsonMessage(ctx context.Context, w http.ResponseWriter, statusCode int, message string) {
+	sendJsonRuleMessage(ctx, w, statusCode, message, "")
+}
+
+// sendJsonRuleMessage sends a message naming the validation rule that failed, if any.
+func sendJsonRuleMessage(ctx context.Context, w http.ResponseWriter, statusCode int, message, rule string) {
+	logger := logging.New(ctx, "sendJsonMessage")
+	if len(message) == 0 {
+		message = http.StatusText(statusCode)
+	}
+	errmsg := struct {
+		StatusCode int    `json:"statusCode"`
+		Message    string `json:"message,omitempty"`
+		Rule       string `json:"rule,omitempty"`
+	}{
+		StatusCode: statusCode,
+		Message:    message,
+		Rule:       rule,
+	}
+	msg, errMsg := json.Marshal(&errmsg)
+	if errMsg != nil {
+		logger.Error().Err(errMsg).Msg("Cannot marshal json error message")
+		msg = []byte("{}")
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
@@ -0,0 +1,270 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	os.Exit(m.Run())
+}
+
+const (
+	ignoreValue    = "XXX"
+	hContentLength = "Content-Length"
+	hContentType   = "Content-Type"
+	hCookie        = "Cookie"
+	hDate          = "Date"
+	mimeTypeJson   = "application/json"
+	mimeTypeText   = "text/plain; charset=utf-8"
+	testPassword   = "secret"
+)
+
+var (
+	testKeys = auth.NewHMACKeys(testPassword)
+)
+
+func getCookieString(user *model.SessionToken, keys *auth.Keys) string {
+	r, err := auth.MakeJWT(user, keys)
+	if err != nil {
+		panic(err)
+	}
+	c := &http.Cookie{
+		Name:    auth.CookieName,
+		Value:   r,
+		Expires: user.Expires,
+		Path:    "/",
+		Secure:  true,
+	}
+	return c.String()
+}
+
+type testCase struct {
+	Alias           string
+	Path            string
+	AdminService    *AdminServiceMock
+	RequestMethod   string
+	RequestHeaders  map[string]string
+	RequestBody     []byte
+	ResponseStatus  int
+	ResponseHeaders map[string]string
+	ResponseBody    []byte
+	Check           func(*testing.T, *AdminServiceMock)
+}
+
+// runTestCases serves the handler at the route behind the authenticator and admin authorizer
+// and checks each response.
+func runTestCases(b *testing.T, method, route string, handlerFn func(*AdminServiceMock) http.HandlerFunc, testCases []testCase) {
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{})...)
+			handler.Use(auth.NewAuthorizer(model.RoleNameAdmin))
+			handler.Method(method, route, handlerFn(testCase.AdminService))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+			if testCase.Check != nil {
+				testCase.Check(t, testCase.AdminService)
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
+
+type AdminServiceMock struct {
+	ListUsersResponse          model.ListUsersResponse
+	CreateUserResponse         model.CreateUserResponse
+	SetUserDisabledResponse    model.SetUserDisabledResponse
+	DeleteUserResponse         model.DeleteUserResponse
+	ResetPasswordResponse      model.ResetPasswordResponse
+	ListUsersRequest           model.ListUsersRequest
+	SetUserDisabledRequest     model.SetUserDisabledRequest
+	DeleteUserRequest          model.DeleteUserRequest
+	ResetPasswordRequest       model.ResetPasswordRequest
+	ListRolesResponse          model.ListRolesResponse
+	CreateRoleResponse         model.CreateRoleResponse
+	DeleteRoleResponse         model.DeleteRoleResponse
+	ListUserRolesResponse      model.ListUserRolesResponse
+	GrantRoleResponse          model.GrantRoleResponse
+	RevokeRoleResponse         model.RevokeRoleResponse
+	CreateRoleRequest          model.CreateRoleRequest
+	DeleteRoleRequest          model.DeleteRoleRequest
+	ListUserRolesRequest       model.ListUserRolesRequest
+	GrantRoleRequest           model.GrantRoleRequest
+	RevokeRoleRequest          model.RevokeRoleRequest
+	UnlockUserResponse         model.UnlockUserResponse
+	UnlockUserRequest          model.UnlockUserRequest
+	SetRolePermissionsResponse model.SetRolePermissionsResponse
+	SetRolePermissionsRequest  model.SetRolePermissionsRequest
+	ListAuditEventsResponse    model.ListAuditEventsResponse
+	ListAuditEventsRequest     model.ListAuditEventsRequest
+	PushStats                  model.PushStats
+}
+
+func (z *AdminServiceMock) ListUsers(ctx context.Context, req model.ListUsersRequest) model.ListUsersResponse {
+	z.ListUsersRequest = req
+	return z.ListUsersResponse
+}
+
+func (z *AdminServiceMock) CreateUser(ctx context.Context, req model.CreateUserRequest) model.CreateUserResponse {
+	return z.CreateUserResponse
+}
+
+func (z *AdminServiceMock) SetUserDisabled(ctx context.Context, req model.SetUserDisabledRequest) model.SetUserDisabledResponse {
+	z.SetUserDisabledRequest = req
+	return z.SetUserDisabledResponse
+}
+
+func (z *AdminServiceMock) UnlockUser(ctx context.Context, req model.UnlockUserRequest) model.UnlockUserResponse {
+	z.UnlockUserRequest = req
+	return z.UnlockUserResponse
+}
+
+func (z *AdminServiceMock) DeleteUser(ctx context.Context, req model.DeleteUserRequest) model.DeleteUserResponse {
+	z.DeleteUserRequest = req
+	return z.DeleteUserResponse
+}
+
+func (z *AdminServiceMock) ResetPassword(ctx context.Context, req model.ResetPasswordRequest) model.ResetPasswordResponse {
+	z.ResetPasswordRequest = req
+	return z.ResetPasswordResponse
+}
+
+func (z *AdminServiceMock) ListRoles(ctx context.Context, req model.ListRolesRequest) model.ListRolesResponse {
+	return z.ListRolesResponse
+}
+
+func (z *AdminServiceMock) CreateRole(ctx context.Context, req model.CreateRoleRequest) model.CreateRoleResponse {
+	z.CreateRoleRequest = req
+	return z.CreateRoleResponse
+}
+
+func (z *AdminServiceMock) DeleteRole(ctx context.Context, req model.DeleteRoleRequest) model.DeleteRoleResponse {
+	z.DeleteRoleRequest = req
+	return z.DeleteRoleResponse
+}
+
+func (z *AdminServiceMock) SetRolePermissions(ctx context.Context, req model.SetRolePermissionsRequest) model.SetRolePermissionsResponse {
+	z.SetRolePermissionsRequest = req
+	return z.SetRolePermissionsResponse
+}
+
+func (z *AdminServiceMock) ListUserRoles(ctx context.Context, req model.ListUserRolesRequest) model.ListUserRolesResponse {
+	z.ListUserRolesRequest = req
+	return z.ListUserRolesResponse
+}
+
+func (z *AdminServiceMock) GrantRole(ctx context.Context, req model.GrantRoleRequest) model.GrantRoleResponse {
+	z.GrantRoleRequest = req
+	return z.GrantRoleResponse
+}
+
+func (z *AdminServiceMock) RevokeRole(ctx context.Context, req model.RevokeRoleRequest) model.RevokeRoleResponse {
+	z.RevokeRoleRequest = req
+	return z.RevokeRoleResponse
+}
+
+func (z *AdminServiceMock) ListAuditEvents(ctx context.Context, req model.ListAuditEventsRequest) model.ListAuditEventsResponse {
+	z.ListAuditEventsRequest = req
+	return z.ListAuditEventsResponse
+}
+
+func (z *AdminServiceMock) Stats() model.PushStats {
+	return z.PushStats
+}
+
+type SessionServiceMock struct{}
+
+func (z *SessionServiceMock) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (z *SessionServiceMock) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+	return nil, nil
+}
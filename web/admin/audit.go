@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type ListAuditEventsService interface {
+	ListAuditEvents(context.Context, model.ListAuditEventsRequest) model.ListAuditEventsResponse
+}
+
+// ListAuditEvents returns a page of audit events, most recent first.
+// Query parameters: offset, limit, actorID, targetID, action, outcome, from and to (RFC 3339).
+func ListAuditEvents(auditService ListAuditEventsService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListAuditEventsHandler")
+		logger.Debug().Msg("invoked")
+
+		query := r.URL.Query()
+		filter := model.AuditFilter{
+			ActorID:  query.Get("actorID"),
+			TargetID: query.Get("targetID"),
+			Action:   query.Get("action"),
+			Outcome:  query.Get("outcome"),
+		}
+
+		var errParam error
+		if value := query.Get("offset"); value != "" {
+			filter.Offset, errParam = strconv.Atoi(value)
+		}
+		if value := query.Get("limit"); value != "" && errParam == nil {
+			filter.Limit, errParam = strconv.Atoi(value)
+		}
+		if value := query.Get("from"); value != "" && errParam == nil {
+			var from time.Time
+			from, errParam = time.Parse(time.RFC3339, value)
+			filter.From = &from
+		}
+		if value := query.Get("to"); value != "" && errParam == nil {
+			var to time.Time
+			to, errParam = time.Parse(time.RFC3339, value)
+			filter.To = &to
+		}
+		if errParam != nil {
+			msg := "bad query parameter"
+			logger.Debug().Err(errParam).Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rsp := auditService.ListAuditEvents(ctx, model.ListAuditEventsRequest{Filter: filter})
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Events []model.AuditEvent `json:"events"`
+			Total  int                `json:"total"`
+		}{
+			Events: rsp.Events,
+			Total:  rsp.Total,
+		})
+
+	})
+}
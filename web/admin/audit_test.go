@@ -0,0 +1,111 @@
+package admin_test
+
+import (
+	"net/http"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/admin"
+)
+
+func TestListAuditEvents(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/audit?offset=20&limit=1&actorID=id&action=login&outcome=failure&from=2019-03-16T00:00:00Z&to=2019-03-17T00:00:00Z",
+			AdminService: &AdminServiceMock{
+				ListAuditEventsResponse: model.ListAuditEventsResponse{
+					Code: http.StatusOK,
+					Events: []model.AuditEvent{
+						{
+							ID:            "A123",
+							Created:       testNow,
+							Action:        model.AuditLogin,
+							Outcome:       model.AuditFailure,
+							ActorName:     "demouser",
+							Message:       "invalid username/password",
+							IPAddress:     "192.0.2.1",
+							CorrelationID: "C123",
+						},
+					},
+					Total: 21,
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "215",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"events":[{"id":"A123","created":"2019-03-16T12:00:00Z","action":"login","outcome":"failure","actorName":"demouser","message":"invalid username/password","ipAddress":"192.0.2.1","correlationID":"C123"}],"total":21}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				filter := mock.ListAuditEventsRequest.Filter
+				if filter.Offset != 20 || filter.Limit != 1 || filter.ActorID != "id" || filter.TargetID != "" || filter.Action != model.AuditLogin || filter.Outcome != model.AuditFailure {
+					t.Errorf("Bad filter: %+v", filter)
+				}
+				if filter.From == nil || filter.From.Day() != 16 || filter.To == nil || filter.To.Day() != 17 {
+					t.Errorf("Bad time range: %v - %v", filter.From, filter.To)
+				}
+			},
+		},
+		{
+			Alias:         "bad time",
+			Path:          "/admin/audit?from=yesterday",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "50",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad query parameter"}`),
+		},
+		{
+			Alias: "any backend error",
+			Path:  "/admin/audit",
+			AdminService: &AdminServiceMock{
+				ListAuditEventsResponse: model.ListAuditEventsResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid offset/limit",
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "51",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"invalid offset/limit"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/audit",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodGet, "/admin/audit", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.ListAuditEvents(mock)
+	}, testCases)
+
+}
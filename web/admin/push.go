@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type PushStatsProvider interface {
+	Stats() model.PushStats
+}
+
+// PushStats returns the number of connected inboxes and the counters of delivered, dropped and evicted messages.
+func PushStats(pushMessenger PushStatsProvider) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "PushStatsHandler")
+		logger.Debug().Msg("invoked")
+
+		sendJson(ctx, w, http.StatusOK, pushMessenger.Stats())
+
+	})
+}
@@ -0,0 +1,54 @@
+package admin_test
+
+import (
+	"net/http"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/admin"
+)
+
+func TestPushStats(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/push",
+			AdminService: &AdminServiceMock{
+				PushStats: model.PushStats{
+					Clients:   2,
+					Delivered: 40,
+					Dropped:   3,
+					Evicted:   1,
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "52",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"clients":2,"delivered":40,"dropped":3,"evicted":1}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/push",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+		},
+	}
+
+	runTestCases(b, http.MethodGet, "/admin/push", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.PushStats(mock)
+	}, testCases)
+
+}
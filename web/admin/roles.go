@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type ListRolesService interface {
+	ListRoles(context.Context, model.ListRolesRequest) model.ListRolesResponse
+}
+
+type CreateRoleService interface {
+	CreateRole(context.Context, model.CreateRoleRequest) model.CreateRoleResponse
+}
+
+type DeleteRoleService interface {
+	DeleteRole(context.Context, model.DeleteRoleRequest) model.DeleteRoleResponse
+}
+
+type SetRolePermissionsService interface {
+	SetRolePermissions(context.Context, model.SetRolePermissionsRequest) model.SetRolePermissionsResponse
+}
+
+type ListUserRolesService interface {
+	ListUserRoles(context.Context, model.ListUserRolesRequest) model.ListUserRolesResponse
+}
+
+type GrantRoleService interface {
+	GrantRole(context.Context, model.GrantRoleRequest) model.GrantRoleResponse
+}
+
+type RevokeRoleService interface {
+	RevokeRole(context.Context, model.RevokeRoleRequest) model.RevokeRoleResponse
+}
+
+// ListRoles returns all roles.
+func ListRoles(adminService ListRolesService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListRolesHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.ListRoles(ctx, model.ListRolesRequest{})
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Roles []model.Role `json:"roles"`
+		}{
+			Roles: rsp.Roles,
+		})
+
+	})
+}
+
+// CreateRole adds a custom role.
+func CreateRole(adminService CreateRoleService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "CreateRoleHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.CreateRoleRequest
+		if !readJson(ctx, w, r, &req) {
+			return
+		}
+
+		rsp := adminService.CreateRole(ctx, req)
+		if rsp.Code != http.StatusCreated {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, rsp.Role)
+
+	})
+}
+
+// DeleteRole removes a custom role and all grants of it.
+func DeleteRole(adminService DeleteRoleService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "DeleteRoleHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.DeleteRole(ctx, model.DeleteRoleRequest{
+			RoleID: chi.URLParam(r, ParamRoleID),
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// SetRolePermissions replaces the permissions of a role: {"permissions": [...]}.
+func SetRolePermissions(adminService SetRolePermissionsService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "SetRolePermissionsHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.SetRolePermissionsRequest
+		if !readJson(ctx, w, r, &req) {
+			return
+		}
+
+		req.RoleID = chi.URLParam(r, ParamRoleID)
+		rsp := adminService.SetRolePermissions(ctx, req)
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// ListUserRoles returns all grants of a user together with their validity windows.
+func ListUserRoles(adminService ListUserRolesService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListUserRolesHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.ListUserRoles(ctx, model.ListUserRolesRequest{
+			UserID: chi.URLParam(r, ParamUserID),
+		})
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Roles []model.UserRole `json:"roles"`
+		}{
+			Roles: rsp.Roles,
+		})
+
+	})
+}
+
+// GrantRole grants a role to a user.
+// The optional request body limits the grant to a validity window: {"validFrom": ..., "validTo": ...}.
+func GrantRole(adminService GrantRoleService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "GrantRoleHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.GrantRoleRequest
+		if r.ContentLength != 0 && !readJson(ctx, w, r, &req) {
+			return
+		}
+
+		req.UserID = chi.URLParam(r, ParamUserID)
+		req.RoleID = chi.URLParam(r, ParamRoleID)
+		rsp := adminService.GrantRole(ctx, req)
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// RevokeRole removes a role from a user.
+func RevokeRole(adminService RevokeRoleService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "RevokeRoleHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.RevokeRole(ctx, model.RevokeRoleRequest{
+			UserID: chi.URLParam(r, ParamUserID),
+			RoleID: chi.URLParam(r, ParamRoleID),
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
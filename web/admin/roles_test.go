@@ -0,0 +1,532 @@
+package admin_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/admin"
+)
+
+func TestListRoles(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/roles",
+			AdminService: &AdminServiceMock{
+				ListRolesResponse: model.ListRolesResponse{
+					Code: http.StatusOK,
+					Roles: []model.Role{
+						{ID: model.RoleIDAdmin, Name: model.RoleNameAdmin},
+						{ID: model.RoleIDUser, Name: model.RoleNameUser},
+					},
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "132",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"roles":[{"id":"05ed6375-0786-4e1a-bcb0-1533c837954d","name":"admin"},{"id":"ab9f2901-5aea-43b6-8f2b-7bf97dd30808","name":"user"}]}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/roles",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodGet, "/admin/roles", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.ListRoles(mock)
+	}, testCases)
+
+}
+
+func TestCreateRole(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/roles",
+			AdminService: &AdminServiceMock{
+				CreateRoleResponse: model.CreateRoleResponse{
+					Code: http.StatusCreated,
+					Role: &model.Role{ID: "roleid", Name: "editor"},
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"name": "editor"}`),
+			ResponseStatus: http.StatusCreated,
+			ResponseHeaders: map[string]string{
+				hContentLength: "31",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"id":"roleid","name":"editor"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.CreateRoleRequest.Name, "editor"; got != want {
+					t.Errorf("Bad role name: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "name taken",
+			Path:  "/admin/roles",
+			AdminService: &AdminServiceMock{
+				CreateRoleResponse: model.CreateRoleResponse{
+					Code:    http.StatusBadRequest,
+					Message: "role name not available",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"name": "editor"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "54",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"role name not available"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/roles",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(userS, testKeys),
+			},
+			RequestBody:     []byte(`{"name": "editor"}`),
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/roles", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.CreateRole(mock)
+	}, testCases)
+
+}
+
+func TestDeleteRole(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/roles/roleid",
+			AdminService: &AdminServiceMock{
+				DeleteRoleResponse: model.DeleteRoleResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.DeleteRoleRequest.RoleID, "roleid"; got != want {
+					t.Errorf("Bad role id: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "builtin",
+			Path:  "/admin/roles/" + model.RoleIDAdmin,
+			AdminService: &AdminServiceMock{
+				DeleteRoleResponse: model.DeleteRoleResponse{
+					Code:    http.StatusBadRequest,
+					Message: "cannot delete builtin role",
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "57",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"cannot delete builtin role"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/roles/roleid",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodDelete, "/admin/roles/{roleID}", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.DeleteRole(mock)
+	}, testCases)
+
+}
+
+func TestSetRolePermissions(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/roles/roleid/permissions",
+			AdminService: &AdminServiceMock{
+				SetRolePermissionsResponse: model.SetRolePermissionsResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"permissions": ["account", "users.manage"]}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.SetRolePermissionsRequest.RoleID, "roleid"; got != want {
+					t.Errorf("Bad role id: %s, expected %s", got, want)
+				}
+				if got, want := len(mock.SetRolePermissionsRequest.Permissions), 2; got != want {
+					t.Errorf("Bad permission count: %d, expected %d", got, want)
+				}
+			},
+		},
+		{
+			Alias: "unknown permission",
+			Path:  "/admin/roles/roleid/permissions",
+			AdminService: &AdminServiceMock{
+				SetRolePermissionsResponse: model.SetRolePermissionsResponse{
+					Code:    http.StatusBadRequest,
+					Message: "permission not valid: bogus",
+				},
+			},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"permissions": ["bogus"]}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "58",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"permission not valid: bogus"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/roles/roleid/permissions",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(userS, testKeys),
+			},
+			RequestBody:     []byte(`{"permissions": []}`),
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPut, "/admin/roles/{roleID}/permissions", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.SetRolePermissions(mock)
+	}, testCases)
+
+}
+
+func TestListUserRoles(b *testing.T) {
+
+	validTo := time.Date(2019, time.March, 17, 12, 0, 0, 0, time.UTC)
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/roles",
+			AdminService: &AdminServiceMock{
+				ListUserRolesResponse: model.ListUserRolesResponse{
+					Code: http.StatusOK,
+					Roles: []model.UserRole{
+						{ID: "roleid", Name: "editor", ValidFrom: &testNow, ValidTo: &validTo},
+						{ID: model.RoleIDUser, Name: model.RoleNameUser},
+					},
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "171",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"roles":[{"id":"roleid","name":"editor","validFrom":"2019-03-16T12:00:00Z","validTo":"2019-03-17T12:00:00Z"},{"id":"ab9f2901-5aea-43b6-8f2b-7bf97dd30808","name":"user"}]}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.ListUserRolesRequest.UserID, "id"; got != want {
+					t.Errorf("Bad user id: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "not found",
+			Path:  "/admin/users/id/roles",
+			AdminService: &AdminServiceMock{
+				ListUserRolesResponse: model.ListUserRolesResponse{
+					Code:    http.StatusNotFound,
+					Message: "user not found",
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusNotFound,
+			ResponseHeaders: map[string]string{
+				hContentLength: "45",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":404,"message":"user not found"}`),
+		},
+	}
+
+	runTestCases(b, http.MethodGet, "/admin/users/{userID}/roles", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.ListUserRoles(mock)
+	}, testCases)
+
+}
+
+func TestGrantRole(b *testing.T) {
+
+	validTo := time.Date(2019, time.March, 17, 12, 0, 0, 0, time.UTC)
+
+	testCases := []testCase{
+		{
+			Alias: "unbounded",
+			Path:  "/admin/users/id/roles/roleid",
+			AdminService: &AdminServiceMock{
+				GrantRoleResponse: model.GrantRoleResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				req := mock.GrantRoleRequest
+				if req.UserID != "id" || req.RoleID != "roleid" || req.ValidFrom != nil || req.ValidTo != nil {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias: "time bounded",
+			Path:  "/admin/users/id/roles/roleid",
+			AdminService: &AdminServiceMock{
+				GrantRoleResponse: model.GrantRoleResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"validFrom": "2019-03-16T12:00:00Z", "validTo": "2019-03-17T12:00:00Z"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				req := mock.GrantRoleRequest
+				if req.ValidFrom == nil || !req.ValidFrom.Equal(testNow) || req.ValidTo == nil || !req.ValidTo.Equal(validTo) {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias: "window reversed",
+			Path:  "/admin/users/id/roles/roleid",
+			AdminService: &AdminServiceMock{
+				GrantRoleResponse: model.GrantRoleResponse{
+					Code:    http.StatusBadRequest,
+					Message: "validTo must be after validFrom",
+				},
+			},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"validFrom": "2019-03-17T12:00:00Z", "validTo": "2019-03-16T12:00:00Z"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "62",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"validTo must be after validFrom"}`),
+		},
+		{
+			Alias:         "bogus payload",
+			Path:          "/admin/users/id/roles/roleid",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"validTo": "tomorrow"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "47",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad json payload"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/roles/roleid",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPut,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPut, "/admin/users/{userID}/roles/{roleID}", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.GrantRole(mock)
+	}, testCases)
+
+}
+
+func TestRevokeRole(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/roles/roleid",
+			AdminService: &AdminServiceMock{
+				RevokeRoleResponse: model.RevokeRoleResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if req := mock.RevokeRoleRequest; req.UserID != "id" || req.RoleID != "roleid" {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias: "not granted",
+			Path:  "/admin/users/id/roles/roleid",
+			AdminService: &AdminServiceMock{
+				RevokeRoleResponse: model.RevokeRoleResponse{
+					Code:    http.StatusNotFound,
+					Message: "role not granted",
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusNotFound,
+			ResponseHeaders: map[string]string{
+				hContentLength: "47",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":404,"message":"role not granted"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/roles/roleid",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodDelete, "/admin/users/{userID}/roles/{roleID}", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.RevokeRole(mock)
+	}, testCases)
+
+}
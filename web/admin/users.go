@@ -0,0 +1,192 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type ListUsersService interface {
+	ListUsers(context.Context, model.ListUsersRequest) model.ListUsersResponse
+}
+
+type CreateUserService interface {
+	CreateUser(context.Context, model.CreateUserRequest) model.CreateUserResponse
+}
+
+type SetUserDisabledService interface {
+	SetUserDisabled(context.Context, model.SetUserDisabledRequest) model.SetUserDisabledResponse
+}
+
+type UnlockUserService interface {
+	UnlockUser(context.Context, model.UnlockUserRequest) model.UnlockUserResponse
+}
+
+type DeleteUserService interface {
+	DeleteUser(context.Context, model.DeleteUserRequest) model.DeleteUserResponse
+}
+
+type ResetPasswordService interface {
+	ResetPassword(context.Context, model.ResetPasswordRequest) model.ResetPasswordResponse
+}
+
+// ListUsers returns a page of users.
+// Query parameters: offset, limit, username (partial match) and disabled (true or false).
+func ListUsers(adminService ListUsersService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListUsersHandler")
+		logger.Debug().Msg("invoked")
+
+		query := r.URL.Query()
+		req := model.ListUsersRequest{
+			Username: query.Get("username"),
+		}
+
+		var errParam error
+		if value := query.Get("offset"); value != "" {
+			req.Offset, errParam = strconv.Atoi(value)
+		}
+		if value := query.Get("limit"); value != "" && errParam == nil {
+			req.Limit, errParam = strconv.Atoi(value)
+		}
+		if value := query.Get("disabled"); value != "" && errParam == nil {
+			var disabled bool
+			disabled, errParam = strconv.ParseBool(value)
+			req.Disabled = &disabled
+		}
+		if errParam != nil {
+			msg := "bad query parameter"
+			logger.Debug().Err(errParam).Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rsp := adminService.ListUsers(ctx, req)
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Users []model.UserInfo `json:"users"`
+			Total int              `json:"total"`
+		}{
+			Users: rsp.Users,
+			Total: rsp.Total,
+		})
+
+	})
+}
+
+// CreateUser adds a user with an initial password.
+func CreateUser(adminService CreateUserService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "CreateUserHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.CreateUserRequest
+		if !readJson(ctx, w, r, &req) {
+			return
+		}
+
+		rsp := adminService.CreateUser(ctx, req)
+		if rsp.Code != http.StatusCreated {
+			sendJsonRuleMessage(ctx, w, rsp.Code, rsp.Message, rsp.Rule)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, rsp.User)
+
+	})
+}
+
+// DisableUser disables a user, ending all of the user's sessions.
+func DisableUser(adminService SetUserDisabledService) http.HandlerFunc {
+	return setUserDisabled(adminService, true)
+}
+
+// EnableUser re-enables a disabled user.
+func EnableUser(adminService SetUserDisabledService) http.HandlerFunc {
+	return setUserDisabled(adminService, false)
+}
+
+func setUserDisabled(adminService SetUserDisabledService, disabled bool) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "SetUserDisabledHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.SetUserDisabled(ctx, model.SetUserDisabledRequest{
+			UserID:   chi.URLParam(r, ParamUserID),
+			Disabled: disabled,
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// UnlockUser lifts a lockout caused by failed logins.
+func UnlockUser(adminService UnlockUserService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "UnlockUserHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.UnlockUser(ctx, model.UnlockUserRequest{
+			UserID: chi.URLParam(r, ParamUserID),
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// DeleteUser removes a user together with the user's roles and sessions.
+func DeleteUser(adminService DeleteUserService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "DeleteUserHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := adminService.DeleteUser(ctx, model.DeleteUserRequest{
+			UserID: chi.URLParam(r, ParamUserID),
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// ResetPassword sets a new password for a user, ending all of the user's sessions.
+func ResetPassword(adminService ResetPasswordService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ResetPasswordHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.ResetPasswordRequest
+		if !readJson(ctx, w, r, &req) {
+			return
+		}
+
+		req.UserID = chi.URLParam(r, ParamUserID)
+		rsp := adminService.ResetPassword(ctx, req)
+
+		sendJsonRuleMessage(ctx, w, rsp.Code, rsp.Message, rsp.Rule)
+
+	})
+}
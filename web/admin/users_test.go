@@ -0,0 +1,596 @@
+package admin_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/admin"
+)
+
+var (
+	testNow = time.Date(2019, time.March, 16, 12, 0, 0, 0, time.UTC)
+
+	adminS = &model.SessionToken{
+		SessionID: "S123",
+		ID:        "adminid",
+		Username:  "admin",
+		Name:      "Admin User",
+		Roles:     []string{model.RoleNameAdmin, model.RoleNameUser},
+		Issued:    time.Now().Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	userS = &model.SessionToken{
+		SessionID: "S456",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{model.RoleNameUser},
+		Issued:    time.Now().Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	forbiddenHeaders = map[string]string{
+		hContentLength: "10",
+		hContentType:   mimeTypeText,
+		hDate:          ignoreValue,
+	}
+)
+
+func TestListUsers(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users?offset=10&limit=1&username=demo&disabled=false",
+			AdminService: &AdminServiceMock{
+				ListUsersResponse: model.ListUsersResponse{
+					Code: http.StatusOK,
+					Users: []model.UserInfo{
+						{
+							ID:       "id",
+							Username: "demouser",
+							Name:     "Demo User",
+							Created:  testNow,
+							Updated:  testNow,
+						},
+					},
+					Total: 11,
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "158",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"users":[{"id":"id","disabled":false,"username":"demouser","name":"Demo User","created":"2019-03-16T12:00:00Z","updated":"2019-03-16T12:00:00Z"}],"total":11}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				req := mock.ListUsersRequest
+				if req.Offset != 10 || req.Limit != 1 || req.Username != "demo" || req.Disabled == nil || *req.Disabled {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias:         "bad query parameter",
+			Path:          "/admin/users?limit=ten",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "50",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad query parameter"}`),
+		},
+		{
+			Alias: "any backend error",
+			Path:  "/admin/users",
+			AdminService: &AdminServiceMock{
+				ListUsersResponse: model.ListUsersResponse{
+					Code:    http.StatusInternalServerError,
+					Message: "any old error",
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseHeaders: map[string]string{
+				hContentLength: "44",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":500,"message":"any old error"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodGet, "/admin/users", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.ListUsers(mock)
+	}, testCases)
+
+}
+
+func TestCreateUser(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users",
+			AdminService: &AdminServiceMock{
+				CreateUserResponse: model.CreateUserResponse{
+					Code: http.StatusCreated,
+					User: &model.UserInfo{
+						ID:       "newid",
+						Username: "newuser",
+						Name:     "New User",
+						Created:  testNow,
+						Updated:  testNow,
+					},
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"username": "newuser", "name": "New User", "password": "newpassword"}`),
+			ResponseStatus: http.StatusCreated,
+			ResponseHeaders: map[string]string{
+				hContentLength: "136",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"id":"newid","disabled":false,"username":"newuser","name":"New User","created":"2019-03-16T12:00:00Z","updated":"2019-03-16T12:00:00Z"}`),
+		},
+		{
+			Alias: "username not available",
+			Path:  "/admin/users",
+			AdminService: &AdminServiceMock{
+				CreateUserResponse: model.CreateUserResponse{
+					Code:    http.StatusBadRequest,
+					Message: "username not available",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"username": "demouser", "name": "New User", "password": "newpassword"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "53",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"username not available"}`),
+		},
+		{
+			Alias:         "no content-type",
+			Path:          "/admin/users",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"username": "newuser", "name": "New User", "password": "newpassword"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "58",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad or missing content type"}`),
+		},
+		{
+			Alias:         "bogus payload",
+			Path:          "/admin/users",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"username"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "47",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad json payload"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(userS, testKeys),
+			},
+			RequestBody:     []byte(`{"username": "newuser", "name": "New User", "password": "newpassword"}`),
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/users", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.CreateUser(mock)
+	}, testCases)
+
+}
+
+func TestDisableUser(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/disable",
+			AdminService: &AdminServiceMock{
+				SetUserDisabledResponse: model.SetUserDisabledResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if req := mock.SetUserDisabledRequest; req.UserID != "id" || !req.Disabled {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias: "not found",
+			Path:  "/admin/users/id/disable",
+			AdminService: &AdminServiceMock{
+				SetUserDisabledResponse: model.SetUserDisabledResponse{
+					Code:    http.StatusNotFound,
+					Message: "user not found",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusNotFound,
+			ResponseHeaders: map[string]string{
+				hContentLength: "45",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":404,"message":"user not found"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/disable",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/users/{userID}/disable", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.DisableUser(mock)
+	}, testCases)
+
+}
+
+func TestEnableUser(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/enable",
+			AdminService: &AdminServiceMock{
+				SetUserDisabledResponse: model.SetUserDisabledResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if req := mock.SetUserDisabledRequest; req.UserID != "id" || req.Disabled {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/enable",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/users/{userID}/enable", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.EnableUser(mock)
+	}, testCases)
+
+}
+
+func TestUnlockUser(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/unlock",
+			AdminService: &AdminServiceMock{
+				UnlockUserResponse: model.UnlockUserResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.UnlockUserRequest.UserID, "id"; got != want {
+					t.Errorf("Bad user id: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "user not found",
+			Path:  "/admin/users/id/unlock",
+			AdminService: &AdminServiceMock{
+				UnlockUserResponse: model.UnlockUserResponse{
+					Code:    http.StatusNotFound,
+					Message: "user not found",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusNotFound,
+			ResponseHeaders: map[string]string{
+				hContentLength: "45",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":404,"message":"user not found"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/unlock",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/users/{userID}/unlock", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.UnlockUser(mock)
+	}, testCases)
+
+}
+
+func TestDeleteUser(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id",
+			AdminService: &AdminServiceMock{
+				DeleteUserResponse: model.DeleteUserResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if got, want := mock.DeleteUserRequest.UserID, "id"; got != want {
+					t.Errorf("Bad user id: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "cannot delete self",
+			Path:  "/admin/users/adminid",
+			AdminService: &AdminServiceMock{
+				DeleteUserResponse: model.DeleteUserResponse{
+					Code:    http.StatusBadRequest,
+					Message: "cannot delete own user",
+				},
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "53",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"cannot delete own user"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(userS, testKeys),
+			},
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodDelete, "/admin/users/{userID}", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.DeleteUser(mock)
+	}, testCases)
+
+}
+
+func TestResetPassword(b *testing.T) {
+
+	testCases := []testCase{
+		{
+			Alias: "success",
+			Path:  "/admin/users/id/password",
+			AdminService: &AdminServiceMock{
+				ResetPasswordResponse: model.ResetPasswordResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "newpassword"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+			Check: func(t *testing.T, mock *AdminServiceMock) {
+				if req := mock.ResetPasswordRequest; req.UserID != "id" || req.Password != "newpassword" {
+					t.Errorf("Bad request: %+v", req)
+				}
+			},
+		},
+		{
+			Alias: "invalid password",
+			Path:  "/admin/users/id/password",
+			AdminService: &AdminServiceMock{
+				ResetPasswordResponse: model.ResetPasswordResponse{
+					Code:    http.StatusBadRequest,
+					Message: "password must have at least 8 characters",
+					Rule:    model.PasswordRuleMinLength,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "short"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "90",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"password must have at least 8 characters","rule":"minLength"}`),
+		},
+		{
+			Alias:         "no content-type",
+			Path:          "/admin/users/id/password",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(adminS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "newpassword"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "58",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad or missing content type"}`),
+		},
+		{
+			Alias:         "forbidden",
+			Path:          "/admin/users/id/password",
+			AdminService:  &AdminServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(userS, testKeys),
+			},
+			RequestBody:     []byte(`{"password": "newpassword"}`),
+			ResponseStatus:  http.StatusForbidden,
+			ResponseHeaders: forbiddenHeaders,
+			ResponseBody:    []byte("Forbidden\n"),
+		},
+	}
+
+	runTestCases(b, http.MethodPost, "/admin/users/{userID}/password", func(mock *AdminServiceMock) http.HandlerFunc {
+		return admin.ResetPassword(mock)
+	}, testCases)
+
+}
@@ -0,0 +1,98 @@
+package apitoken_test
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	os.Exit(m.Run())
+}
+
+const (
+	ignoreValue    = "XXX"
+	hContentLength = "Content-Length"
+	hContentType   = "Content-Type"
+	hCookie        = "Cookie"
+	hDate          = "Date"
+	mimeTypeJson   = "application/json"
+	mimeTypeText   = "text/plain; charset=utf-8"
+	testPassword   = "secret"
+)
+
+var (
+	testKeys = auth.NewHMACKeys(testPassword)
+)
+
+func getCookieString(user *model.SessionToken, keys *auth.Keys) string {
+	r, err := auth.MakeJWT(user, keys)
+	if err != nil {
+		panic(err)
+	}
+	c := &http.Cookie{
+		Name:    auth.CookieName,
+		Value:   r,
+		Expires: user.Expires,
+		Path:    "/",
+		Secure:  true,
+	}
+	return c.String()
+}
+
+type SessionServiceMock struct{}
+
+func (z *SessionServiceMock) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (z *SessionServiceMock) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+	return nil, nil
+}
+
+type APITokenServiceMock struct {
+	CreateAPITokenResponse model.CreateAPITokenResponse
+	ListAPITokensResponse  model.ListAPITokensResponse
+	RevokeAPITokenResponse model.RevokeAPITokenResponse
+	CreateAPITokenRequest  *model.CreateAPITokenRequest
+	RevokeAPITokenRequest  *model.RevokeAPITokenRequest
+}
+
+func (z *APITokenServiceMock) CreateAPIToken(ctx context.Context, req model.CreateAPITokenRequest) model.CreateAPITokenResponse {
+	z.CreateAPITokenRequest = &req
+	return z.CreateAPITokenResponse
+}
+
+func (z *APITokenServiceMock) ListAPITokens(ctx context.Context, req model.ListAPITokensRequest) model.ListAPITokensResponse {
+	return z.ListAPITokensResponse
+}
+
+func (z *APITokenServiceMock) RevokeAPIToken(ctx context.Context, req model.RevokeAPITokenRequest) model.RevokeAPITokenResponse {
+	z.RevokeAPITokenRequest = &req
+	return z.RevokeAPITokenResponse
+}
+
+// serve runs the request through the session authenticator and the handler mounted at pattern.
+func serve(method, pattern string, handlerFn http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	handler := chi.NewRouter()
+	handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{})...)
+	handler.MethodFunc(method, pattern, handlerFn)
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, req)
+	return rsp
+}
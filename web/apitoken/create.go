@@ -0,0 +1,74 @@
+package apitoken
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type CreateAPITokenService interface {
+	CreateAPIToken(context.Context, model.CreateAPITokenRequest) model.CreateAPITokenResponse
+}
+
+// Create issues a new API token for the current user. The token is part of this response only.
+func Create(apiTokenService CreateAPITokenService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "CreateAPITokenHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		if r.Header.Get(hContentType) != mimeTypeJson {
+			msg := "bad or missing content type"
+			logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+
+		body, errBody := ioutil.ReadAll(r.Body)
+		if errBody != nil {
+			msg := "cannot read request"
+			logger.Debug().Err(errBody).Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+		defer r.Body.Close()
+
+		req := model.CreateAPITokenRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			msg := "bad json payload"
+			logger.Debug().Err(err).Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+		req.UserID = sessionToken.ID
+
+		rsp := apiTokenService.CreateAPIToken(ctx, req)
+
+		if rsp.Code != http.StatusCreated {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Token    string          `json:"token"`
+			APIToken *model.APIToken `json:"apiToken"`
+		}{
+			Token:    rsp.Token,
+			APIToken: rsp.APIToken,
+		})
+
+	})
+}
@@ -0,0 +1,151 @@
+package apitoken_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/apitoken"
+)
+
+func TestCreate(b *testing.T) {
+
+	now := time.Date(2019, time.March, 16, 12, 0, 0, 0, time.UTC)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    time.Now().Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias           string
+		OutputResponse  model.CreateAPITokenResponse
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseBody    string
+		ExpectedRequest *model.CreateAPITokenRequest
+	}{
+		{
+			Alias: "success",
+			OutputResponse: model.CreateAPITokenResponse{
+				Code:  http.StatusCreated,
+				Token: "wwt_secret",
+				APIToken: &model.APIToken{
+					ID:      "T123",
+					UserID:  "id",
+					Name:    "backup",
+					Scopes:  []string{"users"},
+					Created: now,
+					Expires: now.Add(time.Hour * 24),
+				},
+			},
+			RequestHeaders: map[string]string{
+				hCookie:      getCookieString(demouserS, testKeys),
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:     []byte(`{"name":"backup","scopes":["users"],"expiresInDays":1}`),
+			ResponseStatus:  http.StatusCreated,
+			ResponseBody:    `{"token":"wwt_secret","apiToken":{"id":"T123","name":"backup","scopes":["users"],"created":"2019-03-16T12:00:00Z","expires":"2019-03-17T12:00:00Z"}}`,
+			ExpectedRequest: &model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{"users"}, ExpiresInDays: 1},
+		},
+		{
+			Alias: "scope not granted",
+			OutputResponse: model.CreateAPITokenResponse{
+				Code:    http.StatusBadRequest,
+				Message: "scope not granted: admins",
+			},
+			RequestHeaders: map[string]string{
+				hCookie:      getCookieString(demouserS, testKeys),
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:     []byte(`{"name":"backup","scopes":["admins"],"expiresInDays":1}`),
+			ResponseStatus:  http.StatusBadRequest,
+			ResponseBody:    `{"statusCode":400,"message":"scope not granted: admins"}`,
+			ExpectedRequest: &model.CreateAPITokenRequest{UserID: "id", Name: "backup", Scopes: []string{"admins"}, ExpiresInDays: 1},
+		},
+		{
+			Alias: "bad content type",
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"name":"backup"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseBody:   `{"statusCode":400,"message":"bad or missing content type"}`,
+		},
+		{
+			Alias: "bad json",
+			RequestHeaders: map[string]string{
+				hCookie:      getCookieString(demouserS, testKeys),
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"name":`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseBody:   `{"statusCode":400,"message":"bad json payload"}`,
+		},
+		{
+			Alias:          "unauthorized",
+			RequestBody:    []byte(`{"name":"backup"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "Unauthorized\n",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &APITokenServiceMock{
+				CreateAPITokenResponse: tCase.OutputResponse,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/apitokens", bytes.NewReader(tCase.RequestBody))
+			for key, value := range tCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+			rsp := serve(http.MethodPost, "/apitokens", apitoken.Create(ss), req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+			body, _ := ioutil.ReadAll(rsp.Body)
+			if got, want := string(body), tCase.ResponseBody; got != want {
+				t.Errorf("bad body %s, expected %s", got, want)
+			}
+
+			if tCase.ExpectedRequest == nil {
+				if ss.CreateAPITokenRequest != nil {
+					t.Errorf("bad request %v, expected none", ss.CreateAPITokenRequest)
+				}
+				return
+			}
+			if ss.CreateAPITokenRequest == nil {
+				t.Fatal("service not invoked")
+			}
+			if got, want := ss.CreateAPITokenRequest.UserID, tCase.ExpectedRequest.UserID; got != want {
+				t.Errorf("bad user ID %s, expected %s", got, want)
+			}
+			if got, want := ss.CreateAPITokenRequest.Name, tCase.ExpectedRequest.Name; got != want {
+				t.Errorf("bad name %s, expected %s", got, want)
+			}
+			if got, want := ss.CreateAPITokenRequest.ExpiresInDays, tCase.ExpectedRequest.ExpiresInDays; got != want {
+				t.Errorf("bad expiry %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,48 @@
+package apitoken
+
+import (
+	"context"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type ListAPITokensService interface {
+	ListAPITokens(context.Context, model.ListAPITokensRequest) model.ListAPITokensResponse
+}
+
+// List returns the API tokens of the current user without the tokens themselves.
+func List(apiTokenService ListAPITokensService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListAPITokensHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		rsp := apiTokenService.ListAPITokens(ctx, model.ListAPITokensRequest{
+			UserID: sessionToken.ID,
+		})
+
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		apiTokens := rsp.APITokens
+		if apiTokens == nil {
+			apiTokens = []model.APIToken{}
+		}
+
+		sendJson(ctx, w, rsp.Code, apiTokens)
+
+	})
+}
@@ -0,0 +1,117 @@
+package apitoken_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/apitoken"
+)
+
+func TestList(b *testing.T) {
+
+	now := time.Date(2019, time.March, 16, 12, 0, 0, 0, time.UTC)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    time.Now().Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias          string
+		OutputResponse model.ListAPITokensResponse
+		RequestHeaders map[string]string
+		ResponseStatus int
+		ResponseBody   string
+	}{
+		{
+			Alias: "success",
+			OutputResponse: model.ListAPITokensResponse{
+				Code: http.StatusOK,
+				APITokens: []model.APIToken{
+					{
+						ID:        "T123",
+						UserID:    "id",
+						Name:      "backup",
+						TokenHash: "hash",
+						Scopes:    []string{"users"},
+						Created:   now,
+						Expires:   now.Add(time.Hour * 24),
+						LastUsed:  &now,
+					},
+				},
+			},
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   `[{"id":"T123","name":"backup","scopes":["users"],"created":"2019-03-16T12:00:00Z","expires":"2019-03-17T12:00:00Z","lastUsed":"2019-03-16T12:00:00Z"}]`,
+		},
+		{
+			Alias: "none",
+			OutputResponse: model.ListAPITokensResponse{
+				Code: http.StatusOK,
+			},
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   `[]`,
+		},
+		{
+			Alias: "error",
+			OutputResponse: model.ListAPITokensResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "just some error",
+			},
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseBody:   `{"statusCode":500,"message":"just some error"}`,
+		},
+		{
+			Alias:          "unauthorized",
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "Unauthorized\n",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &APITokenServiceMock{
+				ListAPITokensResponse: tCase.OutputResponse,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/apitokens", nil)
+			for key, value := range tCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+			rsp := serve(http.MethodGet, "/apitokens", apitoken.List(ss), req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+			body, _ := ioutil.ReadAll(rsp.Body)
+			if got, want := string(body), tCase.ResponseBody; got != want {
+				t.Errorf("bad body %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,53 @@
+package apitoken
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	ParamAPITokenID = "apiTokenID"
+)
+
+type RevokeAPITokenService interface {
+	RevokeAPIToken(context.Context, model.RevokeAPITokenRequest) model.RevokeAPITokenResponse
+}
+
+// Revoke revokes an API token, identified by the apiTokenID URL parameter, of the current user.
+func Revoke(apiTokenService RevokeAPITokenService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "RevokeAPITokenHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		apiTokenID := chi.URLParam(r, ParamAPITokenID)
+		if len(apiTokenID) == 0 {
+			msg := "missing API token id"
+			logger.Debug().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rsp := apiTokenService.RevokeAPIToken(ctx, model.RevokeAPITokenRequest{
+			UserID:     sessionToken.ID,
+			APITokenID: apiTokenID,
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
@@ -0,0 +1,103 @@
+package apitoken_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/apitoken"
+)
+
+func TestRevoke(b *testing.T) {
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    time.Now().Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias              string
+		OutputResponse     model.RevokeAPITokenResponse
+		RequestHeaders     map[string]string
+		ResponseStatus     int
+		ResponseBody       string
+		ExpectedAPITokenID string
+	}{
+		{
+			Alias: "success",
+			OutputResponse: model.RevokeAPITokenResponse{
+				Code: http.StatusOK,
+			},
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			ResponseStatus:     http.StatusOK,
+			ResponseBody:       `{"statusCode":200,"message":"OK"}`,
+			ExpectedAPITokenID: "T123",
+		},
+		{
+			Alias: "not found",
+			OutputResponse: model.RevokeAPITokenResponse{
+				Code:    http.StatusNotFound,
+				Message: "API token not found",
+			},
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			ResponseStatus:     http.StatusNotFound,
+			ResponseBody:       `{"statusCode":404,"message":"API token not found"}`,
+			ExpectedAPITokenID: "T123",
+		},
+		{
+			Alias:          "unauthorized",
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "Unauthorized\n",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &APITokenServiceMock{
+				RevokeAPITokenResponse: tCase.OutputResponse,
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/apitokens/T123", nil)
+			for key, value := range tCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+			rsp := serve(http.MethodDelete, "/apitokens/{apiTokenID}", apitoken.Revoke(ss), req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+			body, _ := ioutil.ReadAll(rsp.Body)
+			if got, want := string(body), tCase.ResponseBody; got != want {
+				t.Errorf("bad body %s, expected %s", got, want)
+			}
+
+			var apiTokenID string
+			if ss.RevokeAPITokenRequest != nil {
+				apiTokenID = ss.RevokeAPITokenRequest.APITokenID
+			}
+			if got, want := apiTokenID, tCase.ExpectedAPITokenID; got != want {
+				t.Errorf("bad API token ID %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
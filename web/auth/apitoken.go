@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	hAuthorization = "Authorization"
+	bearerPrefix   = "Bearer "
+)
+
+type APITokenService interface {
+	ValidateAPIToken(ctx context.Context, token string) (*model.SessionToken, error)
+}
+
+// NewAPITokenAuthenticator returns the authenticator chain accepting an API token as Authorization: Bearer header.
+// Requests without an API token are passed to the given session authenticator chain.
+func NewAPITokenAuthenticator(apiTokenService APITokenService, sessionAuthenticator []func(http.Handler) http.Handler) []func(http.Handler) http.Handler {
+	return []func(next http.Handler) http.Handler{
+		func(next http.Handler) http.Handler {
+
+			sessionHandler := next
+			for i := len(sessionAuthenticator) - 1; i >= 0; i-- {
+				sessionHandler = sessionAuthenticator[i](sessionHandler)
+			}
+
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+				token := APITokenFromHeader(r)
+				if len(token) == 0 {
+					sessionHandler.ServeHTTP(w, r)
+					return
+				}
+
+				ctx := r.Context()
+				logger := logging.New(ctx, "auth", "APITokenAuthenticator")
+
+				user, err := apiTokenService.ValidateAPIToken(ctx, token)
+				if err != nil {
+					logger.Error().Err(err).Msg("Cannot validate API token")
+					sendMessage(w, http.StatusInternalServerError)
+					return
+				}
+				if user == nil {
+					logger.Info().Msg("API token invalid, revoked or expired")
+					sendMessage(w, http.StatusUnauthorized)
+					return
+				}
+
+				ctx = context.WithValue(ctx, model.UserKey, *user)
+				logger.Info().Str("UserID", user.ID).Str("APITokenID", user.APITokenID).Msg("authenticated")
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+
+			})
+
+		},
+	}
+}
+
+// NewSessionRequired returns middleware to forbid requests authenticated by an API token,
+// so that tokens cannot be used to manage the account they belong to.
+func NewSessionRequired() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+			logger := logging.New(ctx, "auth", "SessionRequired")
+			user := model.TokenFromContext(ctx)
+
+			if len(user.APITokenID) != 0 {
+				logger.Info().Str("APITokenID", user.APITokenID).Msg("forbidden for API token")
+				sendMessage(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
+
+// APITokenFromHeader returns the bearer token of the request if it is an API token.
+func APITokenFromHeader(r *http.Request) string {
+	header := r.Header.Get(hAuthorization)
+	if len(header) > len(bearerPrefix) && strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		if token := header[len(bearerPrefix):]; model.IsAPIToken(token) {
+			return token
+		}
+	}
+	return ""
+}
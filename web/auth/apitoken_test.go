@@ -0,0 +1,149 @@
+package auth_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestAPITokenAuthenticator(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now,
+		Expires:   now.Add(model.LoginTimeout),
+	}
+
+	scripted := &model.SessionToken{
+		APITokenID: "T123",
+		ID:         "id",
+		Username:   "demouser",
+		Name:       "Demo User",
+		Roles:      []string{"users"},
+		Issued:     now,
+		Expires:    now.Add(time.Hour),
+	}
+
+	testCases := []struct {
+		Alias             string
+		Path              string
+		RequestHeaders    map[string]string
+		OutputToken       *model.SessionToken
+		OutputError       error
+		ExpectedValidated string
+		ResponseStatus    int
+		ResponseBody      string
+	}{
+		{
+			Alias:             "API token",
+			Path:              "/whoami",
+			RequestHeaders:    map[string]string{"Authorization": "Bearer wwt_secret"},
+			OutputToken:       scripted,
+			ExpectedValidated: "wwt_secret",
+			ResponseStatus:    http.StatusOK,
+			ResponseBody:      `{"sessionID":"","apiTokenID":"T123","id":"id","username":"demouser","name":"Demo User","roles":["users"]}`,
+		},
+		{
+			Alias:             "invalid API token",
+			Path:              "/whoami",
+			RequestHeaders:    map[string]string{"Authorization": "bearer wwt_secret"},
+			ExpectedValidated: "wwt_secret",
+			ResponseStatus:    http.StatusUnauthorized,
+			ResponseBody:      "Unauthorized\n",
+		},
+		{
+			Alias:             "validation fails",
+			Path:              "/whoami",
+			RequestHeaders:    map[string]string{"Authorization": "Bearer wwt_secret"},
+			OutputError:       errors.New("just some error"),
+			ExpectedValidated: "wwt_secret",
+			ResponseStatus:    http.StatusInternalServerError,
+			ResponseBody:      "Internal Server Error\n",
+		},
+		{
+			Alias:          "session cookie",
+			Path:           "/whoami",
+			RequestHeaders: map[string]string{hCookie: getCookieString(demouser, testKeys)},
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   `{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users"]}`,
+		},
+		{
+			Alias:          "no credentials",
+			Path:           "/whoami",
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "Unauthorized\n",
+		},
+		{
+			Alias:             "API token on session only route",
+			Path:              "/account",
+			RequestHeaders:    map[string]string{"Authorization": "Bearer wwt_secret"},
+			OutputToken:       scripted,
+			ExpectedValidated: "wwt_secret",
+			ResponseStatus:    http.StatusForbidden,
+			ResponseBody:      "Forbidden\n",
+		},
+		{
+			Alias:          "session on session only route",
+			Path:           "/account",
+			RequestHeaders: map[string]string{hCookie: getCookieString(demouser, testKeys)},
+			ResponseStatus: http.StatusOK,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			apiTokenService := &APITokenServiceMock{
+				SessionToken:  tCase.OutputToken,
+				ValidateError: tCase.OutputError,
+			}
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAPITokenAuthenticator(apiTokenService, auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true}))...)
+			handler.Get("/whoami", auth.Whoami())
+			handler.With(auth.NewSessionRequired()).Get("/account", sendMessageHandler(http.StatusOK))
+
+			req := httptest.NewRequest(http.MethodGet, tCase.Path, nil)
+			for key, value := range tCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+			if len(tCase.ResponseBody) != 0 {
+				body, _ := ioutil.ReadAll(rsp.Body)
+				if got, want := string(body), tCase.ResponseBody; got != want {
+					t.Errorf("bad body %s, expected %s", got, want)
+				}
+			}
+
+			if got, want := apiTokenService.Token, tCase.ExpectedValidated; got != want {
+				t.Errorf("bad validated token %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
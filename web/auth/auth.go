@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 const (
@@ -12,6 +14,7 @@ const (
 const (
 	hContentLength = "Content-Length"
 	hContentType   = "Content-Type"
+	hRetryAfter    = "Retry-After"
 	mimeTypeJson   = "application/json"
 )
 
@@ -25,3 +28,37 @@ func sendMessageText(w http.ResponseWriter, statusCode int, statusText string) {
 	w.WriteHeader(statusCode)
 	w.Write(msg)
 }
+
+func sendJson(w http.ResponseWriter, statusCode int, payload interface{}) {
+	msg, errMsg := json.Marshal(payload)
+	if errMsg != nil {
+		sendMessage(w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
+
+// sendJsonRuleMessage sends a message naming the validation rule that failed, if any.
+func sendJsonRuleMessage(w http.ResponseWriter, statusCode int, message, rule string) {
+	if len(message) == 0 {
+		message = http.StatusText(statusCode)
+	}
+	sendJson(w, statusCode, struct {
+		StatusCode int    `json:"statusCode"`
+		Message    string `json:"message,omitempty"`
+		Rule       string `json:"rule,omitempty"`
+	}{
+		StatusCode: statusCode,
+		Message:    message,
+		Rule:       rule,
+	})
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set(hRetryAfter, strconv.FormatInt(seconds, 10))
+}
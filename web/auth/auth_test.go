@@ -30,12 +30,17 @@ const (
 	hContentType   = "Content-Type"
 	hCookie        = "Cookie"
 	hDate          = "Date"
+	hRetryAfter    = "Retry-After"
 	hSetCookie     = "Set-Cookie"
 	mimeTypeJson   = "application/json"
 	mimeTypeText   = "text/plain; charset=utf-8"
 	testPassword   = "secret"
 )
 
+var (
+	testKeys = auth.NewHMACKeys(testPassword)
+)
+
 func sendMessageHandler(statusCode int) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sendMessage(w, statusCode)
@@ -49,25 +54,103 @@ func sendMessage(w http.ResponseWriter, statusCode int) {
 	w.Write(msg)
 }
 
-func getCookieString(user *model.SessionToken, password string) string {
-	r, err := auth.MakeJWT(user, password)
+func getCookieString(user *model.SessionToken, keys *auth.Keys) string {
+	r, err := auth.MakeJWT(user, keys)
 	if err != nil {
 		panic(err)
 	}
 	c := &http.Cookie{
-		Name:    auth.CookieName,
-		Value:   r,
-		Expires: user.Expires,
-		Path:    "/",
-		Secure:  true,
+		Name:     auth.CookieName,
+		Value:    r,
+		Expires:  user.Expires,
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
 	}
 	return c.String()
 }
 
 type UserServiceMock struct {
-	LoginResponse model.LoginResponse
+	LoginResponse       model.LoginResponse
+	LoginOTPResponse    model.LoginResponse
+	LoginOTPRequest     model.LoginOTPRequest
+	RegisterResponse    model.RegisterResponse
+	RegisterRequest     model.RegisterRequest
+	VerifyEmailResponse model.VerifyEmailResponse
+	VerifyEmailRequest  model.VerifyEmailRequest
+	ForgotResponse      model.ForgotPasswordResponse
+	ForgotRequest       model.ForgotPasswordRequest
+	ResetResponse       model.ResetForgottenPasswordResponse
+	ResetRequest        model.ResetForgottenPasswordRequest
+	ExternalResponse    model.LoginResponse
+	ExternalRequest     *model.ExternalLoginRequest
 }
 
 func (z *UserServiceMock) Login(ctx context.Context, req model.LoginRequest) model.LoginResponse {
 	return z.LoginResponse
 }
+
+func (z *UserServiceMock) LoginExternal(ctx context.Context, req model.ExternalLoginRequest) model.LoginResponse {
+	z.ExternalRequest = &req
+	return z.ExternalResponse
+}
+
+func (z *UserServiceMock) LoginOTP(ctx context.Context, req model.LoginOTPRequest) model.LoginResponse {
+	z.LoginOTPRequest = req
+	return z.LoginOTPResponse
+}
+
+func (z *UserServiceMock) Register(ctx context.Context, req model.RegisterRequest) model.RegisterResponse {
+	z.RegisterRequest = req
+	return z.RegisterResponse
+}
+
+func (z *UserServiceMock) VerifyEmail(ctx context.Context, req model.VerifyEmailRequest) model.VerifyEmailResponse {
+	z.VerifyEmailRequest = req
+	return z.VerifyEmailResponse
+}
+
+func (z *UserServiceMock) ForgotPassword(ctx context.Context, req model.ForgotPasswordRequest) model.ForgotPasswordResponse {
+	z.ForgotRequest = req
+	return z.ForgotResponse
+}
+
+func (z *UserServiceMock) ResetForgottenPassword(ctx context.Context, req model.ResetForgottenPasswordRequest) model.ResetForgottenPasswordResponse {
+	z.ResetRequest = req
+	return z.ResetResponse
+}
+
+type SessionServiceMock struct {
+	Valid        bool
+	ValidError   error
+	RevokeError  error
+	Revoked      []string
+	Renewed      *model.SessionToken
+	RenewError   error
+	RenewInvoked bool
+}
+
+func (z *SessionServiceMock) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return z.Valid, z.ValidError
+}
+
+func (z *SessionServiceMock) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+	z.RenewInvoked = true
+	return z.Renewed, z.RenewError
+}
+
+func (z *SessionServiceMock) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	z.Revoked = append(z.Revoked, sessionID)
+	return z.RevokeError
+}
+
+type APITokenServiceMock struct {
+	SessionToken  *model.SessionToken
+	ValidateError error
+	Token         string
+}
+
+func (z *APITokenServiceMock) ValidateAPIToken(ctx context.Context, token string) (*model.SessionToken, error) {
+	z.Token = token
+	return z.SessionToken, z.ValidateError
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
@@ -13,15 +14,67 @@ import (
 	"wallawire/model"
 )
 
-func NewAuthenticator(password string) []func(http.Handler) http.Handler {
-	tokenAuthenticator := jwtauth.New(jwt.SigningMethodHS256.Name, []byte(password), nil)
+type SessionService interface {
+	ValidateSession(ctx context.Context, userID, sessionID string) (bool, error)
+	RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error)
+}
+
+func NewAuthenticator(keys *Keys, sessionService SessionService) []func(http.Handler) http.Handler {
 	return []func(next http.Handler) http.Handler{
-		jwtauth.Verifier(tokenAuthenticator),
+		tokenVerifier(keys),
 		jwtauth.Authenticator,
 		tokenToUser(),
+		sessionValidator(sessionService),
+		tokenRenewer(sessionService, keys),
+	}
+}
+
+// tokenVerifier is jwtauth.Verifier choosing the verification key by the kid header of the token.
+func tokenVerifier(keys *Keys) func(next http.Handler) http.Handler {
+	findTokenFns := []func(r *http.Request) string{
+		jwtauth.TokenFromQuery,
+		jwtauth.TokenFromHeader,
+		jwtauth.TokenFromCookie,
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := verifyRequest(keys, r, findTokenFns...)
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
 	}
 }
 
+func verifyRequest(keys *Keys, r *http.Request, findTokenFns ...func(r *http.Request) string) (*jwt.Token, error) {
+
+	var tokenString string
+	for _, fn := range findTokenFns {
+		if tokenString = fn(r); tokenString != "" {
+			break
+		}
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+
+	token, err := keys.Parse(tokenString)
+	if err != nil {
+		if validationError, ok := err.(*jwt.ValidationError); ok && validationError.Errors&jwt.ValidationErrorExpired != 0 {
+			err = jwtauth.ErrExpired
+		}
+		return token, err
+	}
+	if token == nil || !token.Valid {
+		return token, jwtauth.ErrUnauthorized
+	}
+	if jwtauth.IsExpired(token) {
+		return token, jwtauth.ErrExpired
+	}
+
+	return token, nil
+
+}
+
 // tokenToUser converts the JWT token into a session user object and add it to the request context.
 // The user object has all fields including id and roles but not the password_hash field filled.
 func tokenToUser() func(next http.Handler) http.Handler {
@@ -38,54 +91,160 @@ func tokenToUser() func(next http.Handler) http.Handler {
 				return
 			}
 
-			user := &model.SessionToken{}
+			user := userFromClaims(claims)
 
-			if value, ok := claims.Get("sessionid"); ok {
-				if id, ok := value.(string); ok {
-					user.SessionID = id
-				}
-			}
+			ctx = context.WithValue(r.Context(), model.UserKey, user)
+			logger.Info().Str("UserID", user.ID).Str("SessionID", user.SessionID).Str("ImpersonatorID", user.Impersonator).Msg("authenticated")
 
-			if value, ok := claims.Get("id"); ok {
-				if id, ok := value.(string); ok {
-					user.ID = id
-				}
-			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			if value, ok := claims.Get("username"); ok {
-				if username, ok := value.(string); ok {
-					user.Username = username
-				}
-			}
+// userFromClaims converts the claims of a session token into a session user.
+func userFromClaims(claims jwtauth.Claims) model.SessionToken {
+
+	user := model.SessionToken{}
+
+	if value, ok := claims.Get("sessionid"); ok {
+		if id, ok := value.(string); ok {
+			user.SessionID = id
+		}
+	}
+
+	if value, ok := claims.Get("id"); ok {
+		if id, ok := value.(string); ok {
+			user.ID = id
+		}
+	}
+
+	if value, ok := claims.Get("username"); ok {
+		if username, ok := value.(string); ok {
+			user.Username = username
+		}
+	}
+
+	if value, ok := claims.Get("name"); ok {
+		if name, ok := value.(string); ok {
+			user.Name = name
+		}
+	}
+
+	if value, ok := claims.Get("roles"); ok {
+		if roles, ok := value.(string); ok {
+			user.Roles = strings.Split(roles, ",")
+		}
+	}
+
+	if value, ok := claims.Get("permissions"); ok {
+		if permissions, ok := value.(string); ok && len(permissions) != 0 {
+			user.Permissions = strings.Split(permissions, ",")
+		}
+	}
+
+	if value, ok := claims.Get("impersonator"); ok {
+		if impersonator, ok := value.(string); ok {
+			user.Impersonator = impersonator
+		}
+	}
+
+	if value, ok := claims.Get("iat"); ok {
+		if iat, ok := toUnixTime(value); ok {
+			user.Issued = iat
+		}
+	}
+
+	if value, ok := claims.Get("exp"); ok {
+		if exp, ok := toUnixTime(value); ok {
+			user.Expires = exp
+		}
+	}
+
+	return user
+
+}
 
-			if value, ok := claims.Get("name"); ok {
-				if name, ok := value.(string); ok {
-					user.Name = name
-				}
+// toUnixTime converts a numeric date claim, which is decoded as float64 unless the parser uses json.Number.
+func toUnixTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(v, 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sessionValidator rejects tokens whose server-side session has been revoked or has expired.
+func sessionValidator(sessionService SessionService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+			logger := logging.New(ctx, "auth", "SessionValidator")
+			user := model.TokenFromContext(ctx)
+
+			valid, err := sessionService.ValidateSession(ctx, user.ID, user.SessionID)
+			if err != nil {
+				logger.Error().Err(err).Msg("Cannot validate session")
+				sendMessage(w, http.StatusInternalServerError)
+				return
+			}
+			if !valid {
+				logger.Info().Str("UserID", user.ID).Str("SessionID", user.SessionID).Msg("session revoked or expired")
+				sendMessage(w, http.StatusUnauthorized)
+				return
 			}
 
-			if value, ok := claims.Get("roles"); ok {
-				if roles, ok := value.(string); ok {
-					user.Roles = strings.Split(roles, ",")
-				}
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
+
+// tokenRenewer reissues the session cookie once the token has passed half of its lifetime,
+// so that an active user is not logged out. The renewed token replaces the one in the request context.
+// A failed renewal is not fatal, the request continues with the current token.
+func tokenRenewer(sessionService SessionService, keys *Keys) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+			logger := logging.New(ctx, "auth", "TokenRenewer")
+			user := model.TokenFromContext(ctx)
+
+			halftime := user.Issued.Add(user.Expires.Sub(user.Issued) / 2)
+			if time.Now().Before(halftime) {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			if value, ok := claims.Get("iat"); ok {
-				if iat, ok := value.(int64); ok {
-					user.Issued = time.Unix(iat, 0)
-				}
+			sessionToken, err := sessionService.RenewSession(ctx, user.ID, user.SessionID)
+			if err != nil {
+				logger.Warn().Err(err).Msg("Cannot renew session")
+				next.ServeHTTP(w, r)
+				return
+			}
+			if sessionToken == nil {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			if value, ok := claims.Get("exp"); ok {
-				if exp, ok := value.(int64); ok {
-					user.Expires = time.Unix(exp, 0)
-				}
+			if err := setSessionCookie(w, sessionToken, keys); err != nil {
+				logger.Warn().Err(err).Msg("Cannot create JWT")
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			ctx = context.WithValue(r.Context(), model.UserKey, *user)
-			logger.Info().Str("UserID", user.ID).Str("SessionID", user.SessionID).Msg("authenticated")
+			ctx = context.WithValue(ctx, model.UserKey, *sessionToken)
+			logger.Info().Str("UserID", sessionToken.ID).Str("SessionID", sessionToken.SessionID).Msg("token renewed")
 
 			next.ServeHTTP(w, r.WithContext(ctx))
+
 		})
 	}
 }
@@ -2,6 +2,7 @@ package auth_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -29,9 +30,39 @@ func TestAuthenticator(b *testing.T) {
 		Expires:   now.Truncate(time.Minute).Add(model.LoginTimeout),
 	}
 
+	demouserImpersonated := *demouser
+	demouserImpersonated.Impersonator = "admin"
+
+	// past half of its lifetime
+	demouserAged := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users", "guests"},
+		Issued:    now.Add(-time.Hour * 13),
+		Expires:   now.Add(time.Hour * 11),
+	}
+
+	// guests role expired
+	demouserRenewed := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now,
+		Expires:   now.Add(model.LoginTimeout),
+	}
+
 	testCases := []struct {
 		Alias           string
 		Path            string
+		SessionValid    bool
+		SessionError    error
+		SessionRenewed  *model.SessionToken
+		RenewError      error
+		ExpectedRenew   bool
 		RequestMethod   string
 		RequestHeaders  map[string]string
 		RequestBody     []byte
@@ -42,9 +73,103 @@ func TestAuthenticator(b *testing.T) {
 		{
 			Alias:         "success",
 			Path:          "/",
+			SessionValid:  true,
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "98",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users","guests"]}`),
+		},
+		{
+			Alias:         "impersonated",
+			Path:          "/",
+			SessionValid:  true,
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(&demouserImpersonated, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "121",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users","guests"],"impersonator":"admin"}`),
+		},
+		{
+			Alias:          "fresh token not renewed",
+			Path:           "/",
+			SessionValid:   true,
+			SessionRenewed: demouserRenewed,
+			ExpectedRenew:  false,
+			RequestMethod:  http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "98",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users","guests"]}`),
+		},
+		{
+			Alias:          "renewed past halftime",
+			Path:           "/",
+			SessionValid:   true,
+			SessionRenewed: demouserRenewed,
+			ExpectedRenew:  true,
+			RequestMethod:  http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserAged, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "89",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+				hSetCookie:     getCookieString(demouserRenewed, testKeys),
+			},
+			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users"]}`),
+		},
+		{
+			Alias:         "renewal fails",
+			Path:          "/",
+			SessionValid:  true,
+			RenewError:    errors.New("just some error"),
+			ExpectedRenew: true,
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserAged, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "98",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users","guests"]}`),
+		},
+		{
+			Alias:         "session lifetime reached",
+			Path:          "/",
+			SessionValid:  true,
+			ExpectedRenew: true,
 			RequestMethod: http.MethodGet,
 			RequestHeaders: map[string]string{
-				hCookie: getCookieString(demouser, testPassword),
+				hCookie: getCookieString(demouserAged, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusOK,
@@ -55,6 +180,41 @@ func TestAuthenticator(b *testing.T) {
 			},
 			ResponseBody: []byte(`{"sessionID":"S123","id":"id","username":"demouser","name":"Demo User","roles":["users","guests"]}`),
 		},
+		{
+			Alias:         "revoked session",
+			Path:          "/",
+			SessionValid:  false,
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength: "13",
+				hDate:          ignoreValue,
+				hContentType:   mimeTypeText,
+			},
+			ResponseBody: []byte("Unauthorized\n"),
+		},
+		{
+			Alias:         "session lookup fails",
+			Path:          "/",
+			SessionValid:  false,
+			SessionError:  errors.New("just some error"),
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseHeaders: map[string]string{
+				hContentLength: "22",
+				hDate:          ignoreValue,
+				hContentType:   mimeTypeText,
+			},
+			ResponseBody: []byte("Internal Server Error\n"),
+		},
 		{
 			Alias:          "missing cookie",
 			Path:           "/",
@@ -75,7 +235,7 @@ func TestAuthenticator(b *testing.T) {
 			Path:          "/",
 			RequestMethod: http.MethodGet,
 			RequestHeaders: map[string]string{
-				hCookie: getCookieString(demouser, testPassword+"bogus"),
+				hCookie: getCookieString(demouser, auth.NewHMACKeys(testPassword+"bogus")),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusUnauthorized,
@@ -117,8 +277,15 @@ func TestAuthenticator(b *testing.T) {
 
 		testFn := func(t *testing.T) {
 
+			ss := &SessionServiceMock{
+				Valid:      testCase.SessionValid,
+				ValidError: testCase.SessionError,
+				Renewed:    testCase.SessionRenewed,
+				RenewError: testCase.RenewError,
+			}
+
 			handler := chi.NewRouter()
-			handler.Use(auth.NewAuthenticator(testPassword)...)
+			handler.Use(auth.NewAuthenticator(testKeys, ss)...)
 			handler.Get("/", auth.Whoami())
 
 			server := httptest.NewServer(handler)
@@ -175,6 +342,10 @@ func TestAuthenticator(b *testing.T) {
 				}
 			}
 
+			if got, want := ss.RenewInvoked, testCase.ExpectedRenew; got != want {
+				t.Errorf("Bad renew invoked: %t, expected %t", got, want)
+			}
+
 		} // fn
 
 		b.Run(testCase.Alias, testFn)
@@ -9,19 +9,57 @@ import (
 
 // NewAuthorizer returns middleware to forbid users without ALL the specified roles
 func NewAuthorizer(roles ...string) func(http.Handler) http.Handler {
+	return RequireAll(roles...)
+}
+
+// RequireAll returns middleware to forbid users without ALL the specified roles
+func RequireAll(roles ...string) func(http.Handler) http.Handler {
+	return authorizer("RequireAll", func(user model.SessionToken) bool {
+		for _, role := range roles {
+			if !user.HasRole(role) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireAny returns middleware to forbid users without ANY of the specified roles
+func RequireAny(roles ...string) func(http.Handler) http.Handler {
+	return authorizer("RequireAny", func(user model.SessionToken) bool {
+		for _, role := range roles {
+			if user.HasRole(role) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequirePermission returns middleware to forbid users without ALL the specified permissions
+func RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return authorizer("RequirePermission", func(user model.SessionToken) bool {
+		for _, permission := range permissions {
+			if !user.HasPermission(permission) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func authorizer(fn string, allowed func(user model.SessionToken) bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 			ctx := r.Context()
-			logger := logging.New(ctx, "auth", "Authorizer")
+			logger := logging.New(ctx, "auth", fn)
 			user := model.TokenFromContext(r.Context()) // guarenteed to always be not nil
 
-			for _, role := range roles {
-				if !user.HasRole(role) {
-					logger.Info().Msg("forbidden")
-					sendMessage(w, http.StatusForbidden)
-					return
-				}
+			if !allowed(user) {
+				logger.Info().Msg("forbidden")
+				sendMessage(w, http.StatusForbidden)
+				return
 			}
 
 			next.ServeHTTP(w, r)
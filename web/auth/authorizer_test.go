@@ -43,7 +43,7 @@ func TestAuthorizer(t *testing.T) {
 					Roles:    []string{"guests", "users"},
 					Issued:   now.Truncate(time.Minute),
 					Expires:  now.Truncate(time.Minute).Add(model.LoginTimeout),
-				}, testPassword),
+				}, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusOK,
@@ -67,7 +67,7 @@ func TestAuthorizer(t *testing.T) {
 					Roles:    []string{"users"},
 					Issued:   now.Truncate(time.Minute),
 					Expires:  now.Truncate(time.Minute).Add(model.LoginTimeout),
-				}, testPassword),
+				}, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusOK,
@@ -91,7 +91,7 @@ func TestAuthorizer(t *testing.T) {
 					Roles:    []string{"guests"},
 					Issued:   now.Truncate(time.Minute),
 					Expires:  now.Truncate(time.Minute).Add(model.LoginTimeout),
-				}, testPassword),
+				}, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusForbidden,
@@ -115,7 +115,7 @@ func TestAuthorizer(t *testing.T) {
 					Roles:    nil,
 					Issued:   now.Truncate(time.Minute),
 					Expires:  now.Truncate(time.Minute).Add(model.LoginTimeout),
-				}, testPassword),
+				}, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusForbidden,
@@ -139,7 +139,7 @@ func TestAuthorizer(t *testing.T) {
 					Roles:    []string{},
 					Issued:   now.Truncate(time.Minute),
 					Expires:  now.Truncate(time.Minute).Add(model.LoginTimeout),
-				}, testPassword),
+				}, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusForbidden,
@@ -164,7 +164,7 @@ func TestAuthorizer(t *testing.T) {
 		testFn := func(tt *testing.T) {
 
 			handler := chi.NewRouter()
-			handler.Use(auth.NewAuthenticator(testPassword)...)
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
 			handler.Use(auth.NewAuthorizer(testCase.AuthorizedRoles...))
 			handler.Get("/", sendMessageHandler(http.StatusOK))
 
@@ -229,3 +229,85 @@ func TestAuthorizer(t *testing.T) {
 	} // cases
 
 }
+
+func TestRequireAnyAndPermission(b *testing.T) {
+
+	now := time.Now()
+
+	editor := &model.SessionToken{
+		ID:          "id",
+		Username:    "demouser",
+		Name:        "Demo User",
+		Roles:       []string{"users", "editors"},
+		Permissions: []string{model.PermissionAccount, model.PermissionManageUsers},
+		Issued:      now.Truncate(time.Minute),
+		Expires:     now.Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias          string
+		Authorizer     func(http.Handler) http.Handler
+		ResponseStatus int
+	}{
+		{
+			Alias:          "any - one role",
+			Authorizer:     auth.RequireAny("admins", "editors"),
+			ResponseStatus: http.StatusOK,
+		},
+		{
+			Alias:          "any - no role",
+			Authorizer:     auth.RequireAny("admins", "guests"),
+			ResponseStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "any - none required",
+			Authorizer:     auth.RequireAny(),
+			ResponseStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "all - all roles",
+			Authorizer:     auth.RequireAll("users", "editors"),
+			ResponseStatus: http.StatusOK,
+		},
+		{
+			Alias:          "all - one role missing",
+			Authorizer:     auth.RequireAll("users", "admins"),
+			ResponseStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "permission - all permissions",
+			Authorizer:     auth.RequirePermission(model.PermissionAccount, model.PermissionManageUsers),
+			ResponseStatus: http.StatusOK,
+		},
+		{
+			Alias:          "permission - one permission missing",
+			Authorizer:     auth.RequirePermission(model.PermissionManageUsers, model.PermissionManageRoles),
+			ResponseStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
+			handler.Use(tCase.Authorizer)
+			handler.Get("/", sendMessageHandler(http.StatusOK))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Add(hCookie, getCookieString(editor, testKeys))
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	paramUserID = "userID"
+)
+
+type ImpersonateService interface {
+	Impersonate(context.Context, model.ImpersonateRequest) model.ImpersonateResponse
+}
+
+// Impersonate replaces the session cookie of the administrator with one for the user in the path.
+// The impersonation ends at logout or when the session expires, the administrator then signs in again.
+func Impersonate(impersonateService ImpersonateService, keys *Keys) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "ImpersonateHandler")
+		logger.Debug().Msg("invoked")
+
+		client := model.ClientFromContext(ctx)
+		req := model.ImpersonateRequest{
+			UserID:    chi.URLParam(r, paramUserID),
+			UserAgent: client.UserAgent,
+			IPAddress: client.IPAddress,
+		}
+		rsp := impersonateService.Impersonate(ctx, req)
+		if rsp.Code != http.StatusOK {
+			sendMessageText(w, rsp.Code, rsp.Message)
+			return
+		}
+
+		if err := setSessionCookie(w, rsp.SessionToken, keys); err != nil {
+			msg := "cannot create JWT"
+			logger.Error().Err(err).Msg(msg)
+			sendMessageText(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		sendJson(w, http.StatusOK, rsp.SessionToken)
+
+	})
+}
+
+// NewImpersonationForbidden returns middleware to forbid requests of an impersonated session,
+// so that an administrator acting as a user cannot change the credentials of the user.
+func NewImpersonationForbidden() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx := r.Context()
+			logger := logging.New(ctx, "auth", "ImpersonationForbidden")
+			user := model.TokenFromContext(ctx)
+
+			if len(user.Impersonator) != 0 {
+				logger.Info().Str("ImpersonatorID", user.Impersonator).Msg("forbidden while impersonating")
+				sendMessage(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package auth_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+type ImpersonateServiceMock struct {
+	Response model.ImpersonateResponse
+	Request  model.ImpersonateRequest
+}
+
+func (z *ImpersonateServiceMock) Impersonate(ctx context.Context, req model.ImpersonateRequest) model.ImpersonateResponse {
+	z.Request = req
+	return z.Response
+}
+
+func TestImpersonate(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	admin := &model.SessionToken{
+		SessionID:   "S123",
+		ID:          "admin",
+		Username:    "admin",
+		Name:        "Admin",
+		Roles:       []string{model.RoleNameAdmin},
+		Permissions: []string{model.PermissionImpersonate},
+		Issued:      now,
+		Expires:     now.Add(model.LoginTimeout),
+	}
+
+	impersonated := &model.SessionToken{
+		SessionID:    "S456",
+		ID:           "id",
+		Username:     "demouser",
+		Name:         "Demo User",
+		Roles:        []string{model.RoleNameUser},
+		Impersonator: "admin",
+		Issued:       now,
+		Expires:      now.Add(model.ImpersonationTimeout),
+	}
+
+	testCases := []struct {
+		Alias          string
+		Token          *model.SessionToken
+		Response       model.ImpersonateResponse
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedCookie bool
+	}{
+		{
+			Alias:          "success",
+			Token:          admin,
+			Response:       model.ImpersonateResponse{Code: http.StatusOK, SessionToken: impersonated},
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"sessionID":"S456","id":"id","username":"demouser","name":"Demo User","roles":["user"],"impersonator":"admin"}`,
+			ExpectedCookie: true,
+		},
+		{
+			Alias:          "user not found",
+			Token:          admin,
+			Response:       model.ImpersonateResponse{Code: http.StatusNotFound, Message: "user not found"},
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedBody:   "user not found\n",
+		},
+		{
+			Alias:          "forbidden while impersonating",
+			Token:          impersonated,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedBody:   "Forbidden\n",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			svc := &ImpersonateServiceMock{
+				Response: tCase.Response,
+			}
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
+			handler.Use(auth.NewImpersonationForbidden())
+			handler.Post("/admin/users/{userID}/impersonate", auth.Impersonate(svc, testKeys))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/admin/users/id/impersonate", nil)
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			req.Header.Add(hCookie, getCookieString(tCase.Token, testKeys))
+
+			rsp, errRsp := http.DefaultClient.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, tCase.ExpectedStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+			if got, want := string(body), tCase.ExpectedBody; got != want {
+				t.Errorf("Bad body: %s, expected %s", got, want)
+			}
+			if got, want := strings.HasPrefix(rsp.Header.Get(hSetCookie), auth.CookieName+"="), tCase.ExpectedCookie; got != want {
+				t.Errorf("Bad cookie %s, expected %t", rsp.Header.Get(hSetCookie), want)
+			}
+			if tCase.ExpectedStatus == http.StatusForbidden {
+				return
+			}
+			if got, want := svc.Request.UserID, "id"; got != want {
+				t.Errorf("Bad user ID %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"net/http"
+
+	"wallawire/logging"
+)
+
+// JWKS publishes the public keys with which session tokens can be verified by other services.
+func JWKS(keys *Keys) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "JWKSHandler")
+		logger.Debug().Msg("invoked")
+
+		sendJson(w, http.StatusOK, keys.JWKS())
+
+	})
+}
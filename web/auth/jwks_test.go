@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/web/auth"
+)
+
+func TestJWKS(b *testing.T) {
+
+	edSigning, errKey := auth.ParseSigningKey(rfc8037Key(b))
+	if errKey != nil {
+		b.Fatal(errKey)
+	}
+	edKeys, errKeys := auth.NewKeys(edSigning, auth.NewHMACKey(testPassword))
+	if errKeys != nil {
+		b.Fatal(errKeys)
+	}
+
+	testCases := []struct {
+		Alias           string
+		Keys            *auth.Keys
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+	}{
+		{
+			Alias:          "public keys only",
+			Keys:           edKeys,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "168",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"keys":[{"kty":"OKP","use":"sig","alg":"EdDSA","kid":"kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`),
+		},
+		{
+			Alias:          "password only",
+			Keys:           testKeys,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "11",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"keys":[]}`),
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			handler := chi.NewRouter()
+			handler.Get("/.well-known/jwks.json", auth.JWKS(testCase.Keys))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			rsp, errRsp := http.Get(server.URL + "/.well-known/jwks.json")
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if bytes.Compare(body, testCase.ResponseBody) != 0 {
+				t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	headerKeyID = "kid"
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+)
+
+// Key is a key with which session tokens are signed or verified.
+// Asymmetric keys are identified by the RFC 7638 thumbprint of their public key, sent as the kid token header.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{} // nil for verification-only keys
+	verifyKey interface{}
+}
+
+// CanSign tests if the key includes the private part.
+func (z *Key) CanSign() bool {
+	return z.signKey != nil
+}
+
+// Keys holds the key used to sign new tokens and all keys accepted when verifying tokens.
+// Keeping the previous keys for verification allows rotating the signing key without ending existing sessions.
+type Keys struct {
+	signingKey       *Key
+	verificationKeys map[string]*Key
+}
+
+// NewKeys creates a key set signing with signingKey and verifying with signingKey and all verificationKeys.
+func NewKeys(signingKey *Key, verificationKeys ...*Key) (*Keys, error) {
+
+	if signingKey == nil || !signingKey.CanSign() {
+		return nil, errors.New("signing key required")
+	}
+
+	z := &Keys{
+		signingKey:       signingKey,
+		verificationKeys: make(map[string]*Key),
+	}
+
+	for _, key := range append([]*Key{signingKey}, verificationKeys...) {
+		if _, ok := z.verificationKeys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+		z.verificationKeys[key.ID] = key
+	}
+
+	return z, nil
+
+}
+
+// NewHMACKey creates an HS256 key from a shared password. The key has no ID.
+func NewHMACKey(password string) *Key {
+	return &Key{
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(password),
+		verifyKey: []byte(password),
+	}
+}
+
+// NewHMACKeys creates a key set signing and verifying with the given password only.
+func NewHMACKeys(password string) *Keys {
+	keys, _ := NewKeys(NewHMACKey(password))
+	return keys
+}
+
+// LoadSigningKey reads a PEM encoded RSA (RS256) or Ed25519 (EdDSA) private key.
+func LoadSigningKey(filename string) (*Key, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(data)
+}
+
+// LoadVerificationKey reads a PEM encoded RSA or Ed25519 public key.
+// A private key is accepted as well but only its public part is used.
+func LoadVerificationKey(filename string) (*Key, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVerificationKey(data)
+}
+
+func ParseSigningKey(data []byte) (*Key, error) {
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return newKey(jwt.SigningMethodRS256, k, &k.PublicKey)
+	case ed25519.PrivateKey:
+		return newKey(SigningMethodEdDSA, k, k.Public())
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+
+}
+
+func ParseVerificationKey(data []byte) (*Key, error) {
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var publicKey interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "RSA PRIVATE KEY", "PRIVATE KEY":
+		key, errPrivate := ParseSigningKey(data)
+		if errPrivate != nil {
+			return nil, errPrivate
+		}
+		return newKey(key.Method, nil, key.verifyKey)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return newKey(jwt.SigningMethodRS256, nil, k)
+	case ed25519.PublicKey:
+		return newKey(SigningMethodEdDSA, nil, k)
+	}
+
+	return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+
+}
+
+func newKey(method jwt.SigningMethod, signKey, verifyKey interface{}) (*Key, error) {
+
+	key := &Key{
+		Method:    method,
+		signKey:   signKey,
+		verifyKey: verifyKey,
+	}
+
+	jwk, errJWK := key.JWK()
+	if errJWK != nil {
+		return nil, errJWK
+	}
+	key.ID = jwk.Thumbprint()
+
+	return key, nil
+
+}
+
+// Sign creates a signed token with the signing key.
+func (z *Keys) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(z.signingKey.Method, claims)
+	if len(z.signingKey.ID) != 0 {
+		token.Header[headerKeyID] = z.signingKey.ID
+	}
+	return token.SignedString(z.signingKey.signKey)
+}
+
+// Parse verifies a token with the key named by its kid header and returns the parsed token.
+// Tokens without kid are verified with the key without ID, if any.
+func (z *Keys) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, z.keyFunc)
+}
+
+func (z *Keys) keyFunc(t *jwt.Token) (interface{}, error) {
+
+	kid, _ := t.Header[headerKeyID].(string)
+
+	key, ok := z.verificationKeys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	// never let the token choose the algorithm
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedMethod
+	}
+
+	return key.verifyKey, nil
+
+}
+
+// JWKS returns the public verification keys. Symmetric keys are never published.
+func (z *Keys) JWKS() JSONWebKeySet {
+
+	jwks := JSONWebKeySet{
+		Keys: make([]JSONWebKey, 0, len(z.verificationKeys)),
+	}
+
+	// signing key first, as a hint to clients
+	if jwk, err := z.signingKey.JWK(); err == nil {
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	var ids []string
+	for id, key := range z.verificationKeys {
+		if key != z.signingKey {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if jwk, err := z.verificationKeys[id].JWK(); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+
+	return jwks
+
+}
+
+// JSONWebKeySet is a JWK Set as defined in RFC 7517.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey is the public part of a key as defined in RFC 7517 and RFC 8037.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWK returns the public part of an asymmetric key.
+func (z *Key) JWK() (JSONWebKey, error) {
+
+	switch k := z.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: z.Method.Alg(),
+			KeyID:     z.ID,
+			Modulus:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			KeyType:   "OKP",
+			Use:       "sig",
+			Algorithm: z.Method.Alg(),
+			KeyID:     z.ID,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	}
+
+	return JSONWebKey{}, errors.New("no public key")
+
+}
+
+// Thumbprint computes the RFC 7638 thumbprint of the key.
+func (z JSONWebKey) Thumbprint() string {
+
+	// required members only, in lexicographic order
+	var members interface{}
+	switch z.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{z.Exponent, z.KeyType, z.Modulus}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{z.Curve, z.KeyType, z.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+
+}
+
+// Key returns the verification key of a published JWK. The key keeps the kid of the publisher as ID.
+func (z JSONWebKey) Key() (*Key, error) {
+
+	switch z.KeyType {
+	case "RSA":
+		if len(z.Algorithm) != 0 && z.Algorithm != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unsupported algorithm %q", z.Algorithm)
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(z.Modulus)
+		if errN != nil {
+			return nil, errN
+		}
+		e, errE := base64.RawURLEncoding.DecodeString(z.Exponent)
+		if errE != nil {
+			return nil, errE
+		}
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if publicKey.N.Sign() == 0 || publicKey.E == 0 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &Key{ID: z.KeyID, Method: jwt.SigningMethodRS256, verifyKey: publicKey}, nil
+	case "OKP":
+		if z.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", z.Curve)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(z.X)
+		if errX != nil {
+			return nil, errX
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return &Key{ID: z.KeyID, Method: SigningMethodEdDSA, verifyKey: ed25519.PublicKey(x)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", z.KeyType)
+
+}
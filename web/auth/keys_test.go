@@ -0,0 +1,209 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"wallawire/web/auth"
+)
+
+const (
+	// RFC 8037, appendix A
+	rfc8037PrivateKey = "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A"
+	rfc8037PublicKey  = "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"
+	rfc8037Thumbprint = "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"
+)
+
+func rfc8037Key(t *testing.T) []byte {
+	seed, err := base64.RawURLEncoding.DecodeString(rfc8037PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, errDER := x509.MarshalPKCS8PrivateKey(ed25519.NewKeyFromSeed(seed))
+	if errDER != nil {
+		t.Fatal(errDER)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func rsaKey(t *testing.T) (privatePEM, publicPEM []byte) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, errDER := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	if errDER != nil {
+		t.Fatal(errDER)
+	}
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return
+}
+
+func TestKeyThumbprint(t *testing.T) {
+
+	key, err := auth.ParseSigningKey(rfc8037Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := key.ID, rfc8037Thumbprint; got != want {
+		t.Errorf("Bad key id: %s, expected %s", got, want)
+	}
+	if got, want := key.Method.Alg(), "EdDSA"; got != want {
+		t.Errorf("Bad algorithm: %s, expected %s", got, want)
+	}
+
+	jwk, errJWK := key.JWK()
+	if errJWK != nil {
+		t.Fatal(errJWK)
+	}
+	if got, want := jwk.X, rfc8037PublicKey; got != want {
+		t.Errorf("Bad public key: %s, expected %s", got, want)
+	}
+
+}
+
+func TestKeys(b *testing.T) {
+
+	rsaPrivate, rsaPublic := rsaKey(b)
+
+	mustKey := func(key *auth.Key, err error) *auth.Key {
+		if err != nil {
+			b.Fatal(err)
+		}
+		return key
+	}
+	mustKeys := func(keys *auth.Keys, err error) *auth.Keys {
+		if err != nil {
+			b.Fatal(err)
+		}
+		return keys
+	}
+
+	rsaSigning := mustKey(auth.ParseSigningKey(rsaPrivate))
+	rsaVerification := mustKey(auth.ParseVerificationKey(rsaPublic))
+	edSigning := mustKey(auth.ParseSigningKey(rfc8037Key(b)))
+	hmacSigning := auth.NewHMACKey("secret")
+
+	if got, want := rsaVerification.ID, rsaSigning.ID; got != want {
+		b.Fatalf("Bad public key id: %s, expected %s", got, want)
+	}
+	if rsaVerification.CanSign() {
+		b.Fatal("Bad public key: can sign")
+	}
+
+	// token signed with the RSA public key as HMAC secret
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	confused.Header["kid"] = rsaSigning.ID
+	confusedToken, errConfused := confused.SignedString(rsaPublic)
+	if errConfused != nil {
+		b.Fatal(errConfused)
+	}
+
+	testCases := []struct {
+		Alias         string
+		Signer        *auth.Keys
+		Token         string
+		Verifier      *auth.Keys
+		ExpectedKeyID string
+		ExpectedValid bool
+	}{
+		{
+			Alias:         "rsa",
+			Signer:        mustKeys(auth.NewKeys(rsaSigning)),
+			Verifier:      mustKeys(auth.NewKeys(rsaSigning)),
+			ExpectedKeyID: rsaSigning.ID,
+			ExpectedValid: true,
+		},
+		{
+			Alias:         "eddsa",
+			Signer:        mustKeys(auth.NewKeys(edSigning)),
+			Verifier:      mustKeys(auth.NewKeys(edSigning)),
+			ExpectedKeyID: rfc8037Thumbprint,
+			ExpectedValid: true,
+		},
+		{
+			Alias:         "hmac",
+			Signer:        auth.NewHMACKeys("secret"),
+			Verifier:      auth.NewHMACKeys("secret"),
+			ExpectedValid: true,
+		},
+		{
+			Alias:         "rotated key still accepted",
+			Signer:        mustKeys(auth.NewKeys(rsaSigning)),
+			Verifier:      mustKeys(auth.NewKeys(edSigning, rsaVerification)),
+			ExpectedKeyID: rsaSigning.ID,
+			ExpectedValid: true,
+		},
+		{
+			Alias:         "password still accepted",
+			Signer:        auth.NewHMACKeys("secret"),
+			Verifier:      mustKeys(auth.NewKeys(rsaSigning, hmacSigning)),
+			ExpectedValid: true,
+		},
+		{
+			Alias:         "retired key",
+			Signer:        mustKeys(auth.NewKeys(rsaSigning)),
+			Verifier:      mustKeys(auth.NewKeys(edSigning)),
+			ExpectedKeyID: rsaSigning.ID,
+			ExpectedValid: false,
+		},
+		{
+			Alias:         "password no longer accepted",
+			Signer:        auth.NewHMACKeys("secret"),
+			Verifier:      mustKeys(auth.NewKeys(rsaSigning)),
+			ExpectedValid: false,
+		},
+		{
+			Alias:         "algorithm confusion",
+			Token:         confusedToken,
+			Verifier:      mustKeys(auth.NewKeys(rsaSigning)),
+			ExpectedKeyID: rsaSigning.ID,
+			ExpectedValid: false,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			tokenString := tCase.Token
+			if tCase.Signer != nil {
+				s, errSign := tCase.Signer.Sign(jwt.MapClaims{
+					"sub": "id",
+					"exp": time.Now().Add(time.Hour).Unix(),
+				})
+				if errSign != nil {
+					t.Fatal(errSign)
+				}
+				tokenString = s
+			}
+
+			token, err := tCase.Verifier.Parse(tokenString)
+
+			if got, want := err == nil && token.Valid, tCase.ExpectedValid; got != want {
+				t.Errorf("Bad valid: %t, expected %t (%v)", got, want, err)
+			}
+			if token != nil {
+				kid, _ := token.Header["kid"].(string)
+				if got, want := kid, tCase.ExpectedKeyID; got != want {
+					t.Errorf("Bad key id: %s, expected %s", got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
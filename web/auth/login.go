@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 
@@ -17,7 +18,7 @@ type UserService interface {
 	Login(context.Context, model.LoginRequest) model.LoginResponse
 }
 
-func Login(userService UserService, tokenPassword string) http.HandlerFunc {
+func Login(userService UserService, keys *Keys) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		logger := logging.New(ctx, "auth", "LoginHandler")
@@ -47,48 +48,85 @@ func Login(userService UserService, tokenPassword string) http.HandlerFunc {
 			return
 		}
 
+		client := model.ClientFromContext(ctx)
+		req.UserAgent = client.UserAgent
+		req.IPAddress = client.IPAddress
 		rsp := userService.Login(ctx, req)
+		if rsp.Code == http.StatusTooManyRequests {
+			setRetryAfter(w, rsp.RetryAfter)
+		}
 		if rsp.Code != http.StatusOK {
 			sendMessageText(w, rsp.Code, rsp.Message)
 			return
 		}
 
+		if rsp.OTPRequired {
+			// password OK, login continues at LoginOTP with the challenge
+			challenge, errChallenge := MakeChallengeJWT(rsp.UserID, rsp.ChallengeID, keys, time.Now().Add(model.LoginChallengeTimeout))
+			if errChallenge != nil {
+				msg := "cannot create challenge"
+				logger.Error().Err(errChallenge).Msg(msg)
+				sendMessageText(w, http.StatusInternalServerError, msg)
+				return
+			}
+			sendJson(w, http.StatusAccepted, otpChallenge{
+				OTPRequired: true,
+				Challenge:   challenge,
+			})
+			return
+		}
+
 		// OK
 
-		token, errToken := MakeJWT(rsp.SessionToken, tokenPassword)
-		if errToken != nil {
+		if err := setSessionCookie(w, rsp.SessionToken, keys); err != nil {
 			msg := "cannot create JWT"
-			logger.Error().Err(errToken).Msg(msg)
+			logger.Error().Err(err).Msg(msg)
 			sendMessageText(w, http.StatusInternalServerError, msg)
 			return
 		}
 
-		cookie := &http.Cookie{
-			Name:    CookieName,
-			Value:   token,
-			Path:    "/",
-			Expires: rsp.SessionToken.Expires,
-			Secure:  true,
-		}
-		http.SetCookie(w, cookie)
-
 		sendMessage(w, http.StatusOK)
 
 	})
 }
 
-func MakeJWT(user *model.SessionToken, tokenPassword string) (string, error) {
+func setSessionCookie(w http.ResponseWriter, sessionToken *model.SessionToken, keys *Keys) error {
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sessionid": user.SessionID,
-		"id":        user.ID,
-		"username":  user.Username,
-		"name":      user.Name,
-		"roles":     strings.Join(user.Roles, ","),
-		"iat":       user.Issued.Unix(),
-		"exp":       user.Expires.Unix(),
-	})
+	token, errToken := MakeJWT(sessionToken, keys)
+	if errToken != nil {
+		return errToken
+	}
+
+	cookie := &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  sessionToken.Expires,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
+
+	return nil
+
+}
 
-	return token.SignedString([]byte(tokenPassword))
+func MakeJWT(user *model.SessionToken, keys *Keys) (string, error) {
+
+	claims := jwt.MapClaims{
+		"sessionid":   user.SessionID,
+		"id":          user.ID,
+		"username":    user.Username,
+		"name":        user.Name,
+		"roles":       strings.Join(user.Roles, ","),
+		"permissions": strings.Join(user.Permissions, ","),
+		"iat":         user.Issued.Unix(),
+		"exp":         user.Expires.Unix(),
+	}
+	if len(user.Impersonator) != 0 {
+		claims["impersonator"] = user.Impersonator
+	}
+
+	return keys.Sign(claims)
 
 }
@@ -67,10 +67,31 @@ func TestLogin(t *testing.T) {
 				hContentLength: "3",
 				hContentType:   mimeTypeText,
 				hDate:          ignoreValue,
-				hSetCookie:     getCookieString(demouserS, testPassword),
+				hSetCookie:     getCookieString(demouserS, testKeys),
 			},
 			ResponseBody: []byte("OK\n"),
 		},
+		{
+			Alias: "login second factor required",
+			Path:  "/login",
+			OutputResponse: model.LoginResponse{
+				Code:        http.StatusOK,
+				OTPRequired: true,
+				UserID:      "id",
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "demouser", "password": "demouser"}`),
+			ResponseStatus: http.StatusAccepted,
+			ResponseHeaders: map[string]string{
+				hContentLength: ignoreValue,
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: nil, // challenge varies
+		},
 		{
 			Alias:          "login bad method",
 			Path:           "/login",
@@ -146,6 +167,28 @@ func TestLogin(t *testing.T) {
 			},
 			ResponseBody: []byte("cannot unmarshal json\n"),
 		},
+		{
+			Alias: "login locked out",
+			Path:  "/login",
+			OutputResponse: model.LoginResponse{
+				Code:       http.StatusTooManyRequests,
+				Message:    "too many failed logins",
+				RetryAfter: time.Second*90 + time.Millisecond,
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "demouser", "password": "demouser"}`),
+			ResponseStatus: http.StatusTooManyRequests,
+			ResponseHeaders: map[string]string{
+				hContentLength: "23",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+				hRetryAfter:    "91",
+			},
+			ResponseBody: []byte("too many failed logins\n"),
+		},
 		{
 			Alias: "any backend error",
 			Path:  "/login",
@@ -183,7 +226,7 @@ func TestLogin(t *testing.T) {
 				LoginResponse: testCase.OutputResponse,
 			}
 			handler := chi.NewRouter()
-			handler.Post("/login", auth.Login(us, testPassword))
+			handler.Post("/login", auth.Login(us, testKeys))
 			handler.MethodNotAllowed(sendMessageHandler(http.StatusMethodNotAllowed))
 
 			server := httptest.NewServer(handler)
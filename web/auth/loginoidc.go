@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	OIDCCookieName   = "oidc"
+	oidcCookiePath   = "/api/oidc"
+	oidcLoginTimeout = time.Minute * 10
+	oidcRandomSize   = 32
+	purposeOIDC      = "oidc"
+)
+
+type ExternalLoginService interface {
+	LoginExternal(context.Context, model.ExternalLoginRequest) model.LoginResponse
+}
+
+// oidcState is kept in a signed cookie between LoginOIDC and CallbackOIDC.
+type oidcState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// LoginOIDC redirects the browser to the identity provider to start an authorization code flow with PKCE.
+func LoginOIDC(provider *OIDCProvider, keys *Keys) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "LoginOIDCHandler")
+		logger.Debug().Msg("invoked")
+
+		state, errState := newOIDCState()
+		if errState != nil {
+			msg := "cannot create state"
+			logger.Error().Err(errState).Msg(msg)
+			sendMessageText(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		expires := time.Now().Add(oidcLoginTimeout)
+		cookie, errCookie := makeOIDCStateJWT(state, keys, expires)
+		if errCookie != nil {
+			msg := "cannot create JWT"
+			logger.Error().Err(errCookie).Msg(msg)
+			sendMessageText(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     OIDCCookieName,
+			Value:    cookie,
+			Path:     oidcCookiePath,
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode, // sent on the redirect back from the provider
+		})
+
+		http.Redirect(w, r, provider.AuthCodeURL(state.State, state.Nonce, state.CodeVerifier), http.StatusFound)
+
+	})
+}
+
+// CallbackOIDC completes the login started by LoginOIDC when the identity provider redirects back.
+// The verified identity is exchanged for a wallawire session and the browser is redirected to the application.
+func CallbackOIDC(provider *OIDCProvider, userService ExternalLoginService, keys *Keys) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "CallbackOIDCHandler")
+		logger.Debug().Msg("invoked")
+
+		// single use
+		http.SetCookie(w, &http.Cookie{
+			Name:     OIDCCookieName,
+			Path:     oidcCookiePath,
+			MaxAge:   -1,
+			Secure:   true,
+			HttpOnly: true,
+		})
+
+		query := r.URL.Query()
+
+		if e := query.Get("error"); len(e) != 0 {
+			msg := "login refused by identity provider"
+			logger.Debug().Str("error", e).Str("description", query.Get("error_description")).Msg(msg)
+			sendMessageText(w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		cookie, errCookie := r.Cookie(OIDCCookieName)
+		if errCookie != nil {
+			msg := "invalid or expired state"
+			logger.Debug().Err(errCookie).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+		state, errState := parseOIDCStateJWT(cookie.Value, keys)
+		if errState != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+			msg := "invalid or expired state"
+			logger.Debug().Err(errState).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		code := query.Get("code")
+		if len(code) == 0 {
+			msg := "missing authorization code"
+			logger.Debug().Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		req, errExchange := provider.Exchange(ctx, code, state.CodeVerifier, state.Nonce)
+		if errExchange != nil {
+			msg := "cannot verify identity"
+			logger.Warn().Err(errExchange).Msg(msg)
+			sendMessageText(w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		client := model.ClientFromContext(ctx)
+		req.UserAgent = client.UserAgent
+		req.IPAddress = client.IPAddress
+		rsp := userService.LoginExternal(ctx, *req)
+		if rsp.Code != http.StatusOK {
+			sendMessageText(w, rsp.Code, rsp.Message)
+			return
+		}
+
+		// OK
+
+		if err := setSessionCookie(w, rsp.SessionToken, keys); err != nil {
+			msg := "cannot create JWT"
+			logger.Error().Err(err).Msg(msg)
+			sendMessageText(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+
+	})
+}
+
+func newOIDCState() (*oidcState, error) {
+
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, oidcRandomSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	return &oidcState{
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+	}, nil
+
+}
+
+func makeOIDCStateJWT(state *oidcState, keys *Keys, expires time.Time) (string, error) {
+
+	return keys.Sign(jwt.MapClaims{
+		"purpose":  purposeOIDC,
+		"state":    state.State,
+		"nonce":    state.Nonce,
+		"verifier": state.CodeVerifier,
+		"iat":      time.Now().Unix(),
+		"exp":      expires.Unix(),
+	})
+
+}
+
+func parseOIDCStateJWT(tokenString string, keys *Keys) (*oidcState, error) {
+
+	token, errParse := keys.Parse(tokenString)
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != purposeOIDC {
+		return nil, errors.New("invalid token purpose")
+	}
+
+	state := &oidcState{}
+	state.State, _ = claims["state"].(string)
+	state.Nonce, _ = claims["nonce"].(string)
+	state.CodeVerifier, _ = claims["verifier"].(string)
+	if len(state.State) == 0 || len(state.Nonce) == 0 || len(state.CodeVerifier) == 0 {
+		return nil, errors.New("incomplete state")
+	}
+
+	return state, nil
+
+}
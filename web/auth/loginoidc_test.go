@@ -0,0 +1,225 @@
+package auth_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestLoginOIDC(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	newuser := &model.User{
+		ID:       "id",
+		Username: "newuser",
+		Name:     "New User",
+		Created:  now,
+		Updated:  now,
+	}
+	newuserS := model.ToSessionToken("S123", newuser, nil, now, now.Add(model.LoginTimeout))
+
+	const (
+		hLocation   = "Location"
+		redirectURL = "https://wallawire.example.com/api/oidc/callback"
+	)
+
+	testCases := []struct {
+		Alias            string
+		OutputResponse   model.LoginResponse
+		Callback         func(callback *url.URL) // alters the redirect back from the provider
+		NoStateCookie    bool
+		OtherKeys        bool
+		ResponseStatus   int
+		ResponseBody     string
+		ExpectedLogin    bool
+		ExpectedLocation string
+	}{
+		{
+			Alias: "success",
+			OutputResponse: model.LoginResponse{
+				Code:         http.StatusOK,
+				SessionToken: newuserS,
+			},
+			ResponseStatus:   http.StatusFound,
+			ExpectedLogin:    true,
+			ExpectedLocation: "/",
+		},
+		{
+			Alias: "no user linked",
+			OutputResponse: model.LoginResponse{
+				Code:    http.StatusForbidden,
+				Message: "no user linked to identity",
+			},
+			ResponseStatus: http.StatusForbidden,
+			ResponseBody:   "no user linked to identity\n",
+			ExpectedLogin:  true,
+		},
+		{
+			Alias: "state mismatch",
+			Callback: func(callback *url.URL) {
+				q := callback.Query()
+				q.Set("state", "bogus")
+				callback.RawQuery = q.Encode()
+			},
+			ResponseStatus: http.StatusBadRequest,
+			ResponseBody:   "invalid or expired state\n",
+		},
+		{
+			Alias:          "no state cookie",
+			NoStateCookie:  true,
+			ResponseStatus: http.StatusBadRequest,
+			ResponseBody:   "invalid or expired state\n",
+		},
+		{
+			Alias:          "state cookie bad signature",
+			OtherKeys:      true,
+			ResponseStatus: http.StatusBadRequest,
+			ResponseBody:   "invalid or expired state\n",
+		},
+		{
+			Alias: "code already used",
+			Callback: func(callback *url.URL) {
+				q := callback.Query()
+				q.Set("code", "bogus")
+				callback.RawQuery = q.Encode()
+			},
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "cannot verify identity\n",
+		},
+		{
+			Alias: "refused by provider",
+			Callback: func(callback *url.URL) {
+				callback.RawQuery = url.Values{"error": {"access_denied"}, "state": {callback.Query().Get("state")}}.Encode()
+			},
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseBody:   "login refused by identity provider\n",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			fake := newFakeOIDCProvider(t)
+			defer fake.Close()
+
+			provider, errProvider := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+				Issuer:       fake.Issuer(),
+				ClientID:     fake.ClientID,
+				ClientSecret: fake.ClientSecret,
+				RedirectURL:  redirectURL,
+			}, nil)
+			if errProvider != nil {
+				t.Fatal(errProvider)
+			}
+
+			userService := &UserServiceMock{
+				ExternalResponse: tCase.OutputResponse,
+			}
+
+			// start at wallawire
+			loginKeys := testKeys
+			if tCase.OtherKeys {
+				loginKeys = auth.NewHMACKeys("othersecret")
+			}
+			loginRsp := httptest.NewRecorder()
+			auth.LoginOIDC(provider, loginKeys).ServeHTTP(loginRsp, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+			if got, want := loginRsp.Code, http.StatusFound; got != want {
+				t.Fatalf("bad login status %d, expected %d", got, want)
+			}
+			var stateCookie *http.Cookie
+			for _, c := range loginRsp.Result().Cookies() {
+				if c.Name == auth.OIDCCookieName {
+					stateCookie = c
+				}
+			}
+			if stateCookie == nil || !stateCookie.HttpOnly || !stateCookie.Secure {
+				t.Fatalf("bad state cookie %v", stateCookie)
+			}
+			authURL := loginRsp.Header().Get(hLocation)
+			if !strings.HasPrefix(authURL, fake.Issuer()+"/authorize?") {
+				t.Fatalf("bad authorization URL %s", authURL)
+			}
+
+			// authenticate at the provider
+			callback := fake.Authorize(t, authURL)
+			if got, want := callback.Scheme+"://"+callback.Host+callback.Path, redirectURL; got != want {
+				t.Fatalf("bad callback %s, expected %s", got, want)
+			}
+			if tCase.Callback != nil {
+				tCase.Callback(callback)
+			}
+
+			// back at wallawire
+			req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+			req = req.WithContext(context.WithValue(req.Context(), model.ClientKey, model.Client{IPAddress: "192.0.2.1"}))
+			if !tCase.NoStateCookie {
+				req.AddCookie(stateCookie)
+			}
+			rsp := httptest.NewRecorder()
+			auth.CallbackOIDC(provider, userService, testKeys).ServeHTTP(rsp, req)
+
+			if got, want := rsp.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad response status %d, expected %d", got, want)
+			}
+
+			if len(tCase.ResponseBody) != 0 {
+				body, _ := ioutil.ReadAll(rsp.Body)
+				if got, want := string(body), tCase.ResponseBody; got != want {
+					t.Errorf("bad response body %q, expected %q", got, want)
+				}
+			}
+
+			if got, want := rsp.Header().Get(hLocation), tCase.ExpectedLocation; got != want {
+				t.Errorf("bad location %s, expected %s", got, want)
+			}
+
+			// the state cookie is always removed, the session cookie set on success
+			cookies := make(map[string]*http.Cookie)
+			for _, c := range rsp.Result().Cookies() {
+				cookies[c.Name] = c
+			}
+			if c, ok := cookies[auth.OIDCCookieName]; !ok || c.MaxAge >= 0 {
+				t.Errorf("bad state cookie %v, expected removal", c)
+			}
+			if got, want := cookies[auth.CookieName] != nil, tCase.ResponseStatus == http.StatusFound; got != want {
+				t.Errorf("bad session cookie %v, expected %t", cookies[auth.CookieName], want)
+			}
+			if c := cookies[auth.CookieName]; c != nil {
+				if got, want := c.String(), getCookieString(newuserS, testKeys); got != want {
+					t.Errorf("bad session cookie %s, expected %s", got, want)
+				}
+			}
+
+			if got, want := userService.ExternalRequest != nil, tCase.ExpectedLogin; got != want {
+				t.Fatalf("bad login request %v, expected %t", userService.ExternalRequest, want)
+			}
+			if !tCase.ExpectedLogin {
+				return
+			}
+			if got, want := userService.ExternalRequest.Issuer, fake.Issuer(); got != want {
+				t.Errorf("bad issuer %s, expected %s", got, want)
+			}
+			if got, want := userService.ExternalRequest.Subject, fake.Subject; got != want {
+				t.Errorf("bad subject %s, expected %s", got, want)
+			}
+			if got, want := userService.ExternalRequest.IPAddress, "192.0.2.1"; got != want {
+				t.Errorf("bad IP address %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	purposeOTP = "otp"
+)
+
+type LoginOTPService interface {
+	LoginOTP(context.Context, model.LoginOTPRequest) model.LoginResponse
+}
+
+// otpChallenge is returned by Login when a second factor is required.
+type otpChallenge struct {
+	OTPRequired bool   `json:"otpRequired"`
+	Challenge   string `json:"challenge"`
+}
+
+// LoginOTP completes a two-step login by exchanging the challenge returned by Login and a one-time password for a session.
+func LoginOTP(userService LoginOTPService, keys *Keys) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "LoginOTPHandler")
+		logger.Debug().Msg("invoked")
+
+		if r.Header.Get(hContentType) != mimeTypeJson {
+			msg := "bad or missing content type"
+			logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		body, errBody := ioutil.ReadAll(r.Body)
+		if errBody != nil {
+			msg := "cannot read request body"
+			logger.Debug().Err(errBody).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+		defer r.Body.Close()
+
+		var req model.LoginOTPRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			msg := "cannot unmarshal json"
+			logger.Debug().Err(err).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		userID, challengeID, errChallenge := parseChallengeJWT(req.Challenge, keys)
+		if errChallenge != nil {
+			msg := "invalid or expired challenge"
+			logger.Debug().Err(errChallenge).Msg(msg)
+			sendMessageText(w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		req.UserID = userID
+		req.ChallengeID = challengeID
+		client := model.ClientFromContext(ctx)
+		req.UserAgent = client.UserAgent
+		req.IPAddress = client.IPAddress
+		rsp := userService.LoginOTP(ctx, req)
+		if rsp.Code == http.StatusTooManyRequests {
+			setRetryAfter(w, rsp.RetryAfter)
+		}
+		if rsp.Code != http.StatusOK {
+			sendMessageText(w, rsp.Code, rsp.Message)
+			return
+		}
+
+		// OK
+
+		if err := setSessionCookie(w, rsp.SessionToken, keys); err != nil {
+			msg := "cannot create JWT"
+			logger.Error().Err(err).Msg(msg)
+			sendMessageText(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		sendMessage(w, http.StatusOK)
+
+	})
+}
+
+// MakeChallengeJWT creates a short-lived token proving that the password of the user has been verified.
+// It carries no session and is therefore rejected by the authenticator.
+// The ID of the challenge stored by the user service makes the token single-use.
+func MakeChallengeJWT(userID, challengeID string, keys *Keys, expires time.Time) (string, error) {
+
+	return keys.Sign(jwt.MapClaims{
+		"sub":     userID,
+		"jti":     challengeID,
+		"purpose": purposeOTP,
+		"iat":     time.Now().Unix(),
+		"exp":     expires.Unix(),
+	})
+
+}
+
+func parseChallengeJWT(challenge string, keys *Keys) (string, string, error) {
+
+	token, errParse := keys.Parse(challenge)
+	if errParse != nil {
+		return "", "", errParse
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", "", errors.New("invalid token")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != purposeOTP {
+		return "", "", errors.New("invalid token purpose")
+	}
+	userID, _ := claims["sub"].(string)
+	if len(userID) == 0 {
+		return "", "", errors.New("missing subject")
+	}
+	challengeID, _ := claims["jti"].(string)
+	if len(challengeID) == 0 {
+		return "", "", errors.New("missing challenge id")
+	}
+
+	return userID, challengeID, nil
+
+}
@@ -0,0 +1,303 @@
+package auth_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestLoginOTP(t *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.User{
+		ID:       "id",
+		Username: "demouser",
+		Name:     "Demo User",
+		Created:  now,
+		Updated:  now,
+	}
+
+	demoRoles := []model.UserRole{
+		{
+			ID:   "roleid",
+			Name: "users",
+		},
+	}
+
+	demouserS := model.ToSessionToken("S123", demouser, demoRoles, now, now.Add(model.LoginTimeout))
+
+	makeChallenge := func(userID, challengeID string, keys *auth.Keys, expires time.Time) string {
+		challenge, err := auth.MakeChallengeJWT(userID, challengeID, keys, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return challenge
+	}
+
+	sessionJWT, errSessionJWT := auth.MakeJWT(demouserS, testKeys)
+	if errSessionJWT != nil {
+		t.Fatal(errSessionJWT)
+	}
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		OutputResponse  model.LoginResponse
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+		ExpectedUserID  string
+		ExpectedChallID string
+	}{
+		{
+			Alias: "success",
+			Path:  "/login/otp",
+			OutputResponse: model.LoginResponse{
+				Code:         http.StatusOK,
+				SessionToken: demouserS,
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "C123", testKeys, now.Add(time.Minute)) + `", "code": "123456"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "3",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+				hSetCookie:     getCookieString(demouserS, testKeys),
+			},
+			ResponseBody:    []byte("OK\n"),
+			ExpectedUserID:  "id",
+			ExpectedChallID: "C123",
+		},
+		{
+			Alias:          "expired challenge",
+			Path:           "/login/otp",
+			OutputResponse: model.LoginResponse{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "C123", testKeys, now.Add(-time.Minute)) + `", "code": "123456"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength: "29",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("invalid or expired challenge\n"),
+		},
+		{
+			Alias:          "challenge bad signature",
+			Path:           "/login/otp",
+			OutputResponse: model.LoginResponse{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "C123", auth.NewHMACKeys("othersecret"), now.Add(time.Minute)) + `", "code": "123456"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength: "29",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("invalid or expired challenge\n"),
+		},
+		{
+			Alias:          "session token is not a challenge",
+			Path:           "/login/otp",
+			OutputResponse: model.LoginResponse{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + sessionJWT + `", "code": "123456"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength: "29",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("invalid or expired challenge\n"),
+		},
+		{
+			Alias: "bad code",
+			Path:  "/login/otp",
+			OutputResponse: model.LoginResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid code",
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "C123", testKeys, now.Add(time.Minute)) + `", "code": "000000"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "13",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody:    []byte("invalid code\n"),
+			ExpectedUserID:  "id",
+			ExpectedChallID: "C123",
+		},
+		{
+			Alias: "locked",
+			Path:  "/login/otp",
+			OutputResponse: model.LoginResponse{
+				Code:       http.StatusTooManyRequests,
+				Message:    "too many failed logins",
+				RetryAfter: time.Second * 90,
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "C123", testKeys, now.Add(time.Minute)) + `", "code": "000000"}`),
+			ResponseStatus: http.StatusTooManyRequests,
+			ResponseHeaders: map[string]string{
+				hContentLength: "23",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+				hRetryAfter:    "90",
+			},
+			ResponseBody:    []byte("too many failed logins\n"),
+			ExpectedUserID:  "id",
+			ExpectedChallID: "C123",
+		},
+		{
+			Alias:          "challenge without id",
+			Path:           "/login/otp",
+			OutputResponse: model.LoginResponse{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"challenge": "` + makeChallenge("id", "", testKeys, now.Add(time.Minute)) + `", "code": "123456"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength: "29",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("invalid or expired challenge\n"),
+		},
+		{
+			Alias:          "no content-type",
+			Path:           "/login/otp",
+			OutputResponse: model.LoginResponse{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: nil,
+			RequestBody:    []byte(`{"challenge": "x", "code": "000000"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "28",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("bad or missing content type\n"),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(tt *testing.T) {
+
+			us := &UserServiceMock{
+				LoginOTPResponse: testCase.OutputResponse,
+			}
+			handler := chi.NewRouter()
+			handler.Post("/login/otp", auth.LoginOTP(us, testKeys))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				tt.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				tt.Fatalf("UserError getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				tt.Fatalf("UserError reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				tt.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					tt.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					tt.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					tt.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+			if got, want := us.LoginOTPRequest.UserID, testCase.ExpectedUserID; got != want {
+				tt.Errorf("Bad user id: %s, expected %s", got, want)
+			}
+			if got, want := us.LoginOTPRequest.ChallengeID, testCase.ExpectedChallID; got != want {
+				tt.Errorf("Bad challenge id: %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		t.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
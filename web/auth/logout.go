@@ -1,27 +1,54 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+
 	"wallawire/logging"
 	"wallawire/model"
 )
 
-func Logout() http.HandlerFunc {
+type LogoutService interface {
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+}
+
+// Logout clears the session cookie, it does not require an authenticated request
+// so that users with an expired or revoked session can still remove the cookie.
+// The session is revoked if the request carries a valid token.
+func Logout(logoutService LogoutService, keys *Keys) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		logger := logging.New(ctx, "auth", "LogoutHandler")
-		user := model.TokenFromContext(ctx)
-		logger.Info().Str("UserID", user.ID).Str("SessionID", user.SessionID).Msg("logout")
 		cookie := &http.Cookie{
-			Name:    CookieName,
-			Value:   "",
-			Path:    "/",
-			Expires: time.Unix(0, 0),
-			Secure:  true,
+			Name:     CookieName,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Unix(0, 0),
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
 		}
 		http.SetCookie(w, cookie)
+		token, errToken := verifyRequest(keys, r, jwtauth.TokenFromHeader, jwtauth.TokenFromCookie)
+		if errToken != nil {
+			logger.Info().Err(errToken).Msg("logout without valid token")
+			sendMessage(w, http.StatusOK)
+			return
+		}
+		claims, _ := token.Claims.(jwt.MapClaims)
+		user := userFromClaims(jwtauth.Claims(claims))
+		ctx = context.WithValue(ctx, model.UserKey, user)
+		logger.Info().Str("UserID", user.ID).Str("SessionID", user.SessionID).Msg("logout")
+		if len(user.SessionID) != 0 {
+			if err := logoutService.RevokeSession(ctx, user.ID, user.SessionID); err != nil && !model.IsNotFoundError(err) {
+				logger.Error().Err(err).Msg("cannot revoke session")
+				sendMessage(w, http.StatusInternalServerError)
+				return
+			}
+		}
 		sendMessage(w, http.StatusOK)
 	})
 }
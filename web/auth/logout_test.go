@@ -2,12 +2,16 @@ package auth_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"wallawire/model"
 	"wallawire/web/auth"
 
 	"github.com/go-chi/chi"
@@ -15,9 +19,33 @@ import (
 
 func TestLogout(t *testing.T) {
 
+	now := time.Now().Truncate(time.Second)
+
+	demouser := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Truncate(time.Minute),
+		Expires:   now.Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	expireduser := &model.SessionToken{
+		SessionID: "S124",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Add(-model.LoginTimeout * 2),
+		Expires:   now.Add(-model.LoginTimeout),
+	}
+
 	testCases := []struct {
 		Alias           string
 		Path            string
+		RevokeError     error
+		ExpectedRevoked []string
 		RequestMethod   string
 		RequestHeaders  map[string]string
 		RequestBody     []byte
@@ -26,7 +54,60 @@ func TestLogout(t *testing.T) {
 		ResponseBody    []byte
 	}{
 		{
-			Alias:          "logout success POST",
+			Alias:           "logout success POST",
+			Path:            "/logout",
+			ExpectedRevoked: []string{"S123"},
+			RequestMethod:   http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "3",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure; SameSite=Lax",
+			},
+			ResponseBody: []byte("OK\n"),
+		},
+		{
+			Alias:         "logout failure GET",
+			Path:          "/logout",
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusMethodNotAllowed,
+			ResponseHeaders: map[string]string{
+				hContentLength: "19",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("Method Not Allowed\n"),
+		},
+		{
+			Alias:           "logout revoke fails",
+			Path:            "/logout",
+			RevokeError:     errors.New("just some error"),
+			ExpectedRevoked: []string{"S123"},
+			RequestMethod:   http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseHeaders: map[string]string{
+				hContentLength: "22",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure; SameSite=Lax",
+			},
+			ResponseBody: []byte("Internal Server Error\n"),
+		},
+		{
+			Alias:          "logout without token",
 			Path:           "/logout",
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: nil,
@@ -36,30 +117,34 @@ func TestLogout(t *testing.T) {
 				hContentLength: "3",
 				hContentType:   mimeTypeText,
 				hDate:          ignoreValue,
-				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure",
+				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure; SameSite=Lax",
 			},
 			ResponseBody: []byte("OK\n"),
 		},
 		{
-			Alias:          "logout success GET",
-			Path:           "/logout",
-			RequestMethod:  http.MethodGet,
-			RequestHeaders: nil,
+			Alias:         "logout expired session",
+			Path:          "/logout",
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(expireduser, testKeys),
+			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusOK,
 			ResponseHeaders: map[string]string{
 				hContentLength: "3",
 				hContentType:   mimeTypeText,
 				hDate:          ignoreValue,
-				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure",
+				hSetCookie:     "jwt=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Secure; SameSite=Lax",
 			},
 			ResponseBody: []byte("OK\n"),
 		},
 		{
-			Alias:          "logout failure DELETE",
-			Path:           "/logout",
-			RequestMethod:  http.MethodDelete,
-			RequestHeaders: nil,
+			Alias:         "logout failure DELETE",
+			Path:          "/logout",
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouser, testKeys),
+			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusMethodNotAllowed,
 			ResponseHeaders: map[string]string{
@@ -82,9 +167,13 @@ func TestLogout(t *testing.T) {
 
 		testFn := func(tt *testing.T) {
 
+			ss := &SessionServiceMock{
+				Valid:       true,
+				RevokeError: testCase.RevokeError,
+			}
+
 			handler := chi.NewRouter()
-			handler.Get("/logout", auth.Logout())
-			handler.Post("/logout", auth.Logout())
+			handler.Post("/logout", auth.Logout(ss, testKeys))
 			handler.MethodNotAllowed(sendMessageHandler(http.StatusMethodNotAllowed))
 
 			server := httptest.NewServer(handler)
@@ -141,6 +230,10 @@ func TestLogout(t *testing.T) {
 				}
 			}
 
+			if got, want := strings.Join(ss.Revoked, ","), strings.Join(testCase.ExpectedRevoked, ","); got != want {
+				tt.Errorf("Bad revoked sessions: %s, expected %s", got, want)
+			}
+
 		} // fn
 
 		t.Run(testCase.Alias, testFn)
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"wallawire/model"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	oidcClientTimeout = time.Second * 10
+	oidcMaxBodySize   = 1 << 20
+)
+
+// OIDCConfig configures the client registered at an OpenID Connect provider.
+// ClientSecret is empty for public clients, which are protected by PKCE only.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCProvider performs the OpenID Connect authorization code flow with PKCE (RFC 7636).
+// The signing keys of the provider are fetched from its JWKS and refreshed when a token names an unknown key.
+type OIDCProvider struct {
+	config                OIDCConfig
+	client                *http.Client
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	keysMutex             sync.Mutex
+	keys                  map[string]*Key
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewOIDCProvider reads the configuration of the provider from its discovery document.
+// If client is nil, a client with a short timeout is used.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig, client *http.Client) (*OIDCProvider, error) {
+
+	if len(config.Issuer) == 0 || len(config.ClientID) == 0 || len(config.RedirectURL) == 0 {
+		return nil, errors.New("issuer, client ID and redirect URL required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: oidcClientTimeout}
+	}
+
+	z := &OIDCProvider{
+		config: config,
+		client: client,
+	}
+
+	var discovery oidcDiscovery
+	if err := z.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+oidcDiscoveryPath, &discovery); err != nil {
+		return nil, fmt.Errorf("cannot discover provider: %s", err)
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("provider issuer %q does not match %q", discovery.Issuer, config.Issuer)
+	}
+	if len(discovery.AuthorizationEndpoint) == 0 || len(discovery.TokenEndpoint) == 0 || len(discovery.JWKSURI) == 0 {
+		return nil, errors.New("incomplete provider configuration")
+	}
+	z.authorizationEndpoint = discovery.AuthorizationEndpoint
+	z.tokenEndpoint = discovery.TokenEndpoint
+	z.jwksURI = discovery.JWKSURI
+
+	return z, nil
+
+}
+
+// AuthCodeURL returns the URL of the provider at which the user authenticates.
+// The provider receives the S256 challenge of codeVerifier, which must be passed to Exchange.
+func (z *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", z.config.ClientID)
+	params.Set("redirect_uri", z.config.RedirectURL)
+	params.Set("scope", strings.Join(append([]string{"openid"}, z.config.Scopes...), " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(z.authorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return z.authorizationEndpoint + sep + params.Encode()
+
+}
+
+// Exchange redeems an authorization code and returns the identity of the verified ID token.
+func (z *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*model.ExternalLoginRequest, error) {
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", z.config.RedirectURL)
+	form.Set("client_id", z.config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, errReq := http.NewRequest(http.MethodPost, z.tokenEndpoint, strings.NewReader(form.Encode()))
+	if errReq != nil {
+		return nil, errReq
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(hContentType, "application/x-www-form-urlencoded")
+	if len(z.config.ClientSecret) != 0 {
+		req.SetBasicAuth(url.QueryEscape(z.config.ClientID), url.QueryEscape(z.config.ClientSecret))
+	}
+
+	rsp, errRsp := z.client.Do(req)
+	if errRsp != nil {
+		return nil, errRsp
+	}
+	defer rsp.Body.Close()
+
+	var token oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(rsp.Body, oidcMaxBodySize)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("cannot decode token response: %s", err)
+	}
+	if rsp.StatusCode != http.StatusOK || len(token.Error) != 0 {
+		return nil, fmt.Errorf("token request failed: %d %s %s", rsp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if len(token.IDToken) == 0 {
+		return nil, errors.New("no ID token")
+	}
+
+	return z.verifyIDToken(ctx, token.IDToken, nonce)
+
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (z *OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (*model.ExternalLoginRequest, error) {
+
+	token, errParse := jwt.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		return z.keyFunc(ctx, t)
+	})
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid ID token")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("ID token expired")
+	}
+	if iss, _ := claims["iss"].(string); iss != z.config.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	audiences := claimStrings(claims["aud"])
+	if !containsString(audiences, z.config.ClientID) {
+		return nil, errors.New("ID token not issued for this client")
+	}
+	if azp, ok := claims["azp"].(string); (ok || len(audiences) > 1) && azp != z.config.ClientID {
+		return nil, errors.New("ID token not authorized for this client")
+	}
+	if n, _ := claims["nonce"].(string); len(nonce) == 0 || n != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	identity := &model.ExternalLoginRequest{
+		Issuer: z.config.Issuer,
+	}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Name, _ = claims["name"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	if len(identity.Subject) == 0 {
+		return nil, errors.New("missing subject")
+	}
+
+	return identity, nil
+
+}
+
+func (z *OIDCProvider) keyFunc(ctx context.Context, t *jwt.Token) (interface{}, error) {
+
+	kid, _ := t.Header[headerKeyID].(string)
+
+	key, errKey := z.getKey(ctx, kid)
+	if errKey != nil {
+		return nil, errKey
+	}
+
+	// never let the token choose the algorithm
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedMethod
+	}
+
+	return key.verifyKey, nil
+
+}
+
+// getKey returns the key with the given ID, fetching the keys of the provider if the key is unknown.
+func (z *OIDCProvider) getKey(ctx context.Context, kid string) (*Key, error) {
+
+	z.keysMutex.Lock()
+	defer z.keysMutex.Unlock()
+
+	if key, ok := z.keys[kid]; ok {
+		return key, nil
+	}
+
+	var jwks JSONWebKeySet
+	if err := z.getJSON(ctx, z.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("cannot fetch provider keys: %s", err)
+	}
+	keys := make(map[string]*Key)
+	for _, jwk := range jwks.Keys {
+		if len(jwk.Use) != 0 && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue // unsupported keys are skipped
+		}
+		keys[key.ID] = key
+	}
+	z.keys = keys
+
+	if key, ok := z.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+
+}
+
+func (z *OIDCProvider) getJSON(ctx context.Context, u string, v interface{}) error {
+
+	req, errReq := http.NewRequest(http.MethodGet, u, nil)
+	if errReq != nil {
+		return errReq
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", mimeTypeJson)
+
+	rsp, errRsp := z.client.Do(req)
+	if errRsp != nil {
+		return errRsp
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, rsp.Status)
+	}
+
+	data, errData := ioutil.ReadAll(io.LimitReader(rsp.Body, oidcMaxBodySize))
+	if errData != nil {
+		return errData
+	}
+
+	return json.Unmarshal(data, v)
+
+}
+
+// codeChallenge computes the S256 PKCE challenge of a code verifier.
+func codeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// claimStrings returns a claim that is either a string or an array of strings.
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		var values []string
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,392 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"wallawire/web/auth"
+)
+
+// fakeOIDCProvider is an in-process OpenID Connect provider issuing EdDSA signed ID tokens.
+type fakeOIDCProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	Subject      string
+	Claims       map[string]interface{} // added to or replacing the claims of ID tokens
+	mutex        sync.Mutex
+	keys         *auth.Keys
+	codes        map[string]fakeAuthorization
+	issued       int
+}
+
+type fakeAuthorization struct {
+	RedirectURI   string
+	Nonce         string
+	CodeChallenge string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+
+	z := &fakeOIDCProvider{
+		ClientID:     "wallawire",
+		ClientSecret: "secret",
+		Subject:      "248289761001",
+		codes:        make(map[string]fakeAuthorization),
+	}
+	z.RotateKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", z.discovery)
+	mux.HandleFunc("/authorize", z.authorize)
+	mux.HandleFunc("/token", z.token)
+	mux.HandleFunc("/jwks", z.jwks)
+	z.Server = httptest.NewServer(mux)
+
+	return z
+
+}
+
+func (z *fakeOIDCProvider) Close() {
+	z.Server.Close()
+}
+
+func (z *fakeOIDCProvider) Issuer() string {
+	return z.Server.URL
+}
+
+// RotateKey replaces the signing key of the provider.
+func (z *fakeOIDCProvider) RotateKey(t *testing.T) {
+
+	_, privateKey, errKey := ed25519.GenerateKey(rand.Reader)
+	if errKey != nil {
+		t.Fatal(errKey)
+	}
+	der, errDER := x509.MarshalPKCS8PrivateKey(privateKey)
+	if errDER != nil {
+		t.Fatal(errDER)
+	}
+	key, errParse := auth.ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if errParse != nil {
+		t.Fatal(errParse)
+	}
+	keys, errKeys := auth.NewKeys(key)
+	if errKeys != nil {
+		t.Fatal(errKeys)
+	}
+
+	z.mutex.Lock()
+	z.keys = keys
+	z.mutex.Unlock()
+
+}
+
+// Authorize follows the authorization URL like a browser of an authenticated user and returns the redirect back to the client.
+func (z *fakeOIDCProvider) Authorize(t *testing.T, authURL string) *url.URL {
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	rsp, errGet := client.Get(authURL)
+	if errGet != nil {
+		t.Fatal(errGet)
+	}
+	rsp.Body.Close()
+	if got, want := rsp.StatusCode, http.StatusFound; got != want {
+		t.Fatalf("bad authorize status %d, expected %d", got, want)
+	}
+
+	location, errLocation := rsp.Location()
+	if errLocation != nil {
+		t.Fatal(errLocation)
+	}
+
+	return location
+
+}
+
+func (z *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 z.Issuer(),
+		"authorization_endpoint": z.Issuer() + "/authorize",
+		"token_endpoint":         z.Issuer() + "/token",
+		"jwks_uri":               z.Issuer() + "/jwks",
+	})
+}
+
+func (z *fakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	writeJSON(w, http.StatusOK, z.keys.JWKS())
+}
+
+func (z *fakeOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != z.ClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	z.mutex.Lock()
+	z.issued++
+	code := "code" + strconv.Itoa(z.issued)
+	z.codes[code] = fakeAuthorization{
+		RedirectURI:   q.Get("redirect_uri"),
+		Nonce:         q.Get("nonce"),
+		CodeChallenge: q.Get("code_challenge"),
+	}
+	z.mutex.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+
+}
+
+func (z *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+
+	if clientID, secret, ok := r.BasicAuth(); !ok || clientID != z.ClientID || secret != z.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	z.mutex.Lock()
+	code := r.PostFormValue("code")
+	authorization, ok := z.codes[code]
+	delete(z.codes, code) // single use
+	keys := z.keys
+	z.mutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || authorization.RedirectURI != r.PostFormValue("redirect_uri") || authorization.CodeChallenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                z.Issuer(),
+		"sub":                z.Subject,
+		"aud":                z.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute * 5).Unix(),
+		"nonce":              authorization.Nonce,
+		"email":              "newuser@example.com",
+		"email_verified":     true,
+		"name":               "New User",
+		"preferred_username": "newuser",
+	}
+	for k, v := range z.Claims {
+		claims[k] = v
+	}
+	idToken, errSign := keys.Sign(claims)
+	if errSign != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func TestOIDCProvider(b *testing.T) {
+
+	testCases := []struct {
+		Alias         string
+		Claims        map[string]interface{}
+		ClientSecret  string
+		CodeVerifier  string
+		Nonce         string
+		RotateKey     bool
+		ExpectedError bool
+	}{
+		{
+			Alias: "success",
+		},
+		{
+			Alias:     "rotated key",
+			RotateKey: true,
+		},
+		{
+			Alias:  "audience list",
+			Claims: map[string]interface{}{"aud": []string{"other", "wallawire"}, "azp": "wallawire"},
+		},
+		{
+			Alias:         "bad code verifier",
+			CodeVerifier:  "bogus",
+			ExpectedError: true,
+		},
+		{
+			Alias:         "bad client secret",
+			ClientSecret:  "bogus",
+			ExpectedError: true,
+		},
+		{
+			Alias:         "bad nonce",
+			Nonce:         "bogus",
+			ExpectedError: true,
+		},
+		{
+			Alias:         "bad audience",
+			Claims:        map[string]interface{}{"aud": "other"},
+			ExpectedError: true,
+		},
+		{
+			Alias:         "audience list without azp",
+			Claims:        map[string]interface{}{"aud": []string{"other", "wallawire"}},
+			ExpectedError: true,
+		},
+		{
+			Alias:         "bad issuer",
+			Claims:        map[string]interface{}{"iss": "https://bogus.example.com"},
+			ExpectedError: true,
+		},
+		{
+			Alias:         "expired",
+			Claims:        map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()},
+			ExpectedError: true,
+		},
+		{
+			Alias:         "no subject",
+			Claims:        map[string]interface{}{"sub": ""},
+			ExpectedError: true,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			fake := newFakeOIDCProvider(t)
+			defer fake.Close()
+			fake.Claims = tCase.Claims
+
+			secret := fake.ClientSecret
+			if len(tCase.ClientSecret) != 0 {
+				secret = tCase.ClientSecret
+			}
+
+			ctx := context.Background()
+			provider, errProvider := auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+				Issuer:       fake.Issuer(),
+				ClientID:     fake.ClientID,
+				ClientSecret: secret,
+				RedirectURL:  "https://wallawire.example.com/api/oidc/callback",
+				Scopes:       []string{"email", "profile"},
+			}, nil)
+			if errProvider != nil {
+				t.Fatal(errProvider)
+			}
+
+			if tCase.RotateKey {
+				// fetch the first key, then sign with a new one
+				callback := fake.Authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+				if _, err := provider.Exchange(ctx, callback.Query().Get("code"), "verifier", "nonce"); err != nil {
+					t.Fatal(err)
+				}
+				fake.RotateKey(t)
+			}
+
+			callback := fake.Authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+			if got, want := callback.Query().Get("state"), "state"; got != want {
+				t.Errorf("bad state %s, expected %s", got, want)
+			}
+
+			verifier := "verifier"
+			if len(tCase.CodeVerifier) != 0 {
+				verifier = tCase.CodeVerifier
+			}
+			nonce := "nonce"
+			if len(tCase.Nonce) != 0 {
+				nonce = tCase.Nonce
+			}
+
+			identity, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier, nonce)
+			if got, want := err != nil, tCase.ExpectedError; got != want {
+				t.Fatalf("bad error %v, expected %t", err, want)
+			}
+			if tCase.ExpectedError {
+				return
+			}
+
+			if got, want := identity.Issuer, fake.Issuer(); got != want {
+				t.Errorf("bad issuer %s, expected %s", got, want)
+			}
+			if got, want := identity.Subject, fake.Subject; got != want {
+				t.Errorf("bad subject %s, expected %s", got, want)
+			}
+			if got, want := identity.Email, "newuser@example.com"; got != want {
+				t.Errorf("bad email %s, expected %s", got, want)
+			}
+			if !identity.EmailVerified {
+				t.Error("bad email verified false, expected true")
+			}
+			if got, want := identity.PreferredUsername, "newuser"; got != want {
+				t.Errorf("bad preferred username %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestOIDCProviderDiscovery(t *testing.T) {
+
+	fake := newFakeOIDCProvider(t)
+	defer fake.Close()
+
+	config := auth.OIDCConfig{
+		Issuer:      fake.Issuer() + "/",
+		ClientID:    fake.ClientID,
+		RedirectURL: "https://wallawire.example.com/api/oidc/callback",
+	}
+	if _, err := auth.NewOIDCProvider(context.Background(), config, nil); err == nil {
+		t.Error("bad discovery error nil, expected issuer mismatch")
+	}
+
+	config.Issuer = fake.Issuer()
+	config.ClientID = ""
+	if _, err := auth.NewOIDCProvider(context.Background(), config, nil); err == nil {
+		t.Error("bad discovery error nil, expected missing client ID")
+	}
+
+}
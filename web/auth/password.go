@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type PasswordResetService interface {
+	ForgotPassword(context.Context, model.ForgotPasswordRequest) model.ForgotPasswordResponse
+	ResetForgottenPassword(context.Context, model.ResetForgottenPasswordRequest) model.ResetForgottenPasswordResponse
+}
+
+// ForgotPassword sends a token to set a new password to the user.
+// It is accepted whether or not the user exists.
+func ForgotPassword(userService PasswordResetService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "ForgotPasswordHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.ForgotPasswordRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+
+		rsp := userService.ForgotPassword(ctx, req)
+		if rsp.Code != http.StatusAccepted {
+			sendMessageText(w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendMessage(w, http.StatusAccepted)
+
+	})
+}
+
+// ResetPassword sets a new password with the token sent by ForgotPassword.
+func ResetPassword(userService PasswordResetService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "ResetPasswordHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.ResetForgottenPasswordRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+
+		rsp := userService.ResetForgottenPassword(ctx, req)
+		if rsp.Code != http.StatusOK {
+			sendJsonRuleMessage(w, rsp.Code, rsp.Message, rsp.Rule)
+			return
+		}
+
+		sendMessage(w, http.StatusOK)
+
+	})
+}
+
+// readRequest unmarshals the json request body into req or sends an error message.
+func readRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+
+	logger := logging.New(r.Context(), "auth", "readRequest")
+
+	if r.Header.Get(hContentType) != mimeTypeJson {
+		msg := "bad or missing content type"
+		logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+		sendMessageText(w, http.StatusBadRequest, msg)
+		return false
+	}
+
+	body, errBody := ioutil.ReadAll(r.Body)
+	if errBody != nil {
+		msg := "cannot read request body"
+		logger.Debug().Err(errBody).Msg(msg)
+		sendMessageText(w, http.StatusBadRequest, msg)
+		return false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, req); err != nil {
+		msg := "cannot unmarshal json"
+		logger.Debug().Err(err).Msg(msg)
+		sendMessageText(w, http.StatusBadRequest, msg)
+		return false
+	}
+
+	return true
+
+}
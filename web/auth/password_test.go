@@ -0,0 +1,252 @@
+package auth_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestPasswordReset(t *testing.T) {
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		UserService     *UserServiceMock
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+		Check           func(*testing.T, *UserServiceMock)
+	}{
+		{
+			Alias: "forgot success",
+			Path:  "/password/forgot",
+			UserService: &UserServiceMock{
+				ForgotResponse: model.ForgotPasswordResponse{
+					Code: http.StatusAccepted,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "demouser"}`),
+			ResponseStatus: http.StatusAccepted,
+			ResponseHeaders: map[string]string{
+				hContentLength: "9",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("Accepted\n"),
+			Check: func(t *testing.T, mock *UserServiceMock) {
+				if got, want := mock.ForgotRequest.Username, "demouser"; got != want {
+					t.Errorf("Bad username: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "forgot disabled",
+			Path:  "/password/forgot",
+			UserService: &UserServiceMock{
+				ForgotResponse: model.ForgotPasswordResponse{
+					Code:    http.StatusForbidden,
+					Message: "password reset disabled",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "demouser"}`),
+			ResponseStatus: http.StatusForbidden,
+			ResponseHeaders: map[string]string{
+				hContentLength: "24",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("password reset disabled\n"),
+		},
+		{
+			Alias:          "forgot no content-type",
+			Path:           "/password/forgot",
+			UserService:    &UserServiceMock{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: nil,
+			RequestBody:    []byte(`{"username": "demouser"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "28",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("bad or missing content type\n"),
+		},
+		{
+			Alias: "reset success",
+			Path:  "/password/reset",
+			UserService: &UserServiceMock{
+				ResetResponse: model.ResetForgottenPasswordResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"token": "abc-123", "password": "newpassword"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "3",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("OK\n"),
+			Check: func(t *testing.T, mock *UserServiceMock) {
+				expected := model.ResetForgottenPasswordRequest{Token: "abc-123", Password: "newpassword"}
+				if got, want := mock.ResetRequest, expected; got != want {
+					t.Errorf("Bad request: %+v, expected %+v", got, want)
+				}
+			},
+		},
+		{
+			Alias: "reset invalid token",
+			Path:  "/password/reset",
+			UserService: &UserServiceMock{
+				ResetResponse: model.ResetForgottenPasswordResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid token",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"token": "bogus", "password": "newpassword"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "44",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"invalid token"}`),
+		},
+		{
+			Alias: "reset password not valid",
+			Path:  "/password/reset",
+			UserService: &UserServiceMock{
+				ResetResponse: model.ResetForgottenPasswordResponse{
+					Code:    http.StatusBadRequest,
+					Message: "password must have at least 8 characters",
+					Rule:    model.PasswordRuleMinLength,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"token": "abc-123", "password": "short"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "90",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"password must have at least 8 characters","rule":"minLength"}`),
+		},
+		{
+			Alias:         "reset bogus request payload",
+			Path:          "/password/reset",
+			UserService:   &UserServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"token"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "22",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("cannot unmarshal json\n"),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(tt *testing.T) {
+
+			handler := chi.NewRouter()
+			handler.Post("/password/forgot", auth.ForgotPassword(testCase.UserService))
+			handler.Post("/password/reset", auth.ResetPassword(testCase.UserService))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				tt.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := http.DefaultClient.Do(req)
+			if errRsp != nil {
+				tt.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				tt.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				tt.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					tt.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					tt.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if bytes.Compare(body, testCase.ResponseBody) != 0 {
+				tt.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+			}
+
+			if testCase.Check != nil {
+				testCase.Check(tt, testCase.UserService)
+			}
+
+		} // fn
+
+		t.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
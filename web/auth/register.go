@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type RegisterService interface {
+	Register(context.Context, model.RegisterRequest) model.RegisterResponse
+	VerifyEmail(context.Context, model.VerifyEmailRequest) model.VerifyEmailResponse
+}
+
+// Register creates a user which can login once the email address has been verified with the link sent by mail.
+func Register(userService RegisterService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "RegisterHandler")
+		logger.Debug().Msg("invoked")
+
+		if r.Header.Get(hContentType) != mimeTypeJson {
+			msg := "bad or missing content type"
+			logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		body, errBody := ioutil.ReadAll(r.Body)
+		if errBody != nil {
+			msg := "cannot read request body"
+			logger.Debug().Err(errBody).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+		defer r.Body.Close()
+
+		var req model.RegisterRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			msg := "cannot unmarshal json"
+			logger.Debug().Err(err).Msg(msg)
+			sendMessageText(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rsp := userService.Register(ctx, req)
+		if rsp.Code != http.StatusCreated {
+			sendJsonRuleMessage(w, rsp.Code, rsp.Message, rsp.Rule)
+			return
+		}
+
+		sendMessage(w, http.StatusCreated)
+
+	})
+}
+
+// VerifyEmail is the target of the link sent by Register, the token is given as query parameter.
+func VerifyEmail(userService RegisterService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "auth", "VerifyEmailHandler")
+		logger.Debug().Msg("invoked")
+
+		rsp := userService.VerifyEmail(ctx, model.VerifyEmailRequest{
+			Token: r.URL.Query().Get("token"),
+		})
+		if rsp.Code != http.StatusOK {
+			sendMessageText(w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendMessage(w, http.StatusOK)
+
+	})
+}
@@ -0,0 +1,244 @@
+package auth_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestRegister(t *testing.T) {
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		UserService     *UserServiceMock
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+		Check           func(*testing.T, *UserServiceMock)
+	}{
+		{
+			Alias: "register success",
+			Path:  "/register",
+			UserService: &UserServiceMock{
+				RegisterResponse: model.RegisterResponse{
+					Code: http.StatusCreated,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "newuser", "name": "New User", "email": "newuser@example.com", "password": "newpassword"}`),
+			ResponseStatus: http.StatusCreated,
+			ResponseHeaders: map[string]string{
+				hContentLength: "8",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("Created\n"),
+			Check: func(t *testing.T, mock *UserServiceMock) {
+				expected := model.RegisterRequest{Username: "newuser", Name: "New User", Email: "newuser@example.com", Password: "newpassword"}
+				if got, want := mock.RegisterRequest, expected; got != want {
+					t.Errorf("Bad request: %+v, expected %+v", got, want)
+				}
+			},
+		},
+		{
+			Alias: "register disabled",
+			Path:  "/register",
+			UserService: &UserServiceMock{
+				RegisterResponse: model.RegisterResponse{
+					Code:    http.StatusForbidden,
+					Message: "registration disabled",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "newuser", "email": "newuser@example.com", "password": "newpassword"}`),
+			ResponseStatus: http.StatusForbidden,
+			ResponseHeaders: map[string]string{
+				hContentLength: "52",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":403,"message":"registration disabled"}`),
+		},
+		{
+			Alias: "register password not valid",
+			Path:  "/register",
+			UserService: &UserServiceMock{
+				RegisterResponse: model.RegisterResponse{
+					Code:    http.StatusBadRequest,
+					Message: "password must have at least 8 characters",
+					Rule:    model.PasswordRuleMinLength,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username": "newuser", "email": "newuser@example.com", "password": "short"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "90",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"password must have at least 8 characters","rule":"minLength"}`),
+		},
+		{
+			Alias:          "register no content-type",
+			Path:           "/register",
+			UserService:    &UserServiceMock{},
+			RequestMethod:  http.MethodPost,
+			RequestHeaders: nil,
+			RequestBody:    []byte(`{"username": "newuser"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "28",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("bad or missing content type\n"),
+		},
+		{
+			Alias:         "register bogus request payload",
+			Path:          "/register",
+			UserService:   &UserServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"username"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "22",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("cannot unmarshal json\n"),
+		},
+		{
+			Alias: "verify success",
+			Path:  "/register/verify?token=abc-123",
+			UserService: &UserServiceMock{
+				VerifyEmailResponse: model.VerifyEmailResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod:  http.MethodGet,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "3",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("OK\n"),
+			Check: func(t *testing.T, mock *UserServiceMock) {
+				if got, want := mock.VerifyEmailRequest.Token, "abc-123"; got != want {
+					t.Errorf("Bad token: %s, expected %s", got, want)
+				}
+			},
+		},
+		{
+			Alias: "verify invalid token",
+			Path:  "/register/verify?token=bogus",
+			UserService: &UserServiceMock{
+				VerifyEmailResponse: model.VerifyEmailResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid token",
+				},
+			},
+			RequestMethod:  http.MethodGet,
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "14",
+				hContentType:   mimeTypeText,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte("invalid token\n"),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(tt *testing.T) {
+
+			handler := chi.NewRouter()
+			handler.Post("/register", auth.Register(testCase.UserService))
+			handler.Get("/register/verify", auth.VerifyEmail(testCase.UserService))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				tt.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := http.DefaultClient.Do(req)
+			if errRsp != nil {
+				tt.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				tt.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				tt.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					tt.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					tt.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if bytes.Compare(body, testCase.ResponseBody) != 0 {
+				tt.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+			}
+
+			if testCase.Check != nil {
+				testCase.Check(tt, testCase.UserService)
+			}
+
+		} // fn
+
+		t.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
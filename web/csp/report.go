@@ -0,0 +1,118 @@
+package csp
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	hContentLength = "Content-Length"
+	hContentType   = "Content-Type"
+	mimeTypeText   = "text/plain; charset=utf-8"
+	maxReportSize  = 64 * 1024
+)
+
+// violation holds the fields of a report sent as application/csp-report
+// or as the body of a csp-violation in application/reports+json.
+type violation struct {
+	DocumentURI           string `json:"document-uri"`
+	DocumentURL           string `json:"documentURL"`
+	BlockedURI            string `json:"blocked-uri"`
+	BlockedURL            string `json:"blockedURL"`
+	ViolatedDirective     string `json:"violated-directive"`
+	EffectiveDirective    string `json:"effective-directive"`
+	EffectiveDirectiveAlt string `json:"effectiveDirective"`
+	SourceFile            string `json:"source-file"`
+	SourceFileAlt         string `json:"sourceFile"`
+	LineNumber            int    `json:"line-number"`
+	LineNumberAlt         int    `json:"lineNumber"`
+	Disposition           string `json:"disposition"`
+}
+
+// Report logs the Content-Security-Policy violations reported by browsers.
+func Report() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.New(ctx, "csp", "Report")
+
+		body, errBody := ioutil.ReadAll(io.LimitReader(r.Body, maxReportSize+1))
+		if errBody != nil || len(body) > maxReportSize {
+			sendMessage(w, http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		violations, err := parseReport(body)
+		if err != nil {
+			logger.Debug().Err(err).Msg("cannot unmarshal report")
+			sendMessage(w, http.StatusBadRequest)
+			return
+		}
+
+		for _, v := range violations {
+			logger.Warn().
+				Str("document", first(v.DocumentURI, v.DocumentURL)).
+				Str("blocked", first(v.BlockedURI, v.BlockedURL)).
+				Str("directive", first(v.EffectiveDirective, v.EffectiveDirectiveAlt, v.ViolatedDirective)).
+				Str("source", first(v.SourceFile, v.SourceFileAlt)).
+				Int("line", v.LineNumber+v.LineNumberAlt).
+				Str("disposition", v.Disposition).
+				Str("userAgent", model.ClientFromContext(r.Context()).UserAgent).
+				Msg("content security policy violation")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	})
+}
+
+// parseReport accepts the report-uri format {"csp-report": {...}}
+// and the Reporting API format [{"type": "csp-violation", "body": {...}}].
+func parseReport(body []byte) ([]violation, error) {
+
+	var reports []struct {
+		Type string    `json:"type"`
+		Body violation `json:"body"`
+	}
+	if err := json.Unmarshal(body, &reports); err == nil {
+		var result []violation
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				result = append(result, report.Body)
+			}
+		}
+		return result, nil
+	}
+
+	var report struct {
+		Violation violation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	return []violation{report.Violation}, nil
+
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if len(value) != 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+func sendMessage(w http.ResponseWriter, statusCode int) {
+	msg := []byte(http.StatusText(statusCode) + "\n")
+	w.Header().Set(hContentType, mimeTypeText)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
@@ -0,0 +1,80 @@
+package csp_test
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"wallawire/web/csp"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	os.Exit(m.Run())
+}
+
+func TestReport(b *testing.T) {
+
+	testCases := []struct {
+		Alias          string
+		ContentType    string
+		RequestBody    []byte
+		ResponseStatus int
+	}{
+		{
+			Alias:          "csp-report",
+			ContentType:    "application/csp-report",
+			RequestBody:    []byte(`{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"inline","effective-directive":"script-src-elem"}}`),
+			ResponseStatus: http.StatusNoContent,
+		},
+		{
+			Alias:          "reports+json",
+			ContentType:    "application/reports+json",
+			RequestBody:    []byte(`[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"inline","effectiveDirective":"style-src-elem"}}]`),
+			ResponseStatus: http.StatusNoContent,
+		},
+		{
+			Alias:          "bad json",
+			ContentType:    "application/csp-report",
+			RequestBody:    []byte(`{"csp-report":`),
+			ResponseStatus: http.StatusBadRequest,
+		},
+		{
+			Alias:          "too large",
+			ContentType:    "application/csp-report",
+			RequestBody:    []byte(`{"csp-report":{"document-uri":"` + strings.Repeat("x", 64*1024) + `"}}`),
+			ResponseStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodPost, "/api/csp-report", bytes.NewReader(tCase.RequestBody))
+			req.Header.Set("Content-Type", tCase.ContentType)
+			rec := httptest.NewRecorder()
+			csp.Report().ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"wallawire/model"
+)
+
+const (
+	hUserAgent = "User-Agent"
+)
+
+// Client adds the IP address and user agent of the remote end to the request context.
+// Handlers read both with model.ClientFromContext, the services truncate the user agent when storing it.
+func Client() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			client := model.Client{
+				IPAddress: remoteIP(r),
+				UserAgent: r.Header.Get(hUserAgent),
+			}
+
+			ctx := context.WithValue(r.Context(), model.ClientKey, client)
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+		})
+	}
+}
+
+// remoteIP returns the IP address of the client without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
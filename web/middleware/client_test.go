@@ -0,0 +1,71 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/middleware"
+)
+
+func TestClient(b *testing.T) {
+
+	testCases := []struct {
+		Alias             string
+		RemoteAddr        string
+		UserAgent         string
+		ExpectedIPAddress string
+		ExpectedUserAgent string
+	}{
+		{
+			Alias:             "success",
+			RemoteAddr:        "192.0.2.1:1234",
+			UserAgent:         "test-agent",
+			ExpectedIPAddress: "192.0.2.1",
+			ExpectedUserAgent: "test-agent",
+		},
+		{
+			Alias:             "no port",
+			RemoteAddr:        "192.0.2.1",
+			ExpectedIPAddress: "192.0.2.1",
+		},
+		{
+			Alias:             "ipv6",
+			RemoteAddr:        "[2001:db8::1]:1234",
+			UserAgent:         "test-agent",
+			ExpectedIPAddress: "2001:db8::1",
+			ExpectedUserAgent: "test-agent",
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			var client model.Client
+			handler := middleware.Client()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				client = model.ClientFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tCase.RemoteAddr
+			if len(tCase.UserAgent) != 0 {
+				req.Header.Set("User-Agent", tCase.UserAgent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got, want := client.IPAddress, tCase.ExpectedIPAddress; got != want {
+				t.Errorf("bad IP address %s, expected %s", got, want)
+			}
+			if got, want := client.UserAgent, tCase.ExpectedUserAgent; got != want {
+				t.Errorf("bad user agent %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/web/auth"
+)
+
+const (
+	CSRFCookieName = "csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+	csrfTokenBytes = 32
+)
+
+// CSRF protects cookie-authenticated requests with a double-submit token.
+// A random token is set in a cookie readable by the ui, which has to send it back in the X-CSRF-Token header
+// of every request that is not GET, HEAD or OPTIONS. Another site can neither read the cookie nor set the header.
+// Requests with an API token in the Authorization header are not authenticated by cookie and are passed,
+// as are requests to the exempt paths, which must not act on behalf of the user.
+func CSRF(exemptPaths ...string) func(next http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			token := ""
+			if cookie, err := r.Cookie(CSRFCookieName); err == nil {
+				token = cookie.Value
+			}
+			if len(token) == 0 {
+				t, err := newCSRFToken()
+				if err != nil {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     CSRFCookieName,
+					Value:    t,
+					Path:     "/",
+					Secure:   true,
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(auth.APITokenFromHeader(r)) != 0 || exempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := r.Header.Get(CSRFHeaderName)
+			if len(token) == 0 || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				logger := logging.New(r.Context(), "middleware", "CSRF")
+				logger.Info().Str("method", r.Method).Str("path", r.URL.Path).Msg("missing or bad CSRF token")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
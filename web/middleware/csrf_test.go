@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/middleware"
+)
+
+func TestCSRF(b *testing.T) {
+
+	const token = "csrf-token"
+
+	testCases := []struct {
+		Alias          string
+		Method         string
+		Path           string
+		Cookie         string
+		Header         string
+		Authorization  string
+		ExpectedStatus int
+		ExpectedCookie bool
+	}{
+		{
+			Alias:          "safe method sets cookie",
+			Method:         http.MethodGet,
+			ExpectedStatus: http.StatusOK,
+			ExpectedCookie: true,
+		},
+		{
+			Alias:          "safe method keeps cookie",
+			Method:         http.MethodGet,
+			Cookie:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "head passes without token",
+			Method:         http.MethodHead,
+			Cookie:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "post with matching token",
+			Method:         http.MethodPost,
+			Cookie:         token,
+			Header:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "post without header",
+			Method:         http.MethodPost,
+			Cookie:         token,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "post with mismatched header",
+			Method:         http.MethodPost,
+			Cookie:         token,
+			Header:         "other-token",
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "post without cookie",
+			Method:         http.MethodPost,
+			Header:         token,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCookie: true,
+		},
+		{
+			Alias:          "delete without header",
+			Method:         http.MethodDelete,
+			Cookie:         token,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "exempt path passes",
+			Method:         http.MethodPost,
+			Path:           "/exempt",
+			Cookie:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "api token passes",
+			Method:         http.MethodPost,
+			Authorization:  "Bearer " + model.APITokenPrefix + "abc",
+			ExpectedStatus: http.StatusOK,
+			ExpectedCookie: true,
+		},
+		{
+			Alias:          "other authorization header",
+			Method:         http.MethodPost,
+			Cookie:         token,
+			Authorization:  "Basic abc",
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "session token in authorization header",
+			Method:         http.MethodPost,
+			Cookie:         token,
+			Authorization:  "Bearer abc",
+			ExpectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			handler := middleware.CSRF("/exempt")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			path := "/"
+			if len(tCase.Path) != 0 {
+				path = tCase.Path
+			}
+			req := httptest.NewRequest(tCase.Method, path, nil)
+			if len(tCase.Cookie) != 0 {
+				req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: tCase.Cookie})
+			}
+			if len(tCase.Header) != 0 {
+				req.Header.Set(middleware.CSRFHeaderName, tCase.Header)
+			}
+			if len(tCase.Authorization) != 0 {
+				req.Header.Set("Authorization", tCase.Authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tCase.ExpectedStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+			var cookie *http.Cookie
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == middleware.CSRFCookieName {
+					cookie = c
+				}
+			}
+			if got, want := cookie != nil, tCase.ExpectedCookie; got != want {
+				t.Fatalf("cookie set %t, expected %t", got, want)
+			}
+			if cookie != nil {
+				if len(cookie.Value) == 0 || cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+					t.Errorf("bad cookie %s", cookie.String())
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	hRateLimitLimit     = "RateLimit-Limit"
+	hRateLimitRemaining = "RateLimit-Remaining"
+	hRateLimitReset     = "RateLimit-Reset"
+	hRetryAfter         = "Retry-After"
+	rateLimitSweep      = time.Minute
+)
+
+// RateLimit allows a burst of Requests, refilled evenly over Per.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRateLimit parses requests/duration, for example 5/1m.
+func ParseRateLimit(value string) (RateLimit, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, errors.New("expected requests/duration")
+	}
+	requests, errRequests := strconv.Atoi(parts[0])
+	if errRequests != nil || requests < 1 {
+		return RateLimit{}, errors.New("requests must be a positive number")
+	}
+	per, errPer := time.ParseDuration(parts[1])
+	if errPer != nil || per <= 0 {
+		return RateLimit{}, errors.New("duration must be positive")
+	}
+	return RateLimit{Requests: requests, Per: per}, nil
+}
+
+// RateLimitResult is the state of a bucket after taking a token.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until the next token, if not allowed
+	Reset      time.Duration // until the bucket is full
+}
+
+// RateLimitStore keeps the token buckets by key.
+// The memory store serves a single instance, a shared store can implement the interface for several instances.
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) RateLimitResult
+}
+
+// ConnectionStore counts the open connections by key.
+type ConnectionStore interface {
+	Acquire(key string, max int) bool
+	Release(key string)
+}
+
+// RateLimitKey returns the key to limit a request by.
+type RateLimitKey func(r *http.Request) string
+
+// KeyByIP limits requests by the IP address of the client.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + model.ClientFromContext(r.Context()).IPAddress
+}
+
+// KeyByUser limits requests by the authenticated user, or by IP address without one.
+func KeyByUser(r *http.Request) string {
+	if user := model.TokenFromContext(r.Context()); len(user.ID) != 0 {
+		return "user:" + user.ID
+	}
+	return KeyByIP(r)
+}
+
+// RateLimiter limits the requests per key with a token bucket named after the route group.
+// Every response has RateLimit-* headers, refused requests are answered with 429 and Retry-After.
+func RateLimiter(name string, limit RateLimit, store RateLimitStore, keyFn RateLimitKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			result := store.Take(name+"/"+keyFn(r), limit, time.Now())
+
+			h := w.Header()
+			h.Set(hRateLimitLimit, strconv.Itoa(limit.Requests))
+			h.Set(hRateLimitRemaining, strconv.Itoa(result.Remaining))
+			h.Set(hRateLimitReset, seconds(result.Reset))
+
+			if !result.Allowed {
+				logger := logging.New(r.Context(), "middleware", "RateLimiter")
+				logger.Info().Str("limit", name).Str("path", r.URL.Path).Msg("rate limit exceeded")
+				h.Set(hRetryAfter, seconds(result.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
+
+// ConnectionLimiter caps the concurrent requests per key, intended for long-lived streams.
+// Refused requests are answered with 429 and the given Retry-After.
+func ConnectionLimiter(name string, max int, retryAfter time.Duration, store ConnectionStore, keyFn RateLimitKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			key := name + "/" + keyFn(r)
+			h := w.Header()
+			h.Set(hRateLimitLimit, strconv.Itoa(max))
+
+			if !store.Acquire(key, max) {
+				logger := logging.New(r.Context(), "middleware", "ConnectionLimiter")
+				logger.Info().Str("limit", name).Str("path", r.URL.Path).Msg("connection limit exceeded")
+				h.Set(hRateLimitRemaining, "0")
+				h.Set(hRateLimitReset, seconds(retryAfter))
+				h.Set(hRetryAfter, seconds(retryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			defer store.Release(key)
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket is full again, to sweep it
+}
+
+// MemoryRateLimitStore keeps the token buckets in memory.
+type MemoryRateLimitStore struct {
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (z *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) RateLimitResult {
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	if now.Sub(z.swept) > rateLimitSweep {
+		z.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Per.Seconds() // tokens per second
+
+	b, ok := z.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		z.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.updated = now
+	}
+
+	result := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = toDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = toDuration((capacity - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+
+	return result
+
+}
+
+// sweep removes the buckets that are full again, which is the same as not having one.
+func (z *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range z.buckets {
+		if !b.full.After(now) {
+			delete(z.buckets, key)
+		}
+	}
+	z.swept = now
+}
+
+func toDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// MemoryConnectionStore counts the open connections in memory.
+type MemoryConnectionStore struct {
+	lock        sync.Mutex
+	connections map[string]int
+}
+
+func NewMemoryConnectionStore() *MemoryConnectionStore {
+	return &MemoryConnectionStore{
+		connections: make(map[string]int),
+	}
+}
+
+func (z *MemoryConnectionStore) Acquire(key string, max int) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if z.connections[key] >= max {
+		return false
+	}
+	z.connections[key]++
+	return true
+}
+
+func (z *MemoryConnectionStore) Release(key string) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if n := z.connections[key] - 1; n > 0 {
+		z.connections[key] = n
+	} else {
+		delete(z.connections, key)
+	}
+}
@@ -0,0 +1,205 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/middleware"
+)
+
+func TestParseRateLimit(b *testing.T) {
+
+	testCases := []struct {
+		Alias    string
+		Value    string
+		Expected middleware.RateLimit
+		Error    bool
+	}{
+		{
+			Alias:    "success",
+			Value:    "5/1m",
+			Expected: middleware.RateLimit{Requests: 5, Per: time.Minute},
+		},
+		{
+			Alias: "no duration",
+			Value: "5",
+			Error: true,
+		},
+		{
+			Alias: "zero requests",
+			Value: "0/1m",
+			Error: true,
+		},
+		{
+			Alias: "bad duration",
+			Value: "5/minute",
+			Error: true,
+		},
+		{
+			Alias: "zero duration",
+			Value: "5/0s",
+			Error: true,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			limit, err := middleware.ParseRateLimit(tCase.Value)
+			if got, want := err != nil, tCase.Error; got != want {
+				t.Fatalf("error %v, expected error %t", err, want)
+			}
+			if got, want := limit, tCase.Expected; got != want {
+				t.Errorf("bad limit %v, expected %v", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+
+	store := middleware.NewMemoryRateLimitStore()
+	limit := middleware.RateLimit{Requests: 2, Per: time.Minute}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		At         time.Duration
+		Key        string
+		Allowed    bool
+		Remaining  int
+		RetryAfter time.Duration
+		Reset      time.Duration
+	}{
+		{At: 0, Key: "a", Allowed: true, Remaining: 1, Reset: 30 * time.Second},
+		{At: 0, Key: "a", Allowed: true, Remaining: 0, Reset: time.Minute},
+		{At: 0, Key: "a", Allowed: false, Remaining: 0, RetryAfter: 30 * time.Second, Reset: time.Minute},
+		{At: 0, Key: "b", Allowed: true, Remaining: 1, Reset: 30 * time.Second},
+		{At: 15 * time.Second, Key: "a", Allowed: false, Remaining: 0, RetryAfter: 15 * time.Second, Reset: 45 * time.Second},
+		{At: 30 * time.Second, Key: "a", Allowed: true, Remaining: 0, Reset: time.Minute},
+		{At: 10 * time.Minute, Key: "a", Allowed: true, Remaining: 1, Reset: 30 * time.Second},
+	}
+
+	for i, step := range steps {
+		result := store.Take(step.Key, limit, now.Add(step.At))
+		if got, want := result.Allowed, step.Allowed; got != want {
+			t.Errorf("step %d: allowed %t, expected %t", i, got, want)
+		}
+		if got, want := result.Remaining, step.Remaining; got != want {
+			t.Errorf("step %d: remaining %d, expected %d", i, got, want)
+		}
+		if got, want := result.RetryAfter, step.RetryAfter; got != want {
+			t.Errorf("step %d: retry after %s, expected %s", i, got, want)
+		}
+		if got, want := result.Reset, step.Reset; got != want {
+			t.Errorf("step %d: reset %s, expected %s", i, got, want)
+		}
+	}
+
+}
+
+func TestRateLimiter(t *testing.T) {
+
+	store := middleware.NewMemoryRateLimitStore()
+	limit := middleware.RateLimit{Requests: 2, Per: time.Hour}
+	handler := middleware.RateLimiter("test", limit, store, middleware.KeyByUser)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		ctx := context.WithValue(req.Context(), model.ClientKey, model.Client{IPAddress: "192.0.2.1"})
+		if len(userID) != 0 {
+			ctx = context.WithValue(ctx, model.UserKey, model.SessionToken{ID: userID})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+		return rec
+	}
+
+	steps := []struct {
+		UserID     string
+		Status     int
+		Remaining  string
+		Reset      string
+		RetryAfter string
+	}{
+		{UserID: "u1", Status: http.StatusOK, Remaining: "1", Reset: "1800"},
+		{UserID: "u1", Status: http.StatusOK, Remaining: "0", Reset: "3600"},
+		{UserID: "u1", Status: http.StatusTooManyRequests, Remaining: "0", Reset: "3600", RetryAfter: "1800"},
+		{UserID: "u2", Status: http.StatusOK, Remaining: "1", Reset: "1800"},
+		{Status: http.StatusOK, Remaining: "1", Reset: "1800"}, // by ip
+	}
+
+	for i, step := range steps {
+		rec := request(step.UserID)
+		if got, want := rec.Code, step.Status; got != want {
+			t.Errorf("step %d: bad status %d, expected %d", i, got, want)
+		}
+		headers := map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": step.Remaining,
+			"RateLimit-Reset":     step.Reset,
+			"Retry-After":         step.RetryAfter,
+		}
+		for key, want := range headers {
+			if got := rec.Header().Get(key); got != want {
+				t.Errorf("step %d: bad header %s: %s, expected %s", i, key, got, want)
+			}
+		}
+	}
+
+}
+
+func TestConnectionLimiter(t *testing.T) {
+
+	store := middleware.NewMemoryConnectionStore()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := middleware.ConnectionLimiter("test", 1, time.Minute, store, middleware.KeyByUser)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := context.WithValue(req.Context(), model.UserKey, model.SessionToken{ID: "u1"})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+		return rec
+	}
+
+	done := make(chan struct{})
+	go func() {
+		request()
+		close(done)
+	}()
+	<-started
+
+	rec := request()
+	if got, want := rec.Code, http.StatusTooManyRequests; got != want {
+		t.Errorf("bad status %d, expected %d", got, want)
+	}
+	if got, want := rec.Header().Get("Retry-After"), "60"; got != want {
+		t.Errorf("bad Retry-After %s, expected %s", got, want)
+	}
+
+	close(release)
+	<-done
+
+	// released
+	go func() { <-started }()
+	if got, want := request().Code, http.StatusOK; got != want {
+		t.Errorf("bad status after release %d, expected %d", got, want)
+	}
+
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallawire/model"
+)
+
+const (
+	hContentSecurityPolicy   = "Content-Security-Policy"
+	hContentTypeOptions      = "X-Content-Type-Options"
+	hPermissionsPolicy       = "Permissions-Policy"
+	hReferrerPolicy          = "Referrer-Policy"
+	hStrictTransportSecurity = "Strict-Transport-Security"
+	cspNonceBytes            = 16
+)
+
+// CSPNoncePlaceholder is replaced by the nonce of the request in the Content-Security-Policy.
+const CSPNoncePlaceholder = "{nonce}"
+
+// SecurityOptions configures the headers set by SecurityHeaders. Empty values omit the header or directive.
+type SecurityOptions struct {
+	ContentSecurityPolicy string        // may contain CSPNoncePlaceholder
+	FrameAncestors        string        // frame-ancestors directive appended to the policy
+	ReportURI             string        // report-uri directive appended to the policy
+	HSTSMaxAge            time.Duration // only sent over TLS
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+}
+
+// DefaultSecurityOptions allows the ui to load its own resources only and forbids framing.
+var DefaultSecurityOptions = SecurityOptions{
+	ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+		"img-src 'self' data:; font-src 'self'; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'",
+	FrameAncestors:    "'none'",
+	HSTSMaxAge:        time.Hour * 24 * 365,
+	ReferrerPolicy:    "strict-origin-when-cross-origin",
+	PermissionsPolicy: "camera=(), geolocation=(), microphone=(), payment=(), usb=()",
+}
+
+// SecurityHeaders sets Content-Security-Policy, Strict-Transport-Security, X-Content-Type-Options,
+// Referrer-Policy and Permissions-Policy on every response.
+// If the policy contains CSPNoncePlaceholder, a new nonce is generated per request and added to the request context,
+// so that the static handler can mark the scripts and styles of index.html with it.
+func SecurityHeaders(opts SecurityOptions) func(next http.Handler) http.Handler {
+
+	policy := opts.ContentSecurityPolicy
+	if len(opts.FrameAncestors) != 0 {
+		policy = appendDirective(policy, "frame-ancestors "+opts.FrameAncestors)
+	}
+	if len(opts.ReportURI) != 0 {
+		policy = appendDirective(policy, "report-uri "+opts.ReportURI)
+	}
+	useNonce := strings.Contains(policy, CSPNoncePlaceholder)
+
+	hsts := ""
+	if seconds := int64(opts.HSTSMaxAge / time.Second); seconds > 0 {
+		hsts = "max-age=" + strconv.FormatInt(seconds, 10) + "; includeSubDomains"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			h := w.Header()
+
+			if len(policy) != 0 {
+				if useNonce {
+					nonce, err := newCSPNonce()
+					if err != nil {
+						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+						return
+					}
+					h.Set(hContentSecurityPolicy, strings.Replace(policy, CSPNoncePlaceholder, nonce, -1))
+					r = r.WithContext(context.WithValue(r.Context(), model.CSPNonceKey, nonce))
+				} else {
+					h.Set(hContentSecurityPolicy, policy)
+				}
+			}
+			if len(hsts) != 0 && r.TLS != nil {
+				h.Set(hStrictTransportSecurity, hsts)
+			}
+			h.Set(hContentTypeOptions, "nosniff")
+			if len(opts.ReferrerPolicy) != 0 {
+				h.Set(hReferrerPolicy, opts.ReferrerPolicy)
+			}
+			if len(opts.PermissionsPolicy) != 0 {
+				h.Set(hPermissionsPolicy, opts.PermissionsPolicy)
+			}
+
+			next.ServeHTTP(w, r)
+
+		})
+	}
+
+}
+
+func appendDirective(policy, directive string) string {
+	policy = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(policy), ";"))
+	if len(policy) == 0 {
+		return directive
+	}
+	return policy + "; " + directive
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, cspNonceBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,128 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wallawire/model"
+	"wallawire/web/middleware"
+)
+
+func TestSecurityHeaders(b *testing.T) {
+
+	testCases := []struct {
+		Alias           string
+		Options         middleware.SecurityOptions
+		TLS             bool
+		ExpectedHeaders map[string]string
+		ExpectedNonce   bool
+	}{
+		{
+			Alias: "defaults over TLS",
+			Options: middleware.SecurityOptions{
+				ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}';",
+				FrameAncestors:        "'none'",
+				ReportURI:             "/api/csp-report",
+				HSTSMaxAge:            time.Hour,
+				ReferrerPolicy:        "no-referrer",
+				PermissionsPolicy:     "camera=()",
+			},
+			TLS: true,
+			ExpectedHeaders: map[string]string{
+				"Content-Security-Policy":   "default-src 'self'; script-src 'self' 'nonce-{nonce}'; frame-ancestors 'none'; report-uri /api/csp-report",
+				"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"Permissions-Policy":        "camera=()",
+			},
+			ExpectedNonce: true,
+		},
+		{
+			Alias: "no HSTS without TLS",
+			Options: middleware.SecurityOptions{
+				ContentSecurityPolicy: "default-src 'self'",
+				HSTSMaxAge:            time.Hour,
+			},
+			ExpectedHeaders: map[string]string{
+				"Content-Security-Policy":   "default-src 'self'",
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "",
+				"Permissions-Policy":        "",
+			},
+		},
+		{
+			Alias:   "empty options",
+			Options: middleware.SecurityOptions{},
+			TLS:     true,
+			ExpectedHeaders: map[string]string{
+				"Content-Security-Policy":   "",
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "nosniff",
+			},
+		},
+		{
+			Alias: "frame ancestors only",
+			Options: middleware.SecurityOptions{
+				FrameAncestors: "'self'",
+			},
+			ExpectedHeaders: map[string]string{
+				"Content-Security-Policy": "frame-ancestors 'self'",
+			},
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			var nonce string
+			handler := middleware.SecurityHeaders(tCase.Options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce = model.CSPNonceFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tCase.TLS {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := len(nonce) != 0, tCase.ExpectedNonce; got != want {
+				t.Fatalf("nonce %t, expected %t", got, want)
+			}
+			for key, value := range tCase.ExpectedHeaders {
+				want := strings.Replace(value, middleware.CSPNoncePlaceholder, nonce, -1)
+				if got := rec.Header().Get(key); got != want {
+					t.Errorf("bad header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestSecurityHeadersNonce(t *testing.T) {
+
+	var nonces []string
+	handler := middleware.SecurityHeaders(middleware.DefaultSecurityOptions)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, model.CSPNonceFromContext(r.Context()))
+	}))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if len(nonces[0]) == 0 || nonces[0] == nonces[1] {
+		t.Errorf("nonce must be new per request: %v", nonces)
+	}
+
+}
@@ -22,23 +22,74 @@ const (
 	hVary           = "Vary"
 )
 
+// CSPReportPath receives the Content-Security-Policy violation reports.
+const CSPReportPath = "/api/csp-report"
+
 type IdGenerator interface {
 	NewID() string
 }
 
 type Options struct {
-	Authenticator   []func(http.Handler) http.Handler
-	AuthorizerUsers func(http.Handler) http.Handler
-	ChangePassword  http.HandlerFunc
-	ChangeUsername  http.HandlerFunc
-	ChangeProfile   http.HandlerFunc
-	IdGenerator     IdGenerator // because composing middleware in router
-	Login           http.HandlerFunc
-	Logout          http.HandlerFunc
-	Notifier        http.HandlerFunc
-	Static          http.HandlerFunc
-	Status          http.HandlerFunc
-	Whoami          http.HandlerFunc
+	AdminAudit            http.HandlerFunc
+	AdminPush             http.HandlerFunc
+	AdminRoles            http.HandlerFunc
+	AdminRoleCreate       http.HandlerFunc
+	AdminRoleDelete       http.HandlerFunc
+	AdminRolePerms        http.HandlerFunc
+	AdminUsers            http.HandlerFunc
+	AdminUserCreate       http.HandlerFunc
+	AdminUserDelete       http.HandlerFunc
+	AdminUserDisable      http.HandlerFunc
+	AdminUserEnable       http.HandlerFunc
+	AdminUserPassword     http.HandlerFunc
+	AdminUserUnlock       http.HandlerFunc
+	AdminUserRoles        http.HandlerFunc
+	AdminUserGrant        http.HandlerFunc
+	AdminImpersonate      http.HandlerFunc
+	AdminUserRevoke       http.HandlerFunc
+	APITokens             http.HandlerFunc
+	APITokenCreate        http.HandlerFunc
+	APITokenRevoke        http.HandlerFunc
+	Authenticator         []func(http.Handler) http.Handler
+	AuthorizerAdmin       func(http.Handler) http.Handler // user management
+	AuthorizerAudit       func(http.Handler) http.Handler // audit log
+	AuthorizerImpersonate func(http.Handler) http.Handler // impersonation
+	AuthorizerRoles       func(http.Handler) http.Handler // role management
+	AuthorizerUsers       func(http.Handler) http.Handler // any signed in user
+	ChangePassword        http.HandlerFunc
+	ChangeUsername        http.HandlerFunc
+	ChangeProfile         http.HandlerFunc
+	CSPReport             http.HandlerFunc
+	IdGenerator           IdGenerator                     // because composing middleware in router
+	InboxConnections      func(http.Handler) http.Handler // caps the streams per user
+	JWKS                  http.HandlerFunc
+	Login                 http.HandlerFunc
+	LoginOTP              http.HandlerFunc
+	Logout                http.HandlerFunc
+	Notifier              http.HandlerFunc
+	NotifierTopics        http.HandlerFunc
+	NotifierWebSocket     http.HandlerFunc
+	OIDCLogin             http.HandlerFunc // optional
+	OIDCCallback          http.HandlerFunc // optional
+	PasswordForgot        http.HandlerFunc
+	RateLimitAccount      func(http.Handler) http.Handler // password reset and registration
+	RateLimitChange       func(http.Handler) http.Handler // change username, password and profile
+	RateLimitLogin        func(http.Handler) http.Handler // login and second factor
+	PasswordReset         http.HandlerFunc
+	Register              http.HandlerFunc
+	RegisterVerify        http.HandlerFunc
+	Sessions              http.HandlerFunc
+	SessionsDelete        http.HandlerFunc
+	SessionDelete         http.HandlerFunc
+	SecurityHeaders       func(http.Handler) http.Handler
+	SessionRequired       func(http.Handler) http.Handler // forbids API tokens
+	NotImpersonating      func(http.Handler) http.Handler // forbids impersonated sessions
+	Static                http.HandlerFunc
+	Status                http.HandlerFunc
+	TOTPEnroll            http.HandlerFunc
+	TOTPConfirm           http.HandlerFunc
+	TOTPDisable           http.HandlerFunc
+	Whoami                http.HandlerFunc
 }
 
 func Router(opts Options) (http.Handler, error) {
@@ -49,10 +100,12 @@ func Router(opts Options) (http.Handler, error) {
 
 	// global middleware
 	rMain.Use(wallaware.CorrelationID(opts.IdGenerator))
+	rMain.Use(wallaware.Client())
+	rMain.Use(opts.SecurityHeaders)
+	rMain.Use(wallaware.CSRF(CSPReportPath)) // browsers send reports without token
 	rMain.Use(accesslog.AccessHandler(accessLogger()))
 	rMain.Use(noCache)
 	rMain.Use(middleware.SetHeader(hVary, hAcceptEncoding))
-	// TODO security middleware
 	// TODO rMain.Use(compression)
 
 	// api router
@@ -63,18 +116,89 @@ func Router(opts Options) (http.Handler, error) {
 			rAuth.Use(opts.AuthorizerUsers)
 			rAuth.Group(func(rTimeout chi.Router) {
 				rTimeout.Use(middleware.Timeout(time.Second * 60))
-				rTimeout.Post("/changepassword", opts.ChangePassword)
-				rTimeout.Post("/changeusername", opts.ChangeUsername)
-				rTimeout.Post("/changeprofile", opts.ChangeProfile)
 				rTimeout.Get("/whoami", opts.Whoami)
+				rTimeout.With(opts.SessionRequired).Post("/inbox/topics", opts.NotifierTopics)
+				rTimeout.Group(func(rSession chi.Router) {
+					rSession.Use(opts.SessionRequired)
+					rSession.Use(opts.NotImpersonating)
+					rSession.Group(func(rChange chi.Router) {
+						rChange.Use(opts.RateLimitChange)
+						rChange.Post("/changepassword", opts.ChangePassword)
+						rChange.Post("/changeusername", opts.ChangeUsername)
+						rChange.Post("/changeprofile", opts.ChangeProfile)
+					})
+					rSession.Get("/sessions", opts.Sessions)
+					rSession.Delete("/sessions", opts.SessionsDelete)
+					rSession.Delete("/sessions/{sessionID}", opts.SessionDelete)
+					rSession.Post("/totp/enroll", opts.TOTPEnroll)
+					rSession.Post("/totp/confirm", opts.TOTPConfirm)
+					rSession.Post("/totp/disable", opts.TOTPDisable)
+					rSession.Get("/apitokens", opts.APITokens)
+					rSession.Post("/apitokens", opts.APITokenCreate)
+					rSession.Delete("/apitokens/{apiTokenID}", opts.APITokenRevoke)
+				})
+				rTimeout.Group(func(rAdmin chi.Router) {
+					rAdmin.Use(opts.AuthorizerAdmin)
+					rAdmin.Get("/admin/users", opts.AdminUsers)
+					rAdmin.Post("/admin/users", opts.AdminUserCreate)
+					rAdmin.Delete("/admin/users/{userID}", opts.AdminUserDelete)
+					rAdmin.Post("/admin/users/{userID}/disable", opts.AdminUserDisable)
+					rAdmin.Post("/admin/users/{userID}/enable", opts.AdminUserEnable)
+					rAdmin.Post("/admin/users/{userID}/password", opts.AdminUserPassword)
+					rAdmin.Post("/admin/users/{userID}/unlock", opts.AdminUserUnlock)
+					rAdmin.Get("/admin/users/{userID}/roles", opts.AdminUserRoles)
+					rAdmin.Put("/admin/users/{userID}/roles/{roleID}", opts.AdminUserGrant)
+					rAdmin.Delete("/admin/users/{userID}/roles/{roleID}", opts.AdminUserRevoke)
+					rAdmin.Get("/admin/roles", opts.AdminRoles) // to grant roles
+					rAdmin.Get("/admin/push", opts.AdminPush)
+				})
+				rTimeout.Group(func(rRoles chi.Router) {
+					rRoles.Use(opts.AuthorizerRoles)
+					rRoles.Post("/admin/roles", opts.AdminRoleCreate)
+					rRoles.Delete("/admin/roles/{roleID}", opts.AdminRoleDelete)
+					rRoles.Put("/admin/roles/{roleID}/permissions", opts.AdminRolePerms)
+				})
+				rTimeout.Group(func(rImpersonate chi.Router) {
+					rImpersonate.Use(opts.AuthorizerImpersonate)
+					rImpersonate.Use(opts.SessionRequired)
+					rImpersonate.Use(opts.NotImpersonating)
+					rImpersonate.Post("/admin/users/{userID}/impersonate", opts.AdminImpersonate)
+				})
+				rTimeout.Group(func(rAudit chi.Router) {
+					rAudit.Use(opts.AuthorizerAudit)
+					rAudit.Get("/admin/audit", opts.AdminAudit)
+				})
+			})
+			rAuth.Group(func(rInbox chi.Router) {
+				// no timeout
+				// an inbox belongs to a session, API tokens have none and would share one inbox
+				rInbox.Use(opts.SessionRequired)
+				rInbox.Use(opts.InboxConnections)
+				rInbox.Get("/inbox", opts.Notifier)
+				rInbox.Get("/inbox/ws", opts.NotifierWebSocket)
 			})
-			rAuth.Get("/inbox", opts.Notifier) // no timeout
 		})
 
-		rApi.Post("/login", opts.Login)
-		rApi.Get("/logout", opts.Logout)
-		rApi.Post("/logout", opts.Logout)
+		rApi.Post("/csp-report", opts.CSPReport)
+		rApi.Post("/logout", opts.Logout) // clears the cookie of expired sessions as well
+		rApi.Group(func(rLogin chi.Router) {
+			rLogin.Use(opts.RateLimitLogin)
+			rLogin.Post("/login", opts.Login)
+			rLogin.Post("/login/otp", opts.LoginOTP)
+		})
+		if opts.OIDCLogin != nil && opts.OIDCCallback != nil {
+			rApi.Get("/oidc/login", opts.OIDCLogin)
+			rApi.Get("/oidc/callback", opts.OIDCCallback)
+		}
+		rApi.Group(func(rAccount chi.Router) {
+			rAccount.Use(opts.RateLimitAccount)
+			rAccount.Post("/password/forgot", opts.PasswordForgot)
+			rAccount.Post("/password/reset", opts.PasswordReset)
+			rAccount.Post("/register", opts.Register)
+		})
+		rApi.Get("/register/verify", opts.RegisterVerify)
 		rApi.Get("/status", opts.Status)
+		rApi.Get("/.well-known/jwks.json", opts.JWKS)
 		rApi.NotFound(sendMessageHandler(http.StatusNotFound))
 		rApi.MethodNotAllowed(sendMessageHandler(http.StatusMethodNotAllowed))
 	})
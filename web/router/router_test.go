@@ -0,0 +1,355 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+	"wallawire/web/middleware"
+	"wallawire/web/router"
+)
+
+type IdGeneratorMock struct{}
+
+func (z *IdGeneratorMock) NewID() string {
+	return "id"
+}
+
+func TestRouterCSRF(b *testing.T) {
+
+	const token = "csrf-token"
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	pass := func(next http.Handler) http.Handler {
+		return next
+	}
+
+	handler, err := router.Router(router.Options{
+		AuthorizerAdmin:       pass,
+		AuthorizerAudit:       pass,
+		AuthorizerImpersonate: pass,
+		AuthorizerRoles:       pass,
+		AuthorizerUsers:       pass,
+		ChangePassword:        ok,
+		ChangeUsername:        ok,
+		ChangeProfile:         ok,
+		IdGenerator:           &IdGeneratorMock{},
+		InboxConnections:      pass,
+		CSPReport:             ok,
+		Logout:                ok,
+		NotImpersonating:      pass,
+		RateLimitAccount:      pass,
+		RateLimitChange:       pass,
+		RateLimitLogin:        pass,
+		SecurityHeaders:       pass,
+		SessionRequired:       pass,
+		Static:                ok,
+	})
+	if err != nil {
+		b.Fatalf("cannot create router: %s", err.Error())
+	}
+
+	sessionCookie := &http.Cookie{Name: "jwt", Value: "session"}
+	csrfCookie := &http.Cookie{Name: middleware.CSRFCookieName, Value: token}
+
+	testCases := []struct {
+		Alias          string
+		Method         string
+		Path           string
+		Cookies        []*http.Cookie
+		Header         string
+		ExpectedStatus int
+	}{
+		{
+			Alias:          "changepassword cross-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changepassword",
+			Cookies:        []*http.Cookie{sessionCookie},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "changepassword mismatched token",
+			Method:         http.MethodPost,
+			Path:           "/api/changepassword",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			Header:         "other-token",
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "changepassword same-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changepassword",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			Header:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "changeusername cross-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changeusername",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "changeusername same-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changeusername",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			Header:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "changeprofile cross-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changeprofile",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "changeprofile same-site",
+			Method:         http.MethodPost,
+			Path:           "/api/changeprofile",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			Header:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "logout cross-site",
+			Method:         http.MethodPost,
+			Path:           "/api/logout",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Alias:          "logout same-site",
+			Method:         http.MethodPost,
+			Path:           "/api/logout",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			Header:         token,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "csp report without token",
+			Method:         http.MethodPost,
+			Path:           router.CSPReportPath,
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Alias:          "logout GET not allowed",
+			Method:         http.MethodGet,
+			Path:           "/api/logout",
+			Cookies:        []*http.Cookie{sessionCookie, csrfCookie},
+			ExpectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			req := httptest.NewRequest(tCase.Method, tCase.Path, nil)
+			for _, c := range tCase.Cookies {
+				req.AddCookie(c)
+			}
+			if len(tCase.Header) != 0 {
+				req.Header.Set(middleware.CSRFHeaderName, tCase.Header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tCase.ExpectedStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRouterRateLimits(b *testing.T) {
+
+	const hLimit = "X-Limit"
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	pass := func(next http.Handler) http.Handler {
+		return next
+	}
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add(hLimit, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler, err := router.Router(router.Options{
+		AuthorizerAdmin:       pass,
+		AuthorizerAudit:       pass,
+		AuthorizerImpersonate: pass,
+		AuthorizerRoles:       pass,
+		AuthorizerUsers:       pass,
+		ChangePassword:        ok,
+		ChangeUsername:        ok,
+		ChangeProfile:         ok,
+		IdGenerator:           &IdGeneratorMock{},
+		InboxConnections:      tag("inbox"),
+		Login:                 ok,
+		LoginOTP:              ok,
+		Notifier:              ok,
+		NotifierTopics:        ok,
+		NotifierWebSocket:     ok,
+		NotImpersonating:      pass,
+		PasswordForgot:        ok,
+		PasswordReset:         ok,
+		RateLimitAccount:      tag("account"),
+		RateLimitChange:       tag("change"),
+		RateLimitLogin:        tag("login"),
+		Register:              ok,
+		SecurityHeaders:       pass,
+		SessionRequired:       pass,
+		Sessions:              ok,
+		Static:                ok,
+		Whoami:                ok,
+	})
+	if err != nil {
+		b.Fatalf("cannot create router: %s", err.Error())
+	}
+
+	testCases := []struct {
+		Alias         string
+		Method        string
+		Path          string
+		ExpectedLimit string
+	}{
+		{Alias: "login", Method: http.MethodPost, Path: "/api/login", ExpectedLimit: "login"},
+		{Alias: "login otp", Method: http.MethodPost, Path: "/api/login/otp", ExpectedLimit: "login"},
+		{Alias: "password forgot", Method: http.MethodPost, Path: "/api/password/forgot", ExpectedLimit: "account"},
+		{Alias: "password reset", Method: http.MethodPost, Path: "/api/password/reset", ExpectedLimit: "account"},
+		{Alias: "register", Method: http.MethodPost, Path: "/api/register", ExpectedLimit: "account"},
+		{Alias: "changepassword", Method: http.MethodPost, Path: "/api/changepassword", ExpectedLimit: "change"},
+		{Alias: "changeusername", Method: http.MethodPost, Path: "/api/changeusername", ExpectedLimit: "change"},
+		{Alias: "changeprofile", Method: http.MethodPost, Path: "/api/changeprofile", ExpectedLimit: "change"},
+		{Alias: "inbox", Method: http.MethodGet, Path: "/api/inbox", ExpectedLimit: "inbox"},
+		{Alias: "inbox websocket", Method: http.MethodGet, Path: "/api/inbox/ws", ExpectedLimit: "inbox"},
+		{Alias: "sessions", Method: http.MethodGet, Path: "/api/sessions", ExpectedLimit: ""},
+		{Alias: "whoami", Method: http.MethodGet, Path: "/api/whoami", ExpectedLimit: ""},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			req := httptest.NewRequest(tCase.Method, tCase.Path, nil)
+			req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: "token"})
+			req.Header.Set(middleware.CSRFHeaderName, "token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+			if got, want := strings.Join(rec.Header()[hLimit], ","), tCase.ExpectedLimit; got != want {
+				t.Errorf("bad limit %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestRouterInboxSessionRequired(b *testing.T) {
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	pass := func(next http.Handler) http.Handler {
+		return next
+	}
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := model.SessionToken{ID: "u1", SessionID: r.Header.Get("X-Session"), APITokenID: r.Header.Get("X-APIToken")}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), model.UserKey, token)))
+		})
+	}
+
+	handler, err := router.Router(router.Options{
+		Authenticator:         []func(http.Handler) http.Handler{authenticate},
+		AuthorizerAdmin:       pass,
+		AuthorizerAudit:       pass,
+		AuthorizerImpersonate: pass,
+		AuthorizerRoles:       pass,
+		AuthorizerUsers:       pass,
+		IdGenerator:           &IdGeneratorMock{},
+		InboxConnections:      pass,
+		Notifier:              ok,
+		NotifierTopics:        ok,
+		NotifierWebSocket:     ok,
+		NotImpersonating:      pass,
+		RateLimitAccount:      pass,
+		RateLimitChange:       pass,
+		RateLimitLogin:        pass,
+		SecurityHeaders:       pass,
+		SessionRequired:       auth.NewSessionRequired(),
+		Static:                ok,
+	})
+	if err != nil {
+		b.Fatalf("cannot create router: %s", err.Error())
+	}
+
+	testCases := []struct {
+		Alias          string
+		Method         string
+		Path           string
+		APITokenID     string
+		ExpectedStatus int
+	}{
+		{Alias: "session sse", Method: http.MethodGet, Path: "/api/inbox?subscribe=audit", ExpectedStatus: http.StatusOK},
+		{Alias: "session websocket", Method: http.MethodGet, Path: "/api/inbox/ws", ExpectedStatus: http.StatusOK},
+		{Alias: "session topics", Method: http.MethodPost, Path: "/api/inbox/topics?subscribe=audit", ExpectedStatus: http.StatusOK},
+		{Alias: "api token sse", Method: http.MethodGet, Path: "/api/inbox?subscribe=audit", APITokenID: "t1", ExpectedStatus: http.StatusForbidden},
+		{Alias: "api token websocket", Method: http.MethodGet, Path: "/api/inbox/ws", APITokenID: "t1", ExpectedStatus: http.StatusForbidden},
+		{Alias: "api token topics", Method: http.MethodPost, Path: "/api/inbox/topics?subscribe=audit", APITokenID: "t1", ExpectedStatus: http.StatusForbidden},
+	}
+
+	for _, tCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			req := httptest.NewRequest(tCase.Method, tCase.Path, nil)
+			req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: "token"})
+			req.Header.Set(middleware.CSRFHeaderName, "token")
+			if len(tCase.APITokenID) != 0 {
+				req.Header.Set("X-APIToken", tCase.APITokenID)
+			} else {
+				req.Header.Set("X-Session", "s1")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tCase.ExpectedStatus; got != want {
+				t.Errorf("bad status %d, expected %d", got, want)
+			}
+
+		} // fn
+
+		b.Run(tCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"context"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type ListSessionsService interface {
+	ListSessions(context.Context, model.ListSessionsRequest) model.ListSessionsResponse
+}
+
+// List returns the active sessions of the current user.
+func List(sessionService ListSessionsService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ListSessionsHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		rsp := sessionService.ListSessions(ctx, model.ListSessionsRequest{
+			UserID:    sessionToken.ID,
+			SessionID: sessionToken.SessionID,
+		})
+
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, rsp.Sessions)
+
+	})
+}
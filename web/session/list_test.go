@@ -0,0 +1,193 @@
+package session_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+	"wallawire/web/session"
+)
+
+func TestList(b *testing.T) {
+
+	now := time.Date(2019, time.March, 16, 12, 0, 0, 0, time.UTC)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Truncate(time.Minute),
+		Expires:   time.Now().Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		OutputResponse  model.ListSessionsResponse
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+	}{
+		{
+			Alias: "success",
+			Path:  "/sessions",
+			OutputResponse: model.ListSessionsResponse{
+				Code: http.StatusOK,
+				Sessions: []model.SessionInfo{
+					{
+						ID:        "S123",
+						Device:    "curl",
+						UserAgent: "curl/7.58.0",
+						IPAddress: "10.0.0.1",
+						Created:   now,
+						LastSeen:  now,
+						Expires:   now.Add(model.LoginTimeout),
+						Current:   true,
+						Connected: true,
+					},
+				},
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "212",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`[{"id":"S123","device":"curl","userAgent":"curl/7.58.0","ipAddress":"10.0.0.1","created":"2019-03-16T12:00:00Z","lastSeen":"2019-03-16T12:00:00Z","expires":"2019-03-17T12:00:00Z","current":true,"connected":true}]`),
+		},
+		{
+			Alias:          "unauthorized",
+			Path:           "/sessions",
+			OutputResponse: model.ListSessionsResponse{},
+			RequestMethod:  http.MethodGet,
+			RequestHeaders: nil,
+			RequestBody:    nil,
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength:           "13",
+				hContentType:             mimeTypeText,
+				hDate:                    ignoreValue,
+				"X-Content-Type-Options": ignoreValue,
+			},
+			ResponseBody: []byte("Unauthorized\n"),
+		},
+		{
+			Alias: "any backend error",
+			Path:  "/sessions",
+			OutputResponse: model.ListSessionsResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "any old error",
+			},
+			RequestMethod: http.MethodGet,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseHeaders: map[string]string{
+				hContentLength: "44",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":500,"message":"any old error"}`),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &SessionServiceMock{
+				ListSessionsResponse: testCase.OutputResponse,
+			}
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, ss)...)
+			handler.Get("/sessions", session.List(ss))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
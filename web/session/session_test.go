@@ -0,0 +1,82 @@
+package session_test
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Verbose() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	os.Exit(m.Run())
+}
+
+const (
+	ignoreValue    = "XXX"
+	hContentLength = "Content-Length"
+	hContentType   = "Content-Type"
+	hCookie        = "Cookie"
+	hDate          = "Date"
+	mimeTypeJson   = "application/json"
+	mimeTypeText   = "text/plain; charset=utf-8"
+	testPassword   = "secret"
+)
+
+var (
+	testKeys = auth.NewHMACKeys(testPassword)
+)
+
+func getCookieString(user *model.SessionToken, keys *auth.Keys) string {
+	r, err := auth.MakeJWT(user, keys)
+	if err != nil {
+		panic(err)
+	}
+	c := &http.Cookie{
+		Name:    auth.CookieName,
+		Value:   r,
+		Expires: user.Expires,
+		Path:    "/",
+		Secure:  true,
+	}
+	return c.String()
+}
+
+type SessionServiceMock struct {
+	ListSessionsResponse           model.ListSessionsResponse
+	TerminateSessionResponse       model.TerminateSessionResponse
+	TerminateOtherSessionsResponse model.TerminateOtherSessionsResponse
+	TerminateSessionRequest        model.TerminateSessionRequest
+}
+
+func (z *SessionServiceMock) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (z *SessionServiceMock) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+	return nil, nil
+}
+
+func (z *SessionServiceMock) ListSessions(ctx context.Context, req model.ListSessionsRequest) model.ListSessionsResponse {
+	return z.ListSessionsResponse
+}
+
+func (z *SessionServiceMock) TerminateSession(ctx context.Context, req model.TerminateSessionRequest) model.TerminateSessionResponse {
+	z.TerminateSessionRequest = req
+	return z.TerminateSessionResponse
+}
+
+func (z *SessionServiceMock) TerminateOtherSessions(ctx context.Context, req model.TerminateOtherSessionsRequest) model.TerminateOtherSessionsResponse {
+	return z.TerminateOtherSessionsResponse
+}
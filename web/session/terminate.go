@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+const (
+	ParamSessionID = "sessionID"
+)
+
+type TerminateSessionService interface {
+	TerminateSession(context.Context, model.TerminateSessionRequest) model.TerminateSessionResponse
+}
+
+type TerminateOtherSessionsService interface {
+	TerminateOtherSessions(context.Context, model.TerminateOtherSessionsRequest) model.TerminateOtherSessionsResponse
+}
+
+// Terminate revokes a single session, identified by the sessionID URL parameter, of the current user.
+func Terminate(sessionService TerminateSessionService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "TerminateSessionHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		sessionID := chi.URLParam(r, ParamSessionID)
+		if len(sessionID) == 0 {
+			msg := "missing session id"
+			logger.Debug().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+			return
+		}
+
+		rsp := sessionService.TerminateSession(ctx, model.TerminateSessionRequest{
+			UserID:           sessionToken.ID,
+			SessionID:        sessionID,
+			CurrentSessionID: sessionToken.SessionID,
+		})
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// TerminateOthers revokes all sessions of the current user except the session making the request.
+func TerminateOthers(sessionService TerminateOtherSessionsService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "TerminateOtherSessionsHandler")
+		logger.Debug().Msg("invoked")
+
+		sessionToken := model.TokenFromContext(ctx)
+		if len(sessionToken.ID) == 0 {
+			msg := "cannot retrieve user from context"
+			logger.Error().Msg(msg)
+			sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+			return
+		}
+
+		rsp := sessionService.TerminateOtherSessions(ctx, model.TerminateOtherSessionsRequest{
+			UserID:    sessionToken.ID,
+			SessionID: sessionToken.SessionID,
+		})
+
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Count int `json:"count"`
+		}{
+			Count: rsp.Count,
+		})
+
+	})
+}
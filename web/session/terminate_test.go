@@ -0,0 +1,351 @@
+package session_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+	"wallawire/web/session"
+)
+
+func TestTerminate(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Truncate(time.Minute),
+		Expires:   now.Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias             string
+		Path              string
+		OutputResponse    model.TerminateSessionResponse
+		RequestMethod     string
+		RequestHeaders    map[string]string
+		RequestBody       []byte
+		ResponseStatus    int
+		ResponseHeaders   map[string]string
+		ResponseBody      []byte
+		ExpectedSessionID string
+	}{
+		{
+			Alias: "success",
+			Path:  "/sessions/S456",
+			OutputResponse: model.TerminateSessionResponse{
+				Code: http.StatusOK,
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody:      []byte(`{"statusCode":200,"message":"OK"}`),
+			ExpectedSessionID: "S456",
+		},
+		{
+			Alias:          "unauthorized",
+			Path:           "/sessions/S456",
+			OutputResponse: model.TerminateSessionResponse{},
+			RequestMethod:  http.MethodDelete,
+			RequestHeaders: nil,
+			RequestBody:    nil,
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength:           "13",
+				hContentType:             mimeTypeText,
+				hDate:                    ignoreValue,
+				"X-Content-Type-Options": ignoreValue,
+			},
+			ResponseBody: []byte("Unauthorized\n"),
+		},
+		{
+			Alias: "not found",
+			Path:  "/sessions/S456",
+			OutputResponse: model.TerminateSessionResponse{
+				Code:    http.StatusNotFound,
+				Message: "session not found",
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusNotFound,
+			ResponseHeaders: map[string]string{
+				hContentLength: "48",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody:      []byte(`{"statusCode":404,"message":"session not found"}`),
+			ExpectedSessionID: "S456",
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &SessionServiceMock{
+				TerminateSessionResponse: testCase.OutputResponse,
+			}
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, ss)...)
+			handler.Delete("/sessions/{sessionID}", session.Terminate(ss))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+			if got, want := ss.TerminateSessionRequest.SessionID, testCase.ExpectedSessionID; got != want {
+				t.Errorf("Bad session id: %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
+
+func TestTerminateOthers(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Truncate(time.Minute),
+		Expires:   now.Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		OutputResponse  model.TerminateOtherSessionsResponse
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+	}{
+		{
+			Alias: "success",
+			Path:  "/sessions",
+			OutputResponse: model.TerminateOtherSessionsResponse{
+				Code:  http.StatusOK,
+				Count: 2,
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "11",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"count":2}`),
+		},
+		{
+			Alias:          "unauthorized",
+			Path:           "/sessions",
+			OutputResponse: model.TerminateOtherSessionsResponse{},
+			RequestMethod:  http.MethodDelete,
+			RequestHeaders: nil,
+			RequestBody:    nil,
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength:           "13",
+				hContentType:             mimeTypeText,
+				hDate:                    ignoreValue,
+				"X-Content-Type-Options": ignoreValue,
+			},
+			ResponseBody: []byte("Unauthorized\n"),
+		},
+		{
+			Alias: "any backend error",
+			Path:  "/sessions",
+			OutputResponse: model.TerminateOtherSessionsResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "any old error",
+			},
+			RequestMethod: http.MethodDelete,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    nil,
+			ResponseStatus: http.StatusInternalServerError,
+			ResponseHeaders: map[string]string{
+				hContentLength: "44",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":500,"message":"any old error"}`),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			ss := &SessionServiceMock{
+				TerminateOtherSessionsResponse: testCase.OutputResponse,
+			}
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, ss)...)
+			handler.Delete("/sessions", session.TerminateOthers(ss))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
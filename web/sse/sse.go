@@ -1,9 +1,11 @@
 package sse
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"sync/atomic"
+	"strconv"
 
 	"wallawire/logging"
 	"wallawire/model"
@@ -12,15 +14,27 @@ import (
 const (
 	hCacheControl       = "Cache-Control"
 	hConnection         = "Connection"
+	hContentLength      = "Content-Length"
 	hContentType        = "Content-Type"
+	hLastEventID        = "Last-Event-ID"
 	cacheNoCache        = "no-cache"
 	connectionKeepAlive = "keep-alive"
 	mimetypeEventStream = "text/event-stream"
+	mimeTypeJson        = "application/json"
+	pSubscribe          = "subscribe"
+	pUnsubscribe        = "unsubscribe"
+	retryMillis         = 3000
 )
 
 type PushMessenger interface {
-	ConnectClient(userID, sessionID string, client chan model.PushMessage)
-	DisconnectClient(userID, sessionID string)
+	ConnectClient(userID, sessionID, lastEventID string) (<-chan model.PushMessage, func())
+	Subscribe(token model.SessionToken, topic string) error
+	Unsubscribe(userID, sessionID, topic string)
+	Topics(userID, sessionID string) []string
+}
+
+type topicsResponse struct {
+	Topics []string `json:"topics"`
 }
 
 func Handler(pushMessenger PushMessenger) http.HandlerFunc {
@@ -40,44 +54,47 @@ func Handler(pushMessenger PushMessenger) http.HandlerFunc {
 			return
 		}
 
+		// the messages are queued by the messenger, it closes them when it disconnects the client
+		// a reconnecting browser sends the ID of the last message it has seen, the messages missed since are queued first
+		messages, disconnect := pushMessenger.ConnectClient(token.ID, token.SessionID, r.Header.Get(hLastEventID))
+		defer disconnect()
+
+		// the browser reconnects with the same url, so the topics are subscribed again
+		for _, topic := range r.URL.Query()[pSubscribe] {
+			if err := pushMessenger.Subscribe(token, topic); err != nil {
+				logger.Info().Err(err).Str("topic", topic).Msg("cannot subscribe")
+				sendJsonMessage(ctx, w, topicErrorStatus(err), err.Error())
+				return
+			}
+		}
+
 		w.Header().Set(hContentType, mimetypeEventStream)
 		w.Header().Set(hCacheControl, cacheNoCache)
 		w.Header().Set(hConnection, connectionKeepAlive)
 
-		// define the message channel
-		messageChan := make(chan model.PushMessage)
-
-		// add message channel to message pushMessenger
-		pushMessenger.ConnectClient(token.ID, token.SessionID, messageChan)
-		var closed int32
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", retryMillis); err != nil {
+			logger.Error().Err(err).Msg("error sending retry to client")
+			return
+		}
+		flusher.Flush()
 
-		// failsafe: remove connection upon exit of handler
-		// probably already called on request close (below) when this is triggered
-		defer func() {
-			if isClosed := atomic.LoadInt32(&closed); isClosed == 0 {
-				pushMessenger.DisconnectClient(token.ID, token.SessionID)
-			}
-		}()
+		// read until the client closes the request or the messenger closes the messages
+		for {
 
-		// clean close: remove connection when client closes request
-		go func() {
+			var msg model.PushMessage
 			select {
+			case m, ok := <-messages:
+				if !ok {
+					return
+				}
+				msg = m
 			case <-ctx.Done():
-				pushMessenger.DisconnectClient(token.ID, token.SessionID)
-				atomic.AddInt32(&closed, 1)
+				return
 			}
-		}()
-
-		// if _, err := fmt.Fprintf(w, "retry: %d\n\n", 3000); err != nil {
-		// 	logger.Error().Err(err).Msg("error sending message to client")
-		// }
-
-		// read until channel is closed
-		// pushMessenger.CloseClient will close the messageChannel to break out of the loop
-		for msg := range messageChan {
 
 			// see https://hpbn.co/server-sent-events-sse/#event-stream-protocol
-			if len(msg.ID) != 0 {
+			// the browser keeps the last ID it has seen for messages without one, so transient ones must not move it
+			if len(msg.ID) != 0 && !msg.Transient {
 				if _, err := fmt.Fprintf(w, "id: %s\n", msg.ID); err != nil {
 					logger.Error().Err(err).Interface("message", msg).Msg("error sending message id client")
 					continue
@@ -100,3 +117,80 @@ func Handler(pushMessenger PushMessenger) http.HandlerFunc {
 	})
 
 }
+
+// Topics changes the topics of the connected inbox of the session with the subscribe and unsubscribe parameters
+// and returns the topics subscribed.
+func Topics(pushMessenger PushMessenger) http.HandlerFunc {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "web", "Topics")
+		token := model.TokenFromContext(ctx)
+		query := r.URL.Query()
+
+		for _, topic := range query[pUnsubscribe] {
+			pushMessenger.Unsubscribe(token.ID, token.SessionID, topic)
+		}
+		for _, topic := range query[pSubscribe] {
+			if err := pushMessenger.Subscribe(token, topic); err != nil {
+				logger.Info().Err(err).Str("topic", topic).Msg("cannot subscribe")
+				sendJsonMessage(ctx, w, topicErrorStatus(err), err.Error())
+				return
+			}
+		}
+
+		sendJson(ctx, w, http.StatusOK, topicsResponse{
+			Topics: pushMessenger.Topics(token.ID, token.SessionID),
+		})
+
+	})
+
+}
+
+func topicErrorStatus(err error) int {
+	if model.IsNotFoundError(err) {
+		return http.StatusNotFound
+	}
+	if model.IsForbiddenError(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func sendJson(ctx context.Context, w http.ResponseWriter, statusCode int, payload interface{}) {
+	logger := logging.New(ctx, "sendJson")
+	msg, errMsg := json.Marshal(payload)
+	if errMsg != nil {
+		logger.Error().Err(errMsg).Msg("Cannot marshal json payload")
+		sendJsonMessage(ctx, w, http.StatusInternalServerError, "")
+		return
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
+
+func sendJsonMessage(ctx context.Context, w http.ResponseWriter, statusCode int, message string) {
+	logger := logging.New(ctx, "sendJsonMessage")
+	if len(message) == 0 {
+		message = http.StatusText(statusCode)
+	}
+	errmsg := struct {
+		StatusCode int    `json:"statusCode"`
+		Message    string `json:"message,omitempty"`
+	}{
+		StatusCode: statusCode,
+		Message:    message,
+	}
+	msg, errMsg := json.Marshal(&errmsg)
+	if errMsg != nil {
+		logger.Error().Err(errMsg).Msg("Cannot marshal json error message")
+		msg = []byte("{}")
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
@@ -0,0 +1,234 @@
+package sse_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"wallawire/model"
+	"wallawire/web/sse"
+)
+
+type PushMessengerMock struct {
+	Messages     []model.PushMessage
+	UserID       string
+	SessionID    string
+	LastEventID  string
+	Disconnected bool
+	Subscribed   []string
+}
+
+func (z *PushMessengerMock) ConnectClient(userID, sessionID, lastEventID string) (<-chan model.PushMessage, func()) {
+	z.UserID = userID
+	z.SessionID = sessionID
+	z.LastEventID = lastEventID
+	messages := make(chan model.PushMessage, len(z.Messages))
+	for _, msg := range z.Messages {
+		messages <- msg
+	}
+	close(messages)
+	return messages, func() {
+		z.Disconnected = true
+	}
+}
+
+func (z *PushMessengerMock) Subscribe(token model.SessionToken, topic string) error {
+	switch topic {
+	case "secret":
+		return model.NewForbiddenError("forbidden topic " + topic)
+	case "news":
+		z.Subscribed = append(z.Subscribed, topic)
+		return nil
+	}
+	return model.NewNotFoundError("unknown topic " + topic)
+}
+
+func (z *PushMessengerMock) Unsubscribe(userID, sessionID, topic string) {
+	for i, t := range z.Subscribed {
+		if t == topic {
+			z.Subscribed = append(z.Subscribed[:i], z.Subscribed[i+1:]...)
+			return
+		}
+	}
+}
+
+func (z *PushMessengerMock) Topics(userID, sessionID string) []string {
+	return append([]string{}, z.Subscribed...)
+}
+
+func TestHandler(b *testing.T) {
+
+	type testCase struct {
+		Alias          string
+		Query          string
+		LastEventID    string
+		Messages       []model.PushMessage
+		ResponseStatus int
+		ResponseType   string
+		ResponseBody   string
+		Subscribed     []string
+	}
+
+	testCases := []testCase{
+		{
+			Alias:          "empty",
+			ResponseStatus: http.StatusOK,
+			ResponseType:   "text/event-stream",
+			ResponseBody:   "retry: 3000\n\n",
+		},
+		{
+			Alias: "messages",
+			Messages: []model.PushMessage{
+				{ID: "11", Type: "notification", Data: "hello"},
+				{Type: model.PushTypeDisconnect, Data: "slow consumer"},
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseType:   "text/event-stream",
+			ResponseBody:   "retry: 3000\n\nid: 11\nevent: notification\ndata: hello\n\nevent: disconnect\ndata: slow consumer\n\n",
+		},
+		{
+			Alias:       "resume",
+			LastEventID: "10",
+			Messages: []model.PushMessage{
+				{ID: "11", Type: "notification", Data: "missed"},
+				{ID: "12", Type: "notification", Data: "live"},
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseType:   "text/event-stream",
+			ResponseBody:   "retry: 3000\n\nid: 11\nevent: notification\ndata: missed\n\nid: 12\nevent: notification\ndata: live\n\n",
+		},
+		{
+			Alias: "transient",
+			Messages: []model.PushMessage{
+				{ID: "12", Type: "heartbeat", Data: "{}", Transient: true},
+			},
+			ResponseStatus: http.StatusOK,
+			ResponseType:   "text/event-stream",
+			ResponseBody:   "retry: 3000\n\nevent: heartbeat\ndata: {}\n\n",
+		},
+		{
+			Alias:          "subscribe",
+			Query:          "?subscribe=news",
+			ResponseStatus: http.StatusOK,
+			ResponseType:   "text/event-stream",
+			ResponseBody:   "retry: 3000\n\n",
+			Subscribed:     []string{"news"},
+		},
+		{
+			Alias:          "subscribe forbidden",
+			Query:          "?subscribe=news&subscribe=secret",
+			ResponseStatus: http.StatusForbidden,
+			ResponseType:   "application/json",
+			ResponseBody:   `{"statusCode":403,"message":"forbidden topic secret"}`,
+			Subscribed:     []string{"news"},
+		},
+		{
+			Alias:          "subscribe unknown",
+			Query:          "?subscribe=other",
+			ResponseStatus: http.StatusNotFound,
+			ResponseType:   "application/json",
+			ResponseBody:   `{"statusCode":404,"message":"unknown topic other"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tCase := tc
+		testFn := func(t *testing.T) {
+
+			mock := &PushMessengerMock{Messages: tCase.Messages}
+			token := model.SessionToken{ID: "u1", SessionID: "s1"}
+
+			req := httptest.NewRequest(http.MethodGet, "/inbox"+tCase.Query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), model.UserKey, token))
+			if len(tCase.LastEventID) != 0 {
+				req.Header.Set("Last-Event-ID", tCase.LastEventID)
+			}
+			w := httptest.NewRecorder()
+
+			sse.Handler(mock).ServeHTTP(w, req)
+
+			if got, want := w.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status: %d, expected %d", got, want)
+			}
+			if got, want := w.Header().Get("Content-Type"), tCase.ResponseType; got != want {
+				t.Errorf("bad content type: %s, expected %s", got, want)
+			}
+			if got, want := w.Body.String(), tCase.ResponseBody; got != want {
+				t.Errorf("bad response body: %q, expected %q", got, want)
+			}
+			if mock.UserID != token.ID || mock.SessionID != token.SessionID {
+				t.Errorf("bad client: %s %s", mock.UserID, mock.SessionID)
+			}
+			if mock.LastEventID != tCase.LastEventID {
+				t.Errorf("bad last event id: %s, expected %s", mock.LastEventID, tCase.LastEventID)
+			}
+			if !mock.Disconnected {
+				t.Error("client not disconnected")
+			}
+			if got, want := mock.Subscribed, tCase.Subscribed; !reflect.DeepEqual(got, want) {
+				t.Errorf("bad topics: %v, expected %v", got, want)
+			}
+
+		} // fn
+		b.Run(tCase.Alias, testFn)
+	} // cases
+
+}
+
+func TestTopics(b *testing.T) {
+
+	testCases := []struct {
+		Alias          string
+		Query          string
+		Subscribed     []string
+		ResponseStatus int
+		ResponseBody   string
+	}{
+		{
+			Alias:          "subscribe",
+			Query:          "?subscribe=news",
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   `{"topics":["news"]}`,
+		},
+		{
+			Alias:          "unsubscribe",
+			Query:          "?unsubscribe=news",
+			Subscribed:     []string{"news"},
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   `{"topics":[]}`,
+		},
+		{
+			Alias:          "forbidden",
+			Query:          "?subscribe=secret",
+			ResponseStatus: http.StatusForbidden,
+			ResponseBody:   `{"statusCode":403,"message":"forbidden topic secret"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tCase := tc
+		testFn := func(t *testing.T) {
+
+			mock := &PushMessengerMock{Subscribed: tCase.Subscribed}
+			token := model.SessionToken{ID: "u1", SessionID: "s1"}
+
+			req := httptest.NewRequest(http.MethodPost, "/inbox/topics"+tCase.Query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), model.UserKey, token))
+			w := httptest.NewRecorder()
+
+			sse.Topics(mock).ServeHTTP(w, req)
+
+			if got, want := w.Code, tCase.ResponseStatus; got != want {
+				t.Errorf("bad status: %d, expected %d", got, want)
+			}
+			if got, want := w.Body.String(), tCase.ResponseBody; got != want {
+				t.Errorf("bad response body: %s, expected %s", got, want)
+			}
+
+		} // fn
+		b.Run(tCase.Alias, testFn)
+	} // cases
+
+}
@@ -6,6 +6,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"wallawire/model"
 )
 
 const (
@@ -54,13 +56,30 @@ func Handler(assets AssetStore) http.HandlerFunc {
 			f = assets.AssetFile("/index.html")
 		}
 
+		data, modTime := f.Data(), f.ModTime()
+		if nonce := model.CSPNonceFromContext(r.Context()); len(nonce) != 0 && strings.HasSuffix(f.Name(), ".html") {
+			// the nonce changes with every response, a cached page would be blocked by the new policy
+			data, modTime = addNonce(data, nonce), time.Time{}
+		}
+
 		// w.Header().Set(hEtag, `"` + f.Hash() + `"`)
-		http.ServeContent(w, r, f.Name(), f.ModTime(), bytes.NewReader(f.Data()))
+		http.ServeContent(w, r, f.Name(), modTime, bytes.NewReader(data))
 
 	})
 
 }
 
+// addNonce marks the script and style elements of the page with the nonce of the Content-Security-Policy.
+// The nonce is also published in a csp-nonce meta element, which the ui passes to style-loader as __webpack_nonce__
+// and JSS reads itself, so that the styles they create at runtime are allowed as well (see ui/src/nonce.ts).
+func addNonce(page []byte, nonce string) []byte {
+	attr := []byte(` nonce="` + nonce + `"`)
+	page = bytes.Replace(page, []byte("<script"), append([]byte("<script"), attr...), -1)
+	page = bytes.Replace(page, []byte("<style"), append([]byte("<style"), attr...), -1)
+	meta := []byte(`<head><meta property="csp-nonce" content="` + nonce + `">`)
+	return bytes.Replace(page, []byte("<head>"), meta, 1)
+}
+
 func sendMessage(w http.ResponseWriter, statusCode int) {
 	msg := []byte(http.StatusText(statusCode) + "\n")
 	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
@@ -2,6 +2,7 @@ package static_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"wallawire/model"
 	"wallawire/web/assets"
 	"wallawire/web/static"
 )
@@ -259,3 +261,93 @@ func getFileBody(path string) []byte {
 func getFileTime(path string) string {
 	return assets.AssetFile(path).ModTime().UTC().Truncate(time.Second).Format(http.TimeFormat)
 }
+
+type assetMock struct {
+	name string
+	data []byte
+}
+
+func (z *assetMock) Name() string {
+	return z.name
+}
+
+func (z *assetMock) ModTime() time.Time {
+	return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (z *assetMock) Data() []byte {
+	return z.data
+}
+
+type assetStoreMock struct{}
+
+func (z *assetStoreMock) AssetFile(name string) static.Asset {
+	switch name {
+	case "/index.html":
+		return &assetMock{name: name, data: []byte(`<html><head><style>a{}</style></head><body><script src="/app.js"></script></body></html>`)}
+	case "/app.js":
+		return &assetMock{name: name, data: []byte(`console.log("<script")`)}
+	}
+	return nil
+}
+
+func TestStaticNonce(t *testing.T) {
+
+	const nonce = "abc123"
+
+	testCases := []struct {
+		Alias        string
+		Path         string
+		Nonce        string
+		LastModified bool
+		ResponseBody string
+	}{
+		{
+			Alias:        "index page with nonce",
+			Path:         "/",
+			Nonce:        nonce,
+			ResponseBody: `<html><head><meta property="csp-nonce" content="abc123"><style nonce="abc123">a{}</style></head><body><script nonce="abc123" src="/app.js"></script></body></html>`,
+		},
+		{
+			Alias:        "index page without nonce",
+			Path:         "/",
+			LastModified: true,
+			ResponseBody: `<html><head><style>a{}</style></head><body><script src="/app.js"></script></body></html>`,
+		},
+		{
+			Alias:        "scripts are not changed",
+			Path:         "/app.js",
+			Nonce:        nonce,
+			LastModified: true,
+			ResponseBody: `console.log("<script")`,
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(tt *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, testCase.Path, nil)
+			if len(testCase.Nonce) != 0 {
+				req = req.WithContext(context.WithValue(req.Context(), model.CSPNonceKey, testCase.Nonce))
+			}
+			rec := httptest.NewRecorder()
+			static.Handler(&assetStoreMock{}).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusOK; got != want {
+				tt.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+			if got, want := len(rec.Header().Get(hLastModified)) != 0, testCase.LastModified; got != want {
+				tt.Errorf("Last-Modified present %t, expected %t", got, want)
+			}
+			if got, want := rec.Body.String(), testCase.ResponseBody; got != want {
+				tt.Errorf("Bad body: %s, expected %s", got, want)
+			}
+
+		} // fn
+
+		t.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
-	"time"
 
 	"wallawire/logging"
 	"wallawire/model"
@@ -16,7 +15,7 @@ type ChangePasswordService interface {
 	ChangePassword(context.Context, model.ChangePasswordRequest) model.ChangePasswordResponse
 }
 
-func ChangePassword(userService ChangePasswordService, tokenPassword string) http.HandlerFunc {
+func ChangePassword(userService ChangePasswordService, keys *auth.Keys) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		ctx := r.Context()
@@ -60,10 +59,8 @@ func ChangePassword(userService ChangePasswordService, tokenPassword string) htt
 
 		if rsp.Code == http.StatusOK {
 
-			rsp.SessionToken.Issued = time.Now().Truncate(time.Minute)
-			rsp.SessionToken.Expires = rsp.SessionToken.Issued.Add(model.LoginTimeout)
-
-			token, errToken := auth.MakeJWT(rsp.SessionToken, tokenPassword)
+			// keep the lifetime of the current token, renewal is up to the authenticator
+			token, errToken := auth.MakeJWT(rsp.SessionToken, keys)
 			if errToken != nil {
 				msg := "cannot create JWT"
 				logger.Error().Err(errToken).Msg(msg)
@@ -82,7 +79,7 @@ func ChangePassword(userService ChangePasswordService, tokenPassword string) htt
 
 		}
 
-		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+		sendJsonRuleMessage(ctx, w, rsp.Code, rsp.Message, rsp.Rule)
 
 	})
 }
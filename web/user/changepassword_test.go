@@ -79,7 +79,7 @@ func TestChangePassword(b *testing.T) {
 			RequestMethod: http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"oldpassword": "demouser", "newpassword": "demouser2"}`),
 			ResponseStatus: http.StatusOK,
@@ -87,7 +87,7 @@ func TestChangePassword(b *testing.T) {
 				hContentLength: "33",
 				hContentType:   mimeTypeJson,
 				hDate:          ignoreValue,
-				hSetCookie:     getCookieString(demouser2S, testPassword),
+				hSetCookie:     getCookieString(demouser2S, testKeys),
 			},
 			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
 		},
@@ -113,7 +113,7 @@ func TestChangePassword(b *testing.T) {
 			OutputResponse: model.ChangePasswordResponse{},
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
-				hCookie: getCookieString(demouserS, testPassword),
+				hCookie: getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"oldpassword": "demouser", "newpassword": "demouser2"}`),
 			ResponseStatus: http.StatusBadRequest,
@@ -131,7 +131,7 @@ func TestChangePassword(b *testing.T) {
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusBadRequest,
@@ -149,7 +149,7 @@ func TestChangePassword(b *testing.T) {
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"bogus"}`),
 			ResponseStatus: http.StatusBadRequest,
@@ -170,7 +170,7 @@ func TestChangePassword(b *testing.T) {
 			RequestMethod: http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"oldpassword": "demouser", "newpassword": "demouser2"}`),
 			ResponseStatus: http.StatusMisdirectedRequest,
@@ -181,6 +181,28 @@ func TestChangePassword(b *testing.T) {
 			},
 			ResponseBody: []byte(`{"statusCode":421,"message":"any old error"}`),
 		},
+		{
+			Alias: "password policy",
+			Path:  "/changepassword",
+			OutputResponse: model.ChangePasswordResponse{
+				Code:    http.StatusBadRequest,
+				Message: "password is too common",
+				Rule:    model.PasswordRuleBanned,
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"oldpassword": "demouser", "newpassword": "password"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "69",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"password is too common","rule":"banned"}`),
+		},
 	}
 
 	newReader := func(b []byte) io.Reader {
@@ -199,8 +221,8 @@ func TestChangePassword(b *testing.T) {
 			}
 
 			handler := chi.NewRouter()
-			handler.Use(auth.NewAuthenticator(testPassword)...)
-			handler.Post("/changepassword", user.ChangePassword(us, testPassword))
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
+			handler.Post("/changepassword", user.ChangePassword(us, testKeys))
 
 			server := httptest.NewServer(handler)
 			defer server.Close()
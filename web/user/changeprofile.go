@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
-	"time"
 
 	"wallawire/logging"
 	"wallawire/model"
@@ -16,7 +15,7 @@ type ChangeProfileService interface {
 	ChangeProfile(context.Context, model.ChangeProfileRequest) model.ChangeProfileResponse
 }
 
-func ChangeProfile(userService ChangeProfileService, tokenPassword string) http.HandlerFunc {
+func ChangeProfile(userService ChangeProfileService, keys *auth.Keys) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		ctx := r.Context()
@@ -60,10 +59,8 @@ func ChangeProfile(userService ChangeProfileService, tokenPassword string) http.
 
 		if rsp.Code == http.StatusOK {
 
-			rsp.SessionToken.Issued = time.Now().Truncate(time.Minute)
-			rsp.SessionToken.Expires = rsp.SessionToken.Issued.Add(model.LoginTimeout)
-
-			token, errToken := auth.MakeJWT(rsp.SessionToken, tokenPassword)
+			// keep the lifetime of the current token, renewal is up to the authenticator
+			token, errToken := auth.MakeJWT(rsp.SessionToken, keys)
 			if errToken != nil {
 				msg := "cannot create JWT"
 				logger.Error().Err(errToken).Msg(msg)
@@ -79,7 +79,7 @@ func TestChangeProfile(b *testing.T) {
 			RequestMethod: http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"displayname": "demouser2", "password": "demouser"}`),
 			ResponseStatus: http.StatusOK,
@@ -87,7 +87,7 @@ func TestChangeProfile(b *testing.T) {
 				hContentLength: "33",
 				hContentType:   mimeTypeJson,
 				hDate:          ignoreValue,
-				hSetCookie:     getCookieString(demouser2S, testPassword),
+				hSetCookie:     getCookieString(demouser2S, testKeys),
 			},
 			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
 		},
@@ -113,7 +113,7 @@ func TestChangeProfile(b *testing.T) {
 			OutputResponse: model.ChangeProfileResponse{},
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
-				hCookie: getCookieString(demouserS, testPassword),
+				hCookie: getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"displayname": "demouser2", "password": "demouser"}`),
 			ResponseStatus: http.StatusBadRequest,
@@ -131,7 +131,7 @@ func TestChangeProfile(b *testing.T) {
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    nil,
 			ResponseStatus: http.StatusBadRequest,
@@ -149,7 +149,7 @@ func TestChangeProfile(b *testing.T) {
 			RequestMethod:  http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"bogus"}`),
 			ResponseStatus: http.StatusBadRequest,
@@ -170,7 +170,7 @@ func TestChangeProfile(b *testing.T) {
 			RequestMethod: http.MethodPost,
 			RequestHeaders: map[string]string{
 				hContentType: mimeTypeJson,
-				hCookie:      getCookieString(demouserS, testPassword),
+				hCookie:      getCookieString(demouserS, testKeys),
 			},
 			RequestBody:    []byte(`{"oldpassword": "demouser", "newpassword": "demouser2"}`),
 			ResponseStatus: http.StatusMisdirectedRequest,
@@ -199,8 +199,8 @@ func TestChangeProfile(b *testing.T) {
 			}
 
 			handler := chi.NewRouter()
-			handler.Use(auth.NewAuthenticator(testPassword)...)
-			handler.Post("/changeprofile", user.ChangeProfile(us, testPassword))
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
+			handler.Post("/changeprofile", user.ChangeProfile(us, testKeys))
 
 			server := httptest.NewServer(handler)
 			defer server.Close()
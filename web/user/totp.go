@@ -0,0 +1,148 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"wallawire/logging"
+	"wallawire/model"
+)
+
+type EnrollTOTPService interface {
+	EnrollTOTP(context.Context, model.EnrollTOTPRequest) model.EnrollTOTPResponse
+}
+
+type ConfirmTOTPService interface {
+	ConfirmTOTP(context.Context, model.ConfirmTOTPRequest) model.ConfirmTOTPResponse
+}
+
+type DisableTOTPService interface {
+	DisableTOTP(context.Context, model.DisableTOTPRequest) model.DisableTOTPResponse
+}
+
+// EnrollTOTP starts two-factor enrollment, returning the secret and the otpauth URI for authenticator apps.
+func EnrollTOTP(userService EnrollTOTPService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "EnrollTOTPHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.EnrollTOTPRequest
+		userID, ok := readJsonRequest(ctx, w, r, &req)
+		if !ok {
+			return
+		}
+
+		req.UserID = userID
+		rsp := userService.EnrollTOTP(ctx, req)
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"`
+		}{
+			Secret: rsp.Secret,
+			URI:    rsp.URI,
+		})
+
+	})
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery codes, which are shown only once.
+func ConfirmTOTP(userService ConfirmTOTPService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "ConfirmTOTPHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.ConfirmTOTPRequest
+		userID, ok := readJsonRequest(ctx, w, r, &req)
+		if !ok {
+			return
+		}
+
+		req.UserID = userID
+		rsp := userService.ConfirmTOTP(ctx, req)
+		if rsp.Code != http.StatusOK {
+			sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+			return
+		}
+
+		sendJson(ctx, w, rsp.Code, struct {
+			RecoveryCodes []string `json:"recoveryCodes"`
+		}{
+			RecoveryCodes: rsp.RecoveryCodes,
+		})
+
+	})
+}
+
+// DisableTOTP turns off two-factor authentication.
+func DisableTOTP(userService DisableTOTPService) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "DisableTOTPHandler")
+		logger.Debug().Msg("invoked")
+
+		var req model.DisableTOTPRequest
+		userID, ok := readJsonRequest(ctx, w, r, &req)
+		if !ok {
+			return
+		}
+
+		req.UserID = userID
+		rsp := userService.DisableTOTP(ctx, req)
+
+		sendJsonMessage(ctx, w, rsp.Code, rsp.Message)
+
+	})
+}
+
+// readJsonRequest unmarshals the request body into req and returns the ID of the current user.
+// If false is returned, an error response has already been sent.
+func readJsonRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, req interface{}) (string, bool) {
+
+	logger := logging.New(ctx, "readJsonRequest")
+
+	sessionToken := model.TokenFromContext(ctx)
+	if len(sessionToken.ID) == 0 {
+		msg := "cannot retrieve user from context"
+		logger.Error().Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusUnauthorized, msg)
+		return "", false
+	}
+
+	if r.Header.Get(hContentType) != mimeTypeJson {
+		msg := "bad or missing content type"
+		logger.Debug().Str(hContentType, r.Header.Get(hContentType)).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return "", false
+	}
+
+	body, errBody := ioutil.ReadAll(r.Body)
+	if errBody != nil {
+		msg := "cannot read request"
+		logger.Debug().Err(errBody).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return "", false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, req); err != nil {
+		msg := "bad json payload"
+		logger.Debug().Err(err).Msg(msg)
+		sendJsonMessage(ctx, w, http.StatusBadRequest, msg)
+		return "", false
+	}
+
+	return sessionToken.ID, true
+
+}
@@ -0,0 +1,297 @@
+package user_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"wallawire/model"
+	"wallawire/web/auth"
+	"wallawire/web/user"
+)
+
+func TestTOTP(b *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	demouserS := &model.SessionToken{
+		SessionID: "S123",
+		ID:        "id",
+		Username:  "demouser",
+		Name:      "Demo User",
+		Roles:     []string{"users"},
+		Issued:    now.Truncate(time.Minute),
+		Expires:   now.Truncate(time.Minute).Add(model.LoginTimeout),
+	}
+
+	testCases := []struct {
+		Alias           string
+		Path            string
+		UserService     *UserServiceMock
+		RequestMethod   string
+		RequestHeaders  map[string]string
+		RequestBody     []byte
+		ResponseStatus  int
+		ResponseHeaders map[string]string
+		ResponseBody    []byte
+	}{
+		{
+			Alias: "enroll success",
+			Path:  "/totp/enroll",
+			UserService: &UserServiceMock{
+				EnrollTOTPResponse: model.EnrollTOTPResponse{
+					Code:   http.StatusOK,
+					Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+					URI:    "otpauth://totp/wallawire:demouser?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "demouser"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "127",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","uri":"otpauth://totp/wallawire:demouser?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}`),
+		},
+		{
+			Alias: "enroll bad password",
+			Path:  "/totp/enroll",
+			UserService: &UserServiceMock{
+				EnrollTOTPResponse: model.EnrollTOTPResponse{
+					Code:    http.StatusBadRequest,
+					Message: "password incorrect",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "demouser2"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "49",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"password incorrect"}`),
+		},
+		{
+			Alias:         "enroll unauthorized",
+			Path:          "/totp/enroll",
+			UserService:   &UserServiceMock{},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+			},
+			RequestBody:    []byte(`{"password": "demouser"}`),
+			ResponseStatus: http.StatusUnauthorized,
+			ResponseHeaders: map[string]string{
+				hContentLength:           "13",
+				hContentType:             mimeTypeText,
+				hDate:                    ignoreValue,
+				"X-Content-Type-Options": ignoreValue,
+			},
+			ResponseBody: []byte("Unauthorized\n"),
+		},
+		{
+			Alias: "confirm success",
+			Path:  "/totp/confirm",
+			UserService: &UserServiceMock{
+				ConfirmTOTPResponse: model.ConfirmTOTPResponse{
+					Code:          http.StatusOK,
+					RecoveryCodes: []string{"abcd-efgh-ijkl-mnop", "qrst-uvwx-yz23-4567"},
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"code": "123456"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "63",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"recoveryCodes":["abcd-efgh-ijkl-mnop","qrst-uvwx-yz23-4567"]}`),
+		},
+		{
+			Alias: "confirm bad code",
+			Path:  "/totp/confirm",
+			UserService: &UserServiceMock{
+				ConfirmTOTPResponse: model.ConfirmTOTPResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid code",
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"code": "000000"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "43",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"invalid code"}`),
+		},
+		{
+			Alias: "confirm bogus payload",
+			Path:  "/totp/confirm",
+			UserService: &UserServiceMock{
+				ConfirmTOTPResponse: model.ConfirmTOTPResponse{},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"code"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "47",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad json payload"}`),
+		},
+		{
+			Alias: "disable success",
+			Path:  "/totp/disable",
+			UserService: &UserServiceMock{
+				DisableTOTPResponse: model.DisableTOTPResponse{
+					Code: http.StatusOK,
+				},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hContentType: mimeTypeJson,
+				hCookie:      getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "demouser", "code": "123456"}`),
+			ResponseStatus: http.StatusOK,
+			ResponseHeaders: map[string]string{
+				hContentLength: "33",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":200,"message":"OK"}`),
+		},
+		{
+			Alias: "disable no content-type",
+			Path:  "/totp/disable",
+			UserService: &UserServiceMock{
+				DisableTOTPResponse: model.DisableTOTPResponse{},
+			},
+			RequestMethod: http.MethodPost,
+			RequestHeaders: map[string]string{
+				hCookie: getCookieString(demouserS, testKeys),
+			},
+			RequestBody:    []byte(`{"password": "demouser", "code": "123456"}`),
+			ResponseStatus: http.StatusBadRequest,
+			ResponseHeaders: map[string]string{
+				hContentLength: "58",
+				hContentType:   mimeTypeJson,
+				hDate:          ignoreValue,
+			},
+			ResponseBody: []byte(`{"statusCode":400,"message":"bad or missing content type"}`),
+		},
+	}
+
+	newReader := func(b []byte) io.Reader {
+		if b == nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+
+	for _, testCase := range testCases {
+
+		testFn := func(t *testing.T) {
+
+			us := testCase.UserService
+
+			handler := chi.NewRouter()
+			handler.Use(auth.NewAuthenticator(testKeys, &SessionServiceMock{Valid: true})...)
+			handler.Post("/totp/enroll", user.EnrollTOTP(us))
+			handler.Post("/totp/confirm", user.ConfirmTOTP(us))
+			handler.Post("/totp/disable", user.DisableTOTP(us))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+
+			req, err := http.NewRequest(testCase.RequestMethod, server.URL+testCase.Path, newReader(testCase.RequestBody))
+			if err != nil {
+				t.Fatalf("Cannot create request: %s", err.Error())
+			}
+			for key, value := range testCase.RequestHeaders {
+				req.Header.Add(key, value)
+			}
+
+			rsp, errRsp := client.Do(req)
+			if errRsp != nil {
+				t.Fatalf("Error getting response: %s", errRsp.Error())
+			}
+
+			body, errBody := ioutil.ReadAll(rsp.Body)
+			if errBody != nil {
+				t.Fatalf("Error reading response: %s", errBody.Error())
+			}
+			defer rsp.Body.Close()
+
+			if got, want := rsp.StatusCode, testCase.ResponseStatus; got != want {
+				t.Errorf("Bad status: %d, expected: %d", got, want)
+			}
+
+			// test that expected headers are present
+			// that headers are not present (empty string)
+			// that headers are present but do not check value (ignoreValue)
+			for key, value := range testCase.ResponseHeaders {
+				if got, want := rsp.Header.Get(key), value; got != want && want != ignoreValue {
+					t.Errorf("Bad response header %s: %s, expected %s", key, got, want)
+				}
+			}
+
+			// test that no unexpected headers are present
+			for key := range rsp.Header {
+				if _, ok := testCase.ResponseHeaders[key]; !ok {
+					t.Errorf("Unexpected response header %s", key)
+				}
+			}
+
+			if testCase.ResponseBody != nil {
+				if bytes.Compare(body, testCase.ResponseBody) != 0 {
+					t.Errorf("Bad body: %s, expected %s", body, testCase.ResponseBody)
+				}
+			}
+
+		} // fn
+
+		b.Run(testCase.Alias, testFn)
+
+	} // cases
+
+}
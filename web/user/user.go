@@ -15,7 +15,26 @@ const (
 	mimeTypeJson   = "application/json"
 )
 
+func sendJson(ctx context.Context, w http.ResponseWriter, statusCode int, payload interface{}) {
+	logger := logging.New(ctx, "sendJson")
+	msg, errMsg := json.Marshal(payload)
+	if errMsg != nil {
+		logger.Error().Err(errMsg).Msg("Cannot marshal json payload")
+		sendJsonMessage(ctx, w, http.StatusInternalServerError, "")
+		return
+	}
+	w.Header().Set(hContentType, mimeTypeJson)
+	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
+	w.WriteHeader(statusCode)
+	w.Write(msg)
+}
+
 func sendJsonMessage(ctx context.Context, w http.ResponseWriter, statusCode int, message string) {
+	sendJsonRuleMessage(ctx, w, statusCode, message, "")
+}
+
+// sendJsonRuleMessage sends a message naming the validation rule that failed, if any.
+func sendJsonRuleMessage(ctx context.Context, w http.ResponseWriter, statusCode int, message, rule string) {
 	logger := logging.New(ctx, "sendJsonMessage")
 	if len(message) == 0 {
 		message = http.StatusText(statusCode)
@@ -23,9 +42,11 @@ func sendJsonMessage(ctx context.Context, w http.ResponseWriter, statusCode int,
 	errmsg := struct {
 		StatusCode int    `json:"statusCode"`
 		Message    string `json:"message,omitempty"`
+		Rule       string `json:"rule,omitempty"`
 	}{
 		StatusCode: statusCode,
 		Message:    message,
+		Rule:       rule,
 	}
 	msg, errMsg := json.Marshal(&errmsg)
 	if errMsg != nil {
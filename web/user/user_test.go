@@ -36,6 +36,10 @@ const (
 	testPassword   = "secret"
 )
 
+var (
+	testKeys = auth.NewHMACKeys(testPassword)
+)
+
 func sendMessage(w http.ResponseWriter, statusCode int) {
 	msg := []byte(http.StatusText(statusCode) + "\n")
 	w.Header().Set(hContentLength, strconv.Itoa(len(msg)))
@@ -43,8 +47,8 @@ func sendMessage(w http.ResponseWriter, statusCode int) {
 	w.Write(msg)
 }
 
-func getCookieString(user *model.SessionToken, password string) string {
-	r, err := auth.MakeJWT(user, password)
+func getCookieString(user *model.SessionToken, keys *auth.Keys) string {
+	r, err := auth.MakeJWT(user, keys)
 	if err != nil {
 		panic(err)
 	}
@@ -62,6 +66,10 @@ type UserServiceMock struct {
 	ChangePasswordResponse model.ChangePasswordResponse
 	ChangeUsernameResponse model.ChangeUsernameResponse
 	ChangeProfileResponse  model.ChangeProfileResponse
+	EnrollTOTPResponse     model.EnrollTOTPResponse
+	ConfirmTOTPResponse    model.ConfirmTOTPResponse
+	DisableTOTPResponse    model.DisableTOTPResponse
+	DisableTOTPRequest     model.DisableTOTPRequest
 }
 
 func (z *UserServiceMock) ChangePassword(ctx context.Context, req model.ChangePasswordRequest) model.ChangePasswordResponse {
@@ -75,3 +83,29 @@ func (z *UserServiceMock) ChangeUsername(ctx context.Context, req model.ChangeUs
 func (z *UserServiceMock) ChangeProfile(ctx context.Context, req model.ChangeProfileRequest) model.ChangeProfileResponse {
 	return z.ChangeProfileResponse
 }
+
+func (z *UserServiceMock) EnrollTOTP(ctx context.Context, req model.EnrollTOTPRequest) model.EnrollTOTPResponse {
+	return z.EnrollTOTPResponse
+}
+
+func (z *UserServiceMock) ConfirmTOTP(ctx context.Context, req model.ConfirmTOTPRequest) model.ConfirmTOTPResponse {
+	return z.ConfirmTOTPResponse
+}
+
+func (z *UserServiceMock) DisableTOTP(ctx context.Context, req model.DisableTOTPRequest) model.DisableTOTPResponse {
+	z.DisableTOTPRequest = req
+	return z.DisableTOTPResponse
+}
+
+type SessionServiceMock struct {
+	Valid      bool
+	ValidError error
+}
+
+func (z *SessionServiceMock) ValidateSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	return z.Valid, z.ValidError
+}
+
+func (z *SessionServiceMock) RenewSession(ctx context.Context, userID, sessionID string) (*model.SessionToken, error) {
+	return nil, nil
+}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"wallawire/logging"
+	"wallawire/model"
+	"wallawire/web/middleware"
+)
+
+const (
+	pLastEventID   = "lastEventID" // query parameter, browsers cannot set headers on websockets
+	pingPeriod     = time.Second * 30
+	pongWait       = pingPeriod * 2
+	writeWait      = time.Second * 10
+	maxMessageSize = 4096
+	replyQueueSize = 16
+
+	// TypeSubscribe subscribes the session to the topic in data.
+	TypeSubscribe = "subscribe"
+	// TypeUnsubscribe unsubscribes the session from the topic in data.
+	TypeUnsubscribe = "unsubscribe"
+	// TypeAck confirms the client message with the ID in data.
+	TypeAck = "ack"
+	// TypeError refuses a client message, data holds the reason.
+	TypeError = "error"
+)
+
+// ReadLimit caps the read messages of a connection, which are forwarded to all sessions of the user.
+var ReadLimit = middleware.RateLimit{Requests: 10, Per: time.Second * 10}
+
+type PushMessenger interface {
+	ConnectClient(userID, sessionID, lastEventID string) (<-chan model.PushMessage, func())
+	SendMessage(msg model.PushMessage, userID, sessionID string) int
+	Subscribe(token model.SessionToken, topic string) error
+	Unsubscribe(userID, sessionID, topic string)
+}
+
+// Handler delivers the inbox over a websocket as JSON push messages, the same as the event stream.
+// Clients send push messages as well, answered with an ack if they carry an ID or an error.
+// The connection is kept alive with pings, a client not answering with a pong is disconnected.
+func Handler(pushMessenger PushMessenger) http.HandlerFunc {
+
+	// the default origin check refuses cross-site connections, which would otherwise carry the session cookie
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+		logger := logging.New(ctx, "web", "ws")
+		token := model.TokenFromContext(ctx)
+
+		// the upgrader answers failed handshakes itself
+		conn, errUpgrade := upgrader.Upgrade(w, r, nil)
+		if errUpgrade != nil {
+			logger.Info().Err(errUpgrade).Msg("cannot upgrade connection")
+			return
+		}
+		defer conn.Close()
+
+		messages, disconnect := pushMessenger.ConnectClient(token.ID, token.SessionID, r.URL.Query().Get(pLastEventID))
+		defer disconnect()
+
+		replies := make(chan model.PushMessage, replyQueueSize)
+		closed := make(chan struct{})
+		reads := middleware.NewMemoryRateLimitStore() // of this connection
+		go func() {
+			defer close(closed)
+			readMessages(conn, func(data []byte) {
+				reply, ok := handleMessage(data, token, pushMessenger, reads)
+				if !ok {
+					return
+				}
+				select {
+				case replies <- reply:
+				default:
+					logger.Warn().Interface("message", reply).Msg("reply dropped")
+				}
+			})
+		}()
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		// write until the client closes the connection or the messenger closes the messages
+		for {
+
+			var msg model.PushMessage
+			select {
+			case m, ok := <-messages:
+				if !ok {
+					conn.SetWriteDeadline(time.Now().Add(writeWait))
+					conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+				msg = m
+			case msg = <-replies:
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					logger.Debug().Err(err).Msg("error sending ping to client")
+					return
+				}
+				continue
+			case <-closed:
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				logger.Error().Err(err).Interface("message", msg).Msg("error sending message to client")
+				return
+			}
+
+		}
+
+	})
+
+}
+
+// readMessages passes the messages of the client to fn until the connection fails or is closed.
+func readMessages(conn *websocket.Conn, fn func(data []byte)) {
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		fn(data)
+	}
+
+}
+
+// handleMessage executes a client message and returns the reply, if any.
+// Read messages are limited by ReadLimit per connection and forwarded live to the sessions of the user on all instances.
+func handleMessage(data []byte, token model.SessionToken, pushMessenger PushMessenger, reads middleware.RateLimitStore) (model.PushMessage, bool) {
+
+	var msg model.PushMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return model.PushMessage{Type: TypeError, Data: "invalid message"}, true
+	}
+
+	switch msg.Type {
+	case model.PushTypeRead:
+		if len(msg.Data) == 0 {
+			return model.PushMessage{Type: TypeError, Data: "missing message id"}, true
+		}
+		if !reads.Take(model.PushTypeRead, ReadLimit, time.Now()).Allowed {
+			return model.PushMessage{Type: TypeError, Data: "too many read messages"}, true
+		}
+		pushMessenger.SendMessage(model.PushMessage{Type: model.PushTypeRead, Data: msg.Data, Live: true}, token.ID, "")
+	case TypeSubscribe:
+		if err := pushMessenger.Subscribe(token, msg.Data); err != nil {
+			return model.PushMessage{Type: TypeError, Data: err.Error()}, true
+		}
+	case TypeUnsubscribe:
+		pushMessenger.Unsubscribe(token.ID, token.SessionID, msg.Data)
+	default:
+		return model.PushMessage{Type: TypeError, Data: "unknown message type " + msg.Type}, true
+	}
+
+	if len(msg.ID) == 0 {
+		return model.PushMessage{}, false
+	}
+	return model.PushMessage{Type: TypeAck, Data: msg.ID}, true
+
+}
@@ -0,0 +1,145 @@
+package ws_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"wallawire/model"
+	"wallawire/web/ws"
+)
+
+type PushMessengerMock struct {
+	lock         sync.Mutex
+	Messages     chan model.PushMessage
+	LastEventID  string
+	Sent         []model.PushMessage
+	Subscribed   []string
+	Disconnected chan struct{}
+}
+
+func (z *PushMessengerMock) ConnectClient(userID, sessionID, lastEventID string) (<-chan model.PushMessage, func()) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.LastEventID = lastEventID
+	return z.Messages, func() {
+		close(z.Disconnected)
+	}
+}
+
+func (z *PushMessengerMock) SendMessage(msg model.PushMessage, userID, sessionID string) int {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.Sent = append(z.Sent, msg)
+	return 1
+}
+
+func (z *PushMessengerMock) Subscribe(token model.SessionToken, topic string) error {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if topic != "news" {
+		return model.NewForbiddenError("forbidden topic " + topic)
+	}
+	z.Subscribed = append(z.Subscribed, topic)
+	return nil
+}
+
+func (z *PushMessengerMock) Unsubscribe(userID, sessionID, topic string) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.Subscribed = nil
+}
+
+func TestHandler(t *testing.T) {
+
+	mock := &PushMessengerMock{
+		Messages:     make(chan model.PushMessage, 1),
+		Disconnected: make(chan struct{}),
+	}
+	token := model.SessionToken{ID: "u1", SessionID: "s1"}
+	handler := ws.Handler(mock)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), model.UserKey, token)))
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/inbox/ws?lastEventID=10"
+	conn, _, errDial := websocket.DefaultDialer.Dial(url, nil)
+	if errDial != nil {
+		t.Fatal(errDial)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	expect := func(want model.PushMessage) {
+		t.Helper()
+		var got model.PushMessage
+		if err := conn.ReadJSON(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("bad message %v, expected %v", got, want)
+		}
+	}
+
+	// server to client
+	mock.Messages <- model.PushMessage{ID: "11", Type: "notification", Data: "hello"}
+	expect(model.PushMessage{ID: "11", Type: "notification", Data: "hello"})
+
+	// client to server
+	conn.WriteJSON(model.PushMessage{ID: "c1", Type: model.PushTypeRead, Data: "11"})
+	expect(model.PushMessage{Type: ws.TypeAck, Data: "c1"})
+	conn.WriteJSON(model.PushMessage{Type: "unknown"})
+	expect(model.PushMessage{Type: ws.TypeError, Data: "unknown message type unknown"})
+	conn.WriteMessage(websocket.TextMessage, []byte("{"))
+	expect(model.PushMessage{Type: ws.TypeError, Data: "invalid message"})
+	conn.WriteJSON(model.PushMessage{ID: "c2", Type: ws.TypeSubscribe, Data: "news"})
+	expect(model.PushMessage{Type: ws.TypeAck, Data: "c2"})
+	conn.WriteJSON(model.PushMessage{ID: "c3", Type: ws.TypeSubscribe, Data: "secret"})
+	expect(model.PushMessage{Type: ws.TypeError, Data: "forbidden topic secret"})
+
+	mock.lock.Lock()
+	if got, want := mock.LastEventID, "10"; got != want {
+		t.Errorf("bad last event id: %s, expected %s", got, want)
+	}
+	if got, want := len(mock.Sent), 1; got != want {
+		t.Fatalf("bad sent messages: %d, expected %d", got, want)
+	}
+	if got, want := mock.Sent[0], (model.PushMessage{Type: model.PushTypeRead, Data: "11", Live: true}); got != want {
+		t.Errorf("bad sent message %v, expected %v", got, want)
+	}
+	if got, want := len(mock.Subscribed), 1; got != want {
+		t.Errorf("bad topics: %d, expected %d", got, want)
+	}
+	mock.lock.Unlock()
+
+	// read messages are rate-limited
+	for i := 1; i < ws.ReadLimit.Requests; i++ {
+		conn.WriteJSON(model.PushMessage{Type: model.PushTypeRead, Data: "11"})
+	}
+	conn.WriteJSON(model.PushMessage{ID: "c4", Type: model.PushTypeRead, Data: "11"})
+	expect(model.PushMessage{Type: ws.TypeError, Data: "too many read messages"})
+	mock.lock.Lock()
+	if got, want := len(mock.Sent), ws.ReadLimit.Requests; got != want {
+		t.Errorf("bad sent messages: %d, expected %d", got, want)
+	}
+	mock.lock.Unlock()
+
+	// the messenger disconnects the client
+	close(mock.Messages)
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("bad close: %v", err)
+	}
+	select {
+	case <-mock.Disconnected:
+	case <-time.After(time.Second * 5):
+		t.Error("client not disconnected")
+	}
+
+}